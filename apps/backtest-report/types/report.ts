@@ -6,6 +6,14 @@ export interface ReportEntry {
   config: object;
 
   time: string;
+
+  equityCurves?: EquityCurveEntry[];
+}
+
+export interface EquityCurveEntry {
+  session: string;
+  symbol: string;
+  filename: string;
 }
 
 export interface ReportIndex {
@@ -33,6 +41,7 @@ export interface ReportSummary {
   finalTotalBalances: BalanceMap;
   symbolReports: SymbolReport[];
   manifests: Manifest[];
+  equityCurves?: EquityCurveEntry[];
 }
 
 export interface SymbolReport {
@@ -0,0 +1,26 @@
+-- +up
+CREATE TABLE `market_trades`
+(
+    `gid`            BIGINT UNSIGNED         NOT NULL AUTO_INCREMENT,
+    `exchange`       VARCHAR(24)             NOT NULL,
+    `symbol`         VARCHAR(20)             NOT NULL,
+    -- id is the public trade id of the exchange
+    `id`             BIGINT UNSIGNED         NOT NULL,
+    `price`          DECIMAL(20, 8) UNSIGNED NOT NULL,
+    `quantity`       DECIMAL(20, 8) UNSIGNED NOT NULL,
+    `quote_quantity` DECIMAL(20, 8) UNSIGNED NOT NULL,
+    -- side is the side of the taker
+    `side`           VARCHAR(4)              NOT NULL DEFAULT '',
+    `is_buyer`       BOOLEAN                 NOT NULL DEFAULT FALSE,
+    `is_maker`       BOOLEAN                 NOT NULL DEFAULT FALSE,
+    `traded_at`      DATETIME(3)             NOT NULL,
+
+    PRIMARY KEY (`gid`),
+    UNIQUE KEY `id` (`exchange`, `symbol`, `id`)
+);
+
+CREATE INDEX `market_trades_traded_at_symbol` ON `market_trades` (`traded_at`, `symbol`, `exchange`);
+
+-- +down
+DROP INDEX `market_trades_traded_at_symbol` ON `market_trades`;
+DROP TABLE `market_trades`;
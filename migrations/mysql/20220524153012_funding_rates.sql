@@ -0,0 +1,15 @@
+-- +up
+CREATE TABLE `funding_rates`
+(
+    `gid`          BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `exchange`     VARCHAR(24)     NOT NULL,
+    `symbol`       VARCHAR(20)     NOT NULL,
+    `funding_rate` DECIMAL(16, 8)  NOT NULL,
+    `funding_time` DATETIME(3)     NOT NULL,
+
+    PRIMARY KEY (`gid`),
+    UNIQUE KEY `funding_time` (`exchange`, `symbol`, `funding_time`)
+);
+
+-- +down
+DROP TABLE `funding_rates`;
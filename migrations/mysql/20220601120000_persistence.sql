@@ -0,0 +1,15 @@
+-- +up
+CREATE TABLE `persistence`
+(
+    `gid`        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `store_id`   VARCHAR(191)    NOT NULL,
+    `version`    BIGINT UNSIGNED NOT NULL,
+    `data`       LONGTEXT        NOT NULL,
+    `created_at` DATETIME(3)     NOT NULL,
+
+    PRIMARY KEY (`gid`),
+    UNIQUE KEY `store_id_version` (`store_id`, `version`)
+);
+
+-- +down
+DROP TABLE `persistence`;
@@ -0,0 +1,41 @@
+-- +up
+-- +begin
+CREATE TABLE `market_trades`
+(
+    `gid`            INTEGER PRIMARY KEY AUTOINCREMENT,
+    `exchange`       VARCHAR(24)    NOT NULL,
+    `symbol`         VARCHAR(20)    NOT NULL,
+    -- id is the public trade id of the exchange
+    `id`             INTEGER        NOT NULL,
+    `price`          DECIMAL(16, 8) NOT NULL,
+    `quantity`       DECIMAL(16, 8) NOT NULL,
+    `quote_quantity` DECIMAL(16, 8) NOT NULL,
+    -- side is the side of the taker
+    `side`           VARCHAR(4)     NOT NULL DEFAULT '',
+    `is_buyer`       BOOLEAN        NOT NULL DEFAULT FALSE,
+    `is_maker`       BOOLEAN        NOT NULL DEFAULT FALSE,
+    `traded_at`      DATETIME(3)    NOT NULL
+);
+-- +end
+
+-- +begin
+CREATE UNIQUE INDEX `market_trades_exchange_symbol_id` ON `market_trades` (`exchange`, `symbol`, `id`);
+-- +end
+
+-- +begin
+CREATE INDEX `market_trades_traded_at_symbol` ON `market_trades` (`traded_at`, `symbol`, `exchange`);
+-- +end
+
+-- +down
+
+-- +begin
+DROP INDEX IF EXISTS `market_trades_traded_at_symbol`;
+-- +end
+
+-- +begin
+DROP INDEX IF EXISTS `market_trades_exchange_symbol_id`;
+-- +end
+
+-- +begin
+DROP TABLE IF EXISTS `market_trades`;
+-- +end
@@ -0,0 +1,25 @@
+-- +up
+-- +begin
+CREATE TABLE `funding_rates`
+(
+    `gid`          INTEGER PRIMARY KEY AUTOINCREMENT,
+    `exchange`     VARCHAR(24)    NOT NULL,
+    `symbol`       VARCHAR(20)    NOT NULL,
+    `funding_rate` DECIMAL(16, 8) NOT NULL,
+    `funding_time` DATETIME(3)    NOT NULL
+);
+-- +end
+
+-- +begin
+CREATE UNIQUE INDEX `funding_rates_exchange_symbol_funding_time` ON `funding_rates` (`exchange`, `symbol`, `funding_time`);
+-- +end
+
+-- +down
+
+-- +begin
+DROP INDEX IF EXISTS `funding_rates_exchange_symbol_funding_time`;
+-- +end
+
+-- +begin
+DROP TABLE IF EXISTS `funding_rates`;
+-- +end
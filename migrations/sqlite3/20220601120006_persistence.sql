@@ -0,0 +1,25 @@
+-- +up
+-- +begin
+CREATE TABLE `persistence`
+(
+    `gid`        INTEGER PRIMARY KEY AUTOINCREMENT,
+    `store_id`   VARCHAR(191) NOT NULL,
+    `version`    BIGINT       NOT NULL,
+    `data`       TEXT         NOT NULL,
+    `created_at` DATETIME(3)  NOT NULL
+);
+-- +end
+
+-- +begin
+CREATE UNIQUE INDEX `persistence_store_id_version` ON `persistence` (`store_id`, `version`);
+-- +end
+
+-- +down
+
+-- +begin
+DROP INDEX IF EXISTS `persistence_store_id_version`;
+-- +end
+
+-- +begin
+DROP TABLE IF EXISTS `persistence`;
+-- +end
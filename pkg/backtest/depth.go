@@ -0,0 +1,214 @@
+package backtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/multierr"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+	"github.com/c9s/bbgo/pkg/util"
+)
+
+// DepthEvent is one recorded order book message, either a full snapshot or a diff update.
+type DepthEvent struct {
+	Time     time.Time              `json:"time"`
+	Symbol   string                 `json:"symbol"`
+	Snapshot bool                   `json:"snapshot,omitempty"`
+	Bids     types.PriceVolumeSlice `json:"bids"`
+	Asks     types.PriceVolumeSlice `json:"asks"`
+}
+
+// depthLevels encodes the price levels into the compact [[price, volume], ...] array format,
+// which is parsed back by types.PriceVolumeSlice.UnmarshalJSON
+type depthLevels types.PriceVolumeSlice
+
+func (levels depthLevels) MarshalJSON() ([]byte, error) {
+	as := make([][]fixedpoint.Value, 0, len(levels))
+	for _, pv := range levels {
+		as = append(as, []fixedpoint.Value{pv.Price, pv.Volume})
+	}
+
+	return json.Marshal(as)
+}
+
+// MarshalJSON encodes the price levels in the compact format to keep the depth data file small
+func (e DepthEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Time     time.Time   `json:"time"`
+		Symbol   string      `json:"symbol"`
+		Snapshot bool        `json:"snapshot,omitempty"`
+		Bids     depthLevels `json:"bids"`
+		Asks     depthLevels `json:"asks"`
+	}{
+		Time:     e.Time,
+		Symbol:   e.Symbol,
+		Snapshot: e.Snapshot,
+		Bids:     depthLevels(e.Bids),
+		Asks:     depthLevels(e.Asks),
+	})
+}
+
+func (e DepthEvent) Book() types.SliceOrderBook {
+	return types.SliceOrderBook{
+		Symbol: e.Symbol,
+		Bids:   e.Bids,
+		Asks:   e.Asks,
+	}
+}
+
+// DepthFileName returns the depth data file of the given exchange and symbol under the data directory
+func DepthFileName(dataDirectory string, exchange types.ExchangeName, symbol string) string {
+	return filepath.Join(dataDirectory, exchange.String(), symbol+".jsonl")
+}
+
+// DepthDumper records the order book snapshots and updates from the market data stream,
+// so that the depth matching engine can replay them in the backtest.
+type DepthDumper struct {
+	OutputDirectory string
+	Exchange        types.ExchangeName
+
+	files   map[string]*os.File
+	writers map[string]*bufio.Writer
+}
+
+func NewDepthDumper(outputDirectory string, exchange types.ExchangeName) *DepthDumper {
+	return &DepthDumper{
+		OutputDirectory: outputDirectory,
+		Exchange:        exchange,
+		files:           make(map[string]*os.File),
+		writers:         make(map[string]*bufio.Writer),
+	}
+}
+
+func (d *DepthDumper) BindStream(stream types.Stream) {
+	stream.OnBookSnapshot(func(book types.SliceOrderBook) {
+		if err := d.Record(book, true, time.Now()); err != nil {
+			log.WithError(err).Errorf("can not record %s depth snapshot", book.Symbol)
+		}
+	})
+
+	stream.OnBookUpdate(func(book types.SliceOrderBook) {
+		if err := d.Record(book, false, time.Now()); err != nil {
+			log.WithError(err).Errorf("can not record %s depth update", book.Symbol)
+		}
+	})
+}
+
+func (d *DepthDumper) Record(book types.SliceOrderBook, snapshot bool, t time.Time) error {
+	w, ok := d.writers[book.Symbol]
+	if !ok {
+		filename := DepthFileName(d.OutputDirectory, d.Exchange, book.Symbol)
+		if err := util.SafeMkdirAll(filepath.Dir(filename)); err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+
+		w = bufio.NewWriter(f)
+		d.files[book.Symbol] = f
+		d.writers[book.Symbol] = w
+	}
+
+	out, err := json.Marshal(DepthEvent{
+		Time:     t,
+		Symbol:   book.Symbol,
+		Snapshot: snapshot,
+		Bids:     book.Bids,
+		Asks:     book.Asks,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(out); err != nil {
+		return err
+	}
+
+	return w.WriteByte('\n')
+}
+
+func (d *DepthDumper) Close() error {
+	var err error = nil
+	for symbol, w := range d.writers {
+		if err2 := w.Flush(); err2 != nil {
+			err = multierr.Append(err, err2)
+		}
+
+		if err2 := d.files[symbol].Close(); err2 != nil {
+			err = multierr.Append(err, err2)
+		}
+	}
+
+	return err
+}
+
+// DepthReader reads the recorded depth events in time order
+type DepthReader struct {
+	file    *os.File
+	scanner *bufio.Scanner
+
+	next    *DepthEvent
+	lastErr error
+}
+
+func NewDepthReader(filename string) (*DepthReader, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(f)
+	// order book snapshots can be much longer than the default token size
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &DepthReader{file: f, scanner: scanner}, nil
+}
+
+// Peek returns the next event without consuming it
+func (r *DepthReader) Peek() (*DepthEvent, bool) {
+	if r.next != nil {
+		return r.next, true
+	}
+
+	for r.scanner.Scan() {
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var event DepthEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			r.lastErr = fmt.Errorf("depth event decode error: %w", err)
+			return nil, false
+		}
+
+		r.next = &event
+		return r.next, true
+	}
+
+	r.lastErr = r.scanner.Err()
+	return nil, false
+}
+
+// Next returns the next event and consumes it
+func (r *DepthReader) Next() (*DepthEvent, bool) {
+	event, ok := r.Peek()
+	r.next = nil
+	return event, ok
+}
+
+func (r *DepthReader) Err() error {
+	return r.lastErr
+}
+
+func (r *DepthReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,121 @@
+package backtest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.uber.org/multierr"
+
+	"github.com/c9s/bbgo/pkg/data/tsv"
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// EquityCurveEntry is the equity curve file of the session symbol in the report directory
+type EquityCurveEntry struct {
+	Session  string `json:"session"`
+	Symbol   string `json:"symbol"`
+	Filename string `json:"filename"`
+}
+
+var equityCurveHeader = []string{"date", "time", "equity", "drawdown", "drawdownPercent", "baseline"}
+
+type equityCurveState struct {
+	writer *tsv.Writer
+	peak   fixedpoint.Value
+
+	// baseQuantity is the base asset quantity that the buy-and-hold baseline holds since the first kline
+	baseQuantity fixedpoint.Value
+}
+
+// EquityCurveDumper dumps the equity of the session symbols valued in the quote currency, the drawdown
+// from the equity peak and the buy-and-hold baseline per kline into the TSV files.
+type EquityCurveDumper struct {
+	OutputDirectory string
+
+	states  map[string]*equityCurveState
+	entries []EquityCurveEntry
+}
+
+func NewEquityCurveDumper(outputDirectory string) *EquityCurveDumper {
+	return &EquityCurveDumper{
+		OutputDirectory: outputDirectory,
+		states:          make(map[string]*equityCurveState),
+	}
+}
+
+// Entries returns the dumped equity curve files
+func (d *EquityCurveDumper) Entries() []EquityCurveEntry {
+	return d.entries
+}
+
+func (d *EquityCurveDumper) formatFileName(session, symbol string) string {
+	return filepath.Join(d.OutputDirectory, fmt.Sprintf("%s-%s.tsv", session, symbol))
+}
+
+// Record writes the equity of the net balances and the futures position by the close price of the kline, the
+// buy-and-hold baseline converts the equity of the first record into the base asset by the open price of the kline.
+func (d *EquityCurveDumper) Record(session string, market types.Market, balances types.BalanceMap, positions types.FuturesPositionMap, k types.KLine) error {
+	equity := inQuoteAsset(balances, positions, market, k.Close)
+
+	key := SymbolKey(session, market.Symbol)
+	state, ok := d.states[key]
+	if !ok {
+		filename := d.formatFileName(session, market.Symbol)
+		w, err := tsv.NewWriterFile(filename)
+		if err != nil {
+			return err
+		}
+
+		state = &equityCurveState{
+			writer:       w,
+			peak:         equity,
+			baseQuantity: fixedpoint.Zero,
+		}
+
+		if k.Open.Sign() > 0 {
+			state.baseQuantity = inQuoteAsset(balances, positions, market, k.Open).Div(k.Open)
+		}
+
+		d.states[key] = state
+		d.entries = append(d.entries, EquityCurveEntry{
+			Session:  session,
+			Symbol:   market.Symbol,
+			Filename: filename,
+		})
+
+		if err := w.Write(equityCurveHeader); err != nil {
+			return err
+		}
+	}
+
+	state.peak = fixedpoint.Max(state.peak, equity)
+	drawdown := state.peak.Sub(equity)
+	drawdownPercent := fixedpoint.Zero
+	if state.peak.Sign() > 0 {
+		drawdownPercent = drawdown.Div(state.peak)
+	}
+
+	return state.writer.Write([]string{
+		k.EndTime.Time().Format(time.ANSIC),
+		strconv.FormatInt(k.EndTime.Unix(), 10),
+		equity.String(),
+		drawdown.String(),
+		drawdownPercent.String(),
+		state.baseQuantity.Mul(k.Close).String(),
+	})
+}
+
+func (d *EquityCurveDumper) Close() error {
+	var err error = nil
+	for _, state := range d.states {
+		state.writer.Flush()
+		if err2 := state.writer.Close(); err2 != nil {
+			err = multierr.Append(err, err2)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,67 @@
+package backtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func TestEquityCurveDumper(t *testing.T) {
+	dir := t.TempDir()
+	dumper := NewEquityCurveDumper(dir)
+
+	market := types.Market{Symbol: "BTCUSDT", BaseCurrency: "BTC", QuoteCurrency: "USDT"}
+	balances := types.BalanceMap{
+		"USDT": {Currency: "USDT", Available: fixedpoint.NewFromInt(1000)},
+		"BTC":  {Currency: "BTC", Available: fixedpoint.NewFromInt(1)},
+	}
+
+	startTime := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	newKLine := func(i int, open, close float64) types.KLine {
+		return types.KLine{
+			Symbol:    "BTCUSDT",
+			Interval:  types.Interval1m,
+			StartTime: types.Time(startTime.Add(time.Duration(i) * time.Minute)),
+			EndTime:   types.Time(startTime.Add(time.Duration(i+1)*time.Minute - time.Millisecond)),
+			Open:      fixedpoint.NewFromFloat(open),
+			Close:     fixedpoint.NewFromFloat(close),
+			Closed:    true,
+		}
+	}
+
+	assert.NoError(t, dumper.Record("binance", market, balances, nil, newKLine(0, 1000, 1000)))
+	assert.NoError(t, dumper.Record("binance", market, balances, nil, newKLine(1, 1000, 1500)))
+	assert.NoError(t, dumper.Record("binance", market, balances, nil, newKLine(2, 1500, 750)))
+	assert.NoError(t, dumper.Close())
+
+	entries := dumper.Entries()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "binance", entries[0].Session)
+		assert.Equal(t, "BTCUSDT", entries[0].Symbol)
+		assert.Equal(t, filepath.Join(dir, "binance-BTCUSDT.tsv"), entries[0].Filename)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "binance-BTCUSDT.tsv"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.Equal(t, strings.Join(equityCurveHeader, "\t"), lines[0])
+
+		// 1000 USDT + 1 BTC is converted into 2 BTC for the buy-and-hold baseline
+		fields := strings.Split(lines[2], "\t")
+		assert.Equal(t, []string{"2500", "0", "0", "3000"}, fields[2:])
+
+		fields = strings.Split(lines[3], "\t")
+		assert.Equal(t, []string{"1750", "750", "0.3", "1500"}, fields[2:])
+	}
+}
@@ -45,6 +45,9 @@ import (
 var ErrUnimplemented = errors.New("unimplemented method")
 
 type Exchange struct {
+	types.MarginSettings
+	types.FuturesSettings
+
 	sourceName         types.ExchangeName
 	publicExchange     types.Exchange
 	srv                *service.BacktestService
@@ -61,10 +64,24 @@ type Exchange struct {
 	closedOrders      map[string][]types.Order
 	closedOrdersMutex sync.Mutex
 
+	// tradeSymbols are the symbols that replay the market trades (ticks)
+	tradeSymbols       map[string]struct{}
+	pendingTrades      map[string][]types.Trade
+	pendingTradesMutex sync.Mutex
+
 	matchingBooks      map[string]*SimplePriceMatching
+	depthBooks         map[string]*DepthPriceMatching
 	matchingBooksMutex sync.Mutex
 
 	markets types.MarketMap
+
+	// margin account simulation
+	marginConfig     *bbgo.BacktestMarginAccount
+	lastInterestTime time.Time
+
+	// futures account simulation
+	futuresAccount *FuturesAccount
+	fundingRates   map[string][]types.FundingRate
 }
 
 func NewExchange(sourceName types.ExchangeName, sourceExchange types.Exchange, srv *service.BacktestService, config *bbgo.Backtest) (*Exchange, error) {
@@ -83,6 +100,10 @@ func NewExchange(sourceName types.ExchangeName, sourceExchange types.Exchange, s
 		endTime = time.Now()
 	}
 
+	if config.MatchingEngine == bbgo.BacktestMatchingEngineDepth && len(config.DepthDataDirectory) == 0 {
+		return nil, errors.New("backtest.depthDataDirectory is required by the depth matching engine")
+	}
+
 	configAccount := config.GetAccount(sourceName.String())
 
 	account := &types.Account{
@@ -94,6 +115,14 @@ func NewExchange(sourceName types.ExchangeName, sourceExchange types.Exchange, s
 	balances := configAccount.Balances.BalanceMap()
 	account.UpdateBalances(balances)
 
+	if configAccount.Margin != nil && configAccount.Futures != nil {
+		return nil, errors.New("the margin account and the futures account can not be simulated in the same session")
+	}
+
+	if configAccount.Futures != nil && config.MatchingEngine == bbgo.BacktestMatchingEngineDepth {
+		return nil, errors.New("the futures account simulation is not supported by the depth matching engine")
+	}
+
 	e := &Exchange{
 		sourceName:     sourceName,
 		publicExchange: ex,
@@ -105,12 +134,58 @@ func NewExchange(sourceName types.ExchangeName, sourceExchange types.Exchange, s
 		endTime:        endTime,
 		closedOrders:   make(map[string][]types.Order),
 		trades:         make(map[string][]types.Trade),
+		tradeSymbols:   make(map[string]struct{}),
+		pendingTrades:  make(map[string][]types.Trade),
+		fundingRates:   make(map[string][]types.FundingRate),
+	}
+
+	if configAccount.Margin != nil {
+		account.AccountType = types.AccountTypeMargin
+		account.BorrowEnabled = true
+		e.marginConfig = configAccount.Margin
+		e.UseMargin()
+	}
+
+	if configAccount.Futures != nil {
+		account.AccountType = types.AccountTypeFutures
+		e.futuresAccount = NewFuturesAccount(account, configAccount.Futures.Leverage, configAccount.Futures.MaintenanceMarginRate)
+		e.UseFutures()
 	}
 
 	e.resetMatchingBooks()
+
+	if config.MatchingEngine == bbgo.BacktestMatchingEngineDepth {
+		if err := e.openDepthData(config.Symbols); err != nil {
+			for _, depth := range e.depthBooks {
+				_ = depth.Close()
+			}
+			return nil, err
+		}
+	}
+
 	return e, nil
 }
 
+// openDepthData opens the depth data files of the backtest symbols, so that a missing or unreadable file
+// fails the backtest instead of falling back to the kline matching
+func (e *Exchange) openDepthData(symbols []string) error {
+	e.matchingBooksMutex.Lock()
+	defer e.matchingBooksMutex.Unlock()
+
+	for _, symbol := range symbols {
+		depth, ok := e.depthBooks[symbol]
+		if !ok {
+			return fmt.Errorf("market %s is not defined", symbol)
+		}
+
+		if err := depth.Open(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (e *Exchange) addTrade(trade types.Trade) {
 	e.tradesMutex.Lock()
 	e.trades[trade.Symbol] = append(e.trades[trade.Symbol], trade)
@@ -126,6 +201,7 @@ func (e *Exchange) addClosedOrder(order types.Order) {
 func (e *Exchange) resetMatchingBooks() {
 	e.matchingBooksMutex.Lock()
 	e.matchingBooks = make(map[string]*SimplePriceMatching)
+	e.depthBooks = make(map[string]*DepthPriceMatching)
 	for symbol, market := range e.markets {
 		e._addMatchingBook(symbol, market)
 	}
@@ -139,10 +215,16 @@ func (e *Exchange) addMatchingBook(symbol string, market types.Market) {
 }
 
 func (e *Exchange) _addMatchingBook(symbol string, market types.Market) {
-	e.matchingBooks[symbol] = &SimplePriceMatching{
+	matching := &SimplePriceMatching{
 		CurrentTime: e.startTime,
 		Account:     e.account,
 		Market:      market,
+		Futures:     e.futuresAccount,
+	}
+	e.matchingBooks[symbol] = matching
+
+	if e.config.MatchingEngine == bbgo.BacktestMatchingEngineDepth {
+		e.depthBooks[symbol] = NewDepthPriceMatching(matching, DepthFileName(e.config.DepthDataDirectory, e.sourceName, symbol))
 	}
 }
 
@@ -161,7 +243,13 @@ func (e *Exchange) SubmitOrders(ctx context.Context, orders ...types.SubmitOrder
 			return nil, fmt.Errorf("matching engine is not initialized for symbol %s", symbol)
 		}
 
-		createdOrder, _, err := matching.PlaceOrder(order)
+		var createdOrder *types.Order
+		if depth, ok := e.depthBook(symbol); ok {
+			createdOrder, _, err = depth.PlaceOrder(order)
+		} else {
+			createdOrder, _, err = matching.PlaceOrder(order)
+		}
+
 		if err != nil {
 			return nil, err
 		}
@@ -200,7 +288,7 @@ func (e *Exchange) QueryClosedOrders(ctx context.Context, symbol string, since,
 	return orders, nil
 }
 
-func (e *Exchange) CancelOrders(ctx context.Context, orders ...types.Order) error {
+func (e *Exchange) CancelOrders(ctx context.Context, orders ...types.Order) (err error) {
 	if e.userDataStream == nil {
 		return fmt.Errorf("CancelOrders should be called after userDataStream been initialized")
 	}
@@ -209,7 +297,13 @@ func (e *Exchange) CancelOrders(ctx context.Context, orders ...types.Order) erro
 		if !ok {
 			return fmt.Errorf("matching engine is not initialized for symbol %s", order.Symbol)
 		}
-		canceledOrder, err := matching.CancelOrder(order)
+		var canceledOrder types.Order
+		if depth, ok := e.depthBook(order.Symbol); ok {
+			canceledOrder, err = depth.CancelOrder(order)
+		} else {
+			canceledOrder, err = matching.CancelOrder(order)
+		}
+
 		if err != nil {
 			return err
 		}
@@ -221,6 +315,16 @@ func (e *Exchange) CancelOrders(ctx context.Context, orders ...types.Order) erro
 }
 
 func (e *Exchange) QueryAccount(ctx context.Context) (*types.Account, error) {
+	if e.marginConfig != nil {
+		e.account.MarginLevel = e.marginLevel()
+	}
+
+	if e.futuresAccount != nil {
+		e.account.FuturesInfo = &types.FuturesAccountInfo{
+			Positions: e.futuresAccount.Positions(),
+		}
+	}
+
 	return e.account, nil
 }
 
@@ -296,6 +400,13 @@ func (e *Exchange) matchingBook(symbol string) (*SimplePriceMatching, bool) {
 	return m, ok
 }
 
+func (e *Exchange) depthBook(symbol string) (*DepthPriceMatching, bool) {
+	e.matchingBooksMutex.Lock()
+	m, ok := e.depthBooks[symbol]
+	e.matchingBooksMutex.Unlock()
+	return m, ok
+}
+
 func (e *Exchange) InitMarketData() {
 	e.userDataStream.OnTradeUpdate(func(trade types.Trade) {
 		e.addTrade(trade)
@@ -306,6 +417,12 @@ func (e *Exchange) InitMarketData() {
 		matching.OnTradeUpdate(e.userDataStream.EmitTradeUpdate)
 		matching.OnOrderUpdate(e.userDataStream.EmitOrderUpdate)
 		matching.OnBalanceUpdate(e.userDataStream.EmitBalanceUpdate)
+
+		if e.futuresAccount != nil {
+			matching.OnTradeUpdate(func(trade types.Trade) {
+				e.userDataStream.EmitFuturesPositionUpdate(e.futuresAccount.Positions())
+			})
+		}
 	}
 	e.matchingBooksMutex.Unlock()
 }
@@ -330,20 +447,51 @@ func (e *Exchange) SubscribeMarketData(extraIntervals ...types.Interval) (chan t
 		case types.KLineChannel:
 			loadedIntervals[types.Interval(sub.Options.Interval)] = struct{}{}
 
+		case types.MarketTradeChannel:
+			e.tradeSymbols[sub.Symbol] = struct{}{}
+
 		default:
 			// Since Environment is not yet been injected at this point, no hard error
 			log.Errorf("stream channel %s is not supported in backtest", sub.Channel)
 		}
 	}
 
-	var symbols []string
+	// the klines of the symbols that subscribe the market trades are built from the ticks
+	var symbols, tradeSymbols []string
 	for symbol := range loadedSymbols {
-		symbols = append(symbols, symbol)
+		if _, ok := e.tradeSymbols[symbol]; ok {
+			tradeSymbols = append(tradeSymbols, symbol)
+		} else {
+			symbols = append(symbols, symbol)
+		}
 	}
 
-	var intervals []types.Interval
+	// intervals shorter than 1m can only be built from the ticks
+	var intervals, tradeIntervals []types.Interval
 	for interval := range loadedIntervals {
-		intervals = append(intervals, interval)
+		tradeIntervals = append(tradeIntervals, interval)
+		if _, ok := types.SecondIntervals[interval]; !ok {
+			intervals = append(intervals, interval)
+		}
+	}
+
+	if len(tradeSymbols) == 0 {
+		log.Infof("using symbols: %v and intervals: %v for back-testing", symbols, intervals)
+		log.Infof("querying klines from database...")
+		klineC, errC := e.srv.QueryKLinesCh(e.startTime, e.endTime, e, symbols, intervals)
+		go func() {
+			if err := <-errC; err != nil {
+				log.WithError(err).Error("backtest data feed error")
+			}
+		}()
+		return klineC, nil
+	}
+
+	log.Infof("using symbols: %v with market trades and intervals: %v for back-testing", tradeSymbols, tradeIntervals)
+	log.Infof("querying market trades from database...")
+	tradeKLineC := e.queryMarketTradeKLines(tradeSymbols, tradeIntervals)
+	if len(symbols) == 0 {
+		return tradeKLineC, nil
 	}
 
 	log.Infof("using symbols: %v and intervals: %v for back-testing", symbols, intervals)
@@ -354,10 +502,27 @@ func (e *Exchange) SubscribeMarketData(extraIntervals ...types.Interval) (chan t
 			log.WithError(err).Error("backtest data feed error")
 		}
 	}()
-	return klineC, nil
+	return mergeKLineCh(klineC, tradeKLineC), nil
 }
 
 func (e *Exchange) ConsumeKLine(k types.KLine) {
+	// the market trades before the kline close are emitted first
+	if e.consumeMarketTrades(k.Symbol, k.EndTime.Time()) {
+		if k.Interval == types.Interval1m {
+			if depth, ok := e.depthBook(k.Symbol); ok {
+				depth.processKLine(k)
+			} else if matching, ok := e.matchingBook(k.Symbol); ok {
+				// the orders were already matched by the ticks
+				matching.LastKLine = k
+			}
+
+			e.settleAccount(k)
+		}
+
+		e.marketDataStream.EmitKLineClosed(k)
+		return
+	}
+
 	if k.Interval == types.Interval1m {
 		matching, ok := e.matchingBook(k.Symbol)
 		if !ok {
@@ -366,13 +531,27 @@ func (e *Exchange) ConsumeKLine(k types.KLine) {
 		}
 
 		// here we generate trades and order updates
-		matching.processKLine(k)
+		if depth, ok := e.depthBook(k.Symbol); ok {
+			depth.processKLine(k)
+		} else {
+			matching.processKLine(k)
+		}
+
+		e.settleAccount(k)
 	}
 
 	e.marketDataStream.EmitKLineClosed(k)
 }
 
 func (e *Exchange) CloseMarketData() error {
+	e.matchingBooksMutex.Lock()
+	for _, depth := range e.depthBooks {
+		if err := depth.Close(); err != nil {
+			log.WithError(err).Error("depth data close error")
+		}
+	}
+	e.matchingBooksMutex.Unlock()
+
 	if err := e.marketDataStream.Close(); err != nil {
 		log.WithError(err).Error("stream close error")
 		return err
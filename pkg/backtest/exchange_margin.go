@@ -0,0 +1,468 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+var (
+	defaultMarginMaxLeverage            = fixedpoint.NewFromInt(3)
+	defaultMarginLiquidationMarginLevel = fixedpoint.MustNewFromString("1.1")
+)
+
+var _ types.MarginExchange = &Exchange{}
+var _ types.MarginBorrowRepay = &Exchange{}
+var _ types.FuturesExchange = &Exchange{}
+
+func (e *Exchange) marginMaxLeverage() fixedpoint.Value {
+	if e.marginConfig == nil || e.marginConfig.MaxLeverage.IsZero() {
+		return defaultMarginMaxLeverage
+	}
+	return e.marginConfig.MaxLeverage
+}
+
+func (e *Exchange) marginLiquidationLevel() fixedpoint.Value {
+	if e.marginConfig == nil || e.marginConfig.LiquidationMarginLevel.IsZero() {
+		return defaultMarginLiquidationMarginLevel
+	}
+	return e.marginConfig.LiquidationMarginLevel
+}
+
+// valuationCurrency is the currency for valuing the margin account and the equity of the session, it's the quote
+// currency of the first back-test symbol
+func (e *Exchange) valuationCurrency() string {
+	for _, symbol := range e.config.Symbols {
+		if market, ok := e.markets[symbol]; ok {
+			return market.QuoteCurrency
+		}
+	}
+	return "USDT"
+}
+
+// assetPrice returns the last price of the asset in the valuation currency
+func (e *Exchange) assetPrice(asset string) (fixedpoint.Value, bool) {
+	return priceIn(asset, e.valuationCurrency(), e.markets, e.markPrices())
+}
+
+// priceIn returns the price of the asset in the currency by the prices of the symbols
+func priceIn(asset, currency string, markets types.MarketMap, prices map[string]fixedpoint.Value) (fixedpoint.Value, bool) {
+	if asset == currency {
+		return fixedpoint.One, true
+	}
+
+	for symbol, price := range prices {
+		market, ok := markets[symbol]
+		if !ok || price.IsZero() {
+			continue
+		}
+
+		if market.BaseCurrency == asset && market.QuoteCurrency == currency {
+			return price, true
+		}
+
+		if market.BaseCurrency == currency && market.QuoteCurrency == asset {
+			return fixedpoint.One.Div(price), true
+		}
+	}
+
+	return fixedpoint.Zero, false
+}
+
+// inCurrency converts the net balances and the unrealized profit of the futures positions in the currency by the
+// prices of the symbols, the assets that can not be priced are skipped
+func inCurrency(balances types.BalanceMap, positions types.FuturesPositionMap, currency string, markets types.MarketMap, prices map[string]fixedpoint.Value) fixedpoint.Value {
+	equity := fixedpoint.Zero
+	for asset, balance := range balances {
+		price, ok := priceIn(asset, currency, markets, prices)
+		if !ok {
+			continue
+		}
+
+		equity = equity.Add(netAsset(balance).Mul(price))
+	}
+
+	for symbol := range positions {
+		// index the map instead of copying the position since the position embeds a mutex
+		markPrice, ok := prices[symbol]
+		if !ok {
+			continue
+		}
+
+		price, ok := priceIn(positions[symbol].QuoteCurrency, currency, markets, prices)
+		if !ok {
+			continue
+		}
+
+		profit := markPrice.Sub(positions[symbol].AverageCost).Mul(positions[symbol].Base)
+		equity = equity.Add(profit.Mul(price))
+	}
+
+	return equity
+}
+
+// Equity returns the net value of the account in the valuation currency by the last prices, the unrealized profit of
+// the futures positions is included
+func (e *Exchange) Equity() fixedpoint.Value {
+	var positions types.FuturesPositionMap
+	if e.futuresAccount != nil {
+		positions = e.futuresAccount.Positions()
+	}
+
+	return inCurrency(e.account.Balances(), positions, e.valuationCurrency(), e.markets, e.markPrices())
+}
+
+// InitialEquity returns the net value of the balances in the valuation currency by the given prices of the symbols
+func (e *Exchange) InitialEquity(balances types.BalanceMap, prices map[string]fixedpoint.Value) fixedpoint.Value {
+	return inCurrency(balances, nil, e.valuationCurrency(), e.markets, prices)
+}
+
+// marginValues returns the total asset value and the total liability value (borrowed + interest) of the account
+func (e *Exchange) marginValues() (totalAsset, totalLiability fixedpoint.Value) {
+	totalAsset = fixedpoint.Zero
+	totalLiability = fixedpoint.Zero
+
+	for currency, balance := range e.account.Balances() {
+		price, ok := e.assetPrice(currency)
+		if !ok {
+			continue
+		}
+
+		totalAsset = totalAsset.Add(balance.Total().Mul(price))
+		totalLiability = totalLiability.Add(balance.Borrowed.Add(balance.Interest).Mul(price))
+	}
+
+	return totalAsset, totalLiability
+}
+
+// marginLevel returns total asset / total liability, it returns zero if nothing is borrowed
+func (e *Exchange) marginLevel() fixedpoint.Value {
+	totalAsset, totalLiability := e.marginValues()
+	if totalLiability.IsZero() {
+		return fixedpoint.Zero
+	}
+	return totalAsset.Div(totalLiability)
+}
+
+// netAsset calculates the net asset of the balance, the NetAsset field is not updated by the trades
+func netAsset(balance types.Balance) fixedpoint.Value {
+	return balance.Total().Sub(balance.Borrowed).Sub(balance.Interest)
+}
+
+func (e *Exchange) updateMarginBalance(asset string, update func(balance *types.Balance)) {
+	balance, _ := e.account.Balance(asset)
+	balance.Currency = asset
+	update(&balance)
+	balance.NetAsset = netAsset(balance)
+	e.account.UpdateBalances(types.BalanceMap{asset: balance})
+}
+
+func (e *Exchange) emitBalanceUpdate() {
+	if e.userDataStream != nil {
+		e.userDataStream.EmitBalanceUpdate(e.account.Balances())
+	}
+}
+
+func (e *Exchange) QueryMarginAssetMaxBorrowable(ctx context.Context, asset string) (amount fixedpoint.Value, err error) {
+	if e.marginConfig == nil {
+		return fixedpoint.Zero, fmt.Errorf("margin account is not enabled in the back-test account config")
+	}
+
+	price, ok := e.assetPrice(asset)
+	if !ok {
+		return fixedpoint.Zero, fmt.Errorf("can not find the price of %s in %s", asset, e.valuationCurrency())
+	}
+
+	totalAsset, totalLiability := e.marginValues()
+	netAsset := totalAsset.Sub(totalLiability)
+	maxLiability := netAsset.Mul(e.marginMaxLeverage().Sub(fixedpoint.One))
+	borrowable := maxLiability.Sub(totalLiability)
+	if borrowable.Sign() <= 0 {
+		return fixedpoint.Zero, nil
+	}
+
+	return borrowable.Div(price), nil
+}
+
+func (e *Exchange) BorrowMarginAsset(ctx context.Context, asset string, amount fixedpoint.Value) error {
+	maxBorrowable, err := e.QueryMarginAssetMaxBorrowable(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	if amount.Compare(maxBorrowable) > 0 {
+		return fmt.Errorf("borrow amount %s %s exceeds the max borrowable amount %s", amount.String(), asset, maxBorrowable.String())
+	}
+
+	e.updateMarginBalance(asset, func(balance *types.Balance) {
+		balance.Available = balance.Available.Add(amount)
+		balance.Borrowed = balance.Borrowed.Add(amount)
+	})
+
+	e.emitBalanceUpdate()
+	return nil
+}
+
+// RepayMarginAsset repays the interest first, and then the borrowed amount
+func (e *Exchange) RepayMarginAsset(ctx context.Context, asset string, amount fixedpoint.Value) error {
+	if e.marginConfig == nil {
+		return fmt.Errorf("margin account is not enabled in the back-test account config")
+	}
+
+	balance, ok := e.account.Balance(asset)
+	if !ok || balance.Available.Compare(amount) < 0 {
+		return fmt.Errorf("insufficient available balance %s for repay: want to repay %s, available %s", asset, amount.String(), balance.Available.String())
+	}
+
+	debt := balance.Borrowed.Add(balance.Interest)
+	if amount.Compare(debt) > 0 {
+		return fmt.Errorf("repay amount %s %s exceeds the debt %s", amount.String(), asset, debt.String())
+	}
+
+	e.updateMarginBalance(asset, func(balance *types.Balance) {
+		interest := fixedpoint.Min(amount, balance.Interest)
+		balance.Interest = balance.Interest.Sub(interest)
+		balance.Borrowed = balance.Borrowed.Sub(amount.Sub(interest))
+		balance.Available = balance.Available.Sub(amount)
+	})
+
+	e.emitBalanceUpdate()
+	return nil
+}
+
+// accrueInterest charges the hourly interest of the borrowed assets
+func (e *Exchange) accrueInterest(now time.Time) bool {
+	if e.lastInterestTime.IsZero() {
+		e.lastInterestTime = now.Truncate(time.Hour)
+		return false
+	}
+
+	charged := false
+	for !e.lastInterestTime.Add(time.Hour).After(now) {
+		e.lastInterestTime = e.lastInterestTime.Add(time.Hour)
+
+		for currency, balance := range e.account.Balances() {
+			if balance.Borrowed.Sign() <= 0 {
+				continue
+			}
+
+			rate, ok := e.marginConfig.InterestRates[currency]
+			if !ok || rate.IsZero() {
+				continue
+			}
+
+			interest := balance.Borrowed.Mul(rate).Div(fixedpoint.NewFromInt(24))
+			e.updateMarginBalance(currency, func(balance *types.Balance) {
+				balance.Interest = balance.Interest.Add(interest)
+			})
+			charged = true
+		}
+	}
+
+	return charged
+}
+
+// cancelAllOrders cancels all the open orders before the forced liquidation
+func (e *Exchange) cancelAllOrders(ctx context.Context) {
+	e.matchingBooksMutex.Lock()
+	var orders []types.Order
+	for _, matching := range e.matchingBooks {
+		matching.mu.Lock()
+		orders = append(orders, matching.bidOrders...)
+		orders = append(orders, matching.askOrders...)
+		matching.mu.Unlock()
+	}
+	e.matchingBooksMutex.Unlock()
+
+	if len(orders) == 0 {
+		return
+	}
+
+	if err := e.CancelOrders(ctx, orders...); err != nil {
+		log.WithError(err).Error("can not cancel the orders for the liquidation")
+	}
+}
+
+// liquidateMargin closes the net positions of the assets by market orders and repays all the debts
+func (e *Exchange) liquidateMargin(ctx context.Context) {
+	e.cancelAllOrders(ctx)
+
+	currency := e.valuationCurrency()
+
+	e.matchingBooksMutex.Lock()
+	var books []*SimplePriceMatching
+	for _, matching := range e.matchingBooks {
+		if matching.Market.QuoteCurrency == currency && !matching.LastPrice.IsZero() {
+			books = append(books, matching)
+		}
+	}
+	e.matchingBooksMutex.Unlock()
+
+	for _, matching := range books {
+		balance, ok := e.account.Balance(matching.Market.BaseCurrency)
+		if !ok {
+			continue
+		}
+
+		submitOrder := types.SubmitOrder{
+			Symbol: matching.Market.Symbol,
+			Market: matching.Market,
+			Type:   types.OrderTypeMarket,
+		}
+
+		net := netAsset(balance)
+		switch net.Sign() {
+		case -1:
+			// buy back the borrowed base asset, the fee is paid by the base asset
+			submitOrder.Side = types.SideTypeBuy
+			submitOrder.Quantity = net.Neg().Div(fixedpoint.One.Sub(e.account.TakerFeeRate))
+		case 1:
+			submitOrder.Side = types.SideTypeSell
+			submitOrder.Quantity = net
+		default:
+			continue
+		}
+
+		if _, err := e.SubmitOrders(ctx, submitOrder); err != nil {
+			log.WithError(err).Errorf("liquidation order failed: %+v", submitOrder)
+		}
+	}
+
+	for asset, balance := range e.account.Balances() {
+		debt := balance.Borrowed.Add(balance.Interest)
+		if debt.Sign() <= 0 {
+			continue
+		}
+
+		if err := e.RepayMarginAsset(ctx, asset, fixedpoint.Min(debt, balance.Available)); err != nil {
+			log.WithError(err).Errorf("liquidation repay failed: %s", asset)
+		}
+	}
+}
+
+// loadFundingRates loads the funding rates of the symbol in the back-test time range from the database
+func (e *Exchange) loadFundingRates(symbol string) {
+	if _, ok := e.fundingRates[symbol]; ok || e.srv == nil {
+		return
+	}
+
+	rates, err := e.srv.QueryFundingRates(e.sourceName, symbol, e.startTime, e.endTime)
+	if err != nil {
+		log.WithError(err).Errorf("can not load the funding rates of %s", symbol)
+	}
+
+	if len(rates) == 0 {
+		log.Warnf("funding rates of %s are not found, please sync them by enabling backtest.syncFundingRates", symbol)
+	}
+
+	e.fundingRates[symbol] = rates
+}
+
+// settleFunding settles the funding fees that are due at the given time
+func (e *Exchange) settleFunding(matching *SimplePriceMatching, now time.Time) bool {
+	symbol := matching.Market.Symbol
+	e.loadFundingRates(symbol)
+
+	due, remaining := nextFundingRates(e.fundingRates[symbol], now)
+	e.fundingRates[symbol] = remaining
+
+	for _, rate := range due {
+		fee := e.futuresAccount.ApplyFunding(matching.Market, rate.FundingRate, matching.LastPrice)
+		if !fee.IsZero() {
+			log.Infof("funding fee of %s at %s: rate %s, paid %s %s", symbol, rate.FundingTime, rate.FundingRate.String(), fee.String(), matching.Market.QuoteCurrency)
+		}
+	}
+
+	return len(due) > 0
+}
+
+// liquidateFutures closes all the futures positions by market orders
+func (e *Exchange) liquidateFutures(ctx context.Context) {
+	e.cancelAllOrders(ctx)
+
+	positions := e.futuresAccount.Positions()
+	for symbol := range positions {
+		base := positions[symbol].Base
+		if base.IsZero() {
+			continue
+		}
+
+		side := types.SideTypeSell
+		if base.Sign() < 0 {
+			side = types.SideTypeBuy
+		}
+
+		submitOrder := types.SubmitOrder{
+			Symbol:   symbol,
+			Market:   positions[symbol].Market,
+			Side:     side,
+			Type:     types.OrderTypeMarket,
+			Quantity: base.Abs(),
+		}
+
+		if _, err := e.SubmitOrders(ctx, submitOrder); err != nil {
+			log.WithError(err).Errorf("liquidation order failed: %+v", submitOrder)
+		}
+	}
+}
+
+func (e *Exchange) markPrices() map[string]fixedpoint.Value {
+	e.matchingBooksMutex.Lock()
+	defer e.matchingBooksMutex.Unlock()
+
+	prices := make(map[string]fixedpoint.Value)
+	for symbol, matching := range e.matchingBooks {
+		if !matching.LastPrice.IsZero() {
+			prices[symbol] = matching.LastPrice
+		}
+	}
+	return prices
+}
+
+// settleAccount charges the margin interest and the funding fees, and force-liquidates the account when the margin
+// is not enough. It's called after the 1m kline is matched.
+func (e *Exchange) settleAccount(k types.KLine) {
+	ctx := context.Background()
+	now := k.EndTime.Time()
+
+	if e.marginConfig != nil {
+		changed := e.accrueInterest(now)
+
+		level := e.marginLevel()
+		if !level.IsZero() && level.Compare(e.marginLiquidationLevel()) < 0 {
+			log.Warnf("margin level %s is lower than the liquidation margin level %s at %s, liquidating the account", level.String(), e.marginLiquidationLevel().String(), now)
+			e.liquidateMargin(ctx)
+			changed = true
+		}
+
+		if changed {
+			e.emitBalanceUpdate()
+		}
+	}
+
+	if e.futuresAccount != nil {
+		matching, ok := e.matchingBook(k.Symbol)
+		if !ok {
+			return
+		}
+
+		changed := e.settleFunding(matching, now)
+
+		marginBalance, maintenanceMargin := e.futuresAccount.MarginStatus(matching.Market.QuoteCurrency, e.markPrices())
+		if maintenanceMargin.Sign() > 0 && marginBalance.Compare(maintenanceMargin) <= 0 {
+			log.Warnf("margin balance %s is lower than the maintenance margin %s at %s, liquidating the positions", marginBalance.String(), maintenanceMargin.String(), now)
+			e.liquidateFutures(ctx)
+			changed = true
+		}
+
+		if changed {
+			e.emitBalanceUpdate()
+			if e.userDataStream != nil {
+				e.userDataStream.EmitFuturesPositionUpdate(e.futuresAccount.Positions())
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package backtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/bbgo"
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func newTestMarginExchange() (*Exchange, *SimplePriceMatching) {
+	matching := newTestSimpleMatching()
+	e := &Exchange{
+		account:        matching.Account,
+		config:         &bbgo.Backtest{Symbols: []string{"BTCUSDT"}},
+		markets:        types.MarketMap{"BTCUSDT": matching.Market},
+		matchingBooks:  map[string]*SimplePriceMatching{"BTCUSDT": matching},
+		depthBooks:     map[string]*DepthPriceMatching{},
+		closedOrders:   make(map[string][]types.Order),
+		trades:         make(map[string][]types.Trade),
+		userDataStream: &Stream{},
+		marginConfig: &bbgo.BacktestMarginAccount{
+			InterestRates: map[string]fixedpoint.Value{
+				"BTC": fixedpoint.NewFromFloat(0.024),
+			},
+		},
+	}
+	e.UseMargin()
+	return e, matching
+}
+
+func TestExchange_BorrowRepay(t *testing.T) {
+	ctx := context.Background()
+	e, _ := newTestMarginExchange()
+
+	// net asset 200000 USDT, the max liability is 400000 USDT with 3x leverage
+	maxBorrowable, err := e.QueryMarginAssetMaxBorrowable(ctx, "BTC")
+	assert.NoError(t, err)
+	assert.Equal(t, "40", maxBorrowable.String())
+
+	assert.Error(t, e.BorrowMarginAsset(ctx, "BTC", fixedpoint.NewFromInt(41)))
+	assert.NoError(t, e.BorrowMarginAsset(ctx, "BTC", fixedpoint.NewFromInt(10)))
+
+	btc, _ := e.account.Balance("BTC")
+	assert.Equal(t, "20", btc.Available.String())
+	assert.Equal(t, "10", btc.Borrowed.String())
+	assert.Equal(t, "10", btc.NetAsset.String())
+
+	// the interest is charged hourly
+	startTime := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	assert.False(t, e.accrueInterest(startTime))
+	assert.False(t, e.accrueInterest(startTime.Add(30*time.Minute)))
+	assert.True(t, e.accrueInterest(startTime.Add(time.Hour)))
+
+	btc, _ = e.account.Balance("BTC")
+	assert.Equal(t, "0.01", btc.Interest.String())
+
+	// the interest is repaid first
+	assert.NoError(t, e.RepayMarginAsset(ctx, "BTC", fixedpoint.NewFromInt(5)))
+	btc, _ = e.account.Balance("BTC")
+	assert.Equal(t, "0", btc.Interest.String())
+	assert.Equal(t, "5.01", btc.Borrowed.String())
+	assert.Equal(t, "15", btc.Available.String())
+
+	assert.Error(t, e.RepayMarginAsset(ctx, "BTC", fixedpoint.NewFromInt(6)))
+}
+
+func TestExchange_MarginLiquidation(t *testing.T) {
+	ctx := context.Background()
+	e, matching := newTestMarginExchange()
+
+	assert.NoError(t, e.BorrowMarginAsset(ctx, "BTC", fixedpoint.NewFromInt(10)))
+
+	// short 10 BTC with the borrowed BTC
+	_, err := e.SubmitOrders(ctx, newMarketOrder(types.SideTypeSell, 20.0))
+	assert.NoError(t, err)
+
+	// margin level = 300000 / 270000
+	matching.LastPrice = fixedpoint.NewFromInt(27000)
+	e.settleAccount(types.KLine{Symbol: "BTCUSDT", Interval: types.Interval1m})
+	btc, _ := e.account.Balance("BTC")
+	assert.Equal(t, "10", btc.Borrowed.String())
+
+	// margin level = 300000 / 280000, lower than 1.1
+	matching.LastPrice = fixedpoint.NewFromInt(28000)
+	e.settleAccount(types.KLine{Symbol: "BTCUSDT", Interval: types.Interval1m})
+
+	btc, _ = e.account.Balance("BTC")
+	assert.Equal(t, "0", btc.Borrowed.String())
+	assert.Equal(t, "0", btc.Available.String())
+
+	usdt, _ := e.account.Balance("USDT")
+	assert.Equal(t, "20000", usdt.Available.String())
+}
+
+func TestExchange_FuturesLiquidation(t *testing.T) {
+	ctx := context.Background()
+	matching := newTestFuturesMatching()
+	matching.Account.UpdateBalances(types.BalanceMap{
+		"USDT": {Currency: "USDT", Available: fixedpoint.NewFromInt(2000)},
+	})
+
+	e := &Exchange{
+		account:        matching.Account,
+		config:         &bbgo.Backtest{Symbols: []string{"BTCUSDT"}},
+		markets:        types.MarketMap{"BTCUSDT": matching.Market},
+		matchingBooks:  map[string]*SimplePriceMatching{"BTCUSDT": matching},
+		depthBooks:     map[string]*DepthPriceMatching{},
+		closedOrders:   make(map[string][]types.Order),
+		trades:         make(map[string][]types.Trade),
+		fundingRates:   map[string][]types.FundingRate{"BTCUSDT": nil},
+		userDataStream: &Stream{},
+		futuresAccount: matching.Futures,
+	}
+
+	// 10x long position with 1000 USDT initial margin
+	_, err := e.SubmitOrders(ctx, newMarketOrder(types.SideTypeBuy, 1.0))
+	assert.NoError(t, err)
+
+	// margin balance = 2000 - 1900 = 100, maintenance margin = 8100 * 0.4% = 32.4
+	matching.LastPrice = fixedpoint.NewFromInt(8100)
+	e.settleAccount(types.KLine{Symbol: "BTCUSDT", Interval: types.Interval1m})
+	assert.Equal(t, "1", e.futuresAccount.Positions()["BTCUSDT"].Base.String())
+	// 10 BTC * 8100 + the margin balance
+	assert.Equal(t, "81100", e.Equity().String())
+
+	// margin balance = 2000 - 1980 = 20, maintenance margin = 8020 * 0.4% = 32.08
+	matching.LastPrice = fixedpoint.NewFromInt(8020)
+	e.settleAccount(types.KLine{Symbol: "BTCUSDT", Interval: types.Interval1m})
+	assert.Equal(t, "0", e.futuresAccount.Positions()["BTCUSDT"].Base.String())
+
+	usdt, _ := e.account.Balance("USDT")
+	assert.Equal(t, "20", usdt.Available.String())
+	assert.Equal(t, "0", usdt.Locked.String())
+}
+
+func TestExchange_Equity(t *testing.T) {
+	ctx := context.Background()
+	e, _ := newTestMarginExchange()
+
+	// 100000 USDT + 10 BTC at 10000
+	assert.Equal(t, "200000", e.Equity().String())
+
+	// the borrowed asset does not change the equity, but the interest does
+	assert.NoError(t, e.BorrowMarginAsset(ctx, "BTC", fixedpoint.NewFromInt(10)))
+	assert.Equal(t, "200000", e.Equity().String())
+
+	startTime := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	e.accrueInterest(startTime)
+	e.accrueInterest(startTime.Add(time.Hour))
+	assert.Equal(t, "199900", e.Equity().String())
+
+	// the asset without a price is skipped
+	equity := e.InitialEquity(types.BalanceMap{
+		"USDT": {Currency: "USDT", Available: fixedpoint.NewFromInt(1000)},
+		"BTC":  {Currency: "BTC", Available: fixedpoint.NewFromInt(1)},
+		"ETH":  {Currency: "ETH", Available: fixedpoint.NewFromInt(5)},
+	}, map[string]fixedpoint.Value{"BTCUSDT": fixedpoint.NewFromInt(20000)})
+	assert.Equal(t, "21000", equity.String())
+}
@@ -0,0 +1,109 @@
+package backtest
+
+import (
+	"time"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// queryMarketTradeKLines loads the market trades from the database, the trades are queued for the market data stream,
+// and the klines of the given intervals are built from the trades.
+func (e *Exchange) queryMarketTradeKLines(symbols []string, intervals []types.Interval) chan types.KLine {
+	klineC := make(chan types.KLine, 100)
+	tradeC, errC := e.srv.QueryMarketTradesCh(e.startTime, e.endTime, e.sourceName, symbols)
+
+	builders := make(map[string]*KLineBuilder)
+	for _, symbol := range symbols {
+		builders[symbol] = NewKLineBuilder(e.sourceName, symbol, intervals...)
+	}
+
+	go func() {
+		defer close(klineC)
+
+		for trade := range tradeC {
+			builder, ok := builders[trade.Symbol]
+			if !ok {
+				continue
+			}
+
+			// the trade must be queued before the klines it closes are consumed
+			e.pendingTradesMutex.Lock()
+			e.pendingTrades[trade.Symbol] = append(e.pendingTrades[trade.Symbol], trade)
+			e.pendingTradesMutex.Unlock()
+
+			for _, k := range builder.AddTrade(trade) {
+				klineC <- k
+			}
+		}
+
+		if err := <-errC; err != nil {
+			log.WithError(err).Error("backtest market trade feed error")
+		}
+
+		for _, builder := range builders {
+			for _, k := range builder.Flush() {
+				klineC <- k
+			}
+		}
+	}()
+
+	return klineC
+}
+
+// consumeMarketTrades emits the queued market trades of the symbol until the given time,
+// and the orders are matched by the trade price.
+// It returns false if the symbol does not replay the market trades.
+func (e *Exchange) consumeMarketTrades(symbol string, until time.Time) bool {
+	if _, ok := e.tradeSymbols[symbol]; !ok {
+		return false
+	}
+
+	e.pendingTradesMutex.Lock()
+	pending := e.pendingTrades[symbol]
+	idx := 0
+	for ; idx < len(pending); idx++ {
+		if pending[idx].Time.Time().After(until) {
+			break
+		}
+	}
+	trades := pending[:idx]
+	e.pendingTrades[symbol] = pending[idx:]
+	e.pendingTradesMutex.Unlock()
+
+	matching, hasMatching := e.matchingBook(symbol)
+	_, hasDepth := e.depthBook(symbol)
+
+	for _, trade := range trades {
+		// the depth matching engine matches the orders by the recorded order book
+		if hasMatching && !hasDepth {
+			matching.processMarketTrade(trade)
+		}
+
+		e.marketDataStream.EmitMarketTrade(trade)
+	}
+
+	return true
+}
+
+// mergeKLineCh merges the kline channels that are sorted by the end time into one channel
+func mergeKLineCh(a, b chan types.KLine) chan types.KLine {
+	c := make(chan types.KLine, 100)
+
+	go func() {
+		defer close(c)
+
+		ka, okA := <-a
+		kb, okB := <-b
+		for okA || okB {
+			if okA && (!okB || !kb.EndTime.Time().Before(ka.EndTime.Time())) {
+				c <- ka
+				ka, okA = <-a
+			} else {
+				c <- kb
+				kb, okB = <-b
+			}
+		}
+	}()
+
+	return c
+}
@@ -0,0 +1,272 @@
+package backtest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+var (
+	defaultFuturesLeverage              = fixedpoint.NewFromInt(10)
+	defaultFuturesMaintenanceMarginRate = fixedpoint.MustNewFromString("0.4%")
+)
+
+// orderMargin is the initial margin locked by the open order
+type orderMargin struct {
+	margin   fixedpoint.Value
+	quantity fixedpoint.Value
+}
+
+// FuturesAccount simulates the USDT-margined futures account in the cross margin mode.
+//
+// Orders only lock the initial margin (notional / leverage) in the quote currency for the part that opens or increases
+// the position, the base currency is never used. The initial margin of the positions stays in the locked balance,
+// and the realized profit, the trading fee and the funding fee are settled in the available balance.
+type FuturesAccount struct {
+	Account               *types.Account
+	Leverage              fixedpoint.Value
+	MaintenanceMarginRate fixedpoint.Value
+
+	mu              sync.Mutex
+	positions       map[string]*types.FuturesPosition
+	positionMargins map[string]fixedpoint.Value
+	orderMargins    map[uint64]*orderMargin
+}
+
+func NewFuturesAccount(account *types.Account, leverage, maintenanceMarginRate fixedpoint.Value) *FuturesAccount {
+	if leverage.IsZero() {
+		leverage = defaultFuturesLeverage
+	}
+
+	if maintenanceMarginRate.IsZero() {
+		maintenanceMarginRate = defaultFuturesMaintenanceMarginRate
+	}
+
+	return &FuturesAccount{
+		Account:               account,
+		Leverage:              leverage,
+		MaintenanceMarginRate: maintenanceMarginRate,
+		positions:             make(map[string]*types.FuturesPosition),
+		positionMargins:       make(map[string]fixedpoint.Value),
+		orderMargins:          make(map[uint64]*orderMargin),
+	}
+}
+
+func (a *FuturesAccount) position(market types.Market) *types.FuturesPosition {
+	p, ok := a.positions[market.Symbol]
+	if !ok {
+		p = &types.FuturesPosition{
+			Symbol:        market.Symbol,
+			BaseCurrency:  market.BaseCurrency,
+			QuoteCurrency: market.QuoteCurrency,
+			Market:        market,
+			Base:          fixedpoint.Zero,
+			Quote:         fixedpoint.Zero,
+			AverageCost:   fixedpoint.Zero,
+		}
+		a.positions[market.Symbol] = p
+	}
+	return p
+}
+
+// openingQuantity returns the part of the order quantity that opens or increases the position
+func (a *FuturesAccount) openingQuantity(market types.Market, side types.SideType, quantity fixedpoint.Value) fixedpoint.Value {
+	base := a.position(market).Base
+	switch side {
+	case types.SideTypeBuy:
+		if base.Sign() < 0 {
+			return fixedpoint.Max(quantity.Add(base), fixedpoint.Zero)
+		}
+
+	case types.SideTypeSell:
+		if base.Sign() > 0 {
+			return fixedpoint.Max(quantity.Sub(base), fixedpoint.Zero)
+		}
+	}
+
+	return quantity
+}
+
+// LockOrder locks the initial margin of the order by the given price
+func (a *FuturesAccount) LockOrder(market types.Market, order types.Order, price fixedpoint.Value) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	opening := a.openingQuantity(market, order.Side, order.Quantity.Sub(order.ExecutedQuantity))
+	margin := opening.Mul(price).Div(a.Leverage)
+	if err := a.Account.LockBalance(market.QuoteCurrency, margin); err != nil {
+		return err
+	}
+
+	a.orderMargins[order.OrderID] = &orderMargin{
+		margin:   margin,
+		quantity: order.Quantity.Sub(order.ExecutedQuantity),
+	}
+	return nil
+}
+
+// UnlockOrder unlocks the remaining initial margin of the order
+func (a *FuturesAccount) UnlockOrder(market types.Market, order types.Order) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	m, ok := a.orderMargins[order.OrderID]
+	if !ok {
+		return nil
+	}
+
+	delete(a.orderMargins, order.OrderID)
+	return a.Account.UnlockBalance(market.QuoteCurrency, m.margin)
+}
+
+// ExecuteTrade updates the position by the trade, and settles the realized profit and the fee
+func (a *FuturesAccount) ExecuteTrade(market types.Market, trade types.Trade) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// release the initial margin of the filled part of the order
+	released := fixedpoint.Zero
+	if m, ok := a.orderMargins[trade.OrderID]; ok {
+		if trade.Quantity.Compare(m.quantity) >= 0 {
+			released = m.margin
+			delete(a.orderMargins, trade.OrderID)
+		} else {
+			released = m.margin.Mul(trade.Quantity).Div(m.quantity)
+			m.margin = m.margin.Sub(released)
+			m.quantity = m.quantity.Sub(trade.Quantity)
+		}
+	}
+
+	p := a.position(market)
+	profit := a.updatePosition(p, trade)
+
+	// the initial margin of the position is kept in the locked balance
+	positionMargin := p.Base.Abs().Mul(p.AverageCost).Div(a.Leverage)
+	marginDelta := positionMargin.Sub(a.positionMargins[market.Symbol])
+	a.positionMargins[market.Symbol] = positionMargin
+
+	balance, _ := a.Account.Balance(market.QuoteCurrency)
+	balance.Currency = market.QuoteCurrency
+	balance.Locked = balance.Locked.Sub(released).Add(marginDelta)
+	balance.Available = balance.Available.Add(released).Sub(marginDelta).Add(profit).Sub(trade.Fee)
+	a.Account.UpdateBalances(types.BalanceMap{market.QuoteCurrency: balance})
+
+	if balance.Locked.Sign() < 0 {
+		return fmt.Errorf("futures account locked balance %s becomes negative: %s", market.QuoteCurrency, balance.Locked.String())
+	}
+
+	return nil
+}
+
+// updatePosition updates the position by the trade and returns the realized profit
+func (a *FuturesAccount) updatePosition(p *types.FuturesPosition, trade types.Trade) (profit fixedpoint.Value) {
+	profit = fixedpoint.Zero
+
+	delta := trade.Quantity
+	if trade.Side == types.SideTypeSell {
+		delta = delta.Neg()
+	}
+
+	base := p.Base
+	newBase := base.Add(delta)
+
+	if base.IsZero() || base.Sign() == delta.Sign() {
+		// open or increase the position
+		p.AverageCost = base.Abs().Mul(p.AverageCost).Add(trade.Quantity.Mul(trade.Price)).Div(newBase.Abs())
+	} else {
+		// reduce, close or reverse the position
+		closed := fixedpoint.Min(trade.Quantity, base.Abs())
+		profit = trade.Price.Sub(p.AverageCost).Mul(closed)
+		if base.Sign() < 0 {
+			profit = profit.Neg()
+		}
+
+		if newBase.IsZero() {
+			p.AverageCost = fixedpoint.Zero
+		} else if newBase.Sign() != base.Sign() {
+			p.AverageCost = trade.Price
+		}
+	}
+
+	p.Base = newBase
+	p.Quote = newBase.Mul(p.AverageCost).Neg()
+	p.UpdateTime = trade.Time.Time().UnixMilli()
+	return profit
+}
+
+// Positions returns the copied positions
+func (a *FuturesAccount) Positions() types.FuturesPositionMap {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	positions := make(types.FuturesPositionMap)
+	for symbol, p := range a.positions {
+		positions[symbol] = types.FuturesPosition{
+			Symbol:        p.Symbol,
+			BaseCurrency:  p.BaseCurrency,
+			QuoteCurrency: p.QuoteCurrency,
+			Market:        p.Market,
+			Base:          p.Base,
+			Quote:         p.Quote,
+			AverageCost:   p.AverageCost,
+			UpdateTime:    p.UpdateTime,
+		}
+	}
+	return positions
+}
+
+// ApplyFunding settles the funding fee of the position, the long position pays the short position when the rate is positive
+func (a *FuturesAccount) ApplyFunding(market types.Market, rate, markPrice fixedpoint.Value) fixedpoint.Value {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p, ok := a.positions[market.Symbol]
+	if !ok || p.Base.IsZero() {
+		return fixedpoint.Zero
+	}
+
+	fee := p.Base.Mul(markPrice).Mul(rate)
+	a.Account.AddBalance(market.QuoteCurrency, fee.Neg())
+	return fee
+}
+
+// MarginStatus returns the margin balance (wallet balance + unrealized profit) and the maintenance margin of the account,
+// the mark prices are the last prices of the symbols.
+func (a *FuturesAccount) MarginStatus(quoteCurrency string, markPrices map[string]fixedpoint.Value) (marginBalance, maintenanceMargin fixedpoint.Value) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	balance, _ := a.Account.Balance(quoteCurrency)
+	marginBalance = balance.Available.Add(balance.Locked)
+	maintenanceMargin = fixedpoint.Zero
+
+	for symbol, p := range a.positions {
+		if p.Base.IsZero() || p.QuoteCurrency != quoteCurrency {
+			continue
+		}
+
+		markPrice, ok := markPrices[symbol]
+		if !ok {
+			markPrice = p.AverageCost
+		}
+
+		marginBalance = marginBalance.Add(markPrice.Sub(p.AverageCost).Mul(p.Base))
+		maintenanceMargin = maintenanceMargin.Add(p.Base.Abs().Mul(markPrice).Mul(a.MaintenanceMarginRate))
+	}
+
+	return marginBalance, maintenanceMargin
+}
+
+// nextFundingRates pops the funding rates that are due at the given time
+func nextFundingRates(rates []types.FundingRate, now time.Time) (due, remaining []types.FundingRate) {
+	idx := 0
+	for ; idx < len(rates); idx++ {
+		if rates[idx].FundingTime.After(now) {
+			break
+		}
+	}
+	return rates[:idx], rates[idx:]
+}
@@ -0,0 +1,110 @@
+package backtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func newTestFuturesMatching() *SimplePriceMatching {
+	matching := newTestSimpleMatching()
+	matching.Futures = NewFuturesAccount(matching.Account, fixedpoint.NewFromInt(10), fixedpoint.Zero)
+	return matching
+}
+
+func newMarketOrder(side types.SideType, quantity float64) types.SubmitOrder {
+	return types.SubmitOrder{
+		Symbol:   "BTCUSDT",
+		Side:     side,
+		Type:     types.OrderTypeMarket,
+		Quantity: fixedpoint.NewFromFloat(quantity),
+	}
+}
+
+func TestFuturesAccount_ShortPosition(t *testing.T) {
+	matching := newTestFuturesMatching()
+
+	// open a short position without any base asset, the initial margin is 10000 * 2 / 10
+	_, trade, err := matching.PlaceOrder(newMarketOrder(types.SideTypeSell, 2.0))
+	if assert.NoError(t, err) && assert.NotNil(t, trade) {
+		assert.Equal(t, "USDT", trade.FeeCurrency)
+	}
+
+	positions := matching.Futures.Positions()
+	assert.Equal(t, "-2", positions["BTCUSDT"].Base.String())
+	assert.Equal(t, "10000", positions["BTCUSDT"].AverageCost.String())
+
+	usdt, _ := matching.Account.Balance("USDT")
+	assert.Equal(t, "2000", usdt.Locked.String())
+	assert.Equal(t, "98000", usdt.Available.String())
+	btc, _ := matching.Account.Balance("BTC")
+	assert.Equal(t, "10", btc.Available.String())
+
+	// close half of the position with profit
+	matching.LastPrice = fixedpoint.NewFromFloat(9000.0)
+	_, _, err = matching.PlaceOrder(newMarketOrder(types.SideTypeBuy, 1.0))
+	assert.NoError(t, err)
+
+	positions = matching.Futures.Positions()
+	assert.Equal(t, "-1", positions["BTCUSDT"].Base.String())
+
+	usdt, _ = matching.Account.Balance("USDT")
+	assert.Equal(t, "1000", usdt.Locked.String())
+	assert.Equal(t, "100000", usdt.Available.String())
+
+	// reverse the position, 1 BTC closes the short position and 1 BTC opens a long position
+	matching.LastPrice = fixedpoint.NewFromFloat(11000.0)
+	_, _, err = matching.PlaceOrder(newMarketOrder(types.SideTypeBuy, 2.0))
+	assert.NoError(t, err)
+
+	positions = matching.Futures.Positions()
+	assert.Equal(t, "1", positions["BTCUSDT"].Base.String())
+	assert.Equal(t, "11000", positions["BTCUSDT"].AverageCost.String())
+
+	usdt, _ = matching.Account.Balance("USDT")
+	assert.Equal(t, "1100", usdt.Locked.String())
+	assert.Equal(t, "98900", usdt.Available.String())
+
+	// the long position pays the funding fee when the funding rate is positive
+	fee := matching.Futures.ApplyFunding(matching.Market, fixedpoint.NewFromFloat(0.001), fixedpoint.NewFromFloat(11000.0))
+	assert.Equal(t, "11", fee.String())
+
+	marginBalance, maintenanceMargin := matching.Futures.MarginStatus("USDT", map[string]fixedpoint.Value{
+		"BTCUSDT": fixedpoint.NewFromFloat(10000.0),
+	})
+	assert.Equal(t, "98989", marginBalance.String())
+	assert.Equal(t, "40", maintenanceMargin.String())
+}
+
+func TestFuturesAccount_CancelOrder(t *testing.T) {
+	matching := newTestFuturesMatching()
+
+	order, _, err := matching.PlaceOrder(newLimitOrder("BTCUSDT", types.SideTypeBuy, 9000.0, 1.0))
+	assert.NoError(t, err)
+
+	usdt, _ := matching.Account.Balance("USDT")
+	assert.Equal(t, "900", usdt.Locked.String())
+
+	_, err = matching.CancelOrder(*order)
+	assert.NoError(t, err)
+
+	usdt, _ = matching.Account.Balance("USDT")
+	assert.Equal(t, "0", usdt.Locked.String())
+	assert.Equal(t, "100000", usdt.Available.String())
+}
+
+func TestNextFundingRates(t *testing.T) {
+	now := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	rates := []types.FundingRate{
+		{FundingTime: now},
+		{FundingTime: now.Add(8 * time.Hour)},
+	}
+
+	due, remaining := nextFundingRates(rates, now.Add(time.Hour))
+	assert.Len(t, due, 1)
+	assert.Len(t, remaining, 1)
+}
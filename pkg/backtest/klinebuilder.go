@@ -0,0 +1,104 @@
+package backtest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// KLineBuilder builds the klines of the given intervals from the market trades (ticks)
+type KLineBuilder struct {
+	Exchange  types.ExchangeName
+	Symbol    string
+	Intervals []types.Interval
+
+	klines map[types.Interval]*types.KLine
+}
+
+func NewKLineBuilder(exchange types.ExchangeName, symbol string, intervals ...types.Interval) *KLineBuilder {
+	return &KLineBuilder{
+		Exchange:  exchange,
+		Symbol:    symbol,
+		Intervals: intervals,
+		klines:    make(map[types.Interval]*types.KLine),
+	}
+}
+
+func (b *KLineBuilder) newKLine(interval types.Interval, startTime time.Time, price fixedpoint.Value) *types.KLine {
+	return &types.KLine{
+		Exchange:  b.Exchange,
+		Symbol:    b.Symbol,
+		Interval:  interval,
+		StartTime: types.Time(startTime),
+		EndTime:   types.Time(startTime.Add(interval.Duration() - time.Millisecond)),
+		Open:      price,
+		High:      price,
+		Low:       price,
+		Close:     price,
+		Volume:    fixedpoint.Zero,
+		Closed:    false,
+	}
+}
+
+// AddTrade adds the trade into the building klines, and returns the klines that were closed before this trade.
+// The periods without any trade are filled with flat klines of the last close price.
+func (b *KLineBuilder) AddTrade(trade types.Trade) (closed []types.KLine) {
+	tradeTime := trade.Time.Time()
+
+	for _, interval := range b.Intervals {
+		startTime := tradeTime.Truncate(interval.Duration())
+
+		k, ok := b.klines[interval]
+		if ok {
+			for k.StartTime.Time().Before(startTime) {
+				k.Closed = true
+				closed = append(closed, *k)
+				k = b.newKLine(interval, k.StartTime.Time().Add(interval.Duration()), k.Close)
+			}
+		} else {
+			k = b.newKLine(interval, startTime, trade.Price)
+		}
+
+		k.High = fixedpoint.Max(k.High, trade.Price)
+		k.Low = fixedpoint.Min(k.Low, trade.Price)
+		k.Close = trade.Price
+		k.Volume = k.Volume.Add(trade.Quantity)
+		k.QuoteVolume = k.QuoteVolume.Add(trade.QuoteQuantity)
+		k.NumberOfTrades++
+		k.LastTradeID = trade.ID
+		if trade.IsBuyer {
+			k.TakerBuyBaseAssetVolume = k.TakerBuyBaseAssetVolume.Add(trade.Quantity)
+			k.TakerBuyQuoteAssetVolume = k.TakerBuyQuoteAssetVolume.Add(trade.QuoteQuantity)
+		}
+
+		b.klines[interval] = k
+	}
+
+	sortKLines(closed)
+	return closed
+}
+
+// Flush closes all the building klines
+func (b *KLineBuilder) Flush() (closed []types.KLine) {
+	for interval, k := range b.klines {
+		k.Closed = true
+		closed = append(closed, *k)
+		delete(b.klines, interval)
+	}
+
+	sortKLines(closed)
+	return closed
+}
+
+// sortKLines sorts the klines by the end time, the shorter interval goes first if the end times are the same
+func sortKLines(klines []types.KLine) {
+	sort.SliceStable(klines, func(i, j int) bool {
+		ei, ej := klines[i].EndTime.Time(), klines[j].EndTime.Time()
+		if ei.Equal(ej) {
+			return klines[i].Interval.Duration() < klines[j].Interval.Duration()
+		}
+		return ei.Before(ej)
+	})
+}
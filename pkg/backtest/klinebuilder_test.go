@@ -0,0 +1,92 @@
+package backtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func newMarketTrade(t time.Time, price, quantity float64, isBuyer bool) types.Trade {
+	return types.Trade{
+		Symbol:        "BTCUSDT",
+		Price:         fixedpoint.NewFromFloat(price),
+		Quantity:      fixedpoint.NewFromFloat(quantity),
+		QuoteQuantity: fixedpoint.NewFromFloat(price * quantity),
+		IsBuyer:       isBuyer,
+		Time:          types.Time(t),
+	}
+}
+
+func TestKLineBuilder(t *testing.T) {
+	builder := NewKLineBuilder(types.ExchangeBinance, "BTCUSDT", types.Interval1m, types.Interval15s)
+	startTime := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	closed := builder.AddTrade(newMarketTrade(startTime.Add(1*time.Second), 100, 1, true))
+	assert.Len(t, closed, 0)
+
+	closed = builder.AddTrade(newMarketTrade(startTime.Add(5*time.Second), 105, 1, false))
+	assert.Len(t, closed, 0)
+
+	closed = builder.AddTrade(newMarketTrade(startTime.Add(10*time.Second), 95, 2, true))
+	assert.Len(t, closed, 0)
+
+	// the 15s kline is closed, and the gap from 00:15 to 00:45 is filled with flat klines
+	closed = builder.AddTrade(newMarketTrade(startTime.Add(50*time.Second), 99, 1, true))
+	if assert.Len(t, closed, 3) {
+		k := closed[0]
+		assert.Equal(t, types.Interval15s, k.Interval)
+		assert.Equal(t, "100", k.Open.String())
+		assert.Equal(t, "105", k.High.String())
+		assert.Equal(t, "95", k.Low.String())
+		assert.Equal(t, "95", k.Close.String())
+		assert.Equal(t, "4", k.Volume.String())
+		assert.Equal(t, "3", k.TakerBuyBaseAssetVolume.String())
+		assert.Equal(t, uint64(3), k.NumberOfTrades)
+		assert.True(t, k.Closed)
+		assert.Equal(t, startTime.Add(15*time.Second-time.Millisecond), k.EndTime.Time())
+
+		assert.Equal(t, "95", closed[1].Open.String())
+		assert.Equal(t, "95", closed[2].Close.String())
+		assert.Equal(t, "0", closed[2].Volume.String())
+	}
+
+	// the 1m kline and the last 15s kline end at the same time, the shorter interval goes first
+	closed = builder.AddTrade(newMarketTrade(startTime.Add(61*time.Second), 98, 1, false))
+	if assert.Len(t, closed, 2) {
+		assert.Equal(t, types.Interval15s, closed[0].Interval)
+		assert.Equal(t, types.Interval1m, closed[1].Interval)
+		assert.Equal(t, "100", closed[1].Open.String())
+		assert.Equal(t, "99", closed[1].Close.String())
+		assert.Equal(t, "5", closed[1].Volume.String())
+	}
+
+	closed = builder.Flush()
+	assert.Len(t, closed, 2)
+}
+
+func TestMergeKLineCh(t *testing.T) {
+	startTime := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	a := make(chan types.KLine, 10)
+	b := make(chan types.KLine, 10)
+	for i := 0; i < 3; i++ {
+		a <- types.KLine{Symbol: "BTCUSDT", EndTime: types.Time(startTime.Add(time.Duration(2*i) * time.Minute))}
+		b <- types.KLine{Symbol: "ETHUSDT", EndTime: types.Time(startTime.Add(time.Duration(2*i+1) * time.Minute))}
+	}
+	close(a)
+	close(b)
+
+	var endTimes []time.Time
+	for k := range mergeKLineCh(a, b) {
+		endTimes = append(endTimes, k.EndTime.Time())
+	}
+
+	if assert.Len(t, endTimes, 6) {
+		for i := 1; i < len(endTimes); i++ {
+			assert.True(t, endTimes[i].After(endTimes[i-1]))
+		}
+	}
+}
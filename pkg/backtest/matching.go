@@ -39,6 +39,9 @@ type SimplePriceMatching struct {
 
 	Account *types.Account
 
+	// Futures is the simulated futures account, the orders are margined by the futures account when it's set
+	Futures *FuturesAccount
+
 	tradeUpdateCallbacks   []func(trade types.Trade)
 	orderUpdateCallbacks   []func(order types.Order)
 	balanceUpdateCallbacks []func(balances types.BalanceMap)
@@ -55,6 +58,7 @@ func (m *SimplePriceMatching) CancelOrder(o types.Order) (types.Order, error) {
 		for _, order := range m.bidOrders {
 			if o.OrderID == order.OrderID {
 				found = true
+				o = order
 				continue
 			}
 			orders = append(orders, order)
@@ -68,6 +72,7 @@ func (m *SimplePriceMatching) CancelOrder(o types.Order) (types.Order, error) {
 		for _, order := range m.askOrders {
 			if o.OrderID == order.OrderID {
 				found = true
+				o = order
 				continue
 			}
 			orders = append(orders, order)
@@ -81,19 +86,12 @@ func (m *SimplePriceMatching) CancelOrder(o types.Order) (types.Order, error) {
 		return o, fmt.Errorf("cancel order failed, order %d not found: %+v", o.OrderID, o)
 	}
 
-	switch o.Side {
-	case types.SideTypeBuy:
-		if err := m.Account.UnlockBalance(m.Market.QuoteCurrency, o.Price.Mul(o.Quantity)); err != nil {
-			return o, err
-		}
-
-	case types.SideTypeSell:
-		if err := m.Account.UnlockBalance(m.Market.BaseCurrency, o.Quantity); err != nil {
-			return o, err
-		}
+	if err := m.unlockOrder(o); err != nil {
+		return o, err
 	}
 
 	o.Status = types.OrderStatusCanceled
+	o.IsWorking = false
 	m.EmitOrderUpdate(o)
 	m.EmitBalanceUpdate(m.Account.Balances())
 	return o, nil
@@ -110,8 +108,36 @@ func (m *SimplePriceMatching) PlaceOrder(o types.SubmitOrder) (closedOrders *typ
 		}
 
 		price = m.LastPrice
-	case types.OrderTypeLimit, types.OrderTypeLimitMaker:
+	case types.OrderTypeLimit, types.OrderTypeLimitMaker, types.OrderTypeStopLimit:
 		price = o.Price
+
+	case types.OrderTypeStopMarket:
+		price = o.StopPrice
+	}
+
+	if isStopOrder(o.Type) {
+		if o.StopPrice.IsZero() {
+			return nil, nil, fmt.Errorf("stop price of the stop order can not be zero, order: %+v", o)
+		}
+
+		// like the real exchanges, the stop order that would be triggered immediately is rejected
+		if m.shouldTrigger(o.Side, o.StopPrice, m.LastPrice) {
+			return nil, nil, fmt.Errorf("stop order would trigger immediately, stop price %s, last price %s, order: %+v", o.StopPrice.String(), m.LastPrice.String(), o)
+		}
+	}
+
+	switch o.Type {
+	case types.OrderTypeLimitMaker:
+		// the post-only order that would take liquidity is rejected
+		if m.isCrossing(o.Side, o.Price) {
+			return nil, nil, fmt.Errorf("post-only order would immediately match, price %s, last price %s, order: %+v", o.Price.String(), m.LastPrice.String(), o)
+		}
+
+	case types.OrderTypeLimit:
+		// the kline matching fills the whole order or nothing, so the FOK order is rejected if it can not be filled now
+		if o.TimeInForce == types.TimeInForceFOK && !m.isCrossing(o.Side, o.Price) {
+			return nil, nil, fmt.Errorf("FOK order can not be filled completely, price %s, last price %s, order: %+v", o.Price.String(), m.LastPrice.String(), o)
+		}
 	}
 
 	if o.Quantity.Compare(m.Market.MinQuantity) < 0 {
@@ -123,24 +149,16 @@ func (m *SimplePriceMatching) PlaceOrder(o types.SubmitOrder) (closedOrders *typ
 		return nil, nil, fmt.Errorf("order amount %s is less than minNotional %s, order: %+v", quoteQuantity.String(), m.Market.MinNotional.String(), o)
 	}
 
-	switch o.Side {
-	case types.SideTypeBuy:
-		if err := m.Account.LockBalance(m.Market.QuoteCurrency, quoteQuantity); err != nil {
-			return nil, nil, err
-		}
+	// start from one
+	orderID := incOrderID()
+	order := m.newOrder(o, orderID)
 
-	case types.SideTypeSell:
-		if err := m.Account.LockBalance(m.Market.BaseCurrency, o.Quantity); err != nil {
-			return nil, nil, err
-		}
+	if err := m.lockOrder(order, price); err != nil {
+		return nil, nil, err
 	}
 
 	m.EmitBalanceUpdate(m.Account.Balances())
 
-	// start from one
-	orderID := incOrderID()
-	order := m.newOrder(o, orderID)
-
 	if o.Type == types.OrderTypeMarket {
 		m.EmitOrderUpdate(order)
 
@@ -157,6 +175,15 @@ func (m *SimplePriceMatching) PlaceOrder(o types.SubmitOrder) (closedOrders *typ
 		return &order, &trade, nil
 	}
 
+	if o.Type == types.OrderTypeLimit && isImmediateTimeInForce(o.TimeInForce) {
+		return m.executeImmediateOrder(order)
+	}
+
+	// the stop order is not working until it's triggered
+	if isStopOrder(o.Type) {
+		order.IsWorking = false
+	}
+
 	// for limit maker orders
 	// TODO: handle limit taker order
 	switch o.Side {
@@ -180,14 +207,14 @@ func (m *SimplePriceMatching) PlaceOrder(o types.SubmitOrder) (closedOrders *typ
 func (m *SimplePriceMatching) executeTrade(trade types.Trade) {
 	var err error
 	// execute trade, update account balances
-	if trade.IsBuyer {
+	if m.Futures != nil {
+		err = m.Futures.ExecuteTrade(m.Market, trade)
+	} else if trade.IsBuyer {
 		err = m.Account.UseLockedBalance(m.Market.QuoteCurrency, trade.Price.Mul(trade.Quantity))
-
-		m.Account.AddBalance(m.Market.BaseCurrency, trade.Quantity.Sub(trade.Fee.Div(trade.Price)))
+		m.creditTrade(trade)
 	} else {
 		err = m.Account.UseLockedBalance(m.Market.BaseCurrency, trade.Quantity)
-
-		m.Account.AddBalance(m.Market.QuoteCurrency, trade.Quantity.Mul(trade.Price).Sub(trade.Fee))
+		m.creditTrade(trade)
 	}
 
 	if err != nil {
@@ -199,16 +226,20 @@ func (m *SimplePriceMatching) executeTrade(trade types.Trade) {
 	return
 }
 
-func (m *SimplePriceMatching) newTradeFromOrder(order types.Order, isMaker bool) types.Trade {
-	// BINANCE uses 0.1% for both maker and taker
-	// MAX uses 0.050% for maker and 0.15% for taker
-	var feeRate fixedpoint.Value
-	if isMaker {
-		feeRate = m.Account.MakerFeeRate
+// creditTrade adds the currency that the spot trade receives to the account, net of the fee.
+// The fee is charged in the fee currency set by newTrade: the base currency for the buyer,
+// and the quote currency for the seller.
+func (m *SimplePriceMatching) creditTrade(trade types.Trade) {
+	if trade.IsBuyer {
+		m.Account.AddBalance(m.Market.BaseCurrency, trade.Quantity.Sub(trade.Fee))
 	} else {
-		feeRate = m.Account.TakerFeeRate
+		m.Account.AddBalance(m.Market.QuoteCurrency, trade.Quantity.Mul(trade.Price).Sub(trade.Fee))
 	}
+}
 
+func (m *SimplePriceMatching) newTradeFromOrder(order types.Order, isMaker bool) types.Trade {
+	// BINANCE uses 0.1% for both maker and taker
+	// MAX uses 0.050% for maker and 0.15% for taker
 	price := order.Price
 	switch order.Type {
 	case types.OrderTypeMarket, types.OrderTypeStopMarket:
@@ -219,17 +250,36 @@ func (m *SimplePriceMatching) newTradeFromOrder(order types.Order, isMaker bool)
 		price = m.LastPrice
 	}
 
+	return m.newTrade(order, price, order.Quantity, isMaker)
+}
+
+// newTrade creates a trade of the given order with the given price and quantity,
+// the quantity could be less than the order quantity if the order is partially filled.
+func (m *SimplePriceMatching) newTrade(order types.Order, price, quantity fixedpoint.Value, isMaker bool) types.Trade {
+	var feeRate fixedpoint.Value
+	if isMaker {
+		feeRate = m.Account.MakerFeeRate
+	} else {
+		feeRate = m.Account.TakerFeeRate
+	}
+
 	var fee fixedpoint.Value
 	var feeCurrency string
 
 	switch order.Side {
 
 	case types.SideTypeBuy:
-		fee = order.Quantity.Mul(feeRate)
+		fee = quantity.Mul(feeRate)
 		feeCurrency = m.Market.BaseCurrency
 
+		// the futures fee is always paid in the quote currency
+		if m.Futures != nil {
+			fee = quantity.Mul(price).Mul(feeRate)
+			feeCurrency = m.Market.QuoteCurrency
+		}
+
 	case types.SideTypeSell:
-		fee = order.Quantity.Mul(price).Mul(feeRate)
+		fee = quantity.Mul(price).Mul(feeRate)
 		feeCurrency = m.Market.QuoteCurrency
 
 	}
@@ -243,8 +293,8 @@ func (m *SimplePriceMatching) newTradeFromOrder(order types.Order, isMaker bool)
 		OrderID:       order.OrderID,
 		Exchange:      "backtest",
 		Price:         price,
-		Quantity:      order.Quantity,
-		QuoteQuantity: order.Quantity.Mul(price),
+		Quantity:      quantity,
+		QuoteQuantity: quantity.Mul(price),
 		Symbol:        order.Symbol,
 		Side:          order.Side,
 		IsBuyer:       order.Side == types.SideTypeBuy,
@@ -255,130 +305,256 @@ func (m *SimplePriceMatching) newTradeFromOrder(order types.Order, isMaker bool)
 	}
 }
 
-func (m *SimplePriceMatching) BuyToPrice(price fixedpoint.Value) (closedOrders []types.Order, trades []types.Trade) {
-	var askOrders []types.Order
+// executeImmediateOrder fills the IOC or FOK limit order by the last price as a taker order if it's marketable,
+// otherwise the order is canceled without any fill.
+func (m *SimplePriceMatching) executeImmediateOrder(order types.Order) (*types.Order, *types.Trade, error) {
+	m.EmitOrderUpdate(order)
 
-	for _, o := range m.askOrders {
-		switch o.Type {
-
-		case types.OrderTypeStopMarket:
-			// should we trigger the order
-			if price.Compare(o.StopPrice) <= 0 {
-				// not triggering it, put it back
-				askOrders = append(askOrders, o)
-				break
-			}
+	if !m.isCrossing(order.Side, order.Price) {
+		if err := m.unlockOrder(order); err != nil {
+			return nil, nil, err
+		}
 
-			o.Type = types.OrderTypeMarket
-			o.ExecutedQuantity = o.Quantity
-			o.Price = price
-			o.Status = types.OrderStatusFilled
-			closedOrders = append(closedOrders, o)
+		order.Status = types.OrderStatusCanceled
+		order.IsWorking = false
+		m.EmitOrderUpdate(order)
+		m.EmitBalanceUpdate(m.Account.Balances())
+		return &order, nil, nil
+	}
 
-		case types.OrderTypeStopLimit:
-			// should we trigger the order?
-			if price.Compare(o.StopPrice) <= 0 {
-				askOrders = append(askOrders, o)
-				break
-			}
+	trade := m.newTrade(order, m.LastPrice, order.Quantity, false)
+	m.executeTrade(trade)
 
-			o.Type = types.OrderTypeLimit
-
-			// is it a taker order?
-			if price.Compare(o.Price) >= 0 {
-				if o.Price.Compare(m.LastKLine.Low) < 0 {
-					o.Price = m.LastKLine.Low
-				}
-				o.ExecutedQuantity = o.Quantity
-				o.Status = types.OrderStatusFilled
-				closedOrders = append(closedOrders, o)
-			} else {
-				// maker order
-				askOrders = append(askOrders, o)
-			}
+	// the buy order was locked by the limit price, unlock the price improvement
+	if order.Side == types.SideTypeBuy && m.Futures == nil {
+		if err := m.Account.UnlockBalance(m.Market.QuoteCurrency, order.Price.Sub(m.LastPrice).Mul(order.Quantity)); err != nil {
+			return nil, nil, err
+		}
+		m.EmitBalanceUpdate(m.Account.Balances())
+	}
 
-		case types.OrderTypeLimit, types.OrderTypeLimitMaker:
-			if price.Compare(o.Price) >= 0 {
-				if o.Price.Compare(m.LastKLine.Low) < 0 {
-					o.Price = m.LastKLine.Low
-				}
-				o.ExecutedQuantity = o.Quantity
-				o.Status = types.OrderStatusFilled
-				closedOrders = append(closedOrders, o)
-			} else {
-				askOrders = append(askOrders, o)
-			}
+	order.Status = types.OrderStatusFilled
+	order.ExecutedQuantity = order.Quantity
+	order.IsWorking = false
+	m.EmitOrderUpdate(order)
+	return &order, &trade, nil
+}
 
-		default:
-			askOrders = append(askOrders, o)
-		}
+// isCrossing returns true if the limit order of the given price would be matched by the last price immediately
+func (m *SimplePriceMatching) isCrossing(side types.SideType, price fixedpoint.Value) bool {
+	if m.LastPrice.IsZero() {
+		return false
+	}
 
+	switch side {
+	case types.SideTypeBuy:
+		return price.Compare(m.LastPrice) >= 0
+	case types.SideTypeSell:
+		return price.Compare(m.LastPrice) <= 0
 	}
+	return false
+}
 
-	m.askOrders = askOrders
-	m.LastPrice = price
+func isImmediateTimeInForce(timeInForce types.TimeInForce) bool {
+	return timeInForce == types.TimeInForceIOC || timeInForce == types.TimeInForceFOK
+}
 
-	for _, o := range closedOrders {
-		trade := m.newTradeFromOrder(o, true)
-		m.executeTrade(trade)
+func isStopOrder(orderType types.OrderType) bool {
+	return orderType == types.OrderTypeStopLimit || orderType == types.OrderTypeStopMarket
+}
 
-		trades = append(trades, trade)
+// isWorkingLimitOrder returns true if the order sits on the book as a limit order,
+// a triggered stop limit order works like a limit order.
+func isWorkingLimitOrder(o types.Order) bool {
+	switch o.Type {
+	case types.OrderTypeLimit, types.OrderTypeLimitMaker:
+		return true
+	case types.OrderTypeStopLimit:
+		return o.IsWorking
+	}
+	return false
+}
+
+// shouldTrigger returns true if the stop order should be triggered by the given price,
+// the buy stop orders are triggered when the price rises to the stop price,
+// the sell stop orders are triggered when the price falls to the stop price.
+func (m *SimplePriceMatching) shouldTrigger(side types.SideType, stopPrice, price fixedpoint.Value) bool {
+	if price.IsZero() {
+		return false
+	}
+
+	switch side {
+	case types.SideTypeBuy:
+		return price.Compare(stopPrice) >= 0
+	case types.SideTypeSell:
+		return price.Compare(stopPrice) <= 0
+	}
+	return false
+}
+
+// lockedPrice returns the price that was used for locking the quote balance of the buy order
+func lockedPrice(o types.Order) fixedpoint.Value {
+	if o.Type == types.OrderTypeStopMarket {
+		return o.StopPrice
+	}
+	return o.Price
+}
+
+// lockOrder locks the balance required by the order at the given price
+func (m *SimplePriceMatching) lockOrder(o types.Order, price fixedpoint.Value) error {
+	if m.Futures != nil {
+		return m.Futures.LockOrder(m.Market, o, price)
+	}
+
+	switch o.Side {
+	case types.SideTypeBuy:
+		return m.Account.LockBalance(m.Market.QuoteCurrency, o.Quantity.Mul(price))
+
+	case types.SideTypeSell:
+		return m.Account.LockBalance(m.Market.BaseCurrency, o.Quantity)
+	}
+
+	return nil
+}
+
+// unlockOrder unlocks the balance of the unfilled part of the order
+func (m *SimplePriceMatching) unlockOrder(o types.Order) error {
+	if m.Futures != nil {
+		return m.Futures.UnlockOrder(m.Market, o)
+	}
+
+	remaining := o.Quantity.Sub(o.ExecutedQuantity)
+
+	switch o.Side {
+	case types.SideTypeBuy:
+		return m.Account.UnlockBalance(m.Market.QuoteCurrency, lockedPrice(o).Mul(remaining))
+
+	case types.SideTypeSell:
+		return m.Account.UnlockBalance(m.Market.BaseCurrency, remaining)
+	}
+
+	return nil
+}
+
+// relockBuyOrder re-locks the quote balance of the buy order by the actual fill price,
+// since the taker fill price of a triggered order could be different from the price it was locked.
+func (m *SimplePriceMatching) relockBuyOrder(o types.Order, price fixedpoint.Value) error {
+	// the futures margin is released by the filled quantity, so it's not affected by the price
+	if o.Side != types.SideTypeBuy || m.Futures != nil {
+		return nil
+	}
+
+	if err := m.unlockOrder(o); err != nil {
+		return err
+	}
+
+	return m.Account.LockBalance(m.Market.QuoteCurrency, price.Mul(o.Quantity.Sub(o.ExecutedQuantity)))
+}
+
+// triggerStopOrders triggers the stop orders of the given side by the price,
+// the triggered stop market orders and the marketable stop limit orders are filled immediately as taker orders,
+// the other triggered stop limit orders are returned and should be put back to the book.
+func (m *SimplePriceMatching) triggerStopOrders(orders []types.Order, price fixedpoint.Value) (remaining, filled []types.Order) {
+	for _, o := range orders {
+		if !isStopOrder(o.Type) || o.IsWorking || !m.shouldTrigger(o.Side, o.StopPrice, price) {
+			remaining = append(remaining, o)
+			continue
+		}
+
+		o.IsWorking = true
+		o.UpdateTime = types.Time(m.CurrentTime)
+
+		// the price moves continuously from the last price to the current price,
+		// so the triggered order is filled at the stop price unless the price gapped over it
+		fillPrice := o.StopPrice
+		if !m.LastPrice.IsZero() && m.shouldTrigger(o.Side, o.StopPrice, m.LastPrice) {
+			fillPrice = m.LastPrice
+		}
+
+		marketable := o.Type == types.OrderTypeStopMarket ||
+			(o.Side == types.SideTypeBuy && fillPrice.Compare(o.Price) <= 0) ||
+			(o.Side == types.SideTypeSell && fillPrice.Compare(o.Price) >= 0)
+
+		if !marketable {
+			remaining = append(remaining, o)
+			m.EmitOrderUpdate(o)
+			continue
+		}
+
+		if err := m.relockBuyOrder(o, fillPrice); err != nil {
+			// not enough balance for the slippage, the triggered order is rejected
+			log.WithError(err).Errorf("triggered stop order %d can not be filled", o.OrderID)
+			o.Status = types.OrderStatusRejected
+			o.IsWorking = false
+			m.EmitOrderUpdate(o)
+			m.EmitBalanceUpdate(m.Account.Balances())
+			continue
+		}
 
 		m.EmitOrderUpdate(o)
+
+		o.Price = fillPrice
+		o.ExecutedQuantity = o.Quantity
+		o.Status = types.OrderStatusFilled
+		o.IsWorking = false
+		filled = append(filled, o)
 	}
 
-	return closedOrders, trades
+	return remaining, filled
+}
+
+func (m *SimplePriceMatching) BuyToPrice(price fixedpoint.Value) (closedOrders []types.Order, trades []types.Trade) {
+	// the buy stop orders are triggered when the price rises
+	bidOrders, takerOrders := m.triggerStopOrders(m.bidOrders, price)
+	m.bidOrders = bidOrders
+
+	var askOrders []types.Order
+	for _, o := range m.askOrders {
+		if !isWorkingLimitOrder(o) {
+			askOrders = append(askOrders, o)
+			continue
+		}
+
+		if price.Compare(o.Price) >= 0 {
+			if o.Price.Compare(m.LastKLine.Low) < 0 {
+				o.Price = m.LastKLine.Low
+			}
+			o.ExecutedQuantity = o.Quantity
+			o.Status = types.OrderStatusFilled
+			o.IsWorking = false
+			closedOrders = append(closedOrders, o)
+		} else {
+			askOrders = append(askOrders, o)
+		}
+	}
+
+	m.askOrders = askOrders
+	m.LastPrice = price
+
+	return m.executeClosedOrders(closedOrders, takerOrders)
 }
 
 func (m *SimplePriceMatching) SellToPrice(price fixedpoint.Value) (closedOrders []types.Order, trades []types.Trade) {
-	var sellPrice = price
+	// the sell stop orders are triggered when the price falls
+	askOrders, takerOrders := m.triggerStopOrders(m.askOrders, price)
+	m.askOrders = askOrders
+
 	var bidOrders []types.Order
 	for _, o := range m.bidOrders {
-		switch o.Type {
-
-		case types.OrderTypeStopMarket:
-			// should we trigger the order
-			if sellPrice.Compare(o.StopPrice) <= 0 {
-				o.ExecutedQuantity = o.Quantity
-				o.Price = sellPrice
-				o.Status = types.OrderStatusFilled
-				closedOrders = append(closedOrders, o)
-			} else {
-				bidOrders = append(bidOrders, o)
-			}
-
-		case types.OrderTypeStopLimit:
-			// should we trigger the order
-			if sellPrice.Compare(o.StopPrice) <= 0 {
-				o.Type = types.OrderTypeLimit
-
-				if sellPrice.Compare(o.Price) <= 0 {
-					if o.Price.Compare(m.LastKLine.High) > 0 {
-						o.Price = m.LastKLine.High
-					}
-					o.ExecutedQuantity = o.Quantity
-					o.Status = types.OrderStatusFilled
-					closedOrders = append(closedOrders, o)
-				} else {
-					bidOrders = append(bidOrders, o)
-				}
-			} else {
-				bidOrders = append(bidOrders, o)
-			}
+		if !isWorkingLimitOrder(o) {
+			bidOrders = append(bidOrders, o)
+			continue
+		}
 
-		case types.OrderTypeLimit, types.OrderTypeLimitMaker:
-			if sellPrice.Compare(o.Price) <= 0 {
-				if o.Price.Compare(m.LastKLine.High) > 0 {
-					o.Price = m.LastKLine.High
-				}
-				o.ExecutedQuantity = o.Quantity
-				o.Status = types.OrderStatusFilled
-				closedOrders = append(closedOrders, o)
-			} else {
-				bidOrders = append(bidOrders, o)
+		if price.Compare(o.Price) <= 0 {
+			if o.Price.Compare(m.LastKLine.High) > 0 {
+				o.Price = m.LastKLine.High
 			}
-
-		default:
+			o.ExecutedQuantity = o.Quantity
+			o.Status = types.OrderStatusFilled
+			o.IsWorking = false
+			closedOrders = append(closedOrders, o)
+		} else {
 			bidOrders = append(bidOrders, o)
 		}
 	}
@@ -386,16 +562,26 @@ func (m *SimplePriceMatching) SellToPrice(price fixedpoint.Value) (closedOrders
 	m.bidOrders = bidOrders
 	m.LastPrice = price
 
-	for _, o := range closedOrders {
+	return m.executeClosedOrders(closedOrders, takerOrders)
+}
+
+// executeClosedOrders executes the trades of the filled maker orders and the filled triggered taker orders
+func (m *SimplePriceMatching) executeClosedOrders(makerOrders, takerOrders []types.Order) (closedOrders []types.Order, trades []types.Trade) {
+	for _, o := range makerOrders {
 		trade := m.newTradeFromOrder(o, true)
 		m.executeTrade(trade)
-
 		trades = append(trades, trade)
+		m.EmitOrderUpdate(o)
+	}
 
+	for _, o := range takerOrders {
+		trade := m.newTrade(o, o.Price, o.Quantity, false)
+		m.executeTrade(trade)
+		trades = append(trades, trade)
 		m.EmitOrderUpdate(o)
 	}
 
-	return closedOrders, trades
+	return append(makerOrders, takerOrders...), trades
 }
 
 func (m *SimplePriceMatching) processKLine(kline types.KLine) {
@@ -434,6 +620,18 @@ func (m *SimplePriceMatching) processKLine(kline types.KLine) {
 	}
 }
 
+// processMarketTrade matches the orders by the price of the market trade,
+// the taker side of the trade decides which side of the orders could be filled.
+func (m *SimplePriceMatching) processMarketTrade(trade types.Trade) {
+	m.CurrentTime = trade.Time.Time()
+
+	if trade.IsBuyer {
+		m.BuyToPrice(trade.Price)
+	} else {
+		m.SellToPrice(trade.Price)
+	}
+}
+
 func (m *SimplePriceMatching) newOrder(o types.SubmitOrder, orderID uint64) types.Order {
 	return types.Order{
 		OrderID:          orderID,
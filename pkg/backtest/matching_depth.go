@@ -0,0 +1,589 @@
+package backtest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// queuePosition tracks where a resting order sits in its price level
+type queuePosition struct {
+	// ahead is the volume queued before our order at the same price
+	ahead fixedpoint.Value
+
+	// levelVolume is the volume of the price level when we checked it last time
+	levelVolume fixedpoint.Value
+}
+
+// fill is a fill of an order against one price level
+type fill struct {
+	Price, Quantity fixedpoint.Value
+}
+
+// DepthPriceMatching replays the recorded order book snapshots and updates, and fills the orders against the real depth.
+//
+// Taker orders sweep the opposite side of the book level by level, so market orders get slippage and
+// are partially filled when the recorded depth is not enough.
+// Resting orders join the end of the queue of their price level, and they are only filled when
+// the volume queued before them is consumed or when the opposite side of the book trades through their price.
+type DepthPriceMatching struct {
+	*SimplePriceMatching
+
+	// DataFile is the recorded depth file of the symbol, see DepthDumper
+	DataFile string
+
+	book   *types.SliceOrderBook
+	reader *DepthReader
+	queues map[uint64]*queuePosition
+}
+
+func NewDepthPriceMatching(matching *SimplePriceMatching, dataFile string) *DepthPriceMatching {
+	return &DepthPriceMatching{
+		SimplePriceMatching: matching,
+		DataFile:            dataFile,
+		book:                types.NewSliceOrderBook(matching.Market.Symbol),
+		queues:              make(map[uint64]*queuePosition),
+	}
+}
+
+func (m *DepthPriceMatching) hasDepth() bool {
+	_, hasBid := m.book.BestBid()
+	_, hasAsk := m.book.BestAsk()
+	return hasBid && hasAsk
+}
+
+// sweep walks the opposite side of the book and returns the fills of the given quantity,
+// the price of the fills will not be worse than the limit price if the limit price is not zero.
+func (m *DepthPriceMatching) sweep(side types.SideType, quantity, limitPrice fixedpoint.Value) (fills []fill, filled fixedpoint.Value) {
+	var levels types.PriceVolumeSlice
+	switch side {
+	case types.SideTypeBuy:
+		levels = m.book.Asks
+	case types.SideTypeSell:
+		levels = m.book.Bids
+	}
+
+	filled = fixedpoint.Zero
+	for _, pv := range levels {
+		if filled.Compare(quantity) >= 0 {
+			break
+		}
+
+		if !limitPrice.IsZero() {
+			if side == types.SideTypeBuy && pv.Price.Compare(limitPrice) > 0 {
+				break
+			} else if side == types.SideTypeSell && pv.Price.Compare(limitPrice) < 0 {
+				break
+			}
+		}
+
+		q := fixedpoint.Min(pv.Volume, quantity.Sub(filled))
+		fills = append(fills, fill{Price: pv.Price, Quantity: q})
+		filled = filled.Add(q)
+	}
+
+	return fills, filled
+}
+
+// consume removes the filled volume from the book, so that the same depth won't be filled twice
+func (m *DepthPriceMatching) consume(side types.SideType, fills []fill) {
+	var levels types.PriceVolumeSlice
+	switch side {
+	case types.SideTypeBuy:
+		levels = m.book.Asks
+	case types.SideTypeSell:
+		levels = m.book.Bids
+	}
+
+	var updates types.PriceVolumeSlice
+	for _, f := range fills {
+		pv, idx := levels.Find(f.Price, side == types.SideTypeSell)
+		if idx >= len(levels) || pv.Price.Compare(f.Price) != 0 {
+			continue
+		}
+
+		updates = append(updates, types.PriceVolume{
+			Price:  f.Price,
+			Volume: fixedpoint.Max(pv.Volume.Sub(f.Quantity), fixedpoint.Zero),
+		})
+	}
+
+	switch side {
+	case types.SideTypeBuy:
+		m.book.Update(types.SliceOrderBook{Asks: updates})
+	case types.SideTypeSell:
+		m.book.Update(types.SliceOrderBook{Bids: updates})
+	}
+}
+
+func fillsCost(fills []fill) fixedpoint.Value {
+	amount := fixedpoint.Zero
+	for _, f := range fills {
+		amount = amount.Add(f.Price.Mul(f.Quantity))
+	}
+	return amount
+}
+
+func averagePrice(fills []fill) fixedpoint.Value {
+	quantity := fixedpoint.Zero
+	for _, f := range fills {
+		quantity = quantity.Add(f.Quantity)
+	}
+
+	if quantity.IsZero() {
+		return fixedpoint.Zero
+	}
+
+	return fillsCost(fills).Div(quantity)
+}
+
+func (m *DepthPriceMatching) PlaceOrder(o types.SubmitOrder) (*types.Order, []types.Trade, error) {
+	// without the recorded depth, fall back to the kline matching
+	if !m.hasDepth() {
+		order, trade, err := m.SimplePriceMatching.PlaceOrder(o)
+		if trade != nil {
+			return order, []types.Trade{*trade}, err
+		}
+		return order, nil, err
+	}
+
+	switch o.Type {
+	case types.OrderTypeMarket:
+		return m.placeMarketOrder(o)
+
+	case types.OrderTypeLimit, types.OrderTypeLimitMaker:
+		return m.placeLimitOrder(o)
+
+	case types.OrderTypeStopLimit, types.OrderTypeStopMarket:
+		// the stop orders are not in the book until they're triggered, see triggerStopOrders
+		order, _, err := m.SimplePriceMatching.PlaceOrder(o)
+		return order, nil, err
+	}
+
+	return nil, nil, fmt.Errorf("order type %s is not supported by the depth matching engine", o.Type)
+}
+
+func (m *DepthPriceMatching) validate(o types.SubmitOrder, price fixedpoint.Value) error {
+	if o.Quantity.Compare(m.Market.MinQuantity) < 0 {
+		return fmt.Errorf("order quantity %s is less than minQuantity %s, order: %+v", o.Quantity.String(), m.Market.MinQuantity.String(), o)
+	}
+
+	quoteQuantity := o.Quantity.Mul(price)
+	if quoteQuantity.Compare(m.Market.MinNotional) < 0 {
+		return fmt.Errorf("order amount %s is less than minNotional %s, order: %+v", quoteQuantity.String(), m.Market.MinNotional.String(), o)
+	}
+
+	return nil
+}
+
+func (m *DepthPriceMatching) placeMarketOrder(o types.SubmitOrder) (*types.Order, []types.Trade, error) {
+	fills, filled := m.sweep(o.Side, o.Quantity, fixedpoint.Zero)
+	if filled.IsZero() {
+		return nil, nil, fmt.Errorf("no depth to fill the market order: %+v", o)
+	}
+
+	avgPrice := averagePrice(fills)
+	if err := m.validate(o, avgPrice); err != nil {
+		return nil, nil, err
+	}
+
+	if err := m.lockMarketOrder(o.Side, fills, filled); err != nil {
+		return nil, nil, err
+	}
+
+	m.EmitBalanceUpdate(m.Account.Balances())
+
+	order := m.newOrder(o, incOrderID())
+	m.EmitOrderUpdate(order)
+
+	trades := m.fillMarketOrder(&order, fills)
+	m.EmitOrderUpdate(order)
+	return &order, trades, nil
+}
+
+// lockMarketOrder locks the balance of the market order by the actual cost,
+// so that the slippage is paid by the available balance
+func (m *DepthPriceMatching) lockMarketOrder(side types.SideType, fills []fill, filled fixedpoint.Value) error {
+	switch side {
+	case types.SideTypeBuy:
+		return m.Account.LockBalance(m.Market.QuoteCurrency, fillsCost(fills))
+
+	case types.SideTypeSell:
+		return m.Account.LockBalance(m.Market.BaseCurrency, filled)
+	}
+
+	return nil
+}
+
+// fillMarketOrder executes the fills of the locked market order,
+// the unfilled part of a market order is canceled when the depth is not enough.
+func (m *DepthPriceMatching) fillMarketOrder(order *types.Order, fills []fill) (trades []types.Trade) {
+	for _, f := range fills {
+		trades = append(trades, m.executeFill(order, f, f.Price, false))
+	}
+	m.consume(order.Side, fills)
+	m.emitTrades(trades)
+
+	order.Price = averagePrice(fills)
+	order.IsWorking = false
+	if order.ExecutedQuantity.Compare(order.Quantity) < 0 {
+		order.Status = types.OrderStatusCanceled
+	}
+
+	return trades
+}
+
+func (m *DepthPriceMatching) placeLimitOrder(o types.SubmitOrder) (*types.Order, []types.Trade, error) {
+	_, crossed := m.sweep(o.Side, o.Quantity, o.Price)
+	if o.Type == types.OrderTypeLimitMaker && crossed.Sign() > 0 {
+		return nil, nil, fmt.Errorf("post-only order would immediately match, price %s, order: %+v", o.Price.String(), o)
+	}
+
+	if o.Type == types.OrderTypeLimit && o.TimeInForce == types.TimeInForceFOK && crossed.Compare(o.Quantity) < 0 {
+		return nil, nil, fmt.Errorf("FOK order can not be filled completely, fillable quantity %s, order: %+v", crossed.String(), o)
+	}
+
+	if err := m.validate(o, o.Price); err != nil {
+		return nil, nil, err
+	}
+
+	switch o.Side {
+	case types.SideTypeBuy:
+		if err := m.Account.LockBalance(m.Market.QuoteCurrency, o.Price.Mul(o.Quantity)); err != nil {
+			return nil, nil, err
+		}
+
+	case types.SideTypeSell:
+		if err := m.Account.LockBalance(m.Market.BaseCurrency, o.Quantity); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	m.EmitBalanceUpdate(m.Account.Balances())
+
+	order := m.newOrder(o, incOrderID())
+	m.EmitOrderUpdate(order)
+
+	trades := m.fillLimitOrder(&order)
+	if len(trades) > 0 || order.Status == types.OrderStatusCanceled {
+		m.EmitOrderUpdate(order)
+	}
+
+	return &order, trades, nil
+}
+
+// fillLimitOrder fills the crossing part of the locked limit order as a taker order,
+// and the rest of the order joins the end of the queue of its price level.
+// The rest of the IOC order is canceled instead.
+func (m *DepthPriceMatching) fillLimitOrder(order *types.Order) (trades []types.Trade) {
+	fills, _ := m.sweep(order.Side, order.Quantity.Sub(order.ExecutedQuantity), order.Price)
+	for _, f := range fills {
+		trades = append(trades, m.executeFill(order, f, order.Price, false))
+	}
+	m.consume(order.Side, fills)
+	m.emitTrades(trades)
+
+	if order.Status == types.OrderStatusFilled {
+		return trades
+	}
+
+	if order.Type == types.OrderTypeLimit && isImmediateTimeInForce(order.TimeInForce) {
+		if err := m.unlockOrder(*order); err != nil {
+			log.WithError(err).Errorf("can not unlock the IOC order %d", order.OrderID)
+		}
+
+		order.Status = types.OrderStatusCanceled
+		order.IsWorking = false
+		m.EmitBalanceUpdate(m.Account.Balances())
+		return trades
+	}
+
+	level, _ := m.book.SideBook(order.Side).Find(order.Price, order.Side == types.SideTypeBuy)
+	m.queues[order.OrderID] = &queuePosition{
+		ahead:       level.Volume,
+		levelVolume: level.Volume,
+	}
+
+	m.mu.Lock()
+	switch order.Side {
+	case types.SideTypeBuy:
+		m.bidOrders = append(m.bidOrders, *order)
+	case types.SideTypeSell:
+		m.askOrders = append(m.askOrders, *order)
+	}
+	m.mu.Unlock()
+
+	return trades
+}
+
+// triggerStopOrders triggers the stop orders by the mid price of the book,
+// the triggered stop market orders sweep the book, and the triggered stop limit orders are placed as limit orders.
+func (m *DepthPriceMatching) triggerStopOrders() {
+	var triggered []types.Order
+
+	m.mu.Lock()
+	m.bidOrders, triggered = m.splitTriggeredOrders(m.bidOrders, triggered)
+	m.askOrders, triggered = m.splitTriggeredOrders(m.askOrders, triggered)
+	m.mu.Unlock()
+
+	for _, order := range triggered {
+		order.IsWorking = true
+		order.UpdateTime = types.Time(m.CurrentTime)
+
+		if order.Type == types.OrderTypeStopLimit {
+			m.EmitOrderUpdate(order)
+
+			// the balance was locked by the limit price already
+			if trades := m.fillLimitOrder(&order); len(trades) > 0 || order.Status == types.OrderStatusCanceled {
+				m.EmitOrderUpdate(order)
+			}
+			continue
+		}
+
+		// re-lock the stop market order by the actual cost of the sweep
+		if err := m.unlockOrder(order); err != nil {
+			log.WithError(err).Errorf("can not unlock the triggered stop order %d", order.OrderID)
+		}
+
+		fills, filled := m.sweep(order.Side, order.Quantity, fixedpoint.Zero)
+		if err := m.lockMarketOrder(order.Side, fills, filled); err != nil || filled.IsZero() {
+			log.WithError(err).Errorf("triggered stop order %d can not be filled", order.OrderID)
+			order.Status = types.OrderStatusRejected
+			order.IsWorking = false
+			m.EmitBalanceUpdate(m.Account.Balances())
+			m.EmitOrderUpdate(order)
+			continue
+		}
+
+		m.EmitOrderUpdate(order)
+		m.fillMarketOrder(&order, fills)
+		m.EmitOrderUpdate(order)
+	}
+}
+
+func (m *DepthPriceMatching) splitTriggeredOrders(orders, triggered []types.Order) (remaining, _ []types.Order) {
+	for _, order := range orders {
+		if isStopOrder(order.Type) && !order.IsWorking && m.shouldTrigger(order.Side, order.StopPrice, m.LastPrice) {
+			triggered = append(triggered, order)
+			continue
+		}
+
+		remaining = append(remaining, order)
+	}
+
+	return remaining, triggered
+}
+
+func (m *DepthPriceMatching) CancelOrder(o types.Order) (types.Order, error) {
+	delete(m.queues, o.OrderID)
+	return m.SimplePriceMatching.CancelOrder(o)
+}
+
+// executeFill executes a fill of the order and updates the account balances,
+// lockPrice is the price that was used for locking the quote balance of the buy order.
+// The caller is responsible for emitting the trade update.
+func (m *DepthPriceMatching) executeFill(order *types.Order, f fill, lockPrice fixedpoint.Value, isMaker bool) types.Trade {
+	trade := m.newTrade(*order, f.Price, f.Quantity, isMaker)
+
+	var err error
+	switch order.Side {
+	case types.SideTypeBuy:
+		err = m.Account.UseLockedBalance(m.Market.QuoteCurrency, lockPrice.Mul(f.Quantity))
+
+		// refund the price improvement
+		if refund := lockPrice.Sub(f.Price).Mul(f.Quantity); refund.Sign() > 0 {
+			m.Account.AddBalance(m.Market.QuoteCurrency, refund)
+		}
+
+		m.creditTrade(trade)
+
+	case types.SideTypeSell:
+		err = m.Account.UseLockedBalance(m.Market.BaseCurrency, f.Quantity)
+		m.creditTrade(trade)
+	}
+
+	if err != nil {
+		panic(errors.Wrapf(err, "executeFill exception, wanted to use more than the locked balance"))
+	}
+
+	order.ExecutedQuantity = order.ExecutedQuantity.Add(f.Quantity)
+	order.UpdateTime = types.Time(m.CurrentTime)
+	if order.ExecutedQuantity.Compare(order.Quantity) >= 0 {
+		order.Status = types.OrderStatusFilled
+		order.IsWorking = false
+	} else {
+		order.Status = types.OrderStatusPartiallyFilled
+	}
+
+	return trade
+}
+
+func (m *DepthPriceMatching) emitTrades(trades []types.Trade) {
+	for _, trade := range trades {
+		m.EmitTradeUpdate(trade)
+	}
+
+	if len(trades) > 0 {
+		m.EmitBalanceUpdate(m.Account.Balances())
+	}
+}
+
+// matchRestingOrders checks the resting orders against the current book
+func (m *DepthPriceMatching) matchRestingOrders() {
+	m.mu.Lock()
+	bidOrders, bidUpdates, bidTrades := m.matchSide(m.bidOrders)
+	askOrders, askUpdates, askTrades := m.matchSide(m.askOrders)
+	m.bidOrders = bidOrders
+	m.askOrders = askOrders
+	m.mu.Unlock()
+
+	// emit the updates after the lock is released, since the strategy might place new orders in the callbacks
+	m.emitTrades(append(bidTrades, askTrades...))
+	for _, order := range append(bidUpdates, askUpdates...) {
+		m.EmitOrderUpdate(order)
+	}
+}
+
+func (m *DepthPriceMatching) matchSide(orders []types.Order) (remaining, updated []types.Order, trades []types.Trade) {
+	for _, order := range orders {
+		// the stop orders are not in the book until they're triggered
+		if isStopOrder(order.Type) && !order.IsWorking {
+			remaining = append(remaining, order)
+			continue
+		}
+
+		queue, ok := m.queues[order.OrderID]
+		if !ok {
+			queue = &queuePosition{ahead: fixedpoint.Zero, levelVolume: fixedpoint.Zero}
+			m.queues[order.OrderID] = queue
+		}
+
+		unfilled := order.Quantity.Sub(order.ExecutedQuantity)
+		traded := fixedpoint.Zero
+
+		// the opposite side trades through our price, nobody is queued before us anymore
+		fills, crossed := m.sweep(order.Side, unfilled, order.Price)
+		if crossed.Sign() > 0 {
+			m.consume(order.Side, fills)
+			queue.ahead = fixedpoint.Zero
+			traded = crossed
+		} else {
+			sideBook := m.book.SideBook(order.Side)
+			level, _ := sideBook.Find(order.Price, order.Side == types.SideTypeBuy)
+			levelVolume := level.Volume
+
+			// trades only happen at the top of the book, a shrinking top level means
+			// the volume was traded from the front of the queue
+			best, hasBest := sideBook.First()
+			atTop := !hasBest || (order.Side == types.SideTypeBuy && order.Price.Compare(best.Price) >= 0) ||
+				(order.Side == types.SideTypeSell && order.Price.Compare(best.Price) <= 0)
+
+			if decreased := queue.levelVolume.Sub(levelVolume); decreased.Sign() > 0 {
+				if atTop && decreased.Compare(queue.ahead) > 0 {
+					traded = fixedpoint.Min(decreased.Sub(queue.ahead), unfilled)
+				}
+
+				queue.ahead = fixedpoint.Max(queue.ahead.Sub(decreased), fixedpoint.Zero)
+			}
+
+			queue.ahead = fixedpoint.Min(queue.ahead, levelVolume)
+			queue.levelVolume = levelVolume
+		}
+
+		if traded.Sign() > 0 {
+			trades = append(trades, m.executeFill(&order, fill{Price: order.Price, Quantity: traded}, order.Price, true))
+			updated = append(updated, order)
+		}
+
+		if order.Status == types.OrderStatusFilled {
+			delete(m.queues, order.OrderID)
+			continue
+		}
+
+		remaining = append(remaining, order)
+	}
+
+	return remaining, updated, trades
+}
+
+func (m *DepthPriceMatching) processDepthEvent(event *DepthEvent) {
+	m.CurrentTime = event.Time
+
+	if event.Snapshot {
+		m.book.Load(event.Book())
+	} else {
+		m.book.Update(event.Book())
+	}
+
+	m.matchRestingOrders()
+
+	if bid, ok := m.book.BestBid(); ok {
+		if ask, ok := m.book.BestAsk(); ok {
+			m.LastPrice = bid.Price.Add(ask.Price).Div(fixedpoint.NewFromInt(2))
+		}
+	}
+
+	m.triggerStopOrders()
+}
+
+// Open opens the recorded depth data file, the backtest should not start if the file can not be read
+func (m *DepthPriceMatching) Open() error {
+	if m.reader != nil {
+		return nil
+	}
+
+	reader, err := NewDepthReader(m.DataFile)
+	if err != nil {
+		return errors.Wrapf(err, "can not open the depth data file %s", m.DataFile)
+	}
+
+	m.reader = reader
+	return nil
+}
+
+// replay processes the recorded depth events until the given time
+func (m *DepthPriceMatching) replay(until time.Time) {
+	if m.reader == nil {
+		return
+	}
+
+	for {
+		event, ok := m.reader.Peek()
+		if !ok {
+			if err := m.reader.Err(); err != nil {
+				log.WithError(err).Errorf("depth data read error")
+			}
+			return
+		}
+
+		if event.Time.After(until) {
+			return
+		}
+
+		m.reader.Next()
+		m.processDepthEvent(event)
+	}
+}
+
+func (m *DepthPriceMatching) processKLine(kline types.KLine) {
+	m.replay(kline.EndTime.Time())
+
+	m.CurrentTime = kline.EndTime.Time()
+	m.LastKLine = kline
+
+	// when no depth is recorded, the orders are matched by the kline
+	if !m.hasDepth() {
+		m.SimplePriceMatching.processKLine(kline)
+	}
+}
+
+func (m *DepthPriceMatching) Close() error {
+	if m.reader != nil {
+		return m.reader.Close()
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package backtest
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func newTestDepthMatching() *DepthPriceMatching {
+	return NewDepthPriceMatching(newTestSimpleMatching(), "")
+}
+
+func pv(price, volume float64) types.PriceVolume {
+	return types.PriceVolume{Price: fixedpoint.NewFromFloat(price), Volume: fixedpoint.NewFromFloat(volume)}
+}
+
+func TestDepthPriceMatching_MarketOrderSlippage(t *testing.T) {
+	engine := newTestDepthMatching()
+	engine.processDepthEvent(&DepthEvent{
+		Time:     time.Now(),
+		Symbol:   "BTCUSDT",
+		Snapshot: true,
+		Bids:     types.PriceVolumeSlice{pv(9999, 1), pv(9998, 1)},
+		Asks:     types.PriceVolumeSlice{pv(10000, 1), pv(10001, 1), pv(10002, 1)},
+	})
+
+	order, trades, err := engine.PlaceOrder(types.SubmitOrder{
+		Symbol:   "BTCUSDT",
+		Side:     types.SideTypeBuy,
+		Type:     types.OrderTypeMarket,
+		Quantity: fixedpoint.NewFromFloat(2.5),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, trades, 3)
+	assert.Equal(t, types.OrderStatusFilled, order.Status)
+	assert.InDelta(t, 10000.8, order.Price.Float64(), 1e-6)
+
+	// the consumed depth is removed from the book
+	ask, ok := engine.book.BestAsk()
+	assert.True(t, ok)
+	assert.Equal(t, "10002", ask.Price.String())
+	assert.Equal(t, "0.5", ask.Volume.String())
+
+	// not enough depth, the rest of the order is canceled
+	order, trades, err = engine.PlaceOrder(types.SubmitOrder{
+		Symbol:   "BTCUSDT",
+		Side:     types.SideTypeSell,
+		Type:     types.OrderTypeMarket,
+		Quantity: fixedpoint.NewFromFloat(3.0),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, trades, 2)
+	assert.Equal(t, types.OrderStatusCanceled, order.Status)
+	assert.Equal(t, "2", order.ExecutedQuantity.String())
+}
+
+func TestDepthPriceMatching_LimitOrderQueue(t *testing.T) {
+	engine := newTestDepthMatching()
+	engine.processDepthEvent(&DepthEvent{
+		Time:     time.Now(),
+		Symbol:   "BTCUSDT",
+		Snapshot: true,
+		Bids:     types.PriceVolumeSlice{pv(9999, 2), pv(9998, 1)},
+		Asks:     types.PriceVolumeSlice{pv(10000, 1), pv(10001, 1)},
+	})
+
+	var filledTrades []types.Trade
+	engine.OnTradeUpdate(func(trade types.Trade) {
+		filledTrades = append(filledTrades, trade)
+	})
+
+	order, trades, err := engine.PlaceOrder(newLimitOrder("BTCUSDT", types.SideTypeBuy, 9999, 1.0))
+	assert.NoError(t, err)
+	assert.Len(t, trades, 0)
+	assert.Equal(t, types.OrderStatusNew, order.Status)
+
+	// 1.5 traded from the front of the queue, 2 were queued before us
+	engine.processDepthEvent(&DepthEvent{
+		Time:   time.Now(),
+		Symbol: "BTCUSDT",
+		Bids:   types.PriceVolumeSlice{pv(9999, 0.5)},
+	})
+	assert.Len(t, filledTrades, 0)
+
+	// the level is gone, everyone queued before us is done
+	engine.processDepthEvent(&DepthEvent{
+		Time:   time.Now(),
+		Symbol: "BTCUSDT",
+		Bids:   types.PriceVolumeSlice{pv(9999, 0)},
+	})
+	assert.Len(t, filledTrades, 0)
+
+	// others join the queue behind us, and then 0.5 is traded from the front of the queue
+	engine.processDepthEvent(&DepthEvent{
+		Time:   time.Now(),
+		Symbol: "BTCUSDT",
+		Bids:   types.PriceVolumeSlice{pv(9999, 2)},
+	})
+	engine.processDepthEvent(&DepthEvent{
+		Time:   time.Now(),
+		Symbol: "BTCUSDT",
+		Bids:   types.PriceVolumeSlice{pv(9999, 1.5)},
+	})
+	if assert.Len(t, filledTrades, 1) {
+		assert.Equal(t, "0.5", filledTrades[0].Quantity.String())
+		assert.True(t, filledTrades[0].IsMaker)
+	}
+
+	// the ask side trades through our price
+	engine.processDepthEvent(&DepthEvent{
+		Time:   time.Now(),
+		Symbol: "BTCUSDT",
+		Asks:   types.PriceVolumeSlice{pv(9999, 3)},
+	})
+	if assert.Len(t, filledTrades, 2) {
+		assert.Equal(t, "0.5", filledTrades[1].Quantity.String())
+		assert.Equal(t, "9999", filledTrades[1].Price.String())
+	}
+	assert.Len(t, engine.bidOrders, 0)
+}
+
+func TestDepthEvent_JSON(t *testing.T) {
+	event := DepthEvent{
+		Symbol:   "BTCUSDT",
+		Snapshot: true,
+		Bids:     types.PriceVolumeSlice{pv(9999, 2)},
+		Asks:     types.PriceVolumeSlice{pv(10000, 1)},
+	}
+
+	out, err := json.Marshal(event)
+	assert.NoError(t, err)
+
+	var event2 DepthEvent
+	assert.NoError(t, json.Unmarshal(out, &event2))
+	assert.Equal(t, event.Bids, event2.Bids)
+	assert.Equal(t, event.Asks, event2.Asks)
+}
+
+func TestDepthPriceMatching_StopMarketOrder(t *testing.T) {
+	engine := newTestDepthMatching()
+	engine.processDepthEvent(&DepthEvent{
+		Time:     time.Now(),
+		Symbol:   "BTCUSDT",
+		Snapshot: true,
+		Bids:     types.PriceVolumeSlice{pv(9999, 1), pv(9998, 1)},
+		Asks:     types.PriceVolumeSlice{pv(10000, 1), pv(10001, 1)},
+	})
+
+	order, trades, err := engine.PlaceOrder(types.SubmitOrder{
+		Symbol:    "BTCUSDT",
+		Side:      types.SideTypeBuy,
+		Type:      types.OrderTypeStopMarket,
+		Quantity:  fixedpoint.NewFromFloat(1.5),
+		StopPrice: fixedpoint.NewFromFloat(10100),
+	})
+	if assert.NoError(t, err) {
+		assert.Len(t, trades, 0)
+		assert.False(t, order.IsWorking)
+	}
+
+	var filled []types.Trade
+	engine.OnTradeUpdate(func(trade types.Trade) {
+		filled = append(filled, trade)
+	})
+
+	// the mid price rises over the stop price, and the triggered order sweeps the asks
+	engine.processDepthEvent(&DepthEvent{
+		Time:     time.Now(),
+		Symbol:   "BTCUSDT",
+		Snapshot: true,
+		Bids:     types.PriceVolumeSlice{pv(10199, 1)},
+		Asks:     types.PriceVolumeSlice{pv(10200, 1), pv(10210, 1)},
+	})
+
+	if assert.Len(t, filled, 2) {
+		assert.Equal(t, "10200", filled[0].Price.String())
+		assert.Equal(t, "10210", filled[1].Price.String())
+		assert.Equal(t, "0.5", filled[1].Quantity.String())
+	}
+
+	assert.Len(t, engine.bidOrders, 0)
+	usdt, _ := engine.Account.Balance("USDT")
+	assert.Equal(t, "0", usdt.Locked.String())
+	assert.Equal(t, "84695", usdt.Available.String())
+}
+
+func TestDepthPriceMatching_TimeInForce(t *testing.T) {
+	engine := newTestDepthMatching()
+	engine.processDepthEvent(&DepthEvent{
+		Time:     time.Now(),
+		Symbol:   "BTCUSDT",
+		Snapshot: true,
+		Bids:     types.PriceVolumeSlice{pv(9999, 1)},
+		Asks:     types.PriceVolumeSlice{pv(10000, 1), pv(10001, 1), pv(10005, 1)},
+	})
+
+	postOnly := newLimitOrder("BTCUSDT", types.SideTypeBuy, 10000, 1)
+	postOnly.Type = types.OrderTypeLimitMaker
+	_, _, err := engine.PlaceOrder(postOnly)
+	assert.Error(t, err)
+
+	// only 2 BTC are available under 10001
+	fok := newLimitOrder("BTCUSDT", types.SideTypeBuy, 10001, 2.5)
+	fok.TimeInForce = types.TimeInForceFOK
+	_, _, err = engine.PlaceOrder(fok)
+	assert.Error(t, err)
+
+	ioc := newLimitOrder("BTCUSDT", types.SideTypeBuy, 10001, 2.5)
+	ioc.TimeInForce = types.TimeInForceIOC
+	order, trades, err := engine.PlaceOrder(ioc)
+	if assert.NoError(t, err) {
+		assert.Len(t, trades, 2)
+		assert.Equal(t, types.OrderStatusCanceled, order.Status)
+		assert.Equal(t, "2", order.ExecutedQuantity.String())
+	}
+	assert.Len(t, engine.bidOrders, 0)
+
+	usdt, _ := engine.Account.Balance("USDT")
+	assert.Equal(t, "0", usdt.Locked.String())
+	assert.Equal(t, "79999", usdt.Available.String())
+}
+
+func TestDepthPriceMatching_FeeAccounting(t *testing.T) {
+	feeRate := fixedpoint.NewFromFloat(0.001)
+
+	simple := newTestSimpleMatching()
+	simple.Account.MakerFeeRate, simple.Account.TakerFeeRate = feeRate, feeRate
+
+	depth := newTestDepthMatching()
+	depth.Account.MakerFeeRate, depth.Account.TakerFeeRate = feeRate, feeRate
+	depth.Account.UpdateBalances(simple.Account.Balances())
+	depth.processDepthEvent(&DepthEvent{
+		Time:     time.Now(),
+		Symbol:   "BTCUSDT",
+		Snapshot: true,
+		Bids:     types.PriceVolumeSlice{pv(10000, 5)},
+		Asks:     types.PriceVolumeSlice{pv(10000, 5)},
+	})
+
+	for _, side := range []types.SideType{types.SideTypeBuy, types.SideTypeSell} {
+		_, _, err := simple.PlaceOrder(newLimitOrder("BTCUSDT", side, 10000, 1.0))
+		assert.NoError(t, err)
+
+		_, trades, err := depth.PlaceOrder(newLimitOrder("BTCUSDT", side, 10000, 1.0))
+		assert.NoError(t, err)
+		assert.Len(t, trades, 1)
+	}
+
+	_, trades := simple.SellToPrice(fixedpoint.NewFromFloat(10000))
+	assert.Len(t, trades, 1)
+	_, trades = simple.BuyToPrice(fixedpoint.NewFromFloat(10000))
+	assert.Len(t, trades, 1)
+
+	// the buyer pays 0.001 BTC and the seller pays 10 USDT in both engines
+	for _, currency := range []string{"BTC", "USDT"} {
+		expected, _ := simple.Account.Balance(currency)
+		actual, _ := depth.Account.Balance(currency)
+		assert.Equal(t, expected.Total().String(), actual.Total().String(), currency)
+	}
+
+	btc, _ := depth.Account.Balance("BTC")
+	assert.Equal(t, "9.999", btc.Total().String())
+
+	usdt, _ := depth.Account.Balance("USDT")
+	assert.Equal(t, "99990", usdt.Total().String())
+}
+
+func TestDepthPriceMatching_Open(t *testing.T) {
+	engine := newTestDepthMatching()
+	engine.DataFile = filepath.Join(t.TempDir(), "BTCUSDT.jsonl")
+	assert.Error(t, engine.Open())
+}
@@ -100,3 +100,201 @@ func TestSimplePriceMatching_LimitOrder(t *testing.T) {
 	assert.Len(t, closedOrders, 4)
 	assert.Len(t, trades, 4)
 }
+
+func newStopOrder(orderType types.OrderType, side types.SideType, stopPrice, price, quantity float64) types.SubmitOrder {
+	return types.SubmitOrder{
+		Symbol:      "BTCUSDT",
+		Side:        side,
+		Type:        orderType,
+		Quantity:    fixedpoint.NewFromFloat(quantity),
+		Price:       fixedpoint.NewFromFloat(price),
+		StopPrice:   fixedpoint.NewFromFloat(stopPrice),
+		TimeInForce: types.TimeInForceGTC,
+	}
+}
+
+func newTestSimpleMatching() *SimplePriceMatching {
+	account := &types.Account{
+		MakerFeeRate: fixedpoint.Zero,
+		TakerFeeRate: fixedpoint.Zero,
+	}
+
+	account.UpdateBalances(types.BalanceMap{
+		"USDT": {Currency: "USDT", Available: fixedpoint.NewFromFloat(100000.0)},
+		"BTC":  {Currency: "BTC", Available: fixedpoint.NewFromFloat(10.0)},
+	})
+
+	return &SimplePriceMatching{
+		CurrentTime: time.Now(),
+		Account:     account,
+		Market: types.Market{
+			Symbol:          "BTCUSDT",
+			PricePrecision:  8,
+			VolumePrecision: 8,
+			QuoteCurrency:   "USDT",
+			BaseCurrency:    "BTC",
+			MinNotional:     fixedpoint.MustNewFromString("0.001"),
+			MinAmount:       fixedpoint.MustNewFromString("10.0"),
+			MinQuantity:     fixedpoint.MustNewFromString("0.001"),
+		},
+		LastPrice: fixedpoint.NewFromFloat(10000.0),
+		LastKLine: types.KLine{
+			High: fixedpoint.NewFromFloat(20000.0),
+			Low:  fixedpoint.NewFromFloat(5000.0),
+		},
+	}
+}
+
+func TestSimplePriceMatching_StopMarketOrder(t *testing.T) {
+	engine := newTestSimpleMatching()
+
+	var updates []types.Order
+	engine.OnOrderUpdate(func(order types.Order) {
+		updates = append(updates, order)
+	})
+
+	// the stop order that would be triggered immediately is rejected
+	_, _, err := engine.PlaceOrder(newStopOrder(types.OrderTypeStopMarket, types.SideTypeSell, 10100.0, 0, 1.0))
+	assert.Error(t, err)
+
+	order, _, err := engine.PlaceOrder(newStopOrder(types.OrderTypeStopMarket, types.SideTypeSell, 9500.0, 0, 1.0))
+	if assert.NoError(t, err) {
+		assert.Equal(t, types.OrderStatusNew, order.Status)
+		assert.False(t, order.IsWorking)
+	}
+
+	// the sell stop order is not triggered by the rising price
+	closedOrders, trades := engine.BuyToPrice(fixedpoint.NewFromFloat(10500.0))
+	assert.Len(t, closedOrders, 0)
+	assert.Len(t, trades, 0)
+
+	closedOrders, trades = engine.SellToPrice(fixedpoint.NewFromFloat(9600.0))
+	assert.Len(t, closedOrders, 0)
+	assert.Len(t, trades, 0)
+
+	closedOrders, trades = engine.SellToPrice(fixedpoint.NewFromFloat(9400.0))
+	if assert.Len(t, closedOrders, 1) && assert.Len(t, trades, 1) {
+		assert.Equal(t, types.OrderTypeStopMarket, closedOrders[0].Type)
+		assert.Equal(t, types.OrderStatusFilled, closedOrders[0].Status)
+		assert.Equal(t, "9500", trades[0].Price.String())
+		assert.False(t, trades[0].IsMaker)
+	}
+
+	// new -> triggered (working) -> filled
+	if assert.Len(t, updates, 3) {
+		assert.False(t, updates[0].IsWorking)
+		assert.True(t, updates[1].IsWorking)
+		assert.Equal(t, types.OrderStatusNew, updates[1].Status)
+		assert.Equal(t, types.OrderStatusFilled, updates[2].Status)
+	}
+
+	usdt, _ := engine.Account.Balance("USDT")
+	assert.Equal(t, "109500", usdt.Available.String())
+	btc, _ := engine.Account.Balance("BTC")
+	assert.Equal(t, "9", btc.Available.String())
+	assert.Equal(t, "0", btc.Locked.String())
+}
+
+func TestSimplePriceMatching_StopLimitOrder(t *testing.T) {
+	engine := newTestSimpleMatching()
+
+	// buy stop limit order, triggered at 10500, buy at 10400
+	order, _, err := engine.PlaceOrder(newStopOrder(types.OrderTypeStopLimit, types.SideTypeBuy, 10500.0, 10400.0, 1.0))
+	if assert.NoError(t, err) {
+		assert.False(t, order.IsWorking)
+	}
+
+	// the untriggered stop limit order is not filled as a limit order
+	closedOrders, _ := engine.SellToPrice(fixedpoint.NewFromFloat(9000.0))
+	assert.Len(t, closedOrders, 0)
+
+	// triggered, but the price is higher than the limit price, the order rests in the book
+	closedOrders, _ = engine.BuyToPrice(fixedpoint.NewFromFloat(10600.0))
+	assert.Len(t, closedOrders, 0)
+	if assert.Len(t, engine.bidOrders, 1) {
+		assert.True(t, engine.bidOrders[0].IsWorking)
+	}
+
+	closedOrders, trades := engine.SellToPrice(fixedpoint.NewFromFloat(10400.0))
+	if assert.Len(t, closedOrders, 1) && assert.Len(t, trades, 1) {
+		assert.Equal(t, "10400", trades[0].Price.String())
+		assert.True(t, trades[0].IsMaker)
+	}
+
+	// the canceled stop order unlocks the balance
+	order, _, err = engine.PlaceOrder(newStopOrder(types.OrderTypeStopMarket, types.SideTypeBuy, 11000.0, 0, 1.0))
+	if assert.NoError(t, err) {
+		usdt, _ := engine.Account.Balance("USDT")
+		assert.Equal(t, "11000", usdt.Locked.String())
+
+		_, err = engine.CancelOrder(*order)
+		assert.NoError(t, err)
+
+		usdt, _ = engine.Account.Balance("USDT")
+		assert.Equal(t, "0", usdt.Locked.String())
+		assert.Equal(t, "89600", usdt.Available.String())
+	}
+}
+
+func TestSimplePriceMatching_TimeInForce(t *testing.T) {
+	engine := newTestSimpleMatching()
+
+	// post-only order crossing the last price is rejected
+	postOnly := newLimitOrder("BTCUSDT", types.SideTypeBuy, 10100.0, 1.0)
+	postOnly.Type = types.OrderTypeLimitMaker
+	_, _, err := engine.PlaceOrder(postOnly)
+	assert.Error(t, err)
+
+	// FOK order that can not be filled is rejected
+	fok := newLimitOrder("BTCUSDT", types.SideTypeBuy, 9900.0, 1.0)
+	fok.TimeInForce = types.TimeInForceFOK
+	_, _, err = engine.PlaceOrder(fok)
+	assert.Error(t, err)
+
+	// IOC order that can not be filled is canceled
+	ioc := newLimitOrder("BTCUSDT", types.SideTypeBuy, 9900.0, 1.0)
+	ioc.TimeInForce = types.TimeInForceIOC
+	order, trade, err := engine.PlaceOrder(ioc)
+	if assert.NoError(t, err) {
+		assert.Nil(t, trade)
+		assert.Equal(t, types.OrderStatusCanceled, order.Status)
+	}
+	assert.Len(t, engine.bidOrders, 0)
+
+	// marketable IOC order is filled by the last price
+	ioc.Price = fixedpoint.NewFromFloat(10100.0)
+	order, trade, err = engine.PlaceOrder(ioc)
+	if assert.NoError(t, err) && assert.NotNil(t, trade) {
+		assert.Equal(t, types.OrderStatusFilled, order.Status)
+		assert.Equal(t, "10000", trade.Price.String())
+	}
+
+	usdt, _ := engine.Account.Balance("USDT")
+	assert.Equal(t, "0", usdt.Locked.String())
+	assert.Equal(t, "90000", usdt.Available.String())
+}
+
+func TestSimplePriceMatching_BuyerFee(t *testing.T) {
+	engine := newTestSimpleMatching()
+	engine.Account.MakerFeeRate = fixedpoint.NewFromFloat(0.001)
+	engine.Account.TakerFeeRate = fixedpoint.NewFromFloat(0.001)
+
+	// the market buy order is filled at the last price 10000
+	_, trade, err := engine.PlaceOrder(types.SubmitOrder{
+		Symbol:   "BTCUSDT",
+		Side:     types.SideTypeBuy,
+		Type:     types.OrderTypeMarket,
+		Quantity: fixedpoint.NewFromFloat(2.0),
+	})
+	if assert.NoError(t, err) && assert.NotNil(t, trade) {
+		assert.Equal(t, "BTC", trade.FeeCurrency)
+		assert.Equal(t, "0.002", trade.Fee.String())
+	}
+
+	// the fee is deducted from the bought base asset as it is, it's not divided by the price again
+	btc, _ := engine.Account.Balance("BTC")
+	assert.Equal(t, "11.998", btc.Available.String())
+
+	usdt, _ := engine.Account.Balance("USDT")
+	assert.Equal(t, "80000", usdt.Available.String())
+}
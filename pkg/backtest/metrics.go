@@ -0,0 +1,412 @@
+package backtest
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const year = 365 * 24 * time.Hour
+
+// EquityPoint is the account equity valued at the given time
+type EquityPoint struct {
+	Time   time.Time        `json:"time"`
+	Equity fixedpoint.Value `json:"equity"`
+}
+
+// EquityRecorder records the equity curves of the back-test, the curves are keyed by the session name
+// or the session symbol key (see SymbolKey)
+type EquityRecorder struct {
+	mu     sync.Mutex
+	curves map[string][]EquityPoint
+}
+
+func NewEquityRecorder() *EquityRecorder {
+	return &EquityRecorder{
+		curves: make(map[string][]EquityPoint),
+	}
+}
+
+// SymbolKey returns the equity curve key of the symbol in the session
+func SymbolKey(session, symbol string) string {
+	return session + ":" + symbol
+}
+
+// Record appends the equity point to the curve, the last point is replaced if it's at the same time
+func (r *EquityRecorder) Record(key string, t time.Time, equity fixedpoint.Value) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	curve := r.curves[key]
+	if n := len(curve); n > 0 && !t.After(curve[n-1].Time) {
+		curve[n-1].Equity = equity
+		return
+	}
+
+	r.curves[key] = append(curve, EquityPoint{Time: t, Equity: equity})
+}
+
+// RecordSymbol records the net balances and the unrealized profit of the futures position of the symbol in its
+// quote currency by the given price
+func (r *EquityRecorder) RecordSymbol(session string, market types.Market, balances types.BalanceMap, positions types.FuturesPositionMap, price fixedpoint.Value, t time.Time) {
+	r.Record(SymbolKey(session, market.Symbol), t, inQuoteAsset(balances, positions, market, price))
+}
+
+// Curve returns the copied equity curve of the key
+func (r *EquityRecorder) Curve(key string) []EquityPoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]EquityPoint(nil), r.curves[key]...)
+}
+
+// MergeEquityCurves sums the equity curves by time, the curve that has no point at the time
+// contributes its previous equity.
+func MergeEquityCurves(curves ...[]EquityPoint) []EquityPoint {
+	var times []time.Time
+	seen := map[time.Time]struct{}{}
+	for _, curve := range curves {
+		for _, p := range curve {
+			if _, ok := seen[p.Time]; !ok {
+				seen[p.Time] = struct{}{}
+				times = append(times, p.Time)
+			}
+		}
+	}
+
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+
+	indexes := make([]int, len(curves))
+	merged := make([]EquityPoint, 0, len(times))
+	for _, t := range times {
+		equity := fixedpoint.Zero
+		for i, curve := range curves {
+			for indexes[i] < len(curve) && !curve[indexes[i]].Time.After(t) {
+				indexes[i]++
+			}
+
+			if indexes[i] > 0 {
+				equity = equity.Add(curve[indexes[i]-1].Equity)
+			}
+		}
+
+		merged = append(merged, EquityPoint{Time: t, Equity: equity})
+	}
+
+	return merged
+}
+
+// PerformanceMetrics is the statistics of the back-test calculated from the trades and the equity curve
+type PerformanceMetrics struct {
+	// MaxDrawdown is the largest peak-to-trough decline of the equity
+	MaxDrawdown        fixedpoint.Value `json:"maxDrawdown"`
+	MaxDrawdownPercent fixedpoint.Value `json:"maxDrawdownPercent"`
+
+	// AnnualizedReturn is the compound annual growth rate of the equity
+	AnnualizedReturn fixedpoint.Value `json:"annualizedReturn"`
+
+	// SharpeRatio and SortinoRatio are annualized from the returns between the equity points, the risk-free rate is zero
+	SharpeRatio  fixedpoint.Value `json:"sharpeRatio"`
+	SortinoRatio fixedpoint.Value `json:"sortinoRatio"`
+
+	// CalmarRatio is the annualized return divided by the max drawdown percent
+	CalmarRatio fixedpoint.Value `json:"calmarRatio"`
+
+	NumOfTrades         int              `json:"numOfTrades"`
+	NumOfWinningTrades  int              `json:"numOfWinningTrades"`
+	NumOfLosingTrades   int              `json:"numOfLosingTrades"`
+	WinRate             fixedpoint.Value `json:"winRate"`
+	GrossProfit         fixedpoint.Value `json:"grossProfit"`
+	GrossLoss           fixedpoint.Value `json:"grossLoss"`
+	ProfitFactor        fixedpoint.Value `json:"profitFactor"`
+	AverageWin          fixedpoint.Value `json:"averageWin"`
+	AverageLoss         fixedpoint.Value `json:"averageLoss"`
+	LongestLosingStreak int              `json:"longestLosingStreak"`
+
+	// ExposureTime is the ratio of the back-test time that holds a position
+	ExposureTime fixedpoint.Value `json:"exposureTime"`
+
+	// TradingVolume is the traded quote volume, and Turnover is the trading volume divided by the initial equity
+	TradingVolume fixedpoint.Value `json:"tradingVolume"`
+	Turnover      fixedpoint.Value `json:"turnover"`
+}
+
+type tradeResult struct {
+	time   time.Time
+	profit fixedpoint.Value
+}
+
+type timeRange struct {
+	from, to time.Time
+}
+
+// MetricsCalculator calculates the performance metrics of the back-test
+type MetricsCalculator struct {
+	StartTime time.Time
+	EndTime   time.Time
+
+	results       []tradeResult
+	exposures     []timeRange
+	numOfTrades   int
+	tradingVolume fixedpoint.Value
+}
+
+// AddTrades replays the trades of the market with the average cost position, the trades that reduce
+// the position are counted as the winning or the losing trades.
+func (c *MetricsCalculator) AddTrades(market types.Market, trades []types.Trade) {
+	trades = append([]types.Trade(nil), trades...)
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Time.Time().Before(trades[j].Time.Time())
+	})
+
+	position := types.NewPositionFromMarket(market)
+
+	var openTime time.Time
+	var exposed = false
+	for _, trade := range trades {
+		c.numOfTrades++
+		c.tradingVolume = c.tradingVolume.Add(trade.QuoteQuantity)
+
+		if profit, _, madeProfit := position.AddTrade(trade); madeProfit {
+			c.results = append(c.results, tradeResult{time: trade.Time.Time(), profit: profit})
+		}
+
+		flat := market.IsDustQuantity(position.GetBase().Abs(), trade.Price)
+		if !exposed && !flat {
+			exposed = true
+			openTime = trade.Time.Time()
+		} else if exposed && flat {
+			exposed = false
+			c.exposures = append(c.exposures, timeRange{from: openTime, to: trade.Time.Time()})
+		}
+	}
+
+	if exposed {
+		c.exposures = append(c.exposures, timeRange{from: openTime, to: c.EndTime})
+	}
+}
+
+// Calculate calculates the metrics from the added trades and the given equity curve
+func (c *MetricsCalculator) Calculate(equityCurve []EquityPoint) *PerformanceMetrics {
+	metrics := &PerformanceMetrics{
+		NumOfTrades:   c.numOfTrades,
+		TradingVolume: c.tradingVolume,
+	}
+
+	c.calculateTradeMetrics(metrics)
+	c.calculateEquityMetrics(metrics, equityCurve)
+
+	if total := c.EndTime.Sub(c.StartTime); total > 0 {
+		metrics.ExposureTime = fixedpoint.NewFromFloat(float64(exposureDuration(c.exposures)) / float64(total))
+	}
+
+	return metrics
+}
+
+func (c *MetricsCalculator) calculateTradeMetrics(metrics *PerformanceMetrics) {
+	results := append([]tradeResult(nil), c.results...)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].time.Before(results[j].time)
+	})
+
+	streak := 0
+	for _, r := range results {
+		switch r.profit.Sign() {
+		case 1:
+			metrics.NumOfWinningTrades++
+			metrics.GrossProfit = metrics.GrossProfit.Add(r.profit)
+			streak = 0
+
+		case -1:
+			metrics.NumOfLosingTrades++
+			metrics.GrossLoss = metrics.GrossLoss.Add(r.profit.Neg())
+			streak++
+			if streak > metrics.LongestLosingStreak {
+				metrics.LongestLosingStreak = streak
+			}
+		}
+	}
+
+	if n := metrics.NumOfWinningTrades + metrics.NumOfLosingTrades; n > 0 {
+		metrics.WinRate = fixedpoint.NewFromInt(int64(metrics.NumOfWinningTrades)).Div(fixedpoint.NewFromInt(int64(n)))
+	}
+
+	if metrics.NumOfWinningTrades > 0 {
+		metrics.AverageWin = metrics.GrossProfit.Div(fixedpoint.NewFromInt(int64(metrics.NumOfWinningTrades)))
+	}
+
+	if metrics.NumOfLosingTrades > 0 {
+		metrics.AverageLoss = metrics.GrossLoss.Div(fixedpoint.NewFromInt(int64(metrics.NumOfLosingTrades)))
+	}
+
+	if metrics.GrossLoss.Sign() > 0 {
+		metrics.ProfitFactor = metrics.GrossProfit.Div(metrics.GrossLoss)
+	}
+}
+
+func (c *MetricsCalculator) calculateEquityMetrics(metrics *PerformanceMetrics, curve []EquityPoint) {
+	if len(curve) == 0 {
+		return
+	}
+
+	initial := curve[0].Equity
+	if initial.Sign() > 0 {
+		metrics.Turnover = c.tradingVolume.Div(initial)
+	}
+
+	peak := curve[0].Equity
+	for _, p := range curve {
+		if p.Equity.Compare(peak) > 0 {
+			peak = p.Equity
+			continue
+		}
+
+		drawdown := peak.Sub(p.Equity)
+		if drawdown.Compare(metrics.MaxDrawdown) > 0 {
+			metrics.MaxDrawdown = drawdown
+		}
+
+		if peak.Sign() > 0 {
+			metrics.MaxDrawdownPercent = fixedpoint.Max(metrics.MaxDrawdownPercent, drawdown.Div(peak))
+		}
+	}
+
+	if len(curve) < 2 || initial.Sign() <= 0 {
+		return
+	}
+
+	span := curve[len(curve)-1].Time.Sub(curve[0].Time)
+	if span <= 0 {
+		return
+	}
+
+	final := curve[len(curve)-1].Equity
+	if final.Sign() > 0 {
+		annualized := math.Pow(final.Float64()/initial.Float64(), float64(year)/float64(span)) - 1
+		if !math.IsInf(annualized, 0) && !math.IsNaN(annualized) {
+			metrics.AnnualizedReturn = fixedpoint.NewFromFloat(annualized)
+		}
+	}
+
+	if metrics.MaxDrawdownPercent.Sign() > 0 {
+		metrics.CalmarRatio = metrics.AnnualizedReturn.Div(metrics.MaxDrawdownPercent)
+	}
+
+	var returns []float64
+	for i := 1; i < len(curve); i++ {
+		prev := curve[i-1].Equity.Float64()
+		if prev <= 0 {
+			continue
+		}
+		returns = append(returns, curve[i].Equity.Float64()/prev-1)
+	}
+
+	// the returns are annualized by the average interval of the equity points
+	periodsPerYear := float64(year) / (float64(span) / float64(len(curve)-1))
+	metrics.SharpeRatio = fixedpoint.NewFromFloat(sharpeRatio(returns, periodsPerYear))
+	metrics.SortinoRatio = fixedpoint.NewFromFloat(sortinoRatio(returns, periodsPerYear))
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func sharpeRatio(returns []float64, periodsPerYear float64) float64 {
+	if len(returns) < 2 {
+		return 0
+	}
+
+	avg := mean(returns)
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - avg) * (r - avg)
+	}
+
+	stdDev := math.Sqrt(variance / float64(len(returns)-1))
+	if stdDev == 0 {
+		return 0
+	}
+
+	return avg / stdDev * math.Sqrt(periodsPerYear)
+}
+
+func sortinoRatio(returns []float64, periodsPerYear float64) float64 {
+	if len(returns) == 0 {
+		return 0
+	}
+
+	downside := 0.0
+	for _, r := range returns {
+		if r < 0 {
+			downside += r * r
+		}
+	}
+
+	downsideDev := math.Sqrt(downside / float64(len(returns)))
+	if downsideDev == 0 {
+		return 0
+	}
+
+	return mean(returns) / downsideDev * math.Sqrt(periodsPerYear)
+}
+
+// exposureDuration returns the total duration of the union of the time ranges
+func exposureDuration(ranges []timeRange) time.Duration {
+	ranges = append([]timeRange(nil), ranges...)
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].from.Before(ranges[j].from)
+	})
+
+	var total time.Duration
+	var cur *timeRange
+	for i := range ranges {
+		r := ranges[i]
+		if cur != nil && !r.from.After(cur.to) {
+			if r.to.After(cur.to) {
+				cur.to = r.to
+			}
+			continue
+		}
+
+		if cur != nil {
+			total += cur.to.Sub(cur.from)
+		}
+		cur = &r
+	}
+
+	if cur != nil {
+		total += cur.to.Sub(cur.from)
+	}
+
+	return total
+}
+
+func (m *PerformanceMetrics) Print(market types.Market) {
+	color.Green("MAX DRAWDOWN: %s %s (%s)", market.FormatQuantity(m.MaxDrawdown), market.QuoteCurrency, m.MaxDrawdownPercent.FormatPercentage(2))
+	color.Green("ANNUALIZED RETURN: %s", m.AnnualizedReturn.FormatPercentage(2))
+	color.Green("SHARPE RATIO: %s", m.SharpeRatio.FormatString(4))
+	color.Green("SORTINO RATIO: %s", m.SortinoRatio.FormatString(4))
+	color.Green("CALMAR RATIO: %s", m.CalmarRatio.FormatString(4))
+	color.Green("TRADES: %d (WIN %d / LOSS %d, WIN RATE %s)", m.NumOfTrades, m.NumOfWinningTrades, m.NumOfLosingTrades, m.WinRate.FormatPercentage(2))
+	color.Green("PROFIT FACTOR: %s", m.ProfitFactor.FormatString(4))
+	color.Green("AVERAGE WIN: %s %s, AVERAGE LOSS: %s %s", m.AverageWin.FormatString(4), market.QuoteCurrency, m.AverageLoss.FormatString(4), market.QuoteCurrency)
+	color.Green("LONGEST LOSING STREAK: %d", m.LongestLosingStreak)
+	color.Green("EXPOSURE TIME: %s", m.ExposureTime.FormatPercentage(2))
+	color.Green("TURNOVER: %s (VOLUME %s %s)", m.Turnover.FormatString(4), m.TradingVolume.FormatString(2), market.QuoteCurrency)
+}
@@ -0,0 +1,140 @@
+package backtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func newMetricsTrade(t time.Time, side types.SideType, price, quantity float64) types.Trade {
+	return types.Trade{
+		Symbol:        "BTCUSDT",
+		Side:          side,
+		IsBuyer:       side == types.SideTypeBuy,
+		Price:         fixedpoint.NewFromFloat(price),
+		Quantity:      fixedpoint.NewFromFloat(quantity),
+		QuoteQuantity: fixedpoint.NewFromFloat(price * quantity),
+		FeeCurrency:   "USDT",
+		Time:          types.Time(t),
+	}
+}
+
+func TestMetricsCalculator_Trades(t *testing.T) {
+	market := types.Market{Symbol: "BTCUSDT", BaseCurrency: "BTC", QuoteCurrency: "USDT"}
+	startTime := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	endTime := startTime.Add(10 * time.Hour)
+
+	calculator := &MetricsCalculator{StartTime: startTime, EndTime: endTime}
+	calculator.AddTrades(market, []types.Trade{
+		newMetricsTrade(startTime, types.SideTypeBuy, 100, 1),
+		newMetricsTrade(startTime.Add(1*time.Hour), types.SideTypeSell, 110, 1), // +10
+		newMetricsTrade(startTime.Add(2*time.Hour), types.SideTypeBuy, 100, 1),
+		newMetricsTrade(startTime.Add(3*time.Hour), types.SideTypeSell, 95, 1), // -5
+		newMetricsTrade(startTime.Add(4*time.Hour), types.SideTypeBuy, 100, 1),
+		newMetricsTrade(startTime.Add(5*time.Hour), types.SideTypeSell, 90, 1), // -10
+		newMetricsTrade(startTime.Add(8*time.Hour), types.SideTypeBuy, 100, 1), // held until the end
+	})
+
+	metrics := calculator.Calculate(nil)
+	assert.Equal(t, 7, metrics.NumOfTrades)
+	assert.Equal(t, 1, metrics.NumOfWinningTrades)
+	assert.Equal(t, 2, metrics.NumOfLosingTrades)
+	assert.Equal(t, 2, metrics.LongestLosingStreak)
+	assert.InDelta(t, 1.0/3.0, metrics.WinRate.Float64(), 1e-6)
+	assert.Equal(t, "10", metrics.GrossProfit.String())
+	assert.Equal(t, "15", metrics.GrossLoss.String())
+	assert.InDelta(t, 10.0/15.0, metrics.ProfitFactor.Float64(), 1e-6)
+	assert.Equal(t, "10", metrics.AverageWin.String())
+	assert.Equal(t, "7.5", metrics.AverageLoss.String())
+	assert.Equal(t, "695", metrics.TradingVolume.String())
+
+	// exposed for 1h + 1h + 1h + 2h
+	assert.InDelta(t, 0.5, metrics.ExposureTime.Float64(), 1e-6)
+}
+
+func TestMetricsCalculator_EquityCurve(t *testing.T) {
+	startTime := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	var curve []EquityPoint
+	for i, equity := range []float64{1000, 1100, 990, 1045, 1210} {
+		curve = append(curve, EquityPoint{
+			Time:   startTime.Add(time.Duration(i) * 24 * time.Hour),
+			Equity: fixedpoint.NewFromFloat(equity),
+		})
+	}
+
+	calculator := &MetricsCalculator{StartTime: startTime, EndTime: startTime.Add(4 * 24 * time.Hour)}
+	calculator.AddTrades(types.Market{Symbol: "BTCUSDT"}, []types.Trade{
+		newMetricsTrade(startTime, types.SideTypeBuy, 100, 10),
+	})
+
+	metrics := calculator.Calculate(curve)
+	assert.Equal(t, "110", metrics.MaxDrawdown.String())
+	assert.InDelta(t, 0.1, metrics.MaxDrawdownPercent.Float64(), 1e-6)
+	assert.InDelta(t, 1.0, metrics.Turnover.Float64(), 1e-6)
+	assert.True(t, metrics.AnnualizedReturn.Sign() > 0)
+	assert.True(t, metrics.SharpeRatio.Sign() > 0)
+	assert.True(t, metrics.SortinoRatio.Compare(metrics.SharpeRatio) > 0)
+	assert.InDelta(t, metrics.AnnualizedReturn.Float64()/0.1, metrics.CalmarRatio.Float64(), 1e-3)
+}
+
+func TestMergeEquityCurves(t *testing.T) {
+	startTime := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	a := []EquityPoint{
+		{Time: startTime, Equity: fixedpoint.NewFromInt(100)},
+		{Time: startTime.Add(2 * time.Hour), Equity: fixedpoint.NewFromInt(120)},
+	}
+	b := []EquityPoint{
+		{Time: startTime.Add(time.Hour), Equity: fixedpoint.NewFromInt(50)},
+		{Time: startTime.Add(2 * time.Hour), Equity: fixedpoint.NewFromInt(40)},
+	}
+
+	merged := MergeEquityCurves(a, b)
+	if assert.Len(t, merged, 3) {
+		assert.Equal(t, "100", merged[0].Equity.String())
+		assert.Equal(t, "150", merged[1].Equity.String())
+		assert.Equal(t, "160", merged[2].Equity.String())
+	}
+}
+
+func TestEquityRecorder_RecordSymbol(t *testing.T) {
+	market := types.Market{Symbol: "BTCUSDT", BaseCurrency: "BTC", QuoteCurrency: "USDT"}
+	startTime := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	recorder := NewEquityRecorder()
+
+	// margin account: borrowed 1 BTC and sold it, the debt and the interest are deducted
+	marginBalances := types.BalanceMap{
+		"USDT": {Currency: "USDT", Available: fixedpoint.NewFromInt(20000)},
+		"BTC":  {Currency: "BTC", Borrowed: fixedpoint.NewFromInt(1), Interest: fixedpoint.NewFromFloat(0.01)},
+	}
+	recorder.RecordSymbol("margin", market, marginBalances, nil, fixedpoint.NewFromInt(10000), startTime)
+	recorder.RecordSymbol("margin", market, marginBalances, nil, fixedpoint.NewFromInt(12000), startTime.Add(time.Hour))
+
+	curve := recorder.Curve(SymbolKey("margin", "BTCUSDT"))
+	if assert.Len(t, curve, 2) {
+		assert.Equal(t, "9900", curve[0].Equity.String())
+		assert.Equal(t, "7880", curve[1].Equity.String())
+	}
+
+	// futures account: 1000 USDT is locked as the margin of the 1 BTC long position opened at 10000
+	futuresBalances := types.BalanceMap{
+		"USDT": {Currency: "USDT", Available: fixedpoint.NewFromInt(9000), Locked: fixedpoint.NewFromInt(1000)},
+	}
+	positions := types.FuturesPositionMap{
+		"BTCUSDT": {
+			Symbol:      "BTCUSDT",
+			Base:        fixedpoint.NewFromInt(1),
+			Quote:       fixedpoint.NewFromInt(-10000),
+			AverageCost: fixedpoint.NewFromInt(10000),
+		},
+	}
+	recorder.RecordSymbol("futures", market, futuresBalances, positions, fixedpoint.NewFromInt(9500), startTime)
+
+	curve = recorder.Curve(SymbolKey("futures", "BTCUSDT"))
+	if assert.Len(t, curve, 1) {
+		assert.Equal(t, "9500", curve[0].Equity.String())
+	}
+}
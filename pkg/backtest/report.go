@@ -23,6 +23,9 @@ type Run struct {
 	ID     string       `json:"id"`
 	Config *bbgo.Config `json:"config"`
 	Time   time.Time    `json:"time"`
+
+	// EquityCurves are the equity curve files of the run, the filenames are relative to the run directory
+	EquityCurves []EquityCurveEntry `json:"equityCurves,omitempty"`
 }
 
 type ReportIndex struct {
@@ -43,9 +46,14 @@ type SummaryReport struct {
 	TotalProfit           fixedpoint.Value `json:"totalProfit,omitempty"`
 	TotalUnrealizedProfit fixedpoint.Value `json:"totalUnrealizedProfit,omitempty"`
 
+	// Metrics is calculated from the trades of all symbols and the total equity curve of the sessions
+	Metrics *PerformanceMetrics `json:"metrics,omitempty"`
+
 	SymbolReports []SessionSymbolReport `json:"symbolReports,omitempty"`
 
 	Manifests Manifests `json:"manifests,omitempty"`
+
+	EquityCurves []EquityCurveEntry `json:"equityCurves,omitempty"`
 }
 
 func ReadSummaryReport(filename string) (*SummaryReport, error) {
@@ -62,17 +70,19 @@ func ReadSummaryReport(filename string) (*SummaryReport, error) {
 // SessionSymbolReport is the report per exchange session
 // trades are merged, collected and re-calculated
 type SessionSymbolReport struct {
-	Exchange        types.ExchangeName        `json:"exchange"`
-	Symbol          string                    `json:"symbol,omitempty"`
-	Intervals       []types.Interval          `json:"intervals,omitempty"`
-	Subscriptions   []types.Subscription      `json:"subscriptions"`
-	Market          types.Market              `json:"market"`
-	LastPrice       fixedpoint.Value          `json:"lastPrice,omitempty"`
-	StartPrice      fixedpoint.Value          `json:"startPrice,omitempty"`
-	PnL             *pnl.AverageCostPnlReport `json:"pnl,omitempty"`
-	InitialBalances types.BalanceMap          `json:"initialBalances,omitempty"`
-	FinalBalances   types.BalanceMap          `json:"finalBalances,omitempty"`
-	Manifests       Manifests                 `json:"manifests,omitempty"`
+	Exchange         types.ExchangeName        `json:"exchange"`
+	Symbol           string                    `json:"symbol,omitempty"`
+	Intervals        []types.Interval          `json:"intervals,omitempty"`
+	Subscriptions    []types.Subscription      `json:"subscriptions"`
+	Market           types.Market              `json:"market"`
+	LastPrice        fixedpoint.Value          `json:"lastPrice,omitempty"`
+	StartPrice       fixedpoint.Value          `json:"startPrice,omitempty"`
+	PnL              *pnl.AverageCostPnlReport `json:"pnl,omitempty"`
+	InitialBalances  types.BalanceMap          `json:"initialBalances,omitempty"`
+	FinalBalances    types.BalanceMap          `json:"finalBalances,omitempty"`
+	FuturesPositions types.FuturesPositionMap  `json:"futuresPositions,omitempty"`
+	Metrics          *PerformanceMetrics       `json:"metrics,omitempty"`
+	Manifests        Manifests                 `json:"manifests,omitempty"`
 }
 
 func (r *SessionSymbolReport) Print(wantBaseAssetBaseline bool) {
@@ -80,8 +90,8 @@ func (r *SessionSymbolReport) Print(wantBaseAssetBaseline bool) {
 	color.Green("===============================================")
 	r.PnL.Print()
 
-	initQuoteAsset := inQuoteAsset(r.InitialBalances, r.Market, r.StartPrice)
-	finalQuoteAsset := inQuoteAsset(r.FinalBalances, r.Market, r.LastPrice)
+	initQuoteAsset := inQuoteAsset(r.InitialBalances, nil, r.Market, r.StartPrice)
+	finalQuoteAsset := inQuoteAsset(r.FinalBalances, r.FuturesPositions, r.Market, r.LastPrice)
 	color.Green("INITIAL ASSET IN %s ~= %s %s (1 %s = %v)", r.Market.QuoteCurrency, r.Market.FormatQuantity(initQuoteAsset), r.Market.QuoteCurrency, r.Market.BaseCurrency, r.StartPrice)
 	color.Green("FINAL ASSET IN %s ~= %s %s (1 %s = %v)", r.Market.QuoteCurrency, r.Market.FormatQuantity(finalQuoteAsset), r.Market.QuoteCurrency, r.Market.BaseCurrency, r.LastPrice)
 
@@ -120,6 +130,10 @@ func (r *SessionSymbolReport) Print(wantBaseAssetBaseline bool) {
 				r.StartPrice.FormatString(2))
 		}
 	}
+
+	if r.Metrics != nil {
+		r.Metrics.Print(r.Market)
+	}
 }
 
 const SessionTimeFormat = "2006-01-02T15_04"
@@ -186,9 +200,15 @@ func AddReportIndexRun(outputDirectory string, run Run) error {
 	return WriteReportIndex(outputDirectory, reportIndex)
 }
 
-// inQuoteAsset converts all balances in quote asset
-func inQuoteAsset(balances types.BalanceMap, market types.Market, price fixedpoint.Value) fixedpoint.Value {
+// inQuoteAsset converts the net balances (total - borrowed - interest) of the market in quote asset, the unrealized
+// profit of the futures position of the market is added if there is one
+func inQuoteAsset(balances types.BalanceMap, positions types.FuturesPositionMap, market types.Market, price fixedpoint.Value) fixedpoint.Value {
 	quote := balances[market.QuoteCurrency]
 	base := balances[market.BaseCurrency]
-	return base.Total().Mul(price).Add(quote.Total())
+	equity := base.Net().Mul(price).Add(quote.Net())
+
+	// index the map instead of copying the position since the position embeds a mutex,
+	// the base of the missing position is zero
+	positionBase, averageCost := positions[market.Symbol].Base, positions[market.Symbol].AverageCost
+	return equity.Add(price.Sub(averageCost).Mul(positionBase))
 }
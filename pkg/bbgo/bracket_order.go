@@ -0,0 +1,607 @@
+package bbgo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+type BracketOrderStatus string
+
+const (
+	// BracketOrderStatusPending means the entry order is working
+	BracketOrderStatusPending BracketOrderStatus = "pending"
+
+	// BracketOrderStatusOpen means the entry order is filled and the exit legs are working
+	BracketOrderStatusOpen BracketOrderStatus = "open"
+
+	// BracketOrderStatusClosed means one of the exit legs is filled
+	BracketOrderStatusClosed BracketOrderStatus = "closed"
+
+	// BracketOrderStatusCanceled means the entry order is canceled without fills, or the bracket is canceled
+	BracketOrderStatusCanceled BracketOrderStatus = "canceled"
+)
+
+const (
+	BracketLegTakeProfit = "takeProfit"
+	BracketLegStopLoss   = "stopLoss"
+)
+
+// BracketOrder is an entry order with the attached take-profit and stop-loss exit legs.
+// The symbol, the side and the quantity of the legs are filled from the entry order if they are not set,
+// the quantity of the legs is the executed quantity of the entry order.
+type BracketOrder struct {
+	Entry types.SubmitOrder `json:"entry"`
+
+	// TakeProfit is the limit order of the take-profit leg
+	TakeProfit *types.SubmitOrder `json:"takeProfit,omitempty"`
+
+	// StopLoss is the stop order of the stop-loss leg, it's triggered when the price touches StopPrice.
+	// A STOP_MARKET order is submitted as a market order, and a STOP_LIMIT order is submitted as a limit order at Price.
+	StopLoss *types.SubmitOrder `json:"stopLoss,omitempty"`
+
+	Status          BracketOrderStatus `json:"status"`
+	EntryOrder      types.Order        `json:"entryOrder"`
+	TakeProfitOrder *types.Order       `json:"takeProfitOrder,omitempty"`
+	StopLossOrder   *types.Order       `json:"stopLossOrder,omitempty"`
+
+	// ClosedBy is the exit leg that closed the bracket, takeProfit or stopLoss
+	ClosedBy string `json:"closedBy,omitempty"`
+
+	// exitQuantity is the executed quantity of the entry order
+	exitQuantity fixedpoint.Value
+
+	// native is true when the exit legs are submitted as a native OCO order
+	native bool
+
+	// stopTriggered is true when the emulated stop-loss leg is triggered
+	stopTriggered bool
+
+	// stopPending is true when the stop-loss order waits for the canceled take-profit order to be closed
+	stopPending bool
+}
+
+// BracketOrderManager places the exit legs of the bracket orders once the entry orders are filled,
+// and cancels the sibling leg when one of the legs is filled.
+//
+// If the order executor implements OCOOrderExecutor, the exit legs are submitted as a native OCO order through
+// the executor, so that they pass the same risk checks and the journal as the other orders.
+// Otherwise, or if the OCO order can not be submitted, OCO is emulated: the take-profit leg is placed on the exchange, and the stop-loss leg is
+// watched by the closed klines of Interval, so that the exit quantity is not locked twice.
+// When the stop price is touched, the take-profit order is canceled, and the stop-loss order is submitted with the
+// remaining quantity once the take-profit order is closed.
+// Since it only relies on the order updates and the klines, it works in the back-test as well.
+//
+// The orders created by the manager are emitted through OnOrdersSubmitted, so that the strategy can add them to
+// its order store and count the fills in the position.
+//
+//go:generate callbackgen -type BracketOrderManager
+type BracketOrderManager struct {
+	// Interval is the kline interval of the emulated stop-loss trigger, the default interval is 1m
+	Interval types.Interval
+
+	session       *ExchangeSession
+	orderExecutor OrderExecutor
+	ctx           context.Context
+
+	mu sync.Mutex
+
+	// orders maps the order IDs of the entry orders and the exit legs to the brackets
+	orders map[uint64]*BracketOrder
+
+	// pendingUpdates are the order updates received while the orders are being submitted,
+	// the update may arrive before the created order is returned, e.g. in the back-test
+	submitting     int
+	pendingUpdates map[uint64]types.Order
+
+	ordersSubmittedCallbacks []func(bracket *BracketOrder, orders types.OrderSlice)
+
+	errorCallbacks []func(bracket *BracketOrder, err error)
+
+	closedCallbacks []func(bracket *BracketOrder)
+}
+
+func NewBracketOrderManager(session *ExchangeSession, orderExecutor OrderExecutor) *BracketOrderManager {
+	return &BracketOrderManager{
+		Interval:       types.Interval1m,
+		session:        session,
+		orderExecutor:  orderExecutor,
+		ctx:            context.Background(),
+		orders:         make(map[uint64]*BracketOrder),
+		pendingUpdates: make(map[uint64]types.Order),
+	}
+}
+
+// Subscribe subscribes the kline of the emulated stop-loss trigger
+func (m *BracketOrderManager) Subscribe(session *ExchangeSession, symbol string) {
+	session.Subscribe(types.KLineChannel, symbol, types.SubscribeOptions{Interval: m.Interval})
+}
+
+// Run binds the order updates and the klines of the session
+func (m *BracketOrderManager) Run(ctx context.Context) {
+	m.ctx = ctx
+	m.session.UserDataStream.OnOrderUpdate(m.handleOrderUpdate)
+	m.session.MarketDataStream.OnKLineClosed(m.handleKLineClosed)
+}
+
+// Brackets returns the working brackets
+func (m *BracketOrderManager) Brackets() (brackets []BracketOrder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var seen = make(map[*BracketOrder]struct{})
+	for _, b := range m.orders {
+		if _, ok := seen[b]; ok {
+			continue
+		}
+
+		seen[b] = struct{}{}
+		brackets = append(brackets, *b)
+	}
+
+	return brackets
+}
+
+// Submit submits the entry order of the bracket, the exit legs of the given bracket are copied
+func (m *BracketOrderManager) Submit(ctx context.Context, bracket BracketOrder) (*BracketOrder, error) {
+	b := &bracket
+	b.copyLegs()
+	if err := b.fillLegs(); err != nil {
+		return nil, err
+	}
+
+	createdOrders, err := m.submitOrders(b, func() (types.OrderSlice, error) {
+		return m.orderExecutor.SubmitOrders(ctx, b.Entry)
+	}, func(orders types.OrderSlice) {
+		b.Status = BracketOrderStatusPending
+		b.EntryOrder = orders[0]
+		m.orders[orders[0].OrderID] = b
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("bracket entry order submitted: %s", createdOrders[0].String())
+	return b, nil
+}
+
+// Cancel cancels the working orders of the bracket
+func (m *BracketOrderManager) Cancel(ctx context.Context, bracket *BracketOrder) error {
+	m.mu.Lock()
+	var orders []types.Order
+	switch bracket.Status {
+	case BracketOrderStatusPending:
+		orders = append(orders, bracket.EntryOrder)
+	case BracketOrderStatusOpen:
+		if isWorkingOrder(bracket.TakeProfitOrder) {
+			orders = append(orders, *bracket.TakeProfitOrder)
+		}
+		if isWorkingOrder(bracket.StopLossOrder) {
+			orders = append(orders, *bracket.StopLossOrder)
+		}
+	default:
+		m.mu.Unlock()
+		return nil
+	}
+
+	bracket.Status = BracketOrderStatusCanceled
+	m.removeLocked(bracket)
+	m.mu.Unlock()
+
+	if len(orders) > 0 {
+		if err := m.orderExecutor.CancelOrders(ctx, orders...); err != nil {
+			return err
+		}
+	}
+
+	m.EmitClosed(bracket)
+	return nil
+}
+
+// submitOrders calls submit with the pending update buffer, register is called with the lock held
+// and the created orders are emitted before the buffered updates of the created orders are replayed.
+func (m *BracketOrderManager) submitOrders(b *BracketOrder, submit func() (types.OrderSlice, error), register func(orders types.OrderSlice)) (types.OrderSlice, error) {
+	m.mu.Lock()
+	m.submitting++
+	m.mu.Unlock()
+
+	createdOrders, err := submit()
+
+	m.mu.Lock()
+	m.submitting--
+
+	if err == nil && len(createdOrders) == 0 {
+		err = fmt.Errorf("no order is created")
+	}
+
+	var updates []types.Order
+	if err == nil {
+		register(createdOrders)
+
+		for _, o := range createdOrders {
+			if update, ok := m.pendingUpdates[o.OrderID]; ok {
+				updates = append(updates, update)
+			} else if o.Status != types.OrderStatusNew {
+				updates = append(updates, o)
+			}
+		}
+	}
+
+	if m.submitting == 0 {
+		m.pendingUpdates = make(map[uint64]types.Order)
+	}
+	m.mu.Unlock()
+
+	if err == nil {
+		m.EmitOrdersSubmitted(b, createdOrders)
+	}
+
+	for _, update := range updates {
+		m.handleOrderUpdate(update)
+	}
+
+	return createdOrders, err
+}
+
+func (m *BracketOrderManager) handleOrderUpdate(order types.Order) {
+	m.mu.Lock()
+	b, ok := m.orders[order.OrderID]
+	if !ok {
+		if m.submitting > 0 {
+			m.pendingUpdates[order.OrderID] = order
+		}
+		m.mu.Unlock()
+		return
+	}
+
+	var placeExits, submitStopLoss, closed bool
+	switch {
+	case order.OrderID == b.EntryOrder.OrderID:
+		b.EntryOrder = order
+		switch order.Status {
+		case types.OrderStatusFilled:
+			placeExits = b.Status == BracketOrderStatusPending
+
+		case types.OrderStatusCanceled, types.OrderStatusRejected:
+			if b.Status != BracketOrderStatusPending {
+				break
+			}
+
+			if order.ExecutedQuantity.Sign() > 0 {
+				placeExits = true
+			} else {
+				b.Status = BracketOrderStatusCanceled
+				closed = true
+			}
+		}
+
+		if placeExits {
+			b.Status = BracketOrderStatusOpen
+			b.exitQuantity = order.ExecutedQuantity
+		}
+
+	case b.TakeProfitOrder != nil && order.OrderID == b.TakeProfitOrder.OrderID:
+		// ignore the stale update of the closed order, e.g. the queried order arrives after the order update
+		if !isWorkingOrder(b.TakeProfitOrder) && isWorkingOrder(&order) {
+			break
+		}
+
+		b.TakeProfitOrder = &order
+		switch order.Status {
+		case types.OrderStatusFilled:
+			b.stopPending = false
+			b.Status = BracketOrderStatusClosed
+			b.ClosedBy = BracketLegTakeProfit
+			closed = true
+
+		case types.OrderStatusCanceled, types.OrderStatusRejected:
+			if b.stopPending {
+				b.stopPending = false
+				submitStopLoss = true
+			}
+		}
+
+	case b.StopLossOrder != nil && order.OrderID == b.StopLossOrder.OrderID:
+		b.StopLossOrder = &order
+		if order.Status == types.OrderStatusFilled {
+			b.Status = BracketOrderStatusClosed
+			b.ClosedBy = BracketLegStopLoss
+			closed = true
+		}
+	}
+
+	if closed {
+		m.removeLocked(b)
+	}
+	m.mu.Unlock()
+
+	if placeExits {
+		m.placeExits(b)
+	}
+
+	if submitStopLoss {
+		m.submitStopLoss(b)
+	}
+
+	if closed {
+		log.Infof("bracket order of %s is %s by %s", b.Entry.Symbol, b.Status, b.ClosedBy)
+		m.EmitClosed(b)
+	}
+}
+
+// placeExits places the exit legs with the executed quantity of the entry order
+func (m *BracketOrderManager) placeExits(b *BracketOrder) {
+	var takeProfit, stopLoss *types.SubmitOrder
+	if b.TakeProfit != nil {
+		o := *b.TakeProfit
+		o.Quantity = b.exitQuantity
+		takeProfit = &o
+	}
+
+	if b.StopLoss != nil {
+		o := *b.StopLoss
+		o.Quantity = b.exitQuantity
+		stopLoss = &o
+	}
+
+	if ocoExecutor, ok := m.orderExecutor.(OCOOrderExecutor); ok && takeProfit != nil && stopLoss != nil {
+		_, err := m.submitOrders(b, func() (types.OrderSlice, error) {
+			return ocoExecutor.SubmitOCOOrder(m.ctx, *takeProfit, *stopLoss)
+		}, func(orders types.OrderSlice) {
+			b.native = true
+			for i := range orders {
+				o := orders[i]
+				if o.Type == types.OrderTypeStopLimit || o.Type == types.OrderTypeStopMarket {
+					b.StopLossOrder = &o
+				} else {
+					b.TakeProfitOrder = &o
+				}
+				m.orders[o.OrderID] = b
+			}
+		})
+		if err == nil {
+			return
+		}
+
+		// the filled entry order must not be left without the exit legs, fall back to the emulated OCO
+		if errors.Is(err, types.ErrOCOOrderNotSupported) {
+			log.WithError(err).Warnf("oco order is not supported, emulating the oco order of the bracket: %+v", b.Entry)
+		} else {
+			log.WithError(err).Errorf("can not submit the oco order of the bracket, emulating the oco order: %+v", b.Entry)
+		}
+	}
+
+	if takeProfit != nil {
+		_, err := m.submitOrders(b, func() (types.OrderSlice, error) {
+			return m.orderExecutor.SubmitOrders(m.ctx, *takeProfit)
+		}, func(orders types.OrderSlice) {
+			b.TakeProfitOrder = &orders[0]
+			m.orders[orders[0].OrderID] = b
+		})
+		if err != nil {
+			log.WithError(err).Errorf("can not submit the take-profit order of the bracket: %+v", b.Entry)
+			m.EmitError(b, errors.Wrap(err, "take-profit order submission error"))
+		}
+	}
+}
+
+func (m *BracketOrderManager) handleKLineClosed(kline types.KLine) {
+	if kline.Interval != m.Interval {
+		return
+	}
+
+	var triggered []*BracketOrder
+
+	m.mu.Lock()
+	for _, b := range m.orders {
+		if b.Status != BracketOrderStatusOpen || b.native || b.stopTriggered || b.StopLoss == nil || b.Entry.Symbol != kline.Symbol {
+			continue
+		}
+
+		if (b.StopLoss.Side == types.SideTypeSell && kline.Low.Compare(b.StopLoss.StopPrice) <= 0) ||
+			(b.StopLoss.Side == types.SideTypeBuy && kline.High.Compare(b.StopLoss.StopPrice) >= 0) {
+			b.stopTriggered = true
+			triggered = append(triggered, b)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, b := range triggered {
+		m.triggerStopLoss(b)
+	}
+}
+
+// triggerStopLoss cancels the take-profit leg, the stop-loss order is submitted once the take-profit order is closed
+func (m *BracketOrderManager) triggerStopLoss(b *BracketOrder) {
+	log.Infof("bracket stop-loss of %s is triggered at %s", b.Entry.Symbol, b.StopLoss.StopPrice.String())
+
+	m.mu.Lock()
+	takeProfitOrder := b.TakeProfitOrder
+	m.mu.Unlock()
+
+	if isWorkingOrder(takeProfitOrder) {
+		if err := m.orderExecutor.CancelOrders(m.ctx, *takeProfitOrder); err != nil {
+			log.WithError(err).Errorf("can not cancel the take-profit order of the bracket: %s", takeProfitOrder.String())
+			return
+		}
+
+		// the cancel response does not carry the final executed quantity of the take-profit order, and the fills
+		// could arrive later than the cancel response on the live exchanges, so the stop-loss order is sized by
+		// the closed take-profit order from the order update or the order query.
+		m.mu.Lock()
+		waiting := isWorkingOrder(b.TakeProfitOrder)
+		b.stopPending = waiting
+		m.mu.Unlock()
+
+		if waiting {
+			if order, ok := m.queryOrder(*takeProfitOrder); ok {
+				m.handleOrderUpdate(order)
+			}
+			return
+		}
+	}
+
+	m.submitStopLoss(b)
+}
+
+// queryOrder queries the order if the exchange supports the order query
+func (m *BracketOrderManager) queryOrder(order types.Order) (types.Order, bool) {
+	service, ok := m.session.Exchange.(types.ExchangeOrderQueryService)
+	if !ok {
+		return types.Order{}, false
+	}
+
+	queried, err := service.QueryOrder(m.ctx, types.OrderQuery{
+		Symbol:  order.Symbol,
+		OrderID: strconv.FormatUint(order.OrderID, 10),
+	})
+	if err != nil || queried == nil {
+		log.WithError(err).Warnf("can not query the take-profit order of the bracket: %s, waiting for the order update", order.String())
+		return types.Order{}, false
+	}
+
+	return *queried, true
+}
+
+// submitStopLoss submits the stop-loss order with the quantity that is not executed by the take-profit order
+func (m *BracketOrderManager) submitStopLoss(b *BracketOrder) {
+	m.mu.Lock()
+	if b.Status != BracketOrderStatusOpen {
+		m.mu.Unlock()
+		return
+	}
+
+	quantity := b.exitQuantity
+	if b.TakeProfitOrder != nil {
+		quantity = quantity.Sub(b.TakeProfitOrder.ExecutedQuantity)
+	}
+
+	if quantity.Sign() <= 0 {
+		b.Status = BracketOrderStatusClosed
+		b.ClosedBy = BracketLegTakeProfit
+		m.removeLocked(b)
+		m.mu.Unlock()
+		m.EmitClosed(b)
+		return
+	}
+	m.mu.Unlock()
+
+	stopLoss := *b.StopLoss
+	stopLoss.Quantity = quantity
+	stopLoss.StopPrice = fixedpoint.Zero
+	if stopLoss.Type == types.OrderTypeStopLimit {
+		stopLoss.Type = types.OrderTypeLimit
+	} else {
+		stopLoss.Type = types.OrderTypeMarket
+		stopLoss.Price = fixedpoint.Zero
+	}
+
+	_, err := m.submitOrders(b, func() (types.OrderSlice, error) {
+		return m.orderExecutor.SubmitOrders(m.ctx, stopLoss)
+	}, func(orders types.OrderSlice) {
+		b.StopLossOrder = &orders[0]
+		m.orders[orders[0].OrderID] = b
+	})
+	if err != nil {
+		log.WithError(err).Errorf("can not submit the stop-loss order of the bracket: %+v", b.Entry)
+		m.EmitError(b, errors.Wrap(err, "stop-loss order submission error"))
+	}
+}
+
+// removeLocked removes the orders of the bracket, must be called with the lock held
+func (m *BracketOrderManager) removeLocked(b *BracketOrder) {
+	for id, bracket := range m.orders {
+		if bracket == b {
+			delete(m.orders, id)
+		}
+	}
+}
+
+// copyLegs copies the exit legs, so that filling the legs doesn't modify the orders of the caller
+func (b *BracketOrder) copyLegs() {
+	if b.TakeProfit != nil {
+		takeProfit := *b.TakeProfit
+		b.TakeProfit = &takeProfit
+	}
+
+	if b.StopLoss != nil {
+		stopLoss := *b.StopLoss
+		b.StopLoss = &stopLoss
+	}
+}
+
+// fillLegs fills the symbol and the side of the exit legs from the entry order
+func (b *BracketOrder) fillLegs() error {
+	if b.TakeProfit == nil && b.StopLoss == nil {
+		return fmt.Errorf("the bracket order of %s has no exit legs", b.Entry.Symbol)
+	}
+
+	exitSide := types.SideTypeSell
+	if b.Entry.Side == types.SideTypeSell {
+		exitSide = types.SideTypeBuy
+	}
+
+	for _, leg := range []*types.SubmitOrder{b.TakeProfit, b.StopLoss} {
+		if leg == nil {
+			continue
+		}
+
+		if leg.Symbol == "" {
+			leg.Symbol = b.Entry.Symbol
+		}
+
+		if leg.Side == "" {
+			leg.Side = exitSide
+		}
+
+		if leg.Market.Symbol == "" {
+			leg.Market = b.Entry.Market
+		}
+
+		if leg.Symbol != b.Entry.Symbol || leg.Side != exitSide {
+			return fmt.Errorf("the exit leg %s %s does not close the entry order %s %s", leg.Symbol, leg.Side, b.Entry.Symbol, b.Entry.Side)
+		}
+	}
+
+	if b.TakeProfit != nil {
+		if b.TakeProfit.Type == "" {
+			b.TakeProfit.Type = types.OrderTypeLimit
+		}
+
+		if b.TakeProfit.Price.Sign() <= 0 {
+			return fmt.Errorf("the take-profit leg of %s requires the price", b.Entry.Symbol)
+		}
+	}
+
+	if b.StopLoss != nil {
+		if b.StopLoss.Type == "" {
+			b.StopLoss.Type = types.OrderTypeStopMarket
+		}
+
+		if b.StopLoss.StopPrice.Sign() <= 0 {
+			return fmt.Errorf("the stop-loss leg of %s requires the stop price", b.Entry.Symbol)
+		}
+	}
+
+	return nil
+}
+
+func isWorkingOrder(order *types.Order) bool {
+	if order == nil {
+		return false
+	}
+
+	switch order.Status {
+	case types.OrderStatusNew, types.OrderStatusPartiallyFilled:
+		return true
+	}
+
+	return false
+}
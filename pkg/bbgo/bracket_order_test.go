@@ -0,0 +1,374 @@
+package bbgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// testBracketExchange fills the market orders immediately and emits the order updates
+// before the created orders are returned, like the back-test exchange
+type testBracketExchange struct {
+	types.Exchange
+
+	stream      *types.StandardStream
+	lastOrderID uint64
+	orders      map[uint64]types.Order
+
+	// delayCancel holds the canceled order updates until emitCanceled is called,
+	// like the live exchanges that send the order update after the cancel response
+	delayCancel bool
+	canceling   []uint64
+}
+
+func (e *testBracketExchange) SubmitOrders(ctx context.Context, orders ...types.SubmitOrder) (createdOrders types.OrderSlice, err error) {
+	for _, o := range orders {
+		e.lastOrderID++
+		order := types.Order{SubmitOrder: o, OrderID: e.lastOrderID, Status: types.OrderStatusNew}
+		if o.Type == types.OrderTypeMarket {
+			order.Status = types.OrderStatusFilled
+			order.ExecutedQuantity = o.Quantity
+		}
+
+		e.orders[order.OrderID] = order
+		e.stream.EmitOrderUpdate(order)
+		createdOrders = append(createdOrders, order)
+	}
+
+	return createdOrders, nil
+}
+
+func (e *testBracketExchange) CancelOrders(ctx context.Context, orders ...types.Order) error {
+	for _, o := range orders {
+		if e.delayCancel {
+			e.canceling = append(e.canceling, o.OrderID)
+			continue
+		}
+
+		order := e.orders[o.OrderID]
+		order.Status = types.OrderStatusCanceled
+		e.orders[o.OrderID] = order
+		e.stream.EmitOrderUpdate(order)
+	}
+	return nil
+}
+
+func (e *testBracketExchange) emitCanceled() {
+	for _, orderID := range e.canceling {
+		order := e.orders[orderID]
+		order.Status = types.OrderStatusCanceled
+		e.orders[orderID] = order
+		e.stream.EmitOrderUpdate(order)
+	}
+	e.canceling = nil
+}
+
+func (e *testBracketExchange) fill(orderID uint64, quantity fixedpoint.Value) {
+	order := e.orders[orderID]
+	order.ExecutedQuantity = order.ExecutedQuantity.Add(quantity)
+	order.Status = types.OrderStatusPartiallyFilled
+	if order.ExecutedQuantity.Compare(order.Quantity) >= 0 {
+		order.Status = types.OrderStatusFilled
+	}
+
+	e.orders[orderID] = order
+	e.stream.EmitOrderUpdate(order)
+}
+
+func newTestBracketOrderManager() (*BracketOrderManager, *testBracketExchange, *types.StandardStream) {
+	userDataStream := types.NewStandardStream()
+	marketDataStream := types.NewStandardStream()
+	exchange := &testBracketExchange{stream: &userDataStream, orders: make(map[uint64]types.Order)}
+
+	session := &ExchangeSession{
+		Exchange:         exchange,
+		UserDataStream:   &userDataStream,
+		MarketDataStream: &marketDataStream,
+		markets: map[string]types.Market{
+			"BTCUSDT": {Symbol: "BTCUSDT", BaseCurrency: "BTC", QuoteCurrency: "USDT", TickSize: fixedpoint.NewFromFloat(0.01), StepSize: fixedpoint.NewFromFloat(0.0001)},
+		},
+	}
+
+	manager := NewBracketOrderManager(session, &ExchangeOrderExecutor{Session: session})
+	manager.Run(context.Background())
+	return manager, exchange, &marketDataStream
+}
+
+func newTestBracketOrder(entryType types.OrderType) BracketOrder {
+	return BracketOrder{
+		Entry: types.SubmitOrder{
+			Symbol:   "BTCUSDT",
+			Side:     types.SideTypeBuy,
+			Type:     entryType,
+			Price:    fixedpoint.NewFromInt(100),
+			Quantity: fixedpoint.NewFromInt(2),
+		},
+		TakeProfit: &types.SubmitOrder{Price: fixedpoint.NewFromInt(110)},
+		StopLoss:   &types.SubmitOrder{StopPrice: fixedpoint.NewFromInt(95)},
+	}
+}
+
+func newTestKLine(low, high float64) types.KLine {
+	return types.KLine{
+		Symbol:   "BTCUSDT",
+		Interval: types.Interval1m,
+		Low:      fixedpoint.NewFromFloat(low),
+		High:     fixedpoint.NewFromFloat(high),
+		Close:    fixedpoint.NewFromFloat(low),
+		Closed:   true,
+	}
+}
+
+func TestBracketOrderManager_TakeProfit(t *testing.T) {
+	manager, exchange, marketDataStream := newTestBracketOrderManager()
+
+	var closed []*BracketOrder
+	manager.OnClosed(func(bracket *BracketOrder) {
+		closed = append(closed, bracket)
+	})
+
+	ctx := context.Background()
+	bracket, err := manager.Submit(ctx, newTestBracketOrder(types.OrderTypeLimit))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, BracketOrderStatusPending, bracket.Status)
+	assert.Nil(t, bracket.TakeProfitOrder)
+
+	// the exit legs are placed after the entry order is filled
+	exchange.fill(bracket.EntryOrder.OrderID, fixedpoint.NewFromInt(2))
+	assert.Equal(t, BracketOrderStatusOpen, bracket.Status)
+	if assert.NotNil(t, bracket.TakeProfitOrder) {
+		assert.Equal(t, types.SideTypeSell, bracket.TakeProfitOrder.Side)
+		assert.Equal(t, types.OrderTypeLimit, bracket.TakeProfitOrder.Type)
+		assert.Equal(t, "2", bracket.TakeProfitOrder.Quantity.String())
+	}
+
+	// the stop-loss leg is emulated, it's not placed on the exchange
+	assert.Len(t, exchange.orders, 2)
+
+	marketDataStream.EmitKLineClosed(newTestKLine(96, 105))
+	assert.Equal(t, BracketOrderStatusOpen, bracket.Status)
+
+	exchange.fill(bracket.TakeProfitOrder.OrderID, fixedpoint.NewFromInt(2))
+	assert.Equal(t, BracketOrderStatusClosed, bracket.Status)
+	assert.Equal(t, BracketLegTakeProfit, bracket.ClosedBy)
+	assert.Len(t, closed, 1)
+	assert.Empty(t, manager.Brackets())
+
+	// the stop-loss leg is removed with the bracket
+	marketDataStream.EmitKLineClosed(newTestKLine(90, 100))
+	assert.Len(t, exchange.orders, 2)
+}
+
+func TestBracketOrderManager_StopLoss(t *testing.T) {
+	manager, exchange, marketDataStream := newTestBracketOrderManager()
+
+	ctx := context.Background()
+
+	// the market entry order is filled before the created order is returned
+	bracket, err := manager.Submit(ctx, newTestBracketOrder(types.OrderTypeMarket))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, BracketOrderStatusOpen, bracket.Status)
+	if !assert.NotNil(t, bracket.TakeProfitOrder) {
+		return
+	}
+
+	exchange.fill(bracket.TakeProfitOrder.OrderID, fixedpoint.NewFromFloat(0.5))
+
+	// the stop-loss leg cancels the take-profit order, and closes the remaining quantity
+	marketDataStream.EmitKLineClosed(newTestKLine(94, 100))
+	assert.Equal(t, types.OrderStatusCanceled, exchange.orders[bracket.TakeProfitOrder.OrderID].Status)
+	if assert.NotNil(t, bracket.StopLossOrder) {
+		assert.Equal(t, types.OrderTypeMarket, bracket.StopLossOrder.Type)
+		assert.Equal(t, types.SideTypeSell, bracket.StopLossOrder.Side)
+		assert.Equal(t, "1.5", bracket.StopLossOrder.Quantity.String())
+	}
+
+	assert.Equal(t, BracketOrderStatusClosed, bracket.Status)
+	assert.Equal(t, BracketLegStopLoss, bracket.ClosedBy)
+	assert.Empty(t, manager.Brackets())
+}
+
+func TestBracketOrderManager_StopLossFillAfterCancel(t *testing.T) {
+	manager, exchange, marketDataStream := newTestBracketOrderManager()
+	exchange.delayCancel = true
+
+	bracket, err := manager.Submit(context.Background(), newTestBracketOrder(types.OrderTypeMarket))
+	if !assert.NoError(t, err) || !assert.NotNil(t, bracket.TakeProfitOrder) {
+		return
+	}
+
+	// the stop-loss order waits for the canceled take-profit order
+	marketDataStream.EmitKLineClosed(newTestKLine(94, 100))
+	assert.Nil(t, bracket.StopLossOrder)
+	assert.Equal(t, BracketOrderStatusOpen, bracket.Status)
+
+	// the fill of the take-profit order arrives after the cancel response
+	takeProfitOrderID := bracket.TakeProfitOrder.OrderID
+	exchange.fill(takeProfitOrderID, fixedpoint.NewFromFloat(0.5))
+	assert.Nil(t, bracket.StopLossOrder)
+
+	exchange.emitCanceled()
+	if assert.NotNil(t, bracket.StopLossOrder) {
+		assert.Equal(t, "1.5", bracket.StopLossOrder.Quantity.String())
+	}
+
+	assert.Equal(t, BracketOrderStatusClosed, bracket.Status)
+	assert.Equal(t, BracketLegStopLoss, bracket.ClosedBy)
+
+	// the stop-loss order is submitted once
+	assert.Len(t, exchange.orders, 3)
+}
+
+// testBracketOCOExchange submits the exit legs as an OCO order unless err is set
+type testBracketOCOExchange struct {
+	*testBracketExchange
+
+	err       error
+	ocoOrders types.OrderSlice
+}
+
+func (e *testBracketOCOExchange) SubmitOCOOrder(ctx context.Context, takeProfit, stopLoss types.SubmitOrder) (types.OrderSlice, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	createdOrders, err := e.SubmitOrders(ctx, takeProfit, stopLoss)
+	e.ocoOrders = append(e.ocoOrders, createdOrders...)
+	return createdOrders, err
+}
+
+// testRejectOrderCheck rejects the orders that match the reject function
+type testRejectOrderCheck struct {
+	reject func(order types.SubmitOrder) bool
+}
+
+func (c *testRejectOrderCheck) ID() string {
+	return "testRejectOrder"
+}
+
+func (c *testRejectOrderCheck) CheckOrders(ctx context.Context, session *ExchangeSession, orders ...types.SubmitOrder) (accepted []types.SubmitOrder, rejections []RiskRejection) {
+	for _, o := range orders {
+		if c.reject(o) {
+			rejections = append(rejections, NewRiskRejection(c.ID(), o, errors.New("the order is not allowed")))
+			continue
+		}
+
+		accepted = append(accepted, o)
+	}
+	return accepted, rejections
+}
+
+func TestBracketOrderManager_OCO(t *testing.T) {
+	testCases := []struct {
+		name      string
+		err       error
+		riskCheck RiskCheck
+		native    bool
+	}{
+		{name: "native", native: true},
+		{name: "not supported", err: types.ErrOCOOrderNotSupported},
+		{name: "submission error", err: errors.New("oco order submission error")},
+		{name: "rejected by the risk check", riskCheck: &testRejectOrderCheck{reject: func(order types.SubmitOrder) bool {
+			return order.Type == types.OrderTypeStopMarket
+		}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			manager, exchange, _ := newTestBracketOrderManager()
+			ocoExchange := &testBracketOCOExchange{testBracketExchange: exchange, err: tc.err}
+			manager.session.Exchange = ocoExchange
+
+			if tc.riskCheck != nil {
+				manager.orderExecutor = &RiskControlOrderExecutor{
+					ExchangeOrderExecutor: &ExchangeOrderExecutor{Session: manager.session},
+					Checks:                RiskCheckList{tc.riskCheck},
+				}
+			}
+
+			var submitted types.OrderSlice
+			manager.OnOrdersSubmitted(func(bracket *BracketOrder, orders types.OrderSlice) {
+				submitted = append(submitted, orders...)
+			})
+
+			bracket, err := manager.Submit(context.Background(), newTestBracketOrder(types.OrderTypeMarket))
+			if !assert.NoError(t, err) || !assert.NotNil(t, bracket.TakeProfitOrder) {
+				return
+			}
+
+			assert.Equal(t, tc.native, bracket.native)
+			if tc.native {
+				assert.Len(t, ocoExchange.ocoOrders, 2)
+				assert.Len(t, submitted, 3)
+				if assert.NotNil(t, bracket.StopLossOrder) {
+					assert.Equal(t, types.OrderTypeStopMarket, bracket.StopLossOrder.Type)
+				}
+			} else {
+				// the filled entry order is protected by the emulated legs
+				assert.Empty(t, ocoExchange.ocoOrders)
+				assert.Len(t, submitted, 2)
+				assert.Nil(t, bracket.StopLossOrder)
+				assert.Equal(t, types.OrderStatusNew, bracket.TakeProfitOrder.Status)
+			}
+		})
+	}
+}
+
+func TestBracketOrderManager_ExitSubmissionError(t *testing.T) {
+	manager, _, _ := newTestBracketOrderManager()
+	manager.orderExecutor = &RiskControlOrderExecutor{
+		ExchangeOrderExecutor: &ExchangeOrderExecutor{Session: manager.session},
+		Checks: RiskCheckList{&testRejectOrderCheck{reject: func(order types.SubmitOrder) bool {
+			return order.Side == types.SideTypeSell
+		}}},
+	}
+
+	var errs []error
+	manager.OnError(func(bracket *BracketOrder, err error) {
+		errs = append(errs, err)
+	})
+
+	order := newTestBracketOrder(types.OrderTypeMarket)
+	bracket, err := manager.Submit(context.Background(), order)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the filled entry order can not be protected, the failure is emitted
+	assert.Equal(t, BracketOrderStatusOpen, bracket.Status)
+	assert.Nil(t, bracket.TakeProfitOrder)
+	assert.Len(t, errs, 1)
+
+	// the legs of the caller are not modified
+	assert.Empty(t, order.TakeProfit.Symbol)
+	assert.Empty(t, order.StopLoss.Type)
+}
+
+func TestBracketOrderManager_Cancel(t *testing.T) {
+	manager, exchange, _ := newTestBracketOrderManager()
+
+	ctx := context.Background()
+	bracket, err := manager.Submit(ctx, newTestBracketOrder(types.OrderTypeLimit))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, manager.Cancel(ctx, bracket))
+	assert.Equal(t, BracketOrderStatusCanceled, bracket.Status)
+	assert.Equal(t, types.OrderStatusCanceled, exchange.orders[bracket.EntryOrder.OrderID].Status)
+	assert.Empty(t, manager.Brackets())
+
+	_, err = manager.Submit(ctx, BracketOrder{Entry: newTestBracketOrder(types.OrderTypeLimit).Entry})
+	assert.Error(t, err)
+}
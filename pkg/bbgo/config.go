@@ -114,8 +114,33 @@ type Backtest struct {
 	Accounts map[string]BacktestAccount `json:"accounts" yaml:"accounts"`
 	Symbols  []string                   `json:"symbols" yaml:"symbols"`
 	Sessions []string                   `json:"sessions" yaml:"sessions"`
+
+	// MatchingEngine is the matching engine for filling the orders, "kline" (default) or "depth"
+	MatchingEngine BacktestMatchingEngine `json:"matchingEngine,omitempty" yaml:"matchingEngine,omitempty"`
+
+	// SyncMarketTrades syncs the public trades of the symbols for the tick-level back-testing,
+	// the strategies that subscribe the market trade channel receive the ticks and the klines built from the ticks.
+	SyncMarketTrades bool `json:"syncMarketTrades,omitempty" yaml:"syncMarketTrades,omitempty"`
+
+	// SyncFundingRates syncs the funding rates of the symbols, the funding fees of the simulated futures positions
+	// are paid or received by the funding rates.
+	SyncFundingRates bool `json:"syncFundingRates,omitempty" yaml:"syncFundingRates,omitempty"`
+
+	// DepthDataDirectory is the directory of the order book data recorded by `bbgo orderbook --record`,
+	// it's required by the depth matching engine.
+	DepthDataDirectory string `json:"depthDataDirectory,omitempty" yaml:"depthDataDirectory,omitempty"`
 }
 
+type BacktestMatchingEngine string
+
+const (
+	// BacktestMatchingEngineKLine fills the orders by the open, high, low and close price of the 1m kline
+	BacktestMatchingEngineKLine BacktestMatchingEngine = "kline"
+
+	// BacktestMatchingEngineDepth fills the orders against the recorded order book snapshots and updates
+	BacktestMatchingEngineDepth BacktestMatchingEngine = "depth"
+)
+
 func (b *Backtest) GetAccount(n string) BacktestAccount {
 	accountConfig, ok := b.Accounts[n]
 	if ok {
@@ -136,6 +161,32 @@ type BacktestAccount struct {
 	TakerFeeRate fixedpoint.Value `json:"takerFeeRate,omitempty" yaml:"takerFeeRate,omitempty"`
 
 	Balances BacktestAccountBalanceMap `json:"balances" yaml:"balances"`
+
+	// Margin simulates the cross margin account, the assets can be borrowed and repaid
+	Margin *BacktestMarginAccount `json:"margin,omitempty" yaml:"margin,omitempty"`
+
+	// Futures simulates the USDT-margined futures account, the orders open long or short positions with leverage
+	Futures *BacktestFuturesAccount `json:"futures,omitempty" yaml:"futures,omitempty"`
+}
+
+type BacktestMarginAccount struct {
+	// MaxLeverage limits the borrowable amount, the total liability can not exceed net asset * (MaxLeverage - 1)
+	MaxLeverage fixedpoint.Value `json:"maxLeverage,omitempty" yaml:"maxLeverage,omitempty"`
+
+	// LiquidationMarginLevel is the margin level (total asset / total liability) that triggers the forced liquidation
+	LiquidationMarginLevel fixedpoint.Value `json:"liquidationMarginLevel,omitempty" yaml:"liquidationMarginLevel,omitempty"`
+
+	// InterestRates are the daily interest rates of the borrowed assets, the interest is charged hourly
+	InterestRates map[string]fixedpoint.Value `json:"interestRates,omitempty" yaml:"interestRates,omitempty"`
+}
+
+type BacktestFuturesAccount struct {
+	// Leverage is the leverage of the positions, the initial margin is the position notional / leverage
+	Leverage fixedpoint.Value `json:"leverage,omitempty" yaml:"leverage,omitempty"`
+
+	// MaintenanceMarginRate is the maintenance margin rate of the position notional,
+	// the positions are liquidated when the margin balance is lower than the maintenance margin
+	MaintenanceMarginRate fixedpoint.Value `json:"maintenanceMarginRate,omitempty" yaml:"maintenanceMarginRate,omitempty"`
 }
 
 var DefaultBacktestAccount = BacktestAccount{
@@ -181,8 +232,9 @@ func (m BacktestAccountBalanceMap) BalanceMap() types.BalanceMap {
 }
 
 type PersistenceConfig struct {
-	Redis *service.RedisPersistenceConfig `json:"redis,omitempty" yaml:"redis,omitempty"`
-	Json  *service.JsonPersistenceConfig  `json:"json,omitempty" yaml:"json,omitempty"`
+	Redis    *service.RedisPersistenceConfig    `json:"redis,omitempty" yaml:"redis,omitempty"`
+	Json     *service.JsonPersistenceConfig     `json:"json,omitempty" yaml:"json,omitempty"`
+	Database *service.DatabasePersistenceConfig `json:"database,omitempty" yaml:"database,omitempty"`
 }
 
 type BuildTargetConfig struct {
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/codingconcepts/env"
+	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 	"github.com/pquerna/otp"
 	log "github.com/sirupsen/logrus"
@@ -289,9 +290,41 @@ func (environ *Environment) ConfigurePersistence(conf *PersistenceConfig) error
 		environ.PersistenceServiceFacade.Json = &service.JsonPersistenceService{Directory: conf.Json.Directory}
 	}
 
+	if conf.Database != nil {
+		db, err := environ.persistenceDatabase(conf.Database)
+		if err != nil {
+			return err
+		}
+
+		environ.PersistenceServiceFacade.Database = service.NewDatabasePersistenceService(db, conf.Database.MaxVersions)
+	}
+
 	return nil
 }
 
+// persistenceDatabase returns the database of the database persistence,
+// the database of the environment is used if the driver is not specified in the config
+func (environ *Environment) persistenceDatabase(conf *service.DatabasePersistenceConfig) (*sqlx.DB, error) {
+	if conf.Driver == "" {
+		if environ.DatabaseService == nil {
+			return nil, errors.New("database persistence requires the database, please set DB_DRIVER and DB_DSN or the driver and dsn of the persistence config")
+		}
+
+		return environ.DatabaseService.DB, nil
+	}
+
+	databaseService := service.NewDatabaseService(conf.Driver, conf.DSN)
+	if err := databaseService.Connect(); err != nil {
+		return nil, err
+	}
+
+	if err := databaseService.Upgrade(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return databaseService.DB, nil
+}
+
 // ConfigureNotificationRouting configures the notification rules
 // for symbol-based routes, we should register the same symbol rules for each session.
 // for session-based routes, we should set the fixed callbacks for each session
@@ -1,12 +1,16 @@
 package bbgo
 
-import "github.com/c9s/bbgo/pkg/types"
+import (
+	"sort"
+	"sync"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
 
 const MaxNumOfKLines = 5_000
 const MaxNumOfKLinesTruncate = 100
 
 // MarketDataStore receives and maintain the public market data
-//go:generate callbackgen -type MarketDataStore
 type MarketDataStore struct {
 	Symbol string
 
@@ -14,6 +18,10 @@ type MarketDataStore struct {
 	KLineWindows map[types.Interval]*types.KLineWindow `json:"-"`
 
 	kLineWindowUpdateCallbacks []func(interval types.Interval, klines types.KLineWindow)
+
+	// mu protects the kline windows and the callbacks, since the historical klines could be loaded
+	// while the closed klines are added from the stream
+	mu sync.RWMutex
 }
 
 func NewMarketDataStore(symbol string) *MarketDataStore {
@@ -26,15 +34,31 @@ func NewMarketDataStore(symbol string) *MarketDataStore {
 }
 
 func (store *MarketDataStore) SetKLineWindows(windows map[types.Interval]*types.KLineWindow) {
+	store.mu.Lock()
 	store.KLineWindows = windows
+	store.mu.Unlock()
 }
 
 // KLinesOfInterval returns the kline window of the given interval
 func (store *MarketDataStore) KLinesOfInterval(interval types.Interval) (kLines *types.KLineWindow, ok bool) {
+	store.mu.RLock()
 	kLines, ok = store.KLineWindows[interval]
+	store.mu.RUnlock()
 	return kLines, ok
 }
 
+// kLineWindow returns a snapshot of the kline window of the given interval,
+// the snapshot is not changed by the klines that are added later
+func (store *MarketDataStore) kLineWindow(interval types.Interval) types.KLineWindow {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if window, ok := store.KLineWindows[interval]; ok {
+		return *window
+	}
+	return nil
+}
+
 func (store *MarketDataStore) BindStream(stream types.Stream) {
 	stream.OnKLineClosed(store.handleKLineClosed)
 }
@@ -47,7 +71,68 @@ func (store *MarketDataStore) handleKLineClosed(kline types.KLine) {
 	store.AddKLine(kline)
 }
 
+// OnKLineWindowUpdate registers the callback of the kline window updates.
+// The callback is called with the loaded kline windows right away, so that the indicators bound to the store
+// by Bind(store) are warmed up by the loaded klines instead of waiting for the next closed kline.
+func (store *MarketDataStore) OnKLineWindowUpdate(cb func(interval types.Interval, klines types.KLineWindow)) {
+	store.mu.Lock()
+	store.kLineWindowUpdateCallbacks = append(store.kLineWindowUpdateCallbacks, cb)
+
+	var windows []types.KLineWindow
+	for _, window := range store.KLineWindows {
+		if len(*window) > 0 {
+			windows = append(windows, *window)
+		}
+	}
+	store.mu.Unlock()
+
+	// replay the shorter intervals first, like the order of the klines that are closed at the same time
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].GetInterval().Duration() < windows[j].GetInterval().Duration()
+	})
+
+	for _, window := range windows {
+		cb(window.GetInterval(), window)
+	}
+}
+
+func (store *MarketDataStore) EmitKLineWindowUpdate(interval types.Interval, klines types.KLineWindow) {
+	store.mu.RLock()
+	callbacks := store.kLineWindowUpdateCallbacks
+	store.mu.RUnlock()
+
+	for _, cb := range callbacks {
+		cb(interval, klines)
+	}
+}
+
+// PrependKLines inserts the historical klines before the loaded klines of the interval,
+// the kline window update is not emitted since the klines are older than the klines that the indicators calculated.
+func (store *MarketDataStore) PrependKLines(interval types.Interval, kLines []types.KLine) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var tmp = make(types.KLineWindow, 0, len(kLines)+1000)
+	tmp = append(tmp, kLines...)
+	if window, ok := store.KLineWindows[interval]; ok {
+		tmp = append(tmp, *window...)
+	}
+
+	if len(tmp) > MaxNumOfKLines {
+		tmp = tmp[len(tmp)-MaxNumOfKLines:]
+	}
+
+	// keep the window pointer, since it might be referenced by the callers of KLinesOfInterval
+	if window, ok := store.KLineWindows[interval]; ok {
+		*window = tmp
+		return
+	}
+
+	store.KLineWindows[interval] = &tmp
+}
+
 func (store *MarketDataStore) AddKLine(kline types.KLine) {
+	store.mu.Lock()
 	window, ok := store.KLineWindows[kline.Interval]
 	if !ok {
 		var tmp = make(types.KLineWindow, 0, 1000)
@@ -60,5 +145,8 @@ func (store *MarketDataStore) AddKLine(kline types.KLine) {
 		*window = (*window)[MaxNumOfKLinesTruncate-1:]
 	}
 
-	store.EmitKLineWindowUpdate(kline.Interval, *window)
+	klines := *window
+	store.mu.Unlock()
+
+	store.EmitKLineWindowUpdate(kline.Interval, klines)
 }
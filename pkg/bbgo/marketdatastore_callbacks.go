@@ -1,17 +0,0 @@
-// Code generated by "callbackgen -type MarketDataStore"; DO NOT EDIT.
-
-package bbgo
-
-import (
-	"github.com/c9s/bbgo/pkg/types"
-)
-
-func (store *MarketDataStore) OnKLineWindowUpdate(cb func(interval types.Interval, klines types.KLineWindow)) {
-	store.kLineWindowUpdateCallbacks = append(store.kLineWindowUpdateCallbacks, cb)
-}
-
-func (store *MarketDataStore) EmitKLineWindowUpdate(interval types.Interval, klines types.KLineWindow) {
-	for _, cb := range store.kLineWindowUpdateCallbacks {
-		cb(interval, klines)
-	}
-}
@@ -0,0 +1,64 @@
+package bbgo
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/indicator"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func TestMarketDataStore_BindWarmUp(t *testing.T) {
+	startTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	kLines := buildSessionTestKLines(types.Interval4h, startTime, 600)
+
+	store := NewMarketDataStore("BTCUSDT")
+	for _, k := range kLines {
+		store.AddKLine(k)
+	}
+	for _, k := range buildSessionTestKLines(types.Interval1h, startTime, 10) {
+		store.AddKLine(k)
+	}
+
+	// the indicator bound to the store directly is warmed up by the loaded klines
+	ewma := &indicator.EWMA{IntervalWindow: types.IntervalWindow{Interval: types.Interval4h, Window: 200}}
+	ewma.Bind(store)
+	assert.True(t, ewma.IsReady())
+	assert.Equal(t, 600, ewma.Length())
+
+	var intervals []types.Interval
+	store.OnKLineWindowUpdate(func(interval types.Interval, klines types.KLineWindow) {
+		intervals = append(intervals, interval)
+	})
+	assert.Equal(t, []types.Interval{types.Interval1h, types.Interval4h}, intervals)
+
+	// the new closed kline is calculated once
+	store.AddKLine(buildSessionTestKLines(types.Interval4h, startTime.Add(600*types.Interval4h.Duration()), 1)[0])
+	assert.Equal(t, 601, ewma.Length())
+}
+
+func TestMarketDataStore_PrependKLines(t *testing.T) {
+	startTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	kLines := buildSessionTestKLines(types.Interval1h, startTime, 200)
+
+	store := NewMarketDataStore("BTCUSDT")
+	store.AddKLine(kLines[100])
+
+	// the historical klines could be loaded while the stream adds the closed klines
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, k := range kLines[101:] {
+			store.AddKLine(k)
+		}
+	}()
+
+	store.PrependKLines(types.Interval1h, kLines[:100])
+	wg.Wait()
+
+	assert.Equal(t, kLines, []types.KLine(store.kLineWindow(types.Interval1h)))
+}
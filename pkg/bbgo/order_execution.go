@@ -21,6 +21,12 @@ type OrderExecutor interface {
 	EmitOrderUpdate(order types.Order)
 }
 
+// OCOOrderExecutor is implemented by the order executors that can submit the native OCO orders.
+// It returns types.ErrOCOOrderNotSupported if the exchange doesn't support the OCO orders.
+type OCOOrderExecutor interface {
+	SubmitOCOOrder(ctx context.Context, takeProfit, stopLoss types.SubmitOrder) (createdOrders types.OrderSlice, err error)
+}
+
 type OrderExecutionRouter interface {
 	// SubmitOrdersTo submit order to a specific exchange Session
 	SubmitOrdersTo(ctx context.Context, session string, orders ...types.SubmitOrder) (createdOrders types.OrderSlice, err error)
@@ -115,6 +121,27 @@ func (e *ExchangeOrderExecutor) SubmitOrders(ctx context.Context, orders ...type
 	return e.Session.Exchange.SubmitOrders(ctx, formattedOrders...)
 }
 
+// SubmitOCOOrder submits the orders as an OCO order if the exchange implements types.ExchangeOCOOrderService
+func (e *ExchangeOrderExecutor) SubmitOCOOrder(ctx context.Context, takeProfit, stopLoss types.SubmitOrder) (types.OrderSlice, error) {
+	ocoService, ok := e.Session.Exchange.(types.ExchangeOCOOrderService)
+	if !ok {
+		return nil, types.ErrOCOOrderNotSupported
+	}
+
+	formattedOrders, err := formatOrders(e.Session, []types.SubmitOrder{takeProfit, stopLoss})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, order := range formattedOrders {
+		log.Infof("submitting oco order: %s", order.String())
+	}
+
+	e.notifySubmitOrders(formattedOrders...)
+
+	return ocoService.SubmitOCOOrder(ctx, formattedOrders[0], formattedOrders[1])
+}
+
 func (e *ExchangeOrderExecutor) CancelOrders(ctx context.Context, orders ...types.Order) error {
 	for _, order := range orders {
 		log.Infof("cancelling order: %s", order)
@@ -311,7 +338,27 @@ func (c *BasicRiskController) ProcessOrders(session *ExchangeSession, orders ...
 		outOrders = append(outOrders, order)
 	}
 
-	return outOrders, nil
+	return outOrders, errs
+}
+
+func (c *BasicRiskController) ID() string {
+	return "basic"
+}
+
+// CheckOrders implements the RiskCheck interface
+func (c *BasicRiskController) CheckOrders(ctx context.Context, session *ExchangeSession, orders ...types.SubmitOrder) ([]types.SubmitOrder, []RiskRejection) {
+	return checkOrdersBy(c.ID(), orders, func(order types.SubmitOrder) (types.SubmitOrder, error) {
+		outOrders, errs := c.ProcessOrders(session, order)
+		if len(errs) > 0 {
+			return order, errs[0]
+		}
+
+		if len(outOrders) == 0 {
+			return order, fmt.Errorf("order is rejected: %s", order.String())
+		}
+
+		return outOrders[0], nil
+	})
 }
 
 func formatOrders(session *ExchangeSession, orders []types.SubmitOrder) (formattedOrders []types.SubmitOrder, err error) {
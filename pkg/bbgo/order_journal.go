@@ -0,0 +1,472 @@
+package bbgo
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/service"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+type JournalOrderStatus string
+
+const (
+	// JournalOrderStatusPending means the order is going to be submitted, the exchange may or may not receive it
+	JournalOrderStatusPending JournalOrderStatus = "pending"
+
+	// JournalOrderStatusSubmitted means the exchange has accepted the order
+	JournalOrderStatusSubmitted JournalOrderStatus = "submitted"
+
+	// JournalOrderStatusCanceling means the cancel request of the order is going to be sent
+	JournalOrderStatusCanceling JournalOrderStatus = "canceling"
+)
+
+// journalClientOrderIDLength keeps the client order ID short enough for the broker prefixes of the exchanges,
+// e.g. binance prepends "x-NSUYEBKM" and limits the client order ID to 32 characters.
+const journalClientOrderIDLength = 20
+
+// JournalOrder is the journal record of an order that is not closed yet
+type JournalOrder struct {
+	ClientOrderID    string             `json:"clientOrderID"`
+	Order            types.SubmitOrder  `json:"order"`
+	OrderID          uint64             `json:"orderID,omitempty"`
+	Status           JournalOrderStatus `json:"status"`
+	ExecutedQuantity fixedpoint.Value   `json:"executedQuantity"`
+	TradeIDs         []uint64           `json:"tradeIDs,omitempty"`
+	UpdatedAt        time.Time          `json:"updatedAt"`
+}
+
+type orderJournalState struct {
+	Orders map[string]JournalOrder `json:"orders"`
+}
+
+// OrderJournal is the write-ahead journal of the order intents.
+// The submit and cancel intents are saved to the persistence store before they are sent to the exchange,
+// and the order updates and the fills from the user data stream keep the journal up-to-date.
+// The closed orders are removed from the journal, so that the journal only contains the orders
+// that could still be alive on the exchange.
+type OrderJournal struct {
+	store service.Store
+
+	mu     sync.Mutex
+	orders map[string]JournalOrder
+
+	// pendingTrades buffers the trades that arrive before the order IDs of the pending records are known,
+	// the trades are applied once the order ID is assigned to the record.
+	pendingTrades map[uint64][]types.Trade
+}
+
+// NewOrderJournal creates the order journal of the given id, e.g. the instance id of the strategy
+func NewOrderJournal(persistence service.PersistenceService, id string) *OrderJournal {
+	return &OrderJournal{
+		store:         persistence.NewStore(id, "order-journal"),
+		orders:        make(map[string]JournalOrder),
+		pendingTrades: make(map[uint64][]types.Trade),
+	}
+}
+
+// Load merges the journal records of the persistence store into the in-memory records,
+// the newer record wins if a record exists in both.
+func (j *OrderJournal) Load() error {
+	var state orderJournalState
+	if err := j.store.Load(&state); err != nil {
+		if err == service.ErrPersistenceNotExists {
+			return nil
+		}
+		return errors.Wrap(err, "order journal load error")
+	}
+
+	j.mu.Lock()
+	for id, o := range state.Orders {
+		if record, ok := j.orders[id]; ok && !record.UpdatedAt.Before(o.UpdatedAt) {
+			continue
+		}
+
+		j.orders[id] = o
+	}
+	j.mu.Unlock()
+	return nil
+}
+
+// Orders returns the journal records
+func (j *OrderJournal) Orders() (orders []JournalOrder) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, o := range j.orders {
+		orders = append(orders, o)
+	}
+	return orders
+}
+
+// save must be called with the lock held
+func (j *OrderJournal) save() error {
+	var state = orderJournalState{Orders: make(map[string]JournalOrder, len(j.orders))}
+	for id, o := range j.orders {
+		state.Orders[id] = o
+	}
+
+	if err := j.store.Save(state); err != nil {
+		return errors.Wrap(err, "order journal save error")
+	}
+	return nil
+}
+
+// RecordSubmit assigns the client order IDs to the orders and saves the submit intents.
+// The returned orders should be submitted instead of the given orders.
+func (j *OrderJournal) RecordSubmit(orders ...types.SubmitOrder) ([]types.SubmitOrder, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var now = time.Now()
+	var outOrders = make([]types.SubmitOrder, 0, len(orders))
+	for _, order := range orders {
+		if order.ClientOrderID == "" || order.ClientOrderID == types.NoClientOrderID {
+			order.ClientOrderID = newJournalClientOrderID()
+		}
+
+		j.orders[order.ClientOrderID] = JournalOrder{
+			ClientOrderID: order.ClientOrderID,
+			Order:         order,
+			Status:        JournalOrderStatusPending,
+			UpdatedAt:     now,
+		}
+		outOrders = append(outOrders, order)
+	}
+
+	return outOrders, j.save()
+}
+
+// RecordSubmitResult updates the journal with the result of the submission.
+// The created orders are marked as submitted. The orders that are not created are removed from the journal
+// only when the result is definite, i.e., no error, the orders are rejected by the risk checks or the OCO order is
+// not supported, otherwise they stay pending until the next recovery since the exchange may have received them.
+func (j *OrderJournal) RecordSubmitResult(orders []types.SubmitOrder, createdOrders []types.Order, err error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var now = time.Now()
+	var created = make(map[string]struct{}, len(createdOrders))
+	for _, o := range createdOrders {
+		record, ok := j.findLocked(o)
+		if !ok {
+			continue
+		}
+
+		created[record.ClientOrderID] = struct{}{}
+		record = j.assignOrderIDLocked(record, o.OrderID)
+		if record.Status == JournalOrderStatusPending {
+			record.Status = JournalOrderStatusSubmitted
+		}
+		record.UpdatedAt = now
+		j.orders[record.ClientOrderID] = record
+	}
+
+	var rejectionErr *RiskRejectionError
+	if err == nil || errors.As(err, &rejectionErr) || errors.Is(err, types.ErrOCOOrderNotSupported) {
+		for _, order := range orders {
+			if _, ok := created[order.ClientOrderID]; ok {
+				continue
+			}
+
+			if record, ok := j.orders[order.ClientOrderID]; ok && record.Status == JournalOrderStatusPending {
+				delete(j.orders, order.ClientOrderID)
+			}
+		}
+	}
+
+	j.prunePendingTradesLocked()
+	return j.save()
+}
+
+// RecordCancel saves the cancel intents of the orders
+func (j *OrderJournal) RecordCancel(orders ...types.Order) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var now = time.Now()
+	for _, o := range orders {
+		record, ok := j.findLocked(o)
+		if !ok {
+			continue
+		}
+
+		record.Status = JournalOrderStatusCanceling
+		record.UpdatedAt = now
+		j.orders[record.ClientOrderID] = record
+	}
+
+	return j.save()
+}
+
+// BindStream updates the journal records with the order updates and the trade updates of the user data stream
+func (j *OrderJournal) BindStream(stream types.Stream) {
+	stream.OnOrderUpdate(j.handleOrderUpdate)
+	stream.OnTradeUpdate(j.handleTradeUpdate)
+}
+
+func (j *OrderJournal) handleOrderUpdate(order types.Order) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	record, ok := j.findLocked(order)
+	if !ok {
+		return
+	}
+
+	switch order.Status {
+	case types.OrderStatusFilled, types.OrderStatusCanceled, types.OrderStatusRejected:
+		delete(j.orders, record.ClientOrderID)
+
+	default:
+		record = j.assignOrderIDLocked(record, order.OrderID)
+		if record.Status == JournalOrderStatusPending {
+			record.Status = JournalOrderStatusSubmitted
+		}
+		record.UpdatedAt = time.Now()
+		j.orders[record.ClientOrderID] = record
+	}
+
+	j.prunePendingTradesLocked()
+	if err := j.save(); err != nil {
+		log.WithError(err).Errorf("can not save the order journal")
+	}
+}
+
+func (j *OrderJournal) handleTradeUpdate(trade types.Trade) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for id, record := range j.orders {
+		if record.OrderID == 0 || record.OrderID != trade.OrderID || record.Order.Symbol != trade.Symbol {
+			continue
+		}
+
+		record, ok := addJournalTrade(record, trade)
+		if !ok {
+			return
+		}
+
+		j.orders[id] = record
+		if err := j.save(); err != nil {
+			log.WithError(err).Errorf("can not save the order journal")
+		}
+		return
+	}
+
+	// the trade may arrive before the submission result or the order update, and the trade does not carry
+	// the client order ID, so buffer it until the order ID of a pending record of the symbol is known.
+	if !j.hasPendingRecordLocked(trade.Symbol) {
+		return
+	}
+
+	for _, t := range j.pendingTrades[trade.OrderID] {
+		if t.ID == trade.ID {
+			return
+		}
+	}
+
+	j.pendingTrades[trade.OrderID] = append(j.pendingTrades[trade.OrderID], trade)
+}
+
+// assignOrderIDLocked sets the order ID of the record and applies the buffered trades of the order,
+// must be called with the lock held
+func (j *OrderJournal) assignOrderIDLocked(record JournalOrder, orderID uint64) JournalOrder {
+	record.OrderID = orderID
+	if orderID == 0 {
+		return record
+	}
+
+	for _, trade := range j.pendingTrades[orderID] {
+		if trade.Symbol != record.Order.Symbol {
+			continue
+		}
+
+		record, _ = addJournalTrade(record, trade)
+	}
+
+	delete(j.pendingTrades, orderID)
+	return record
+}
+
+// hasPendingRecordLocked returns true if a record of the symbol has no order ID yet,
+// must be called with the lock held
+func (j *OrderJournal) hasPendingRecordLocked(symbol string) bool {
+	for _, record := range j.orders {
+		if record.OrderID == 0 && (symbol == "" || record.Order.Symbol == symbol) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// prunePendingTradesLocked drops the buffered trades when no record is waiting for its order ID,
+// must be called with the lock held
+func (j *OrderJournal) prunePendingTradesLocked() {
+	if len(j.pendingTrades) > 0 && !j.hasPendingRecordLocked("") {
+		j.pendingTrades = make(map[uint64][]types.Trade)
+	}
+}
+
+// addJournalTrade adds the trade to the record, it returns false if the trade is already added
+func addJournalTrade(record JournalOrder, trade types.Trade) (JournalOrder, bool) {
+	for _, tradeID := range record.TradeIDs {
+		if tradeID == trade.ID {
+			return record, false
+		}
+	}
+
+	record.TradeIDs = append(record.TradeIDs, trade.ID)
+	record.ExecutedQuantity = record.ExecutedQuantity.Add(trade.Quantity)
+	record.UpdatedAt = time.Now()
+	return record, true
+}
+
+// Recover merges the journal records of the persistence store into the in-memory records, and matches the
+// journal records of the symbol against the open orders of the exchange by the client order ID,
+// the live orders are added to the active order book, and the records that are no longer open are removed.
+// It returns the recovered orders.
+func (j *OrderJournal) Recover(ctx context.Context, ex types.ExchangeTradeService, symbol string, book *LocalActiveOrderBook) ([]types.Order, error) {
+	if err := j.Load(); err != nil {
+		return nil, err
+	}
+
+	openOrders, err := ex.QueryOpenOrders(ctx, symbol)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not query the open orders of %s for the order journal recovery", symbol)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var recovered []types.Order
+	for id, record := range j.orders {
+		if record.Order.Symbol != symbol {
+			continue
+		}
+
+		openOrder, ok := matchJournalOrder(record, openOrders)
+		if !ok {
+			log.Infof("order journal: %s order %s is no longer open, removing it from the journal", record.Status, id)
+			delete(j.orders, id)
+			continue
+		}
+
+		record = j.assignOrderIDLocked(record, openOrder.OrderID)
+		record.Status = JournalOrderStatusSubmitted
+		record.UpdatedAt = time.Now()
+		j.orders[id] = record
+		recovered = append(recovered, openOrder)
+	}
+
+	if book != nil && len(recovered) > 0 {
+		book.Add(recovered...)
+	}
+
+	j.prunePendingTradesLocked()
+	return recovered, j.save()
+}
+
+// findLocked finds the journal record of the order, must be called with the lock held
+func (j *OrderJournal) findLocked(order types.Order) (JournalOrder, bool) {
+	if record, ok := j.orders[order.ClientOrderID]; ok {
+		return record, true
+	}
+
+	for _, record := range j.orders {
+		if record.Order.Symbol != order.Symbol {
+			continue
+		}
+
+		if (record.OrderID != 0 && record.OrderID == order.OrderID) || matchClientOrderID(order.ClientOrderID, record.ClientOrderID) {
+			return record, true
+		}
+	}
+
+	return JournalOrder{}, false
+}
+
+func matchJournalOrder(record JournalOrder, openOrders []types.Order) (types.Order, bool) {
+	for _, o := range openOrders {
+		if matchClientOrderID(o.ClientOrderID, record.ClientOrderID) {
+			return o, true
+		}
+
+		if record.OrderID != 0 && o.OrderID == record.OrderID {
+			return o, true
+		}
+	}
+
+	return types.Order{}, false
+}
+
+// matchClientOrderID matches the client order ID of the exchange with the journal client order ID,
+// some exchanges prepend their broker prefix to the client order ID.
+func matchClientOrderID(exchangeClientOrderID, clientOrderID string) bool {
+	if exchangeClientOrderID == "" || clientOrderID == "" {
+		return false
+	}
+
+	return exchangeClientOrderID == clientOrderID || strings.HasSuffix(exchangeClientOrderID, clientOrderID)
+}
+
+func newJournalClientOrderID() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")[:journalClientOrderIDLength]
+}
+
+// JournalOrderExecutor writes the order intents to the order journal before they are sent to the exchange
+type JournalOrderExecutor struct {
+	OrderExecutor
+
+	Journal *OrderJournal
+}
+
+func (e *JournalOrderExecutor) SubmitOrders(ctx context.Context, orders ...types.SubmitOrder) (types.OrderSlice, error) {
+	orders, err := e.Journal.RecordSubmit(orders...)
+	if err != nil {
+		return nil, err
+	}
+
+	createdOrders, err := e.OrderExecutor.SubmitOrders(ctx, orders...)
+	if journalErr := e.Journal.RecordSubmitResult(orders, createdOrders, err); journalErr != nil {
+		log.WithError(journalErr).Errorf("can not save the order journal")
+	}
+
+	return createdOrders, err
+}
+
+// SubmitOCOOrder writes the submit intents of the OCO order before it's sent through the wrapped executor
+func (e *JournalOrderExecutor) SubmitOCOOrder(ctx context.Context, takeProfit, stopLoss types.SubmitOrder) (types.OrderSlice, error) {
+	ocoExecutor, ok := e.OrderExecutor.(OCOOrderExecutor)
+	if !ok {
+		return nil, types.ErrOCOOrderNotSupported
+	}
+
+	orders, err := e.Journal.RecordSubmit(takeProfit, stopLoss)
+	if err != nil {
+		return nil, err
+	}
+
+	createdOrders, err := ocoExecutor.SubmitOCOOrder(ctx, orders[0], orders[1])
+	if journalErr := e.Journal.RecordSubmitResult(orders, createdOrders, err); journalErr != nil {
+		log.WithError(journalErr).Errorf("can not save the order journal")
+	}
+
+	return createdOrders, err
+}
+
+func (e *JournalOrderExecutor) CancelOrders(ctx context.Context, orders ...types.Order) error {
+	if err := e.Journal.RecordCancel(orders...); err != nil {
+		return err
+	}
+
+	return e.OrderExecutor.CancelOrders(ctx, orders...)
+}
@@ -0,0 +1,180 @@
+package bbgo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/service"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func TestOrderJournal_Recover(t *testing.T) {
+	persistence := service.NewMemoryService()
+	executor, exchange := newTestRiskControlOrderExecutor()
+	journal := NewOrderJournal(persistence, "test")
+	journalExecutor := &JournalOrderExecutor{OrderExecutor: executor, Journal: journal}
+
+	ctx := context.Background()
+	createdOrders, err := journalExecutor.SubmitOrders(ctx, newTestSubmitOrder(types.SideTypeBuy, 90), newTestSubmitOrder(types.SideTypeSell, 110))
+	if !assert.NoError(t, err) || !assert.Len(t, createdOrders, 2) {
+		return
+	}
+
+	// the client order IDs are assigned before the submission
+	for _, o := range exchange.submitted {
+		assert.Len(t, o.ClientOrderID, journalClientOrderIDLength)
+	}
+
+	records := journal.Orders()
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, JournalOrderStatusSubmitted, records[0].Status)
+	}
+
+	buyOrder := createdOrders[0]
+	buyOrder.OrderID = 1
+	sellOrder := createdOrders[1]
+	sellOrder.OrderID = 2
+	journal.handleOrderUpdate(buyOrder)
+	journal.handleOrderUpdate(sellOrder)
+
+	trade := newTestTrade(1, 2, types.SideTypeSell, 0.4)
+	journal.handleTradeUpdate(trade)
+	journal.handleTradeUpdate(trade)
+
+	assert.NoError(t, journalExecutor.CancelOrders(ctx, buyOrder))
+	assert.Len(t, exchange.canceled, 1)
+
+	// restart: the buy order was canceled during the crash, the sell order is still open
+	// and the exchange prepends its broker prefix to the client order ID
+	openOrder := sellOrder
+	openOrder.ClientOrderID = "x-NSUYEBKM" + sellOrder.ClientOrderID
+	openOrder.Status = types.OrderStatusPartiallyFilled
+
+	book := NewLocalActiveOrderBook("BTCUSDT")
+	restarted := NewOrderJournal(persistence, "test")
+	recovered, err := restarted.Recover(ctx, &testReconcileExchange{openOrders: []types.Order{openOrder}}, "BTCUSDT", book)
+	if !assert.NoError(t, err) || !assert.Len(t, recovered, 1) {
+		return
+	}
+
+	assert.Equal(t, uint64(2), recovered[0].OrderID)
+	assert.True(t, book.Exists(openOrder))
+
+	records = restarted.Orders()
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, sellOrder.ClientOrderID, records[0].ClientOrderID)
+		assert.Equal(t, JournalOrderStatusSubmitted, records[0].Status)
+		assert.Equal(t, "0.4", records[0].ExecutedQuantity.String())
+	}
+
+	// the filled order is removed from the journal
+	openOrder.Status = types.OrderStatusFilled
+	openOrder.ExecutedQuantity = fixedpoint.One
+	restarted.handleOrderUpdate(openOrder)
+	assert.Empty(t, restarted.Orders())
+}
+
+func TestOrderJournal_TradeBeforeOrderID(t *testing.T) {
+	journal := NewOrderJournal(service.NewMemoryService(), "test")
+	orders, err := journal.RecordSubmit(newTestSubmitOrder(types.SideTypeBuy, 90))
+	if !assert.NoError(t, err) || !assert.Len(t, orders, 1) {
+		return
+	}
+
+	// the trade arrives before the order ID of the pending record is known
+	trade := newTestTrade(1, 5, types.SideTypeBuy, 0.3)
+	journal.handleTradeUpdate(trade)
+	journal.handleTradeUpdate(trade)
+
+	records := journal.Orders()
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "0", records[0].ExecutedQuantity.String())
+	}
+
+	journal.handleOrderUpdate(types.Order{SubmitOrder: orders[0], OrderID: 5, Status: types.OrderStatusPartiallyFilled})
+
+	records = journal.Orders()
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, uint64(5), records[0].OrderID)
+		assert.Equal(t, "0.3", records[0].ExecutedQuantity.String())
+		assert.Equal(t, []uint64{1}, records[0].TradeIDs)
+	}
+	assert.Empty(t, journal.pendingTrades)
+}
+
+func TestOrderJournal_RecoverMergesRecords(t *testing.T) {
+	persistence := service.NewMemoryService()
+	journal := NewOrderJournal(persistence, "test")
+
+	orders, err := journal.RecordSubmit(newTestSubmitOrder(types.SideTypeBuy, 90))
+	if !assert.NoError(t, err) || !assert.Len(t, orders, 1) {
+		return
+	}
+
+	buyOrder := types.Order{SubmitOrder: orders[0], OrderID: 1, Status: types.OrderStatusNew}
+	journal.handleOrderUpdate(buyOrder)
+	journal.handleTradeUpdate(newTestTrade(1, 1, types.SideTypeBuy, 0.3))
+
+	// the store holds an older record of the buy order and a record that is not in memory
+	sellOrder := newTestSubmitOrder(types.SideTypeSell, 110)
+	sellOrder.ClientOrderID = "sell"
+	assert.NoError(t, persistence.NewStore("test", "order-journal").Save(orderJournalState{
+		Orders: map[string]JournalOrder{
+			orders[0].ClientOrderID: {ClientOrderID: orders[0].ClientOrderID, Order: orders[0], Status: JournalOrderStatusPending},
+			"sell":                  {ClientOrderID: "sell", Order: sellOrder, OrderID: 2, Status: JournalOrderStatusSubmitted},
+		},
+	}))
+
+	openOrders := []types.Order{
+		buyOrder,
+		{SubmitOrder: sellOrder, OrderID: 2, Status: types.OrderStatusNew},
+	}
+
+	recovered, err := journal.Recover(context.Background(), &testReconcileExchange{openOrders: openOrders}, "BTCUSDT", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, recovered, 2)
+
+	records := journal.Orders()
+	if assert.Len(t, records, 2) {
+		for _, record := range records {
+			if record.ClientOrderID == orders[0].ClientOrderID {
+				assert.Equal(t, "0.3", record.ExecutedQuantity.String())
+			}
+		}
+	}
+}
+
+func TestOrderJournal_RiskRejection(t *testing.T) {
+	executor, _ := newTestRiskControlOrderExecutor()
+	executor.Checks = RiskCheckList{&testMaxQuantityCheck{MaxQuantity: fixedpoint.One}}
+
+	journal := NewOrderJournal(service.NewMemoryService(), "test")
+	journalExecutor := &JournalOrderExecutor{OrderExecutor: executor, Journal: journal}
+
+	large := newTestSubmitOrder(types.SideTypeBuy, 90)
+	large.Quantity = fixedpoint.NewFromInt(2)
+
+	createdOrders, err := journalExecutor.SubmitOrders(context.Background(), newTestSubmitOrder(types.SideTypeBuy, 90), large)
+	assert.Error(t, err)
+	assert.Len(t, createdOrders, 1)
+
+	// the rejected order is never sent, so it is removed from the journal
+	assert.Len(t, journal.Orders(), 1)
+
+	// all the orders are rejected
+	_, err = journalExecutor.SubmitOrders(context.Background(), large)
+	assert.Error(t, err)
+	assert.Len(t, journal.Orders(), 1)
+}
+
+func TestMatchClientOrderID(t *testing.T) {
+	assert.True(t, matchClientOrderID("abc", "abc"))
+	assert.True(t, matchClientOrderID("x-NSUYEBKMabc", "abc"))
+	assert.False(t, matchClientOrderID("abcd", "abc"))
+	assert.False(t, matchClientOrderID("", ""))
+}
@@ -1,6 +1,7 @@
 package bbgo
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 
@@ -39,6 +40,13 @@ func (p *Persistence) backendService(t string) (service.PersistenceService, erro
 	case "memory":
 		return p.Facade.Memory, nil
 
+	case "database":
+		if p.Facade.Database == nil {
+			log.Warn("database persistence is not available, fallback to memory backend")
+			return p.Facade.Memory, nil
+		}
+		return p.Facade.Database, nil
+
 	}
 
 	return nil, fmt.Errorf("unsupported persistent type %s", t)
@@ -57,7 +65,7 @@ func (p *Persistence) Load(val interface{}, subIDs ...string) error {
 	}
 
 	store := ps.NewStore(p.PersistenceSelector.StoreID, subIDs...)
-	return store.Load(val)
+	return loadPersistenceValue(store, persistenceMigrationKeyOf(subIDs), val)
 }
 
 func (p *Persistence) Save(val interface{}, subIDs ...string) error {
@@ -73,7 +81,7 @@ func (p *Persistence) Save(val interface{}, subIDs ...string) error {
 	}
 
 	store := ps.NewStore(p.PersistenceSelector.StoreID, subIDs...)
-	return store.Save(val)
+	return savePersistenceValue(store, persistenceMigrationKeyOf(subIDs), val)
 }
 
 func (p *Persistence) Sync(obj interface{}) error {
@@ -140,12 +148,23 @@ func newTypeValueInterface(typ reflect.Type) interface{} {
 	return dst.Interface()
 }
 
+// persistenceStrategyID returns the strategy ID of obj that the migrations of the persistence fields are registered with
+func persistenceStrategyID(obj interface{}) string {
+	if s, ok := obj.(StrategyID); ok {
+		return s.ID()
+	}
+
+	return ""
+}
+
 func loadPersistenceFields(obj interface{}, id string, persistence service.PersistenceService) error {
+	strategyID := persistenceStrategyID(obj)
 	return iterateFieldsByTag(obj, "persistence", func(tag string, field reflect.StructField, value reflect.Value) error {
 		newValueInf := newTypeValueInterface(value.Type())
 		// inf := value.Interface()
 		store := persistence.NewStore("state", id, tag)
-		if err := store.Load(&newValueInf); err != nil {
+		key := persistenceMigrationKey{StrategyID: strategyID, Field: tag}
+		if err := loadPersistenceValue(store, key, newValueInf); err != nil {
 			if err == service.ErrPersistenceNotExists {
 				return nil
 			}
@@ -165,11 +184,24 @@ func loadPersistenceFields(obj interface{}, id string, persistence service.Persi
 	})
 }
 
+// storePersistenceFields saves the persistence fields of obj, the fields are saved atomically
+// if the persistence service supports transactions.
 func storePersistenceFields(obj interface{}, id string, persistence service.PersistenceService) error {
+	if txPersistence, ok := persistence.(service.TransactionalPersistenceService); ok {
+		return txPersistence.Transaction(context.Background(), func(ps service.PersistenceService) error {
+			return storeFields(obj, id, ps)
+		})
+	}
+
+	return storeFields(obj, id, persistence)
+}
+
+func storeFields(obj interface{}, id string, persistence service.PersistenceService) error {
+	strategyID := persistenceStrategyID(obj)
 	return iterateFieldsByTag(obj, "persistence", func(tag string, ft reflect.StructField, fv reflect.Value) error {
 		inf := fv.Interface()
 
 		store := persistence.NewStore("state", id, tag)
-		return store.Save(inf)
+		return savePersistenceValue(store, persistenceMigrationKey{StrategyID: strategyID, Field: tag}, inf)
 	})
 }
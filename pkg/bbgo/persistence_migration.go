@@ -0,0 +1,193 @@
+package bbgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/c9s/bbgo/pkg/service"
+)
+
+// legacyPersistenceSchemaVersion is the schema version of the values that are stored without the version envelope
+const legacyPersistenceSchemaVersion = 1
+
+// PersistenceMigrationFunc migrates the json data of a persisted value from the previous schema version
+type PersistenceMigrationFunc func(data json.RawMessage) (json.RawMessage, error)
+
+var persistenceMigrationsMutex sync.RWMutex
+
+// persistenceMigrationKey identifies the persisted values that share the same schema,
+// e.g. the persistence field "state" of the strategy "grid"
+type persistenceMigrationKey struct {
+	StrategyID string
+	Field      string
+}
+
+func (k persistenceMigrationKey) String() string {
+	return k.StrategyID + "." + k.Field
+}
+
+// persistenceMigrationKeyOf returns the migration key of the values saved by Persistence.Load and Persistence.Save,
+// the first sub ID is the strategy ID and the last sub ID is the field.
+func persistenceMigrationKeyOf(subIDs []string) persistenceMigrationKey {
+	switch len(subIDs) {
+	case 0:
+		return persistenceMigrationKey{}
+	case 1:
+		return persistenceMigrationKey{StrategyID: subIDs[0]}
+	}
+
+	return persistenceMigrationKey{StrategyID: subIDs[0], Field: subIDs[len(subIDs)-1]}
+}
+
+// persistenceMigrations maps the persisted values to the migrations of the schema versions
+var persistenceMigrations = make(map[persistenceMigrationKey]map[int]PersistenceMigrationFunc)
+
+// RegisterPersistenceMigration registers the migration of the persisted values of the strategy field
+// from the schema version toVersion-1 to toVersion.
+//
+// The field is the tag of the `persistence:"..."` field, or the last sub ID passed to Persistence.Load and
+// Persistence.Save whose first sub ID is the strategy ID, e.g. Load(&state, ID, s.Symbol, "state") is migrated
+// by the migrations of (ID, "state").
+//
+// The highest registered version is the current schema version of the field, the values stored
+// before the schema versioning are version 1. When a value of an older version is loaded,
+// the migrations are applied one version at a time, for example:
+//
+//	func init() {
+//	    bbgo.RegisterPersistenceMigration(ID, "state", 2, func(data json.RawMessage) (json.RawMessage, error) {
+//	        // rename the field "profit" to "profitStats"
+//	    })
+//	}
+func RegisterPersistenceMigration(strategyID, field string, toVersion int, fn PersistenceMigrationFunc) {
+	key := persistenceMigrationKey{StrategyID: strategyID, Field: field}
+	if toVersion <= legacyPersistenceSchemaVersion {
+		panic(fmt.Errorf("the migration version of %s must be greater than %d, %d given", key, legacyPersistenceSchemaVersion, toVersion))
+	}
+
+	persistenceMigrationsMutex.Lock()
+	defer persistenceMigrationsMutex.Unlock()
+
+	migrations, ok := persistenceMigrations[key]
+	if !ok {
+		migrations = make(map[int]PersistenceMigrationFunc)
+		persistenceMigrations[key] = migrations
+	}
+
+	if _, ok := migrations[toVersion]; ok {
+		panic(fmt.Errorf("the migration of %s to version %d is already registered", key, toVersion))
+	}
+
+	migrations[toVersion] = fn
+}
+
+// persistenceSchemaVersion returns the current schema version of the key
+func persistenceSchemaVersion(key persistenceMigrationKey) int {
+	persistenceMigrationsMutex.RLock()
+	defer persistenceMigrationsMutex.RUnlock()
+
+	version := legacyPersistenceSchemaVersion
+	for v := range persistenceMigrations[key] {
+		if v > version {
+			version = v
+		}
+	}
+
+	return version
+}
+
+// migratePersistenceData migrates the data of the key from the given version to the current schema version
+func migratePersistenceData(key persistenceMigrationKey, version int, data json.RawMessage) (json.RawMessage, error) {
+	currentVersion := persistenceSchemaVersion(key)
+	if version > currentVersion {
+		return nil, fmt.Errorf("the schema version %d of the persisted %s is newer than the current version %d", version, key, currentVersion)
+	}
+
+	persistenceMigrationsMutex.RLock()
+	migrations := persistenceMigrations[key]
+	persistenceMigrationsMutex.RUnlock()
+
+	for v := version + 1; v <= currentVersion; v++ {
+		fn, ok := migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("the migration of the persisted %s to version %d is not registered", key, v)
+		}
+
+		var err error
+		data, err = fn(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "persisted %s migration to version %d error", key, v)
+		}
+	}
+
+	return data, nil
+}
+
+// persistenceEnvelope wraps the json data of a persisted value with its schema version
+type persistenceEnvelope struct {
+	SchemaVersion int
+	Data          json.RawMessage
+}
+
+type persistenceEnvelopeJSON struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Data          json.RawMessage `json:"data"`
+}
+
+func (e persistenceEnvelope) MarshalJSON() ([]byte, error) {
+	return json.Marshal(persistenceEnvelopeJSON{SchemaVersion: e.SchemaVersion, Data: e.Data})
+}
+
+// UnmarshalJSON decodes the envelope, the data without the envelope is decoded as the legacy version
+func (e *persistenceEnvelope) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err == nil && len(fields) == 2 {
+		_, hasVersion := fields["schemaVersion"]
+		_, hasData := fields["data"]
+		if hasVersion && hasData {
+			var envelope persistenceEnvelopeJSON
+			if err := json.Unmarshal(data, &envelope); err != nil {
+				return err
+			}
+
+			e.SchemaVersion = envelope.SchemaVersion
+			e.Data = envelope.Data
+			return nil
+		}
+	}
+
+	e.SchemaVersion = legacyPersistenceSchemaVersion
+	e.Data = append(json.RawMessage(nil), bytes.TrimSpace(data)...)
+	return nil
+}
+
+// savePersistenceValue saves the value with the version envelope of the key
+func savePersistenceValue(store service.Store, key persistenceMigrationKey, val interface{}) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	return store.Save(persistenceEnvelope{
+		SchemaVersion: persistenceSchemaVersion(key),
+		Data:          data,
+	})
+}
+
+// loadPersistenceValue loads the value and migrates it to the current schema version of the key
+func loadPersistenceValue(store service.Store, key persistenceMigrationKey, val interface{}) error {
+	var envelope persistenceEnvelope
+	if err := store.Load(&envelope); err != nil {
+		return err
+	}
+
+	data, err := migratePersistenceData(key, envelope.SchemaVersion, envelope.Data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, val)
+}
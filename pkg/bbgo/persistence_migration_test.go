@@ -0,0 +1,109 @@
+package bbgo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/service"
+)
+
+var testMigrationKey = persistenceMigrationKey{StrategyID: "migration-test", Field: "state"}
+
+// testMigratedState renamed the field "profit" to "totalProfit" in version 2,
+// and changed the quantity from a float to a fixedpoint string in version 3
+type testMigratedState struct {
+	TotalProfit fixedpoint.Value `json:"totalProfit"`
+	Quantity    string           `json:"quantity"`
+}
+
+func init() {
+	RegisterPersistenceMigration(testMigrationKey.StrategyID, testMigrationKey.Field, 2, func(data json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+
+		fields["totalProfit"] = fields["profit"]
+		delete(fields, "profit")
+		return json.Marshal(fields)
+	})
+
+	RegisterPersistenceMigration(testMigrationKey.StrategyID, testMigrationKey.Field, 3, func(data json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+
+		if q, ok := fields["quantity"].(float64); ok {
+			fields["quantity"] = fixedpoint.NewFromFloat(q).String()
+		}
+		return json.Marshal(fields)
+	})
+}
+
+func TestPersistenceMigration(t *testing.T) {
+	assert.Equal(t, 3, persistenceSchemaVersion(testMigrationKey))
+
+	// the other fields of the same strategy are not migrated even if they share the same Go type
+	assert.Equal(t, legacyPersistenceSchemaVersion, persistenceSchemaVersion(persistenceMigrationKey{StrategyID: "migration-test", Field: "backup"}))
+	assert.Equal(t, testMigrationKey, persistenceMigrationKeyOf([]string{"migration-test", "BTCUSDT", "state"}))
+
+	ps := &service.JsonPersistenceService{Directory: "testoutput/persistence"}
+	store := ps.NewStore("migration-test")
+
+	t.Run("legacy", func(t *testing.T) {
+		// the value saved before the schema versioning has no envelope
+		assert.NoError(t, store.Save(map[string]interface{}{"profit": "10.5", "quantity": 0.25}))
+
+		var state testMigratedState
+		if assert.NoError(t, loadPersistenceValue(store, testMigrationKey, &state)) {
+			assert.Equal(t, "10.5", state.TotalProfit.String())
+			assert.Equal(t, "0.25", state.Quantity)
+		}
+	})
+
+	t.Run("envelope", func(t *testing.T) {
+		assert.NoError(t, store.Save(persistenceEnvelope{SchemaVersion: 2, Data: json.RawMessage(`{"totalProfit":"3","quantity":1.5}`)}))
+
+		var state *testMigratedState
+		if assert.NoError(t, loadPersistenceValue(store, testMigrationKey, &state)) {
+			assert.Equal(t, "3", state.TotalProfit.String())
+			assert.Equal(t, "1.5", state.Quantity)
+		}
+
+		assert.NoError(t, savePersistenceValue(store, testMigrationKey, state))
+
+		var envelope persistenceEnvelope
+		if assert.NoError(t, store.Load(&envelope)) {
+			assert.Equal(t, 3, envelope.SchemaVersion)
+		}
+	})
+
+	t.Run("newer version", func(t *testing.T) {
+		assert.NoError(t, store.Save(persistenceEnvelope{SchemaVersion: 4, Data: json.RawMessage(`{}`)}))
+
+		var state testMigratedState
+		assert.Error(t, loadPersistenceValue(store, testMigrationKey, &state))
+	})
+
+	assert.NoError(t, store.Reset())
+}
+
+func TestPersistenceEnvelope_UnmarshalJSON(t *testing.T) {
+	var envelope persistenceEnvelope
+	assert.NoError(t, json.Unmarshal([]byte(`{"schemaVersion":2,"data":[1,2]}`), &envelope))
+	assert.Equal(t, 2, envelope.SchemaVersion)
+	assert.Equal(t, "[1,2]", string(envelope.Data))
+
+	// the legacy data that has other fields
+	assert.NoError(t, json.Unmarshal([]byte(`{"schemaVersion":2,"data":[1,2],"other":1}`), &envelope))
+	assert.Equal(t, legacyPersistenceSchemaVersion, envelope.SchemaVersion)
+	assert.Equal(t, `{"schemaVersion":2,"data":[1,2],"other":1}`, string(envelope.Data))
+
+	assert.NoError(t, json.Unmarshal([]byte(`12`), &envelope))
+	assert.Equal(t, legacyPersistenceSchemaVersion, envelope.SchemaVersion)
+	assert.Equal(t, "12", string(envelope.Data))
+}
@@ -1,6 +1,7 @@
 package bbgo
 
 import (
+	"context"
 	"os"
 	"reflect"
 	"testing"
@@ -24,7 +25,7 @@ func (t *TestStruct) InstanceID() string {
 	return "test-struct"
 }
 
-func preparePersistentServices() []service.PersistenceService {
+func preparePersistentServices(t *testing.T) []service.PersistenceService {
 	mem := service.NewMemoryService()
 	jsonDir := &service.JsonPersistenceService{Directory: "testoutput/persistence"}
 	pss := []service.PersistenceService{
@@ -32,6 +33,15 @@ func preparePersistentServices() []service.PersistenceService {
 		jsonDir,
 	}
 
+	databaseService := service.NewDatabaseService("sqlite3", ":memory:")
+	if assert.NoError(t, databaseService.Connect()) {
+		// every connection of the in-memory sqlite database is a new database
+		databaseService.DB.SetMaxOpenConns(1)
+		if assert.NoError(t, databaseService.Upgrade(context.Background())) {
+			pss = append(pss, service.NewDatabasePersistenceService(databaseService.DB, 0))
+		}
+	}
+
 	if _, ok := os.LookupEnv("TEST_REDIS"); ok {
 		redisP := service.NewRedisPersistenceService(&service.RedisPersistenceConfig{
 			Host: "localhost",
@@ -50,7 +60,7 @@ func Test_callID(t *testing.T) {
 }
 
 func Test_storePersistenceFields(t *testing.T) {
-	var pss = preparePersistentServices()
+	var pss = preparePersistentServices(t)
 
 	var a = &TestStruct{
 		Integer:  1,
@@ -71,13 +81,13 @@ func Test_storePersistenceFields(t *testing.T) {
 
 			var i int64
 			store := ps.NewStore("state", "test-struct", "integer")
-			err = store.Load(&i)
+			err = loadPersistenceValue(store, persistenceMigrationKey{Field: "integer"}, &i)
 			assert.NoError(t, err)
 			assert.Equal(t, int64(1), i)
 
 			var p *types.Position
 			store = ps.NewStore("state", "test-struct", "position")
-			err = store.Load(&p)
+			err = loadPersistenceValue(store, persistenceMigrationKey{Field: "position"}, &p)
 			assert.NoError(t, err)
 			assert.Equal(t, fixedpoint.NewFromFloat(10.0), p.Base)
 			assert.Equal(t, fixedpoint.NewFromFloat(3343.0), p.AverageCost)
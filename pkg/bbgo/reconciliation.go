@@ -0,0 +1,391 @@
+package bbgo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const (
+	defaultReconciliationInterval = 5 * time.Minute
+	defaultReconciliationLookback = time.Hour
+)
+
+var ErrPositionDrift = errors.New("position drift is above the threshold")
+
+// ReconciliationConfig is the config of the position reconciliation
+type ReconciliationConfig struct {
+	// Interval is the interval of the reconciliation, the default interval is 5m
+	Interval types.Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+
+	// Lookback is the time range of the trades that are queried from the exchange, the default lookback is 1h
+	Lookback types.Duration `json:"lookback,omitempty" yaml:"lookback,omitempty"`
+
+	// Repair recovers the missing trades of the tracked orders into the positions
+	Repair bool `json:"repair,omitempty" yaml:"repair,omitempty"`
+
+	// CheckBalances compares the base asset balance with the sum of the positions of the same asset.
+	// Only enable it when the registered positions own the whole balance of the base assets.
+	CheckBalances bool `json:"checkBalances,omitempty" yaml:"checkBalances,omitempty"`
+
+	// MaxDriftAmount is the max drift in the quote currency, the new orders of the symbol are rejected
+	// when the drift is above the threshold. The orders are never blocked if it's zero.
+	MaxDriftAmount fixedpoint.Value `json:"maxDriftAmount,omitempty" yaml:"maxDriftAmount,omitempty"`
+}
+
+// ReconciliationReport is the drift of a position and its order store from the exchange state
+type ReconciliationReport struct {
+	Session string    `json:"session"`
+	Symbol  string    `json:"symbol"`
+	Time    time.Time `json:"time"`
+
+	// PositionBase is the base quantity of the position after the repair
+	PositionBase fixedpoint.Value `json:"positionBase"`
+
+	// MissingTrades are the trades of the tracked orders that are not added to the position
+	MissingTrades []types.Trade `json:"missingTrades,omitempty"`
+
+	// RecoveredTrades is the number of the missing trades that are recovered into the position
+	RecoveredTrades int `json:"recoveredTrades"`
+
+	// PositionDrift is the base quantity of the missing trades that are not recovered
+	PositionDrift fixedpoint.Value `json:"positionDrift"`
+
+	// BalanceDrift is the base asset balance minus the sum of the positions of the same asset,
+	// it's only calculated when CheckBalances is enabled
+	BalanceDrift fixedpoint.Value `json:"balanceDrift"`
+
+	// StaleOrders are the active orders of the order store that are not open on the exchange
+	StaleOrders []types.Order `json:"staleOrders,omitempty"`
+
+	// UntrackedOrders are the open orders on the exchange that are not in the order stores
+	UntrackedOrders []types.Order `json:"untrackedOrders,omitempty"`
+
+	// DriftAmount is the absolute drift in the quote currency
+	DriftAmount fixedpoint.Value `json:"driftAmount"`
+
+	Blocked bool `json:"blocked"`
+}
+
+// HasDrift returns true if the position or the order store drifts from the exchange state,
+// the untracked orders are not counted since they can be placed by the other programs
+func (r ReconciliationReport) HasDrift() bool {
+	return !r.PositionDrift.IsZero() || !r.BalanceDrift.IsZero() || len(r.StaleOrders) > 0
+}
+
+func (r ReconciliationReport) String() string {
+	return fmt.Sprintf("%s %s reconciliation: position %s, missing trades %d (recovered %d), position drift %s, balance drift %s, stale orders %d, untracked orders %d, drift amount %s",
+		r.Session, r.Symbol,
+		r.PositionBase.String(),
+		len(r.MissingTrades), r.RecoveredTrades,
+		r.PositionDrift.String(), r.BalanceDrift.String(),
+		len(r.StaleOrders), len(r.UntrackedOrders),
+		r.DriftAmount.String())
+}
+
+type reconciliationEntry struct {
+	session   *ExchangeSession
+	collector *TradeCollector
+}
+
+// ReconciliationService periodically compares the positions and the order stores of the trade collectors
+// with the exchange balances, open orders and trades.
+//
+// It also implements the RiskCheck interface to reject the new orders of the symbols that have too much drift.
+type ReconciliationService struct {
+	*ReconciliationConfig
+	*Notifiability
+
+	mu      sync.Mutex
+	entries []reconciliationEntry
+	blocked map[sessionSymbol]ReconciliationReport
+}
+
+func NewReconciliationService(config *ReconciliationConfig, notifiability *Notifiability) *ReconciliationService {
+	return &ReconciliationService{
+		ReconciliationConfig: config,
+		Notifiability:        notifiability,
+		blocked:              make(map[sessionSymbol]ReconciliationReport),
+	}
+}
+
+// AddTradeCollector registers the position and the order store of the trade collector for the reconciliation.
+// The registration is opt-in, only the trade collectors of the strategies that call it are reconciled.
+func (s *ReconciliationService) AddTradeCollector(session *ExchangeSession, collector *TradeCollector) {
+	s.mu.Lock()
+	s.entries = append(s.entries, reconciliationEntry{session: session, collector: collector})
+	s.mu.Unlock()
+}
+
+func (s *ReconciliationService) Run(ctx context.Context) {
+	interval := s.Interval.Duration()
+	if interval == 0 {
+		interval = defaultReconciliationInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case now := <-ticker.C:
+			if _, err := s.Reconcile(ctx, now); err != nil {
+				log.WithError(err).Errorf("reconciliation error")
+			}
+		}
+	}
+}
+
+// Reconcile compares all the registered positions with the exchange state, and updates the blocked symbols
+func (s *ReconciliationService) Reconcile(ctx context.Context, now time.Time) (reports []ReconciliationReport, err error) {
+	s.mu.Lock()
+	entries := s.entries
+	s.mu.Unlock()
+
+	for _, entry := range entries {
+		report, err := s.reconcileEntry(ctx, now, entry, entries)
+		if err != nil {
+			return reports, errors.Wrapf(err, "%s %s reconciliation error", entry.session.Name, entry.collector.Symbol)
+		}
+
+		reports = append(reports, report)
+	}
+
+	// the balance drift can only be calculated after the positions of the same asset are repaired
+	if s.CheckBalances {
+		if err := s.updateBalanceDrifts(ctx, entries, reports); err != nil {
+			return reports, err
+		}
+	}
+
+	for i := range reports {
+		s.updateBlocked(&reports[i])
+	}
+
+	return reports, nil
+}
+
+func (s *ReconciliationService) reconcileEntry(ctx context.Context, now time.Time, entry reconciliationEntry, entries []reconciliationEntry) (ReconciliationReport, error) {
+	session, collector := entry.session, entry.collector
+	symbol := collector.Symbol
+	report := ReconciliationReport{
+		Session: session.Name,
+		Symbol:  symbol,
+		Time:    now,
+	}
+
+	openOrders, err := session.Exchange.QueryOpenOrders(ctx, symbol)
+	if err != nil {
+		return report, err
+	}
+
+	report.StaleOrders, report.UntrackedOrders = compareOpenOrders(collector.OrderStore(), openOrders, entries, session)
+
+	if historyService, ok := session.Exchange.(types.ExchangeTradeHistoryService); ok {
+		lookback := s.Lookback.Duration()
+		if lookback == 0 {
+			lookback = defaultReconciliationLookback
+		}
+
+		from := now.Add(-lookback)
+		trades, err := historyService.QueryTrades(ctx, symbol, &types.TradeQueryOptions{StartTime: &from})
+		if err != nil {
+			return report, err
+		}
+
+		report.MissingTrades = findMissingTrades(collector, trades)
+		if s.Repair && len(report.MissingTrades) > 0 {
+			if err := collector.Recover(ctx, historyService, symbol, from); err != nil {
+				return report, err
+			}
+		}
+
+		for _, trade := range report.MissingTrades {
+			if collector.Processed(trade) {
+				report.RecoveredTrades++
+				continue
+			}
+
+			if trade.Side == types.SideTypeBuy {
+				report.PositionDrift = report.PositionDrift.Add(trade.Quantity)
+			} else {
+				report.PositionDrift = report.PositionDrift.Sub(trade.Quantity)
+			}
+		}
+	}
+
+	report.PositionBase = collector.Position().GetBase()
+	return report, nil
+}
+
+// updateBalanceDrifts compares the base asset balances with the sum of the positions of the same asset
+func (s *ReconciliationService) updateBalanceDrifts(ctx context.Context, entries []reconciliationEntry, reports []ReconciliationReport) error {
+	type sessionAsset struct {
+		session, asset string
+	}
+
+	var positions = make(map[sessionAsset]fixedpoint.Value)
+	var balances = make(map[string]types.BalanceMap)
+	for i, entry := range entries {
+		market, ok := entry.session.Market(entry.collector.Symbol)
+		if !ok {
+			return fmt.Errorf("market %s is not defined", entry.collector.Symbol)
+		}
+
+		key := sessionAsset{session: entry.session.Name, asset: market.BaseCurrency}
+		positions[key] = positions[key].Add(reports[i].PositionBase)
+
+		if _, ok := balances[entry.session.Name]; !ok {
+			b, err := entry.session.Exchange.QueryAccountBalances(ctx)
+			if err != nil {
+				return err
+			}
+
+			balances[entry.session.Name] = b
+		}
+	}
+
+	for i, entry := range entries {
+		market, _ := entry.session.Market(entry.collector.Symbol)
+		key := sessionAsset{session: entry.session.Name, asset: market.BaseCurrency}
+
+		balance := fixedpoint.Zero
+		if b, ok := balances[entry.session.Name][market.BaseCurrency]; ok {
+			balance = b.Total()
+		}
+
+		reports[i].BalanceDrift = balance.Sub(positions[key])
+	}
+
+	return nil
+}
+
+// updateBlocked calculates the drift amount, and blocks or unblocks the symbol by the threshold
+func (s *ReconciliationService) updateBlocked(report *ReconciliationReport) {
+	drift := report.PositionDrift.Add(report.BalanceDrift).Abs()
+	report.DriftAmount = drift
+
+	s.mu.Lock()
+	for _, entry := range s.entries {
+		if entry.session.Name == report.Session && entry.collector.Symbol == report.Symbol {
+			if price, ok := entry.session.LastPrice(report.Symbol); ok {
+				report.DriftAmount = drift.Mul(price)
+			}
+			break
+		}
+	}
+
+	key := sessionSymbol{session: report.Session, symbol: report.Symbol}
+	_, wasBlocked := s.blocked[key]
+	report.Blocked = s.MaxDriftAmount.Sign() > 0 && report.DriftAmount.Compare(s.MaxDriftAmount) > 0
+	if report.Blocked {
+		s.blocked[key] = *report
+	} else {
+		delete(s.blocked, key)
+	}
+	s.mu.Unlock()
+
+	if report.HasDrift() {
+		log.Warn(report.String())
+	}
+
+	switch {
+	case report.Blocked && !wasBlocked:
+		s.notify(":no_entry: %s %s orders are blocked, drift amount %s exceeds %s: %s", report.Session, report.Symbol,
+			report.DriftAmount.String(), s.MaxDriftAmount.String(), report.String())
+
+	case !report.Blocked && wasBlocked:
+		s.notify(":white_check_mark: %s %s orders are unblocked, drift amount %s", report.Session, report.Symbol, report.DriftAmount.String())
+
+	case report.HasDrift():
+		s.notify(":warning: %s", report.String())
+	}
+}
+
+func (s *ReconciliationService) notify(msg string, args ...interface{}) {
+	if s.Notifiability != nil {
+		s.Notify(msg, args...)
+	}
+}
+
+// Blocked returns the last report of the symbol if the orders of the symbol are blocked
+func (s *ReconciliationService) Blocked(session, symbol string) (ReconciliationReport, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report, ok := s.blocked[sessionSymbol{session: session, symbol: symbol}]
+	return report, ok
+}
+
+func (s *ReconciliationService) ID() string {
+	return "reconciliation"
+}
+
+// CheckOrders rejects the orders of the blocked symbols
+func (s *ReconciliationService) CheckOrders(ctx context.Context, session *ExchangeSession, orders ...types.SubmitOrder) ([]types.SubmitOrder, []RiskRejection) {
+	return checkOrdersBy(s.ID(), orders, func(order types.SubmitOrder) (types.SubmitOrder, error) {
+		if report, ok := s.Blocked(session.Name, order.Symbol); ok {
+			return order, errors.Wrapf(ErrPositionDrift, "drift amount %s exceeds %s", report.DriftAmount.String(), s.MaxDriftAmount.String())
+		}
+
+		return order, nil
+	})
+}
+
+// findMissingTrades returns the trades of the tracked orders that are not processed by the trade collector
+func findMissingTrades(collector *TradeCollector, trades []types.Trade) (missing []types.Trade) {
+	store := collector.OrderStore()
+	for _, trade := range trades {
+		if !store.Exists(trade.OrderID) || collector.Processed(trade) {
+			continue
+		}
+
+		missing = append(missing, trade)
+	}
+
+	return missing
+}
+
+// compareOpenOrders returns the active orders of the order store that are not open on the exchange,
+// and the open orders on the exchange that are not tracked by any order store of the session
+func compareOpenOrders(store *OrderStore, openOrders []types.Order, entries []reconciliationEntry, session *ExchangeSession) (stale, untracked []types.Order) {
+	var open = make(map[uint64]struct{}, len(openOrders))
+	for _, o := range openOrders {
+		open[o.OrderID] = struct{}{}
+	}
+
+	for _, o := range store.Orders() {
+		switch o.Status {
+		case types.OrderStatusNew, types.OrderStatusPartiallyFilled:
+			if _, ok := open[o.OrderID]; !ok {
+				stale = append(stale, o)
+			}
+		}
+	}
+
+	for _, o := range openOrders {
+		tracked := false
+		for _, entry := range entries {
+			if entry.session == session && entry.collector.OrderStore().Exists(o.OrderID) {
+				tracked = true
+				break
+			}
+		}
+
+		if !tracked {
+			untracked = append(untracked, o)
+		}
+	}
+
+	return stale, untracked
+}
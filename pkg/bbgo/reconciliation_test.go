@@ -0,0 +1,147 @@
+package bbgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// testReconcileExchange returns the fixed exchange state
+type testReconcileExchange struct {
+	types.Exchange
+
+	openOrders []types.Order
+	trades     []types.Trade
+	balances   types.BalanceMap
+}
+
+func (e *testReconcileExchange) QueryOpenOrders(ctx context.Context, symbol string) ([]types.Order, error) {
+	return e.openOrders, nil
+}
+
+func (e *testReconcileExchange) QueryTrades(ctx context.Context, symbol string, options *types.TradeQueryOptions) ([]types.Trade, error) {
+	return e.trades, nil
+}
+
+func (e *testReconcileExchange) QueryClosedOrders(ctx context.Context, symbol string, since, until time.Time, lastOrderID uint64) ([]types.Order, error) {
+	return nil, nil
+}
+
+func (e *testReconcileExchange) QueryAccountBalances(ctx context.Context) (types.BalanceMap, error) {
+	return e.balances, nil
+}
+
+func newTestTrade(id, orderID uint64, side types.SideType, quantity float64) types.Trade {
+	return types.Trade{
+		ID:            id,
+		OrderID:       orderID,
+		Exchange:      types.ExchangeBinance,
+		Symbol:        "BTCUSDT",
+		Side:          side,
+		IsBuyer:       side == types.SideTypeBuy,
+		Price:         fixedpoint.NewFromInt(100),
+		Quantity:      fixedpoint.NewFromFloat(quantity),
+		QuoteQuantity: fixedpoint.NewFromFloat(quantity * 100),
+	}
+}
+
+func TestReconciliationService(t *testing.T) {
+	filled := newTestRestingOrder(1, types.SideTypeBuy, 100)
+	filled.Status = types.OrderStatusFilled
+
+	store := NewOrderStore("BTCUSDT")
+	store.Add(filled, newTestRestingOrder(2, types.SideTypeBuy, 90), newTestRestingOrder(3, types.SideTypeSell, 110))
+
+	market := types.Market{Symbol: "BTCUSDT", BaseCurrency: "BTC", QuoteCurrency: "USDT"}
+	position := types.NewPositionFromMarket(market)
+	collector := NewTradeCollector("BTCUSDT", position, store)
+
+	processed := newTestTrade(1, 1, types.SideTypeBuy, 1)
+	assert.True(t, collector.ProcessTrade(processed))
+
+	exchange := &testReconcileExchange{
+		openOrders: []types.Order{newTestRestingOrder(3, types.SideTypeSell, 110), newTestRestingOrder(9, types.SideTypeSell, 120)},
+		trades: []types.Trade{
+			processed,
+			newTestTrade(2, 1, types.SideTypeBuy, 0.5),
+			// the manual trade is not tracked by the order store
+			newTestTrade(3, 99, types.SideTypeBuy, 3),
+		},
+		balances: types.BalanceMap{
+			"BTC": {Currency: "BTC", Available: fixedpoint.NewFromFloat(1.5), Locked: fixedpoint.NewFromFloat(0.5)},
+		},
+	}
+
+	session := &ExchangeSession{
+		Name:       "binance",
+		Exchange:   exchange,
+		markets:    map[string]types.Market{"BTCUSDT": market},
+		lastPrices: map[string]fixedpoint.Value{"BTCUSDT": fixedpoint.NewFromInt(100)},
+	}
+
+	service := NewReconciliationService(&ReconciliationConfig{
+		MaxDriftAmount: fixedpoint.NewFromInt(10),
+	}, nil)
+	service.AddTradeCollector(session, collector)
+
+	ctx := context.Background()
+	reports, err := service.Reconcile(ctx, time.Now())
+	if !assert.NoError(t, err) || !assert.Len(t, reports, 1) {
+		return
+	}
+
+	report := reports[0]
+	assert.Len(t, report.MissingTrades, 1)
+	assert.Equal(t, 0, report.RecoveredTrades)
+	assert.Equal(t, "0.5", report.PositionDrift.String())
+	assert.Equal(t, "50", report.DriftAmount.String())
+	if assert.Len(t, report.StaleOrders, 1) {
+		assert.Equal(t, uint64(2), report.StaleOrders[0].OrderID)
+	}
+	if assert.Len(t, report.UntrackedOrders, 1) {
+		assert.Equal(t, uint64(9), report.UntrackedOrders[0].OrderID)
+	}
+	assert.True(t, report.Blocked)
+
+	// the orders of the drifted position are rejected
+	accepted, rejections := service.CheckOrders(ctx, session, newTestSubmitOrder(types.SideTypeBuy, 100))
+	assert.Empty(t, accepted)
+	if assert.Len(t, rejections, 1) {
+		assert.True(t, errors.Is(rejections[0], ErrPositionDrift))
+	}
+
+	// repair the position from the missing trades
+	service.Repair = true
+	service.CheckBalances = true
+	reports, err = service.Reconcile(ctx, time.Now())
+	if !assert.NoError(t, err) || !assert.Len(t, reports, 1) {
+		return
+	}
+
+	report = reports[0]
+	assert.Equal(t, 1, report.RecoveredTrades)
+	assert.Equal(t, "0", report.PositionDrift.String())
+	assert.Equal(t, "1.5", report.PositionBase.String())
+	assert.Equal(t, "1.5", position.GetBase().String())
+
+	// the balance is 2 BTC, and the position is 1.5 BTC
+	assert.Equal(t, "0.5", report.BalanceDrift.String())
+	assert.True(t, report.Blocked)
+
+	service.MaxDriftAmount = fixedpoint.NewFromInt(100)
+	reports, err = service.Reconcile(ctx, time.Now())
+	if assert.NoError(t, err) && assert.Len(t, reports, 1) {
+		assert.Empty(t, reports[0].MissingTrades)
+		assert.False(t, reports[0].Blocked)
+	}
+
+	accepted, rejections = service.CheckOrders(ctx, session, newTestSubmitOrder(types.SideTypeBuy, 100))
+	assert.Len(t, accepted, 1)
+	assert.Empty(t, rejections)
+}
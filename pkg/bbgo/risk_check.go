@@ -0,0 +1,199 @@
+package bbgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// RiskCheck is a pre-trade check of the submit orders.
+// A risk check can be registered by RegisterRiskCheck and referenced by its ID in the riskControls config.
+type RiskCheck interface {
+	ID() string
+
+	// CheckOrders returns the accepted orders (the orders can be modified, e.g. the quantity is reduced)
+	// and the rejections of the rejected orders
+	CheckOrders(ctx context.Context, session *ExchangeSession, orders ...types.SubmitOrder) (accepted []types.SubmitOrder, rejections []RiskRejection)
+}
+
+// RiskRejection is the reason why an order is rejected by a risk check
+type RiskRejection struct {
+	// Check is the ID of the risk check that rejected the order
+	Check string `json:"check"`
+
+	Order types.SubmitOrder `json:"order"`
+
+	// Reason is the error message of the rejection
+	Reason string `json:"reason"`
+
+	// Err is the original error, it can be matched by errors.Is, e.g. ErrPriceOutOfBand
+	Err error `json:"-"`
+}
+
+func NewRiskRejection(check string, order types.SubmitOrder, err error) RiskRejection {
+	return RiskRejection{
+		Check:  check,
+		Order:  order,
+		Reason: err.Error(),
+		Err:    err,
+	}
+}
+
+func (r RiskRejection) Error() string {
+	return fmt.Sprintf("%s: %s", r.Check, r.Reason)
+}
+
+func (r RiskRejection) Unwrap() error {
+	return r.Err
+}
+
+// RiskRejectionError is returned by RiskControlOrderExecutor.SubmitOrders when any order is rejected by the risk checks.
+// It's not fatal: the accepted orders are still submitted, and the created orders are returned along with the error.
+type RiskRejectionError struct {
+	Rejections []RiskRejection
+}
+
+func (e *RiskRejectionError) Error() string {
+	var reasons []string
+	for _, r := range e.Rejections {
+		reasons = append(reasons, r.Error())
+	}
+
+	return fmt.Sprintf("%d orders are rejected by the risk checks: %s", len(e.Rejections), strings.Join(reasons, "; "))
+}
+
+// Is matches the errors of the rejections
+func (e *RiskRejectionError) Is(target error) bool {
+	for _, r := range e.Rejections {
+		if errors.Is(r.Err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var LoadedRiskChecks = make(map[string]RiskCheck)
+
+// RegisterRiskCheck registers the risk check type, the config of the risk check is unmarshalled
+// into a new instance of the registered type.
+func RegisterRiskCheck(key string, check RiskCheck) {
+	LoadedRiskChecks[key] = check
+}
+
+func init() {
+	RegisterRiskCheck("basic", &BasicRiskController{})
+	RegisterRiskCheck("priceProtection", &PriceProtectionRiskController{})
+}
+
+// RiskCheckList is the chain of the risk checks, it's configured as a list of the risk check IDs and their configs:
+//
+//   checks:
+//   - priceProtection:
+//       maxPriceDeviation: 5%
+//   - myCheck: {}
+//
+type RiskCheckList []RiskCheck
+
+func (l *RiskCheckList) UnmarshalYAML(node *yaml.Node) error {
+	var entries []interface{}
+	if err := node.Decode(&entries); err != nil {
+		return err
+	}
+
+	return l.load(entries)
+}
+
+func (l *RiskCheckList) UnmarshalJSON(data []byte) error {
+	var entries []interface{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	return l.load(entries)
+}
+
+func (l *RiskCheckList) load(entries []interface{}) error {
+	for _, entry := range entries {
+		var id string
+		var conf interface{}
+
+		switch tv := entry.(type) {
+		case string:
+			id, conf = tv, map[string]interface{}{}
+
+		case map[string]interface{}:
+			if len(tv) != 1 {
+				return fmt.Errorf("risk check config should have exactly one risk check id, given: %+v", tv)
+			}
+
+			for k, v := range tv {
+				id, conf = k, v
+			}
+
+		default:
+			return fmt.Errorf("unexpected risk check config type: %T value: %+v", entry, entry)
+		}
+
+		check, err := newRiskCheck(id, conf)
+		if err != nil {
+			return err
+		}
+
+		*l = append(*l, check)
+	}
+
+	return nil
+}
+
+// newRiskCheck creates the risk check of the registered type from the config
+func newRiskCheck(id string, conf interface{}) (RiskCheck, error) {
+	tpe, ok := LoadedRiskChecks[id]
+	if !ok {
+		var ids []string
+		for k := range LoadedRiskChecks {
+			ids = append(ids, k)
+		}
+		sort.Strings(ids)
+		return nil, fmt.Errorf("risk check %q is not registered, registered risk checks: %s", id, strings.Join(ids, ", "))
+	}
+
+	if conf == nil {
+		conf = map[string]interface{}{}
+	}
+
+	val, err := reUnmarshal(conf, tpe)
+	if err != nil {
+		return nil, err
+	}
+
+	check, ok := val.(RiskCheck)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a risk check: %s", id, reflect.TypeOf(val))
+	}
+
+	return check, nil
+}
+
+// checkOrdersBy adapts the ProcessOrders style controllers that check the orders one by one
+func checkOrdersBy(id string, orders []types.SubmitOrder, process func(order types.SubmitOrder) (types.SubmitOrder, error)) (accepted []types.SubmitOrder, rejections []RiskRejection) {
+	for _, order := range orders {
+		o, err := process(order)
+		if err != nil {
+			rejections = append(rejections, NewRiskRejection(id, order, err))
+			continue
+		}
+
+		accepted = append(accepted, o)
+	}
+
+	return accepted, rejections
+}
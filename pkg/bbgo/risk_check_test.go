@@ -0,0 +1,122 @@
+package bbgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+var errQuantityTooLarge = errors.New("quantity too large")
+
+// testMaxQuantityCheck rejects the orders that are larger than MaxQuantity
+type testMaxQuantityCheck struct {
+	MaxQuantity fixedpoint.Value `json:"maxQuantity"`
+}
+
+func (c *testMaxQuantityCheck) ID() string {
+	return "testMaxQuantity"
+}
+
+func (c *testMaxQuantityCheck) CheckOrders(ctx context.Context, session *ExchangeSession, orders ...types.SubmitOrder) ([]types.SubmitOrder, []RiskRejection) {
+	return checkOrdersBy(c.ID(), orders, func(order types.SubmitOrder) (types.SubmitOrder, error) {
+		if order.Quantity.Compare(c.MaxQuantity) > 0 {
+			return order, fmt.Errorf("%w: %s > %s", errQuantityTooLarge, order.Quantity.String(), c.MaxQuantity.String())
+		}
+		return order, nil
+	})
+}
+
+func init() {
+	RegisterRiskCheck("testMaxQuantity", &testMaxQuantityCheck{})
+}
+
+func TestRiskCheckList_Unmarshal(t *testing.T) {
+	var executor RiskControlOrderExecutor
+	err := yaml.Unmarshal([]byte(`
+checks:
+- testMaxQuantity:
+    maxQuantity: 2.0
+bySymbol:
+  BTCUSDT:
+    checks:
+    - priceProtection:
+        maxPriceDeviation: 5%
+    - testMaxQuantity:
+        maxQuantity: 1.0
+`), &executor)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.Len(t, executor.Checks, 1) {
+		assert.Equal(t, "2", executor.Checks[0].(*testMaxQuantityCheck).MaxQuantity.String())
+	}
+
+	checks := executor.riskChecks("BTCUSDT")
+	if assert.Len(t, checks, 3) {
+		assert.Equal(t, "testMaxQuantity", checks[0].ID())
+		assert.Equal(t, "0.05", checks[1].(*PriceProtectionRiskController).MaxPriceDeviation.String())
+		assert.Equal(t, "1", checks[2].(*testMaxQuantityCheck).MaxQuantity.String())
+	}
+
+	var list RiskCheckList
+	assert.NoError(t, json.Unmarshal([]byte(`["testMaxQuantity", {"basic": {"maxOrderAmount": 100}}]`), &list))
+	assert.Len(t, list, 2)
+
+	err = yaml.Unmarshal([]byte(`checks: [unknownCheck]`), &executor)
+	assert.Error(t, err)
+}
+
+func TestRiskControlOrderExecutor_RiskChecks(t *testing.T) {
+	executor, exchange := newTestRiskControlOrderExecutor()
+	executor.Checks = RiskCheckList{&testMaxQuantityCheck{MaxQuantity: fixedpoint.NewFromInt(2)}}
+	executor.BySymbol = map[string]*SymbolBasedRiskController{
+		"BTCUSDT": {Checks: RiskCheckList{&testMaxQuantityCheck{MaxQuantity: fixedpoint.One}}},
+	}
+
+	order := newTestSubmitOrder(types.SideTypeBuy, 100)
+	large := newTestSubmitOrder(types.SideTypeBuy, 100)
+	large.Quantity = fixedpoint.NewFromInt(3)
+	medium := newTestSubmitOrder(types.SideTypeBuy, 100)
+	medium.Quantity = fixedpoint.NewFromFloat(1.5)
+
+	var rejections []RiskRejection
+	executor.OnRiskRejection(func(rejection RiskRejection) {
+		rejections = append(rejections, rejection)
+	})
+
+	// the accepted order is created and returned along with the rejection error
+	createdOrders, err := executor.SubmitOrders(context.Background(), order, large, medium)
+	assert.Len(t, createdOrders, 1)
+	assert.Len(t, exchange.submitted, 1)
+
+	var rejectionErr *RiskRejectionError
+	if assert.True(t, errors.As(err, &rejectionErr)) {
+		assert.Len(t, rejectionErr.Rejections, 2)
+	}
+
+	if assert.Len(t, rejections, 2) {
+		// the large order is rejected by the session check, the medium order is rejected by the symbol check
+		assert.Equal(t, "3", rejections[0].Order.Quantity.String())
+		assert.Equal(t, "1.5", rejections[1].Order.Quantity.String())
+		assert.Equal(t, "testMaxQuantity", rejections[1].Check)
+		assert.True(t, errors.Is(rejections[0], errQuantityTooLarge))
+	}
+
+	// all the orders are rejected
+	createdOrders, err = executor.SubmitOrders(context.Background(), large)
+	assert.Empty(t, createdOrders)
+
+	if assert.True(t, errors.As(err, &rejectionErr)) {
+		assert.Len(t, rejectionErr.Rejections, 1)
+	}
+	assert.True(t, errors.Is(err, errQuantityTooLarge))
+}
@@ -2,34 +2,95 @@ package bbgo
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 
 	"github.com/c9s/bbgo/pkg/types"
 )
 
 type SymbolBasedRiskController struct {
 	BasicRiskController *BasicRiskController `json:"basic,omitempty" yaml:"basic,omitempty"`
+
+	// PriceProtection rejects or clamps the fat-finger orders of the symbol
+	PriceProtection *PriceProtectionRiskController `json:"priceProtection,omitempty" yaml:"priceProtection,omitempty"`
+
+	// OrderRateLimit limits the order submissions of the symbol
+	OrderRateLimit *RateLimit `json:"orderRateLimit,omitempty" yaml:"orderRateLimit,omitempty"`
+
+	// CancelRateLimit limits the order cancellations of the symbol
+	CancelRateLimit *RateLimit `json:"cancelRateLimit,omitempty" yaml:"cancelRateLimit,omitempty"`
+
+	// Checks is the chain of the registered risk checks of the symbol, they are applied after the basic and
+	// the price protection controllers
+	Checks RiskCheckList `json:"checks,omitempty" yaml:"checks,omitempty"`
 }
 
+//go:generate callbackgen -type RiskControlOrderExecutor
 type RiskControlOrderExecutor struct {
 	*ExchangeOrderExecutor
 
 	// Symbol => Executor config
 	BySymbol map[string]*SymbolBasedRiskController `json:"bySymbol,omitempty" yaml:"bySymbol,omitempty"`
+
+	// Checks is the chain of the registered risk checks of all the symbols of the session
+	Checks RiskCheckList `json:"checks,omitempty" yaml:"checks,omitempty"`
+
+	// OrderRateLimit limits the order submissions of the session
+	OrderRateLimit *RateLimit `json:"orderRateLimit,omitempty" yaml:"orderRateLimit,omitempty"`
+
+	// CancelRateLimit limits the order cancellations of the session
+	CancelRateLimit *RateLimit `json:"cancelRateLimit,omitempty" yaml:"cancelRateLimit,omitempty"`
+
+	// SelfTradePrevention is the policy of the new orders that cross our resting orders:
+	// reject, cancelResting or reprice. The self-trade prevention is disabled if it's empty.
+	SelfTradePrevention string `json:"selfTradePrevention,omitempty" yaml:"selfTradePrevention,omitempty"`
+
+	mu               sync.Mutex
+	limiters         map[string]*rate.Limiter
+	activeOrderBooks []*LocalActiveOrderBook
+
+	riskRejectionCallbacks []func(rejection RiskRejection)
 }
 
+// riskChecks returns the risk check chain of the symbol, the session checks are applied before the symbol checks
+func (e *RiskControlOrderExecutor) riskChecks(symbol string) (checks []RiskCheck) {
+	checks = append(checks, e.Checks...)
+
+	if controller, ok := e.BySymbol[symbol]; ok && controller != nil {
+		if controller.BasicRiskController != nil {
+			checks = append(checks, controller.BasicRiskController)
+		}
+
+		if controller.PriceProtection != nil {
+			checks = append(checks, controller.PriceProtection)
+		}
+
+		checks = append(checks, controller.Checks...)
+	}
+
+	return checks
+}
+
+// SubmitOrders submits the orders that pass the risk checks. The rejections are emitted through OnRiskRejection.
+// If any order is rejected, a *RiskRejectionError is returned together with the orders that are created,
+// so the caller must keep the returned orders even if the error is not nil.
 func (e *RiskControlOrderExecutor) SubmitOrders(ctx context.Context, orders ...types.SubmitOrder) (retOrders types.OrderSlice, err error) {
+	var rejections []RiskRejection
 	var symbolOrders = groupSubmitOrdersBySymbol(orders)
 	for symbol, orders := range symbolOrders {
-		if controller, ok := e.BySymbol[symbol]; ok && controller != nil {
-			var riskErrs []error
-
-			orders, riskErrs = controller.BasicRiskController.ProcessOrders(e.Session, orders...)
-			for _, riskErr := range riskErrs {
-				// use logger from ExchangeOrderExecutor
-				logrus.Warnf("RISK ERROR: %s", riskErr.Error())
-			}
+		var symbolRejections []RiskRejection
+		orders, symbolRejections = e.checkOrders(ctx, symbol, orders)
+		rejections = append(rejections, symbolRejections...)
+
+		if len(orders) == 0 {
+			continue
+		}
+
+		if err := e.takeOrderTokens(ctx, symbol, len(orders)); err != nil {
+			return retOrders, err
 		}
 
 		formattedOrders, err := formatOrders(e.Session, orders)
@@ -45,7 +106,90 @@ func (e *RiskControlOrderExecutor) SubmitOrders(ctx context.Context, orders ...t
 		retOrders = append(retOrders, retOrders2...)
 	}
 
-	return
+	if len(rejections) > 0 {
+		return retOrders, &RiskRejectionError{Rejections: rejections}
+	}
+
+	return retOrders, nil
+}
+
+// SubmitOCOOrder submits the OCO order if both the orders pass the risk checks,
+// a *RiskRejectionError is returned if any of the orders is rejected.
+func (e *RiskControlOrderExecutor) SubmitOCOOrder(ctx context.Context, takeProfit, stopLoss types.SubmitOrder) (types.OrderSlice, error) {
+	symbol := takeProfit.Symbol
+	orders, rejections := e.checkOrders(ctx, symbol, []types.SubmitOrder{takeProfit, stopLoss})
+	if len(rejections) > 0 {
+		return nil, &RiskRejectionError{Rejections: rejections}
+	}
+
+	if len(orders) != 2 {
+		return nil, fmt.Errorf("the oco order of %s must have 2 orders, %d orders are accepted", symbol, len(orders))
+	}
+
+	if err := e.takeOrderTokens(ctx, symbol, len(orders)); err != nil {
+		return nil, err
+	}
+
+	return e.ExchangeOrderExecutor.SubmitOCOOrder(ctx, orders[0], orders[1])
+}
+
+// checkOrders applies the risk check chain and the self-trade prevention to the orders of the symbol
+func (e *RiskControlOrderExecutor) checkOrders(ctx context.Context, symbol string, orders []types.SubmitOrder) ([]types.SubmitOrder, []RiskRejection) {
+	var rejections []RiskRejection
+	for _, check := range e.riskChecks(symbol) {
+		if len(orders) == 0 {
+			break
+		}
+
+		var checkRejections []RiskRejection
+		orders, checkRejections = check.CheckOrders(ctx, e.Session, orders...)
+		rejections = append(rejections, e.reportRejections(checkRejections)...)
+	}
+
+	if e.SelfTradePrevention != "" && len(orders) > 0 {
+		var checkRejections []RiskRejection
+		orders, checkRejections = e.preventSelfTrades(ctx, symbol, orders)
+		rejections = append(rejections, e.reportRejections(checkRejections)...)
+	}
+
+	return orders, rejections
+}
+
+// reportRejections logs the rejections, reports them through the notifiers and emits the risk rejection callbacks
+func (e *RiskControlOrderExecutor) reportRejections(rejections []RiskRejection) []RiskRejection {
+	for _, r := range rejections {
+		e.EmitRiskRejection(r)
+
+		// use logger from ExchangeOrderExecutor
+		logrus.Warnf("RISK ERROR: %s, order: %s", r.Error(), r.Order.String())
+
+		if channel, ok := e.RouteSymbol(r.Order.Symbol); ok {
+			e.NotifyTo(channel, ":no_entry: %s order rejected by %s: %s", r.Order.Symbol, r.Check, r.Reason)
+		} else {
+			e.Notify(":no_entry: %s order rejected by %s: %s", r.Order.Symbol, r.Check, r.Reason)
+		}
+	}
+
+	return rejections
+}
+
+func (e *RiskControlOrderExecutor) CancelOrders(ctx context.Context, orders ...types.Order) error {
+	var symbolOrders = make(map[string][]types.Order)
+	for _, order := range orders {
+		symbolOrders[order.Symbol] = append(symbolOrders[order.Symbol], order)
+	}
+
+	for symbol, orders := range symbolOrders {
+		if err := e.takeCancelTokens(ctx, symbol, len(orders)); err != nil {
+			return err
+		}
+
+		if err := e.ExchangeOrderExecutor.CancelOrders(ctx, orders...); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 type SessionBasedRiskControl struct {
@@ -71,4 +215,10 @@ func groupSubmitOrdersBySymbol(orders []types.SubmitOrder) map[string][]types.Su
 
 type RiskControls struct {
 	SessionBasedRiskControl map[string]*SessionBasedRiskControl `json:"sessionBased,omitempty" yaml:"sessionBased,omitempty"`
+
+	// Portfolio is the cross-session risk control of the portfolio equity and exposure
+	Portfolio *PortfolioRiskControl `json:"portfolio,omitempty" yaml:"portfolio,omitempty"`
+
+	// Reconciliation compares the strategy positions with the exchange state
+	Reconciliation *ReconciliationConfig `json:"reconciliation,omitempty" yaml:"reconciliation,omitempty"`
 }
@@ -0,0 +1,212 @@
+package bbgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// testOrderExchange records the submitted and the canceled orders
+type testOrderExchange struct {
+	types.Exchange
+
+	submitted []types.SubmitOrder
+	canceled  []types.Order
+}
+
+func (e *testOrderExchange) SubmitOrders(ctx context.Context, orders ...types.SubmitOrder) (createdOrders types.OrderSlice, err error) {
+	for _, o := range orders {
+		e.submitted = append(e.submitted, o)
+		createdOrders = append(createdOrders, types.Order{SubmitOrder: o, Status: types.OrderStatusNew})
+	}
+	return createdOrders, nil
+}
+
+func (e *testOrderExchange) CancelOrders(ctx context.Context, orders ...types.Order) error {
+	e.canceled = append(e.canceled, orders...)
+	return nil
+}
+
+func newTestRiskControlOrderExecutor(resting ...types.Order) (*RiskControlOrderExecutor, *testOrderExchange) {
+	exchange := &testOrderExchange{}
+	store := NewOrderStore("BTCUSDT")
+	store.Add(resting...)
+
+	session := &ExchangeSession{
+		Exchange: exchange,
+		markets: map[string]types.Market{
+			"BTCUSDT": {Symbol: "BTCUSDT", BaseCurrency: "BTC", QuoteCurrency: "USDT", TickSize: fixedpoint.NewFromFloat(0.01)},
+		},
+		orderStores: map[string]*OrderStore{"BTCUSDT": store},
+	}
+
+	return &RiskControlOrderExecutor{
+		ExchangeOrderExecutor: &ExchangeOrderExecutor{Session: session},
+	}, exchange
+}
+
+func newTestSubmitOrder(side types.SideType, price float64) types.SubmitOrder {
+	return types.SubmitOrder{
+		Symbol:   "BTCUSDT",
+		Side:     side,
+		Type:     types.OrderTypeLimit,
+		Price:    fixedpoint.NewFromFloat(price),
+		Quantity: fixedpoint.One,
+	}
+}
+
+func newTestRestingOrder(id uint64, side types.SideType, price float64) types.Order {
+	return types.Order{
+		SubmitOrder: newTestSubmitOrder(side, price),
+		OrderID:     id,
+		Status:      types.OrderStatusNew,
+	}
+}
+
+func TestRiskControlOrderExecutor_OrderRateLimit(t *testing.T) {
+	executor, exchange := newTestRiskControlOrderExecutor()
+	executor.BySymbol = map[string]*SymbolBasedRiskController{
+		"BTCUSDT": {OrderRateLimit: &RateLimit{Limit: 1, Interval: types.Duration(time.Hour), Burst: 2}},
+	}
+
+	ctx := context.Background()
+	_, err := executor.SubmitOrders(ctx, newTestSubmitOrder(types.SideTypeBuy, 100), newTestSubmitOrder(types.SideTypeBuy, 99))
+	assert.NoError(t, err)
+
+	// the symbol bucket is empty
+	_, err = executor.SubmitOrders(ctx, newTestSubmitOrder(types.SideTypeBuy, 98))
+	assert.True(t, errors.Is(err, ErrOrderRateLimitExceeded))
+
+	assert.Len(t, exchange.submitted, 2)
+
+	// the session limit is applied to all the symbols
+	executor, exchange = newTestRiskControlOrderExecutor()
+	executor.OrderRateLimit = &RateLimit{Limit: 1, Interval: types.Duration(time.Hour), Burst: 3}
+	_, err = executor.SubmitOrders(ctx, newTestSubmitOrder(types.SideTypeBuy, 100), newTestSubmitOrder(types.SideTypeBuy, 99))
+	assert.NoError(t, err)
+	_, err = executor.SubmitOrders(ctx, newTestSubmitOrder(types.SideTypeBuy, 98), newTestSubmitOrder(types.SideTypeBuy, 97))
+	assert.True(t, errors.Is(err, ErrOrderRateLimitExceeded))
+	assert.Len(t, exchange.submitted, 2)
+}
+
+func TestRiskControlOrderExecutor_CancelRateLimit(t *testing.T) {
+	executor, exchange := newTestRiskControlOrderExecutor()
+	executor.CancelRateLimit = &RateLimit{Limit: 1, Interval: types.Duration(time.Hour)}
+
+	ctx := context.Background()
+	assert.NoError(t, executor.CancelOrders(ctx, newTestRestingOrder(1, types.SideTypeBuy, 100)))
+
+	err := executor.CancelOrders(ctx, newTestRestingOrder(2, types.SideTypeBuy, 100))
+	assert.True(t, errors.Is(err, ErrCancelRateLimitExceeded))
+	assert.Len(t, exchange.canceled, 1)
+}
+
+func TestRateLimit_UnmarshalYAML(t *testing.T) {
+	var limit RateLimit
+	err := yaml.Unmarshal([]byte("limit: 60\ninterval: 1m\nburst: 10\n"), &limit)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 60.0, limit.Limit)
+		assert.Equal(t, time.Minute, limit.Interval.Duration())
+		assert.Equal(t, 10, limit.Burst)
+	}
+}
+
+func TestRiskControlOrderExecutor_SelfTradePrevention(t *testing.T) {
+	resting := []types.Order{
+		newTestRestingOrder(1, types.SideTypeSell, 101),
+		newTestRestingOrder(2, types.SideTypeSell, 100),
+		newTestRestingOrder(3, types.SideTypeBuy, 95),
+	}
+
+	// the filled orders are not resting orders
+	filled := newTestRestingOrder(4, types.SideTypeSell, 90)
+	filled.Status = types.OrderStatusFilled
+	resting = append(resting, filled)
+
+	ctx := context.Background()
+
+	t.Run("reject", func(t *testing.T) {
+		executor, exchange := newTestRiskControlOrderExecutor(resting...)
+		executor.SelfTradePrevention = SelfTradePreventionReject
+
+		var rejections []RiskRejection
+		executor.OnRiskRejection(func(rejection RiskRejection) {
+			rejections = append(rejections, rejection)
+		})
+
+		createdOrders, err := executor.SubmitOrders(ctx,
+			newTestSubmitOrder(types.SideTypeBuy, 100),
+			newTestSubmitOrder(types.SideTypeBuy, 99),
+			newTestSubmitOrder(types.SideTypeSell, 96))
+		assert.True(t, errors.Is(err, ErrSelfTrade))
+		assert.Len(t, createdOrders, 2)
+
+		if assert.Len(t, rejections, 1) {
+			assert.Equal(t, "selfTradePrevention", rejections[0].Check)
+			assert.Equal(t, "100", rejections[0].Order.Price.String())
+			assert.True(t, errors.Is(rejections[0], ErrSelfTrade))
+		}
+		if assert.Len(t, exchange.submitted, 2) {
+			assert.Equal(t, "99", exchange.submitted[0].Price.String())
+			assert.Equal(t, "96", exchange.submitted[1].Price.String())
+		}
+	})
+
+	t.Run("cancelResting", func(t *testing.T) {
+		executor, exchange := newTestRiskControlOrderExecutor(resting...)
+		executor.SelfTradePrevention = SelfTradePreventionCancelResting
+
+		_, err := executor.SubmitOrders(ctx, newTestSubmitOrder(types.SideTypeBuy, 100.5))
+		assert.NoError(t, err)
+		assert.Len(t, exchange.submitted, 1)
+		if assert.Len(t, exchange.canceled, 1) {
+			assert.Equal(t, uint64(2), exchange.canceled[0].OrderID)
+		}
+	})
+
+	t.Run("reprice", func(t *testing.T) {
+		executor, exchange := newTestRiskControlOrderExecutor(resting...)
+		executor.SelfTradePrevention = SelfTradePreventionReprice
+
+		market := newTestSubmitOrder(types.SideTypeSell, 0)
+		market.Type = types.OrderTypeMarket
+
+		var rejections []RiskRejection
+		executor.OnRiskRejection(func(rejection RiskRejection) {
+			rejections = append(rejections, rejection)
+		})
+
+		_, err := executor.SubmitOrders(ctx,
+			newTestSubmitOrder(types.SideTypeBuy, 102),
+			newTestSubmitOrder(types.SideTypeSell, 94),
+			market)
+		assert.True(t, errors.Is(err, ErrSelfTrade))
+		if assert.Len(t, rejections, 1) {
+			assert.True(t, errors.Is(rejections[0], ErrSelfTrade))
+		}
+		if assert.Len(t, exchange.submitted, 2) {
+			assert.Equal(t, "99.99", exchange.submitted[0].Price.String())
+			assert.Equal(t, "95.01", exchange.submitted[1].Price.String())
+		}
+	})
+
+	t.Run("active order book", func(t *testing.T) {
+		executor, exchange := newTestRiskControlOrderExecutor()
+		executor.SelfTradePrevention = SelfTradePreventionReject
+
+		book := NewLocalActiveOrderBook("BTCUSDT")
+		book.Add(newTestRestingOrder(5, types.SideTypeSell, 100))
+		executor.AddActiveOrderBook(book)
+
+		_, err := executor.SubmitOrders(ctx, newTestSubmitOrder(types.SideTypeBuy, 100))
+		assert.True(t, errors.Is(err, ErrSelfTrade))
+		assert.Empty(t, exchange.submitted)
+	})
+}
@@ -0,0 +1,504 @@
+package bbgo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const (
+	PortfolioRiskActionSuspend       = "suspend"
+	PortfolioRiskActionEmergencyStop = "emergencyStop"
+)
+
+const defaultPortfolioRiskCheckInterval = time.Minute
+
+// PortfolioRiskControl is the config of the portfolio risk engine, the amounts are valued in USD
+type PortfolioRiskControl struct {
+	// MaxDailyLoss is the max loss of the portfolio equity since the start of the day (UTC)
+	MaxDailyLoss fixedpoint.Value `json:"maxDailyLoss,omitempty" yaml:"maxDailyLoss,omitempty"`
+
+	// MaxDrawdown is the max drawdown percentage of the portfolio equity from its peak, e.g. 10%
+	MaxDrawdown fixedpoint.Value `json:"maxDrawdown,omitempty" yaml:"maxDrawdown,omitempty"`
+
+	// MaxGrossExposure is the max sum of the absolute non-USD asset values
+	MaxGrossExposure fixedpoint.Value `json:"maxGrossExposure,omitempty" yaml:"maxGrossExposure,omitempty"`
+
+	// MaxNetExposure is the max absolute sum of the non-USD asset values, the borrowed assets are negative
+	MaxNetExposure fixedpoint.Value `json:"maxNetExposure,omitempty" yaml:"maxNetExposure,omitempty"`
+
+	// MaxAssetConcentration is the max percentage of one non-USD asset value in the portfolio equity, e.g. 50%
+	MaxAssetConcentration fixedpoint.Value `json:"maxAssetConcentration,omitempty" yaml:"maxAssetConcentration,omitempty"`
+
+	// MaxRealizedLoss is the max realized loss of the tracked positions since the engine starts
+	MaxRealizedLoss fixedpoint.Value `json:"maxRealizedLoss,omitempty" yaml:"maxRealizedLoss,omitempty"`
+
+	// MaxUnrealizedLoss is the max unrealized loss of the tracked positions
+	MaxUnrealizedLoss fixedpoint.Value `json:"maxUnrealizedLoss,omitempty" yaml:"maxUnrealizedLoss,omitempty"`
+
+	// Action is the action taken on the affected strategies when a limit is breached: suspend or emergencyStop,
+	// the default action is suspend
+	Action string `json:"action,omitempty" yaml:"action,omitempty"`
+
+	// CheckInterval is the interval of the risk check, the default interval is 1m
+	CheckInterval types.Duration `json:"checkInterval,omitempty" yaml:"checkInterval,omitempty"`
+}
+
+// PortfolioRiskStatus is the snapshot of the portfolio, the values are in USD
+type PortfolioRiskStatus struct {
+	Time             time.Time                   `json:"time"`
+	Equity           fixedpoint.Value            `json:"equity"`
+	PeakEquity       fixedpoint.Value            `json:"peakEquity"`
+	DayStartEquity   fixedpoint.Value            `json:"dayStartEquity"`
+	RealizedProfit   fixedpoint.Value            `json:"realizedProfit"`
+	UnrealizedProfit fixedpoint.Value            `json:"unrealizedProfit"`
+	GrossExposure    fixedpoint.Value            `json:"grossExposure"`
+	NetExposure      fixedpoint.Value            `json:"netExposure"`
+	Exposures        map[string]fixedpoint.Value `json:"exposures"`
+
+	// UnpricedAssets are the non-USD assets without a USD price, they are not included in the equity and the exposures
+	UnpricedAssets []string `json:"unpricedAssets,omitempty"`
+}
+
+// PortfolioRiskBreach is a breached limit of the portfolio risk engine
+type PortfolioRiskBreach struct {
+	// Limit is the name of the breached limit, e.g. maxDailyLoss
+	Limit string
+
+	// Asset is the asset of the concentration limit, empty for the portfolio limits
+	Asset string
+
+	Value     fixedpoint.Value
+	Threshold fixedpoint.Value
+}
+
+func (b PortfolioRiskBreach) String() string {
+	if b.Asset != "" {
+		return fmt.Sprintf("%s of %s: %v exceeds %v", b.Limit, b.Asset, b.Value, b.Threshold)
+	}
+	return fmt.Sprintf("%s: %v exceeds %v", b.Limit, b.Value, b.Threshold)
+}
+
+// riskStrategy is the strategy controlled by the portfolio risk engine
+type riskStrategy struct {
+	session  string
+	symbol   string
+	strategy interface{}
+}
+
+type sessionSymbol struct {
+	session, symbol string
+}
+
+// PortfolioRiskEngine tracks the equity, the PnL and the exposure of all the sessions, and suspends or stops
+// the strategies when a limit of the portfolio is breached.
+type PortfolioRiskEngine struct {
+	*PortfolioRiskControl
+	*Notifiability
+
+	sessions   map[string]*ExchangeSession
+	strategies []riskStrategy
+
+	mu        sync.Mutex
+	positions map[sessionSymbol]*types.Position
+	status    PortfolioRiskStatus
+	tripped   map[string]bool
+	day       time.Time
+}
+
+func NewPortfolioRiskEngine(control *PortfolioRiskControl, sessions map[string]*ExchangeSession, notifiability *Notifiability) *PortfolioRiskEngine {
+	return &PortfolioRiskEngine{
+		PortfolioRiskControl: control,
+		Notifiability:        notifiability,
+		sessions:             sessions,
+		positions:            make(map[sessionSymbol]*types.Position),
+		tripped:              make(map[string]bool),
+	}
+}
+
+// AddStrategy adds the strategy running on the session, the session is empty for the cross exchange strategies
+func (e *PortfolioRiskEngine) AddStrategy(session string, strategy interface{}) {
+	symbol, _ := isSymbolBasedStrategy(reflect.ValueOf(strategy))
+	e.strategies = append(e.strategies, riskStrategy{session: session, symbol: symbol, strategy: strategy})
+}
+
+// SeedPositions creates the positions of the strategy symbols from the base balances of the sessions, so that the
+// holdings before the engine starts are tracked. The seeded positions cost the last price, the base balance is only
+// seeded into the first strategy symbol of the base currency in the session. It must be called after the sessions
+// are initialized and before the user data streams are connected.
+func (e *PortfolioRiskEngine) SeedPositions() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	seeded := make(map[sessionSymbol]struct{})
+	for _, s := range e.strategies {
+		if s.symbol == "" {
+			continue
+		}
+
+		session, ok := e.sessions[s.session]
+		if !ok {
+			continue
+		}
+
+		market, ok := session.Market(s.symbol)
+		if !ok {
+			continue
+		}
+
+		key := sessionSymbol{session: s.session, symbol: s.symbol}
+		if _, ok := e.positions[key]; ok {
+			continue
+		}
+
+		position := types.NewPositionFromMarket(market)
+		e.positions[key] = position
+
+		asset := sessionSymbol{session: s.session, symbol: market.BaseCurrency}
+		if _, ok := seeded[asset]; ok {
+			continue
+		}
+		seeded[asset] = struct{}{}
+
+		balance, ok := session.GetAccount().Balance(market.BaseCurrency)
+		if !ok || balance.Net().IsZero() {
+			continue
+		}
+
+		price, ok := session.LastPrice(s.symbol)
+		if !ok {
+			log.Warnf("portfolio risk engine: no last price of %s, the %s balance is not seeded", s.symbol, market.BaseCurrency)
+			continue
+		}
+
+		position.Base = balance.Net()
+		position.Quote = position.Base.Mul(price).Neg()
+		position.AverageCost = price
+		position.ApproximateAverageCost = price
+	}
+}
+
+// BindStream tracks the realized profit of the positions by the trades of the sessions
+func (e *PortfolioRiskEngine) BindStream() {
+	for name, session := range e.sessions {
+		sessionName := name
+		session.UserDataStream.OnTradeUpdate(func(trade types.Trade) {
+			e.AddTrade(sessionName, trade)
+		})
+	}
+}
+
+// AddTrade updates the tracked position of the trade symbol
+func (e *PortfolioRiskEngine) AddTrade(sessionName string, trade types.Trade) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := sessionSymbol{session: sessionName, symbol: trade.Symbol}
+	position, ok := e.positions[key]
+	if !ok {
+		session, ok := e.sessions[sessionName]
+		if !ok {
+			return
+		}
+
+		market, ok := session.Market(trade.Symbol)
+		if !ok {
+			return
+		}
+
+		position = types.NewPositionFromMarket(market)
+		e.positions[key] = position
+	}
+
+	position.AddTrade(trade)
+}
+
+// Status returns the last portfolio status
+func (e *PortfolioRiskEngine) Status() PortfolioRiskStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status
+}
+
+// Reset re-arms the tripped limits and resets the peak equity
+func (e *PortfolioRiskEngine) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.tripped = make(map[string]bool)
+	e.status.PeakEquity = e.status.Equity
+}
+
+func (e *PortfolioRiskEngine) Run(ctx context.Context) {
+	interval := e.CheckInterval.Duration()
+	if interval == 0 {
+		interval = defaultPortfolioRiskCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case now := <-ticker.C:
+			e.Check(now)
+		}
+	}
+}
+
+// Check updates the portfolio status, and takes the action on the affected strategies of the breached limits
+func (e *PortfolioRiskEngine) Check(now time.Time) []PortfolioRiskBreach {
+	e.mu.Lock()
+	status := e.updateStatus(now)
+
+	var breaches []PortfolioRiskBreach
+	for _, breach := range e.checkLimits(status) {
+		key := breach.Limit + ":" + breach.Asset
+		if e.tripped[key] {
+			continue
+		}
+
+		e.tripped[key] = true
+		breaches = append(breaches, breach)
+	}
+	e.mu.Unlock()
+
+	for _, breach := range breaches {
+		log.Warnf("portfolio risk limit breached: %s", breach.String())
+		e.notify(":rotating_light: Portfolio risk limit breached: %s, equity %v USD", breach.String(), status.Equity)
+		e.stopStrategies(breach)
+	}
+
+	return breaches
+}
+
+func (e *PortfolioRiskEngine) notify(msg string, args ...interface{}) {
+	if e.Notifiability == nil {
+		return
+	}
+
+	e.Notifiability.Notify(msg, args...)
+}
+
+// usdStableCoins are the USD stable coins that don't have the USD prefix
+var usdStableCoins = map[string]struct{}{
+	"BUSD": {},
+	"DAI":  {},
+	"TUSD": {},
+}
+
+func isUSDCurrency(currency string) bool {
+	if _, ok := usdStableCoins[currency]; ok {
+		return true
+	}
+
+	return strings.HasPrefix(currency, "USD")
+}
+
+// usdPrice returns the USD price of the currency by the last prices of the session
+func usdPrice(session *ExchangeSession, currency string) (fixedpoint.Value, bool) {
+	if isUSDCurrency(currency) {
+		return fixedpoint.One, true
+	}
+
+	for _, quote := range []string{"USDT", "USDC", "BUSD", "USD"} {
+		if price, ok := session.LastPrice(currency + quote); ok && price.Sign() > 0 {
+			return price, true
+		}
+	}
+
+	return fixedpoint.Zero, false
+}
+
+func (e *PortfolioRiskEngine) updateStatus(now time.Time) PortfolioRiskStatus {
+	status := e.status
+	status.Time = now
+	status.Equity = fixedpoint.Zero
+	status.GrossExposure = fixedpoint.Zero
+	status.NetExposure = fixedpoint.Zero
+	status.Exposures = make(map[string]fixedpoint.Value)
+	status.UnpricedAssets = nil
+
+	for _, session := range e.sessions {
+		assets := session.GetAccount().Balances().Assets(session.AllLastPrices(), now)
+		for currency, asset := range assets {
+			if isUSDCurrency(currency) {
+				status.Equity = status.Equity.Add(asset.NetAsset)
+				continue
+			}
+
+			// the asset without a price is skipped instead of being valued at zero
+			if asset.PriceInUSD.IsZero() {
+				status.UnpricedAssets = append(status.UnpricedAssets, currency)
+				continue
+			}
+
+			status.Equity = status.Equity.Add(asset.InUSD)
+			status.Exposures[currency] = status.Exposures[currency].Add(asset.InUSD)
+		}
+	}
+
+	sort.Strings(status.UnpricedAssets)
+	if len(status.UnpricedAssets) > 0 && !reflect.DeepEqual(status.UnpricedAssets, e.status.UnpricedAssets) {
+		log.Warnf("portfolio risk engine: assets %v have no USD price, they are skipped", status.UnpricedAssets)
+	}
+
+	for _, exposure := range status.Exposures {
+		status.GrossExposure = status.GrossExposure.Add(exposure.Abs())
+		status.NetExposure = status.NetExposure.Add(exposure)
+	}
+
+	status.RealizedProfit = fixedpoint.Zero
+	status.UnrealizedProfit = fixedpoint.Zero
+	for key, position := range e.positions {
+		session := e.sessions[key.session]
+
+		// the profit is in the quote currency of the position
+		quotePrice, ok := usdPrice(session, position.QuoteCurrency)
+		if !ok {
+			continue
+		}
+
+		status.RealizedProfit = status.RealizedProfit.Add(position.AccumulatedProfit.Mul(quotePrice))
+
+		price, ok := session.LastPrice(key.symbol)
+		base := position.GetBase()
+		if ok && !base.IsZero() {
+			status.UnrealizedProfit = status.UnrealizedProfit.Add(price.Sub(position.AverageCost).Mul(base).Mul(quotePrice))
+		}
+	}
+
+	day := now.UTC().Truncate(24 * time.Hour)
+	if !day.Equal(e.day) {
+		e.day = day
+		status.DayStartEquity = status.Equity
+
+		// the daily loss limit is re-armed every day
+		delete(e.tripped, "maxDailyLoss:")
+	}
+
+	if status.Equity.Compare(status.PeakEquity) > 0 {
+		status.PeakEquity = status.Equity
+	}
+
+	e.status = status
+	return status
+}
+
+func (e *PortfolioRiskEngine) checkLimits(status PortfolioRiskStatus) (breaches []PortfolioRiskBreach) {
+	if e.MaxDailyLoss.Sign() > 0 {
+		if loss := status.DayStartEquity.Sub(status.Equity); loss.Compare(e.MaxDailyLoss) > 0 {
+			breaches = append(breaches, PortfolioRiskBreach{Limit: "maxDailyLoss", Value: loss, Threshold: e.MaxDailyLoss})
+		}
+	}
+
+	if e.MaxDrawdown.Sign() > 0 && status.PeakEquity.Sign() > 0 {
+		drawdown := status.PeakEquity.Sub(status.Equity).Div(status.PeakEquity)
+		if drawdown.Compare(e.MaxDrawdown) > 0 {
+			breaches = append(breaches, PortfolioRiskBreach{Limit: "maxDrawdown", Value: drawdown, Threshold: e.MaxDrawdown})
+		}
+	}
+
+	if e.MaxGrossExposure.Sign() > 0 && status.GrossExposure.Compare(e.MaxGrossExposure) > 0 {
+		breaches = append(breaches, PortfolioRiskBreach{Limit: "maxGrossExposure", Value: status.GrossExposure, Threshold: e.MaxGrossExposure})
+	}
+
+	if e.MaxNetExposure.Sign() > 0 && status.NetExposure.Abs().Compare(e.MaxNetExposure) > 0 {
+		breaches = append(breaches, PortfolioRiskBreach{Limit: "maxNetExposure", Value: status.NetExposure, Threshold: e.MaxNetExposure})
+	}
+
+	if e.MaxRealizedLoss.Sign() > 0 {
+		if loss := status.RealizedProfit.Neg(); loss.Compare(e.MaxRealizedLoss) > 0 {
+			breaches = append(breaches, PortfolioRiskBreach{Limit: "maxRealizedLoss", Value: loss, Threshold: e.MaxRealizedLoss})
+		}
+	}
+
+	if e.MaxUnrealizedLoss.Sign() > 0 {
+		if loss := status.UnrealizedProfit.Neg(); loss.Compare(e.MaxUnrealizedLoss) > 0 {
+			breaches = append(breaches, PortfolioRiskBreach{Limit: "maxUnrealizedLoss", Value: loss, Threshold: e.MaxUnrealizedLoss})
+		}
+	}
+
+	if e.MaxAssetConcentration.Sign() > 0 && status.Equity.Sign() > 0 {
+		for currency, exposure := range status.Exposures {
+			concentration := exposure.Abs().Div(status.Equity)
+			if concentration.Compare(e.MaxAssetConcentration) > 0 {
+				breaches = append(breaches, PortfolioRiskBreach{
+					Limit:     "maxAssetConcentration",
+					Asset:     currency,
+					Value:     concentration,
+					Threshold: e.MaxAssetConcentration,
+				})
+			}
+		}
+	}
+
+	return breaches
+}
+
+// isAffected returns true if the strategy is affected by the breach, the asset concentration breach only affects
+// the strategies that trade the asset, other breaches affect all the strategies.
+func (e *PortfolioRiskEngine) isAffected(s riskStrategy, breach PortfolioRiskBreach) bool {
+	if breach.Asset == "" || s.symbol == "" {
+		return true
+	}
+
+	session, ok := e.sessions[s.session]
+	if !ok {
+		return true
+	}
+
+	market, ok := session.Market(s.symbol)
+	if !ok {
+		return true
+	}
+
+	return market.BaseCurrency == breach.Asset || market.QuoteCurrency == breach.Asset
+}
+
+func (e *PortfolioRiskEngine) stopStrategies(breach PortfolioRiskBreach) {
+	for _, s := range e.strategies {
+		if !e.isAffected(s, breach) {
+			continue
+		}
+
+		id := fmt.Sprintf("%T", s.strategy)
+		if sid, ok := s.strategy.(StrategyID); ok {
+			id = sid.ID()
+		}
+
+		if e.Action == PortfolioRiskActionEmergencyStop {
+			if stopper, ok := s.strategy.(EmergencyStopper); ok {
+				if err := stopper.EmergencyStop(); err != nil {
+					log.WithError(err).Errorf("strategy %s emergency stop error", id)
+				} else {
+					e.notify("Strategy %s is stopped by the portfolio risk engine", id)
+				}
+				continue
+			}
+		}
+
+		if toggler, ok := s.strategy.(StrategyToggler); ok {
+			if err := toggler.Suspend(); err != nil {
+				log.WithError(err).Errorf("strategy %s suspend error", id)
+			} else {
+				e.notify("Strategy %s is suspended by the portfolio risk engine", id)
+			}
+			continue
+		}
+
+		log.Warnf("strategy %s does not implement StrategyToggler or EmergencyStopper", id)
+	}
+}
@@ -0,0 +1,204 @@
+package bbgo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+type testRiskStrategy struct {
+	StrategyController
+
+	Symbol string
+
+	emergencyStopped bool
+}
+
+func (s *testRiskStrategy) ID() string { return "test" }
+
+func (s *testRiskStrategy) Run(ctx context.Context, orderExecutor OrderExecutor, session *ExchangeSession) error {
+	return nil
+}
+
+func (s *testRiskStrategy) EmergencyStop() error {
+	s.emergencyStopped = true
+	return s.StrategyController.EmergencyStop()
+}
+
+func newTestRiskSession(name string, balances types.BalanceMap, prices map[string]fixedpoint.Value) *ExchangeSession {
+	account := types.NewAccount()
+	account.UpdateBalances(balances)
+
+	return &ExchangeSession{
+		Name:    name,
+		Account: account,
+		markets: map[string]types.Market{
+			"BTCUSDT": {Symbol: "BTCUSDT", BaseCurrency: "BTC", QuoteCurrency: "USDT"},
+			"ETHUSDT": {Symbol: "ETHUSDT", BaseCurrency: "ETH", QuoteCurrency: "USDT"},
+		},
+		lastPrices: prices,
+	}
+}
+
+func newTestBalance(currency string, available float64) types.Balance {
+	return types.Balance{Currency: currency, Available: fixedpoint.NewFromFloat(available)}
+}
+
+func TestPortfolioRiskEngine_Drawdown(t *testing.T) {
+	prices := map[string]fixedpoint.Value{
+		"BTCUSDT": fixedpoint.NewFromInt(10000),
+		"ETHUSDT": fixedpoint.NewFromInt(1000),
+	}
+
+	binance := newTestRiskSession("binance", types.BalanceMap{
+		"USDT": newTestBalance("USDT", 10000),
+		"BTC":  newTestBalance("BTC", 1),
+	}, prices)
+	max := newTestRiskSession("max", types.BalanceMap{
+		"USDT": newTestBalance("USDT", 10000),
+	}, prices)
+
+	engine := NewPortfolioRiskEngine(&PortfolioRiskControl{
+		MaxDrawdown:  fixedpoint.MustNewFromString("10%"),
+		MaxDailyLoss: fixedpoint.NewFromInt(5000),
+		Action:       PortfolioRiskActionEmergencyStop,
+	}, map[string]*ExchangeSession{"binance": binance, "max": max}, nil)
+
+	btcStrategy := &testRiskStrategy{Symbol: "BTCUSDT"}
+	ethStrategy := &testRiskStrategy{Symbol: "ETHUSDT"}
+	engine.AddStrategy("binance", btcStrategy)
+	engine.AddStrategy("max", ethStrategy)
+
+	now := time.Date(2022, time.May, 1, 10, 0, 0, 0, time.UTC)
+	assert.Empty(t, engine.Check(now))
+
+	status := engine.Status()
+	assert.Equal(t, "30000", status.Equity.String())
+	assert.Equal(t, "10000", status.GrossExposure.String())
+
+	// track the realized profit of the positions
+	engine.AddTrade("binance", types.Trade{Symbol: "BTCUSDT", Side: types.SideTypeBuy, Price: fixedpoint.NewFromInt(12000), Quantity: fixedpoint.One, QuoteQuantity: fixedpoint.NewFromInt(12000)})
+
+	// 30000 -> 26000, the drawdown is 13.3%
+	prices["BTCUSDT"] = fixedpoint.NewFromInt(6000)
+	breaches := engine.Check(now.Add(time.Minute))
+	if assert.Len(t, breaches, 1) {
+		assert.Equal(t, "maxDrawdown", breaches[0].Limit)
+	}
+
+	status = engine.Status()
+	assert.Equal(t, "-6000", status.UnrealizedProfit.String())
+	assert.True(t, btcStrategy.emergencyStopped)
+	assert.True(t, ethStrategy.emergencyStopped)
+
+	// the tripped limit is not triggered again
+	assert.Empty(t, engine.Check(now.Add(2*time.Minute)))
+
+	// the daily loss is calculated from the equity of the day start
+	prices["BTCUSDT"] = fixedpoint.NewFromInt(10000)
+	assert.Empty(t, engine.Check(now.Add(24*time.Hour)))
+	prices["BTCUSDT"] = fixedpoint.NewFromInt(4000)
+	breaches = engine.Check(now.Add(25 * time.Hour))
+	if assert.Len(t, breaches, 1) {
+		assert.Equal(t, "maxDailyLoss", breaches[0].Limit)
+		assert.Equal(t, "6000", breaches[0].Value.String())
+	}
+}
+
+func TestPortfolioRiskEngine_Concentration(t *testing.T) {
+	prices := map[string]fixedpoint.Value{
+		"BTCUSDT": fixedpoint.NewFromInt(10000),
+		"ETHUSDT": fixedpoint.NewFromInt(1000),
+	}
+
+	session := newTestRiskSession("binance", types.BalanceMap{
+		"USDT": newTestBalance("USDT", 2000),
+		"BTC":  newTestBalance("BTC", 1),
+		"ETH":  newTestBalance("ETH", 1),
+	}, prices)
+
+	engine := NewPortfolioRiskEngine(&PortfolioRiskControl{
+		MaxAssetConcentration: fixedpoint.MustNewFromString("50%"),
+		MaxGrossExposure:      fixedpoint.NewFromInt(20000),
+	}, map[string]*ExchangeSession{"binance": session}, nil)
+
+	btcStrategy := &testRiskStrategy{Symbol: "BTCUSDT"}
+	ethStrategy := &testRiskStrategy{Symbol: "ETHUSDT"}
+	engine.AddStrategy("binance", btcStrategy)
+	engine.AddStrategy("binance", ethStrategy)
+
+	// BTC is 10000 / 13000 of the equity
+	breaches := engine.Check(time.Now())
+	if assert.Len(t, breaches, 1) {
+		assert.Equal(t, "maxAssetConcentration", breaches[0].Limit)
+		assert.Equal(t, "BTC", breaches[0].Asset)
+	}
+
+	// only the strategy that trades BTC is suspended
+	assert.Equal(t, types.StrategyStatusStopped, btcStrategy.GetStatus())
+	assert.False(t, btcStrategy.emergencyStopped)
+	assert.NotEqual(t, types.StrategyStatusStopped, ethStrategy.GetStatus())
+
+	prices["ETHUSDT"] = fixedpoint.NewFromInt(15000)
+	breaches = engine.Check(time.Now())
+	if assert.Len(t, breaches, 2) {
+		assert.Equal(t, "maxGrossExposure", breaches[0].Limit)
+		assert.Equal(t, "maxAssetConcentration", breaches[1].Limit)
+		assert.Equal(t, "ETH", breaches[1].Asset)
+	}
+	assert.Equal(t, types.StrategyStatusStopped, ethStrategy.GetStatus())
+}
+
+func TestPortfolioRiskEngine_PnL(t *testing.T) {
+	prices := map[string]fixedpoint.Value{
+		"BTCUSDT": fixedpoint.NewFromInt(10000),
+	}
+
+	session := newTestRiskSession("binance", types.BalanceMap{
+		"USDT": newTestBalance("USDT", 10000),
+		"BUSD": newTestBalance("BUSD", 1000),
+		"BTC":  newTestBalance("BTC", 1),
+		"XYZ":  newTestBalance("XYZ", 5),
+	}, prices)
+
+	engine := NewPortfolioRiskEngine(&PortfolioRiskControl{
+		MaxUnrealizedLoss: fixedpoint.NewFromInt(1500),
+		MaxRealizedLoss:   fixedpoint.NewFromInt(1000),
+	}, map[string]*ExchangeSession{"binance": session}, nil)
+
+	strategy := &testRiskStrategy{Symbol: "BTCUSDT"}
+	engine.AddStrategy("binance", strategy)
+
+	// the BTC balance is seeded at the last price
+	engine.SeedPositions()
+
+	now := time.Date(2022, time.May, 1, 10, 0, 0, 0, time.UTC)
+	assert.Empty(t, engine.Check(now))
+
+	// BUSD is valued as USD, XYZ has no price and it's skipped
+	status := engine.Status()
+	assert.Equal(t, "21000", status.Equity.String())
+	assert.Equal(t, "0", status.UnrealizedProfit.String())
+	assert.Equal(t, []string{"XYZ"}, status.UnpricedAssets)
+	assert.NotContains(t, status.Exposures, "XYZ")
+
+	prices["BTCUSDT"] = fixedpoint.NewFromInt(8000)
+	breaches := engine.Check(now.Add(time.Minute))
+	if assert.Len(t, breaches, 1) {
+		assert.Equal(t, "maxUnrealizedLoss", breaches[0].Limit)
+		assert.Equal(t, "2000", breaches[0].Value.String())
+	}
+
+	// the seeded position is closed with the loss
+	engine.AddTrade("binance", types.Trade{Symbol: "BTCUSDT", Side: types.SideTypeSell, Price: fixedpoint.NewFromInt(8000), Quantity: fixedpoint.One, QuoteQuantity: fixedpoint.NewFromInt(8000)})
+	breaches = engine.Check(now.Add(2 * time.Minute))
+	if assert.Len(t, breaches, 1) {
+		assert.Equal(t, "maxRealizedLoss", breaches[0].Limit)
+		assert.Equal(t, "2000", breaches[0].Value.String())
+	}
+}
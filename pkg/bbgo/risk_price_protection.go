@@ -0,0 +1,200 @@
+package bbgo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const (
+	PriceProtectionSourceLastPrice = "lastPrice"
+	PriceProtectionSourceMidPrice  = "midPrice"
+
+	PriceProtectionActionReject = "reject"
+	PriceProtectionActionClamp  = "clamp"
+)
+
+var (
+	ErrPriceOutOfBand    = errors.New("order price is out of the price band")
+	ErrOrderSizeTooLarge = errors.New("order size is too large")
+)
+
+// PriceProtectionRiskController is the pre-trade check of the fat-finger orders.
+// It checks the order price against a percentage band around the reference price,
+// and caps the order quantity by the order book depth and the recent kline volume.
+type PriceProtectionRiskController struct {
+	// MaxPriceDeviation is the max deviation of the order price from the reference price, e.g. 5%
+	MaxPriceDeviation fixedpoint.Value `json:"maxPriceDeviation,omitempty" yaml:"maxPriceDeviation,omitempty"`
+
+	// PriceSource is the reference price: lastPrice or midPrice, the default source is lastPrice.
+	// The mid price falls back to the last price when the order book is empty.
+	PriceSource string `json:"priceSource,omitempty" yaml:"priceSource,omitempty"`
+
+	// MaxDepthRatio is the max order quantity as a fraction of the top DepthLevels volume on the opposite side of the book
+	MaxDepthRatio fixedpoint.Value `json:"maxDepthRatio,omitempty" yaml:"maxDepthRatio,omitempty"`
+
+	// DepthLevels is the number of the price levels of the depth, the default is 5
+	DepthLevels int `json:"depthLevels,omitempty" yaml:"depthLevels,omitempty"`
+
+	// MaxVolumeRatio is the max order quantity as a fraction of the volume of the recent VolumeWindow klines
+	MaxVolumeRatio fixedpoint.Value `json:"maxVolumeRatio,omitempty" yaml:"maxVolumeRatio,omitempty"`
+
+	// VolumeInterval is the kline interval of the volume, the default interval is 1m
+	VolumeInterval types.Interval `json:"volumeInterval,omitempty" yaml:"volumeInterval,omitempty"`
+
+	// VolumeWindow is the number of the klines of the volume, the default window is 60
+	VolumeWindow int `json:"volumeWindow,omitempty" yaml:"volumeWindow,omitempty"`
+
+	// Action is the action of the orders that fail the checks: reject or clamp, the default action is reject.
+	// clamp moves the price into the band and reduces the quantity to the cap.
+	Action string `json:"action,omitempty" yaml:"action,omitempty"`
+}
+
+func (c *PriceProtectionRiskController) ID() string {
+	return "priceProtection"
+}
+
+// CheckOrders rejects or clamps the orders that are out of the price band or larger than the size caps
+func (c *PriceProtectionRiskController) CheckOrders(ctx context.Context, session *ExchangeSession, orders ...types.SubmitOrder) ([]types.SubmitOrder, []RiskRejection) {
+	return checkOrdersBy(c.ID(), orders, func(order types.SubmitOrder) (types.SubmitOrder, error) {
+		return c.processOrder(session, order)
+	})
+}
+
+func (c *PriceProtectionRiskController) processOrder(session *ExchangeSession, order types.SubmitOrder) (types.SubmitOrder, error) {
+	market, ok := session.Market(order.Symbol)
+	if !ok {
+		return order, fmt.Errorf("the market config of symbol %q is not found, order: %s", order.Symbol, order.String())
+	}
+
+	clamp := c.Action == PriceProtectionActionClamp
+
+	if c.MaxPriceDeviation.Sign() > 0 && hasLimitPrice(order) {
+		refPrice, err := c.referencePrice(session, order.Symbol)
+		if err != nil {
+			return order, err
+		}
+
+		lower := refPrice.Mul(fixedpoint.One.Sub(c.MaxPriceDeviation))
+		upper := refPrice.Mul(fixedpoint.One.Add(c.MaxPriceDeviation))
+		if order.Price.Compare(lower) < 0 || order.Price.Compare(upper) > 0 {
+			if !clamp {
+				return order, errors.Wrapf(ErrPriceOutOfBand, "price %s is out of %s ~ %s, order: %s",
+					order.Price.String(), lower.String(), upper.String(), order.String())
+			}
+
+			order.Price = fixedpoint.Min(fixedpoint.Max(order.Price, lower), upper)
+		}
+	}
+
+	maxQuantity, limit, err := c.maxQuantity(session, order)
+	if err != nil {
+		return order, err
+	}
+
+	if limit != "" && order.Quantity.Compare(maxQuantity) > 0 {
+		if !clamp {
+			return order, errors.Wrapf(ErrOrderSizeTooLarge, "quantity %s exceeds the %s cap %s, order: %s",
+				order.Quantity.String(), limit, maxQuantity.String(), order.String())
+		}
+
+		order.Quantity = market.TruncateQuantity(maxQuantity)
+		if order.Quantity.Compare(market.MinQuantity) < 0 || order.Quantity.Sign() <= 0 {
+			return order, errors.Wrapf(ErrOrderSizeTooLarge, "the %s cap %s is less than the min quantity %s, order: %s",
+				limit, maxQuantity.String(), market.MinQuantity.String(), order.String())
+		}
+	}
+
+	return order, nil
+}
+
+// referencePrice returns the reference price of the price band
+func (c *PriceProtectionRiskController) referencePrice(session *ExchangeSession, symbol string) (fixedpoint.Value, error) {
+	if c.PriceSource == PriceProtectionSourceMidPrice {
+		if book, ok := session.OrderBook(symbol); ok {
+			if bid, ask, ok := book.BestBidAndAsk(); ok {
+				return bid.Price.Add(ask.Price).Div(fixedpoint.NewFromInt(2)), nil
+			}
+		}
+	}
+
+	price, ok := session.LastPrice(symbol)
+	if !ok || price.Sign() <= 0 {
+		return fixedpoint.Zero, fmt.Errorf("the reference price of symbol %q is not found", symbol)
+	}
+
+	return price, nil
+}
+
+// maxQuantity returns the smallest quantity cap of the depth and the volume limits, limit is empty if no cap is configured
+func (c *PriceProtectionRiskController) maxQuantity(session *ExchangeSession, order types.SubmitOrder) (maxQuantity fixedpoint.Value, limit string, err error) {
+	if c.MaxDepthRatio.Sign() > 0 {
+		book, ok := session.OrderBook(order.Symbol)
+		if !ok {
+			return maxQuantity, limit, fmt.Errorf("the order book of symbol %q is not found, order: %s", order.Symbol, order.String())
+		}
+
+		levels := c.DepthLevels
+		if levels <= 0 {
+			levels = 5
+		}
+
+		// a buy order takes the asks, a sell order takes the bids
+		side := types.SideTypeSell
+		if order.Side == types.SideTypeSell {
+			side = types.SideTypeBuy
+		}
+
+		depth := fixedpoint.Zero
+		for _, pv := range book.CopyDepth(levels).SideBook(side) {
+			depth = depth.Add(pv.Volume)
+		}
+
+		maxQuantity, limit = depth.Mul(c.MaxDepthRatio), "depth"
+	}
+
+	if c.MaxVolumeRatio.Sign() > 0 {
+		interval := c.VolumeInterval
+		if interval == "" {
+			interval = types.Interval1m
+		}
+
+		window := c.VolumeWindow
+		if window <= 0 {
+			window = 60
+		}
+
+		volume := fixedpoint.Zero
+		if store, ok := session.MarketDataStore(order.Symbol); ok {
+			if klines, ok := store.KLinesOfInterval(interval); ok {
+				for _, k := range klines.Tail(window) {
+					volume = volume.Add(k.Volume)
+				}
+			}
+		}
+
+		if volume.IsZero() {
+			return maxQuantity, limit, fmt.Errorf("the %s kline volume of symbol %q is not found, order: %s", interval, order.Symbol, order.String())
+		}
+
+		volumeCap := volume.Mul(c.MaxVolumeRatio)
+		if limit == "" || volumeCap.Compare(maxQuantity) < 0 {
+			maxQuantity, limit = volumeCap, "volume"
+		}
+	}
+
+	return maxQuantity, limit, nil
+}
+
+func hasLimitPrice(order types.SubmitOrder) bool {
+	switch order.Type {
+	case types.OrderTypeMarket, types.OrderTypeStopMarket:
+		return false
+	}
+
+	return order.Price.Sign() > 0
+}
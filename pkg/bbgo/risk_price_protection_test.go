@@ -0,0 +1,161 @@
+package bbgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+type testNotifier struct {
+	NullNotifier
+	messages []interface{}
+}
+
+func (n *testNotifier) Notify(obj interface{}, args ...interface{}) {
+	n.messages = append(n.messages, obj)
+}
+
+func newTestPriceProtectionSession() *ExchangeSession {
+	book := types.NewStreamBook("BTCUSDT")
+	book.Load(types.SliceOrderBook{
+		Symbol: "BTCUSDT",
+		Bids: types.PriceVolumeSlice{
+			{Price: fixedpoint.NewFromInt(99), Volume: fixedpoint.NewFromInt(2)},
+			{Price: fixedpoint.NewFromInt(98), Volume: fixedpoint.NewFromInt(3)},
+		},
+		Asks: types.PriceVolumeSlice{
+			{Price: fixedpoint.NewFromInt(101), Volume: fixedpoint.NewFromInt(1)},
+			{Price: fixedpoint.NewFromInt(102), Volume: fixedpoint.NewFromInt(1)},
+			{Price: fixedpoint.NewFromInt(103), Volume: fixedpoint.NewFromInt(10)},
+		},
+	})
+
+	store := NewMarketDataStore("BTCUSDT")
+	for i := 0; i < 3; i++ {
+		store.AddKLine(types.KLine{Symbol: "BTCUSDT", Interval: types.Interval1m, Volume: fixedpoint.NewFromInt(10)})
+	}
+
+	return &ExchangeSession{
+		markets: map[string]types.Market{
+			"BTCUSDT": {Symbol: "BTCUSDT", BaseCurrency: "BTC", QuoteCurrency: "USDT", MinQuantity: fixedpoint.NewFromFloat(0.001), StepSize: fixedpoint.NewFromFloat(0.001), TickSize: fixedpoint.NewFromFloat(0.01)},
+		},
+		lastPrices:       map[string]fixedpoint.Value{"BTCUSDT": fixedpoint.NewFromInt(110)},
+		orderBooks:       map[string]*types.StreamOrderBook{"BTCUSDT": book},
+		marketDataStores: map[string]*MarketDataStore{"BTCUSDT": store},
+	}
+}
+
+func TestPriceProtectionRiskController_PriceBand(t *testing.T) {
+	ctx := context.Background()
+	session := newTestPriceProtectionSession()
+
+	controller := &PriceProtectionRiskController{MaxPriceDeviation: fixedpoint.MustNewFromString("10%")}
+	orders, rejections := controller.CheckOrders(ctx, session,
+		newTestSubmitOrder(types.SideTypeBuy, 100),
+		newTestSubmitOrder(types.SideTypeBuy, 98),
+		newTestSubmitOrder(types.SideTypeSell, 122))
+	if assert.Len(t, orders, 1) {
+		assert.Equal(t, "100", orders[0].Price.String())
+	}
+	if assert.Len(t, rejections, 2) {
+		assert.True(t, errors.Is(rejections[0], ErrPriceOutOfBand))
+	}
+
+	// the band of the mid price 100 is 90 ~ 110
+	controller.PriceSource = PriceProtectionSourceMidPrice
+	controller.Action = PriceProtectionActionClamp
+	orders, rejections = controller.CheckOrders(ctx, session,
+		newTestSubmitOrder(types.SideTypeBuy, 80),
+		newTestSubmitOrder(types.SideTypeSell, 115))
+	assert.Empty(t, rejections)
+	if assert.Len(t, orders, 2) {
+		assert.Equal(t, "90", orders[0].Price.String())
+		assert.Equal(t, "110", orders[1].Price.String())
+	}
+
+	// market orders have no price
+	market := newTestSubmitOrder(types.SideTypeBuy, 0)
+	market.Type = types.OrderTypeMarket
+	orders, rejections = controller.CheckOrders(ctx, session, market)
+	assert.Empty(t, rejections)
+	assert.Len(t, orders, 1)
+}
+
+func TestPriceProtectionRiskController_SizeCaps(t *testing.T) {
+	ctx := context.Background()
+	session := newTestPriceProtectionSession()
+
+	// the top 2 asks have 2 BTC, the top 2 bids have 5 BTC
+	controller := &PriceProtectionRiskController{
+		MaxDepthRatio: fixedpoint.MustNewFromString("50%"),
+		DepthLevels:   2,
+	}
+
+	buy := newTestSubmitOrder(types.SideTypeBuy, 100)
+	buy.Quantity = fixedpoint.NewFromFloat(1.5)
+	sell := newTestSubmitOrder(types.SideTypeSell, 100)
+	sell.Quantity = fixedpoint.NewFromFloat(2.5)
+
+	orders, rejections := controller.CheckOrders(ctx, session, buy, sell)
+	if assert.Len(t, rejections, 1) {
+		assert.True(t, errors.Is(rejections[0], ErrOrderSizeTooLarge))
+	}
+	if assert.Len(t, orders, 1) {
+		assert.Equal(t, types.SideTypeSell, orders[0].Side)
+	}
+
+	// the volume cap of the last 2 klines is 20 * 10% = 2 BTC
+	controller.MaxVolumeRatio = fixedpoint.MustNewFromString("10%")
+	controller.VolumeWindow = 2
+	controller.Action = PriceProtectionActionClamp
+	orders, rejections = controller.CheckOrders(ctx, session, buy, sell)
+	assert.Empty(t, rejections)
+	if assert.Len(t, orders, 2) {
+		assert.Equal(t, "1", orders[0].Quantity.String())
+		assert.Equal(t, "2", orders[1].Quantity.String())
+	}
+
+	// the size caps can not be checked without the market data
+	session.orderBooks = map[string]*types.StreamOrderBook{}
+	_, rejections = controller.CheckOrders(ctx, session, buy)
+	assert.Len(t, rejections, 1)
+}
+
+func TestRiskControlOrderExecutor_PriceProtection(t *testing.T) {
+	exchange := &testOrderExchange{}
+	session := newTestPriceProtectionSession()
+	session.Exchange = exchange
+
+	notifier := &testNotifier{}
+	executor := &RiskControlOrderExecutor{
+		ExchangeOrderExecutor: &ExchangeOrderExecutor{Session: session},
+		BySymbol: map[string]*SymbolBasedRiskController{
+			"BTCUSDT": {
+				PriceProtection: &PriceProtectionRiskController{MaxPriceDeviation: fixedpoint.MustNewFromString("5%")},
+			},
+		},
+	}
+	executor.AddNotifier(notifier)
+
+	var rejections []RiskRejection
+	executor.OnRiskRejection(func(rejection RiskRejection) {
+		rejections = append(rejections, rejection)
+	})
+
+	_, err := executor.SubmitOrders(context.Background(),
+		newTestSubmitOrder(types.SideTypeBuy, 108),
+		newTestSubmitOrder(types.SideTypeBuy, 50))
+	assert.True(t, errors.Is(err, ErrPriceOutOfBand))
+	assert.Len(t, exchange.submitted, 1)
+	if assert.Len(t, rejections, 1) {
+		assert.True(t, errors.Is(rejections[0], ErrPriceOutOfBand))
+	}
+
+	// the rejection is reported through the notifiers
+	assert.Contains(t, notifier.messages, ":no_entry: %s order rejected by %s: %s")
+}
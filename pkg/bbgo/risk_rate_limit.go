@@ -0,0 +1,117 @@
+package bbgo
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/c9s/bbgo/pkg/types"
+	"github.com/c9s/bbgo/pkg/util"
+)
+
+var (
+	ErrOrderRateLimitExceeded  = errors.New("order rate limit exceeded")
+	ErrCancelRateLimitExceeded = errors.New("cancel rate limit exceeded")
+)
+
+// RateLimit is the token bucket config of the order submissions or the order cancellations,
+// every order takes one token from the bucket.
+type RateLimit struct {
+	// Limit is the number of the orders that can be sent in every interval
+	Limit float64 `json:"limit" yaml:"limit"`
+
+	// Interval is the refill interval of the bucket, the default interval is 1s
+	Interval types.Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+
+	// Burst is the size of the bucket, the default burst is the limit
+	Burst int `json:"burst,omitempty" yaml:"burst,omitempty"`
+
+	// Wait waits for the tokens instead of rejecting the orders when the bucket is empty
+	Wait bool `json:"wait,omitempty" yaml:"wait,omitempty"`
+}
+
+func (l *RateLimit) NewLimiter() (*rate.Limiter, error) {
+	interval := l.Interval.Duration()
+	if interval == 0 {
+		interval = time.Second
+	}
+
+	burst := l.Burst
+	if burst == 0 {
+		burst = int(math.Ceil(l.Limit))
+	}
+
+	return util.NewTokenBucket(l.Limit, interval, burst)
+}
+
+// getLimiter returns the limiter of the key, the limiter is created on the first use
+func (e *RiskControlOrderExecutor) getLimiter(key string, config *RateLimit) (*rate.Limiter, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if limiter, ok := e.limiters[key]; ok {
+		return limiter, nil
+	}
+
+	limiter, err := config.NewLimiter()
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid rate limit %s", key)
+	}
+
+	if e.limiters == nil {
+		e.limiters = make(map[string]*rate.Limiter)
+	}
+
+	e.limiters[key] = limiter
+	return limiter, nil
+}
+
+// takeTokens takes n tokens from the bucket of the key, errLimit is returned when the bucket is empty
+func (e *RiskControlOrderExecutor) takeTokens(ctx context.Context, key string, config *RateLimit, n int, errLimit error) error {
+	if config == nil || n == 0 {
+		return nil
+	}
+
+	limiter, err := e.getLimiter(key, config)
+	if err != nil {
+		return err
+	}
+
+	if config.Wait {
+		if err := limiter.WaitN(ctx, n); err != nil {
+			return errors.Wrapf(errLimit, "%s: %v", key, err)
+		}
+		return nil
+	}
+
+	if !limiter.AllowN(time.Now(), n) {
+		return errors.Wrapf(errLimit, "%s: can not send %d orders", key, n)
+	}
+
+	return nil
+}
+
+// takeOrderTokens applies the per-symbol and the per-session order rate limits
+func (e *RiskControlOrderExecutor) takeOrderTokens(ctx context.Context, symbol string, n int) error {
+	if controller, ok := e.BySymbol[symbol]; ok && controller != nil {
+		if err := e.takeTokens(ctx, "order:"+symbol, controller.OrderRateLimit, n, ErrOrderRateLimitExceeded); err != nil {
+			return err
+		}
+	}
+
+	return e.takeTokens(ctx, "order", e.OrderRateLimit, n, ErrOrderRateLimitExceeded)
+}
+
+// takeCancelTokens applies the per-symbol and the per-session cancel rate limits
+func (e *RiskControlOrderExecutor) takeCancelTokens(ctx context.Context, symbol string, n int) error {
+	if controller, ok := e.BySymbol[symbol]; ok && controller != nil {
+		if err := e.takeTokens(ctx, "cancel:"+symbol, controller.CancelRateLimit, n, ErrCancelRateLimitExceeded); err != nil {
+			return err
+		}
+	}
+
+	return e.takeTokens(ctx, "cancel", e.CancelRateLimit, n, ErrCancelRateLimitExceeded)
+}
@@ -0,0 +1,196 @@
+package bbgo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const (
+	// SelfTradePreventionReject rejects the new order that crosses our resting orders
+	SelfTradePreventionReject = "reject"
+
+	// SelfTradePreventionCancelResting cancels the crossed resting orders before submitting the new order
+	SelfTradePreventionCancelResting = "cancelResting"
+
+	// SelfTradePreventionReprice moves the price of the new order one tick away from the crossed resting orders,
+	// market orders are rejected since they can not be repriced
+	SelfTradePreventionReprice = "reprice"
+)
+
+const selfTradePreventionCheckID = "selfTradePrevention"
+
+var ErrSelfTrade = errors.New("self-trade prevented")
+
+// AddActiveOrderBook adds the active order books that are checked by the self-trade prevention,
+// the orders of the session order stores are always checked.
+func (e *RiskControlOrderExecutor) AddActiveOrderBook(books ...*LocalActiveOrderBook) {
+	e.mu.Lock()
+	e.activeOrderBooks = append(e.activeOrderBooks, books...)
+	e.mu.Unlock()
+}
+
+// restingOrders returns the open orders of the symbol from the session order store and the active order books
+func (e *RiskControlOrderExecutor) restingOrders(symbol string) (orders []types.Order) {
+	var seen = make(map[uint64]struct{})
+	var add = func(o types.Order) {
+		if o.Symbol != symbol {
+			return
+		}
+
+		switch o.Status {
+		case types.OrderStatusNew, types.OrderStatusPartiallyFilled:
+		default:
+			return
+		}
+
+		if _, ok := seen[o.OrderID]; ok {
+			return
+		}
+
+		seen[o.OrderID] = struct{}{}
+		orders = append(orders, o)
+	}
+
+	if e.Session != nil {
+		if store, ok := e.Session.OrderStore(symbol); ok {
+			for _, o := range store.Orders() {
+				add(o)
+			}
+		}
+	}
+
+	e.mu.Lock()
+	books := e.activeOrderBooks
+	e.mu.Unlock()
+
+	for _, book := range books {
+		for _, o := range book.Orders() {
+			add(o)
+		}
+	}
+
+	return orders
+}
+
+// crossingOrders returns the resting orders that would be matched by the submit order
+func crossingOrders(order types.SubmitOrder, resting []types.Order) (crossed []types.Order) {
+	isMarket := order.Type == types.OrderTypeMarket || order.Type == types.OrderTypeStopMarket
+	for _, o := range resting {
+		if o.Side == order.Side {
+			continue
+		}
+
+		switch order.Side {
+		case types.SideTypeBuy:
+			if isMarket || o.Price.Compare(order.Price) <= 0 {
+				crossed = append(crossed, o)
+			}
+
+		case types.SideTypeSell:
+			if isMarket || o.Price.Compare(order.Price) >= 0 {
+				crossed = append(crossed, o)
+			}
+		}
+	}
+
+	return crossed
+}
+
+// preventSelfTrades checks the submit orders against our resting orders with the self-trade prevention policy
+func (e *RiskControlOrderExecutor) preventSelfTrades(ctx context.Context, symbol string, orders []types.SubmitOrder) (outOrders []types.SubmitOrder, rejections []RiskRejection) {
+	resting := e.restingOrders(symbol)
+	if len(resting) == 0 {
+		return orders, nil
+	}
+
+	for _, order := range orders {
+		crossed := crossingOrders(order, resting)
+		if len(crossed) == 0 {
+			outOrders = append(outOrders, order)
+			continue
+		}
+
+		switch e.SelfTradePrevention {
+		case SelfTradePreventionReject:
+			rejections = append(rejections, NewRiskRejection(selfTradePreventionCheckID, order, errors.Wrapf(ErrSelfTrade, "the order crosses %d resting orders", len(crossed))))
+			continue
+
+		case SelfTradePreventionCancelResting:
+			if err := e.CancelOrders(ctx, crossed...); err != nil {
+				rejections = append(rejections, NewRiskRejection(selfTradePreventionCheckID, order, errors.Wrapf(ErrSelfTrade, "can not cancel the crossed resting orders: %v", err)))
+				continue
+			}
+
+			resting = excludeOrders(resting, crossed)
+
+		case SelfTradePreventionReprice:
+			repriced, err := e.repriceOrder(order, crossed)
+			if err != nil {
+				rejections = append(rejections, NewRiskRejection(selfTradePreventionCheckID, order, err))
+				continue
+			}
+
+			order = repriced
+
+		default:
+			rejections = append(rejections, NewRiskRejection(selfTradePreventionCheckID, order, fmt.Errorf("unsupported self-trade prevention policy %q", e.SelfTradePrevention)))
+			continue
+		}
+
+		outOrders = append(outOrders, order)
+	}
+
+	return outOrders, rejections
+}
+
+// repriceOrder moves the order price one tick away from the best crossed resting order
+func (e *RiskControlOrderExecutor) repriceOrder(order types.SubmitOrder, crossed []types.Order) (types.SubmitOrder, error) {
+	if order.Type == types.OrderTypeMarket || order.Type == types.OrderTypeStopMarket {
+		return order, errors.Wrapf(ErrSelfTrade, "market orders can not be repriced")
+	}
+
+	market, ok := e.Session.Market(order.Symbol)
+	if !ok {
+		return order, fmt.Errorf("the market config of symbol %q is not found, order: %s", order.Symbol, order.String())
+	}
+
+	price := crossed[0].Price
+	for _, o := range crossed[1:] {
+		if (order.Side == types.SideTypeBuy && o.Price.Compare(price) < 0) ||
+			(order.Side == types.SideTypeSell && o.Price.Compare(price) > 0) {
+			price = o.Price
+		}
+	}
+
+	if order.Side == types.SideTypeBuy {
+		price = price.Sub(market.TickSize)
+	} else {
+		price = price.Add(market.TickSize)
+	}
+
+	if price.Sign() <= 0 {
+		return order, errors.Wrapf(ErrSelfTrade, "the order can not be repriced below zero")
+	}
+
+	order.Price = price
+	return order, nil
+}
+
+func excludeOrders(orders []types.Order, excluded []types.Order) (rest []types.Order) {
+	var ids = make(map[uint64]struct{}, len(excluded))
+	for _, o := range excluded {
+		ids[o.OrderID] = struct{}{}
+	}
+
+	for _, o := range orders {
+		if _, ok := ids[o.OrderID]; !ok {
+			rest = append(rest, o)
+		}
+	}
+
+	return rest
+}
@@ -23,6 +23,10 @@ import (
 	"github.com/c9s/bbgo/pkg/util"
 )
 
+// defaultKLinePreloadLimit is the number of the klines that are preloaded for each subscribed interval,
+// it's also the max number of the klines queried by one request
+const defaultKLinePreloadLimit = 1000
+
 var (
 	debugEWMA = false
 	debugSMA  = false
@@ -37,46 +41,50 @@ func init() {
 
 type StandardIndicatorSet struct {
 	Symbol string
-	// Standard indicators
-	// interval -> window
-	sma        map[types.IntervalWindow]*indicator.SMA
-	ewma       map[types.IntervalWindow]*indicator.EWMA
-	boll       map[types.IntervalWindowBandWidth]*indicator.BOLL
-	stoch      map[types.IntervalWindow]*indicator.STOCH
-	volatility map[types.IntervalWindow]*indicator.VOLATILITY
+
+	// indicators stores the shared indicators by the indicator names and the params,
+	// so that the strategies that ask for the same indicator use the same instance
+	indicators map[string]indicator.Indicator
 
 	store *MarketDataStore
+
+	// warmUpBars stores the max warm-up bars of the created indicators by the intervals
+	warmUpBars map[types.Interval]int
+
+	// loadKLines loads the historical klines of the interval into the store until the store has the given number of klines,
+	// it's set by the session after the klines are preloaded
+	loadKLines func(interval types.Interval, bars int) error
+
+	mu sync.Mutex
+
+	// loadMu serializes the historical kline loads, so that mu is not held during the network requests
+	loadMu sync.Mutex
 }
 
 func NewStandardIndicatorSet(symbol string, store *MarketDataStore) *StandardIndicatorSet {
 	set := &StandardIndicatorSet{
 		Symbol:     symbol,
-		sma:        make(map[types.IntervalWindow]*indicator.SMA),
-		ewma:       make(map[types.IntervalWindow]*indicator.EWMA),
-		boll:       make(map[types.IntervalWindowBandWidth]*indicator.BOLL),
-		stoch:      make(map[types.IntervalWindow]*indicator.STOCH),
-		volatility: make(map[types.IntervalWindow]*indicator.VOLATILITY),
+		indicators: make(map[string]indicator.Indicator),
 		store:      store,
+		warmUpBars: make(map[types.Interval]int),
 	}
 
 	// let us pre-defined commonly used intervals
 	for interval := range types.SupportedIntervals {
 		for _, window := range []int{7, 25, 99} {
 			iw := types.IntervalWindow{Interval: interval, Window: window}
-			set.sma[iw] = &indicator.SMA{IntervalWindow: iw}
-			set.sma[iw].Bind(store)
+			sma := set.SMA(iw)
 			if debugSMA {
-				set.sma[iw].OnUpdate(func(value float64) {
+				sma.OnUpdate(func(value float64) {
 					log.Infof("%s SMA %s: %f", symbol, iw.String(), value)
 				})
 			}
 
-			set.ewma[iw] = &indicator.EWMA{IntervalWindow: iw}
-			set.ewma[iw].Bind(store)
+			ewma := set.EWMA(iw)
 
 			// if debug EWMA is enabled, we add the debug handler
 			if debugEWMA {
-				set.ewma[iw].OnUpdate(func(value float64) {
+				ewma.OnUpdate(func(value float64) {
 					log.Infof("%s EWMA %s: %f", symbol, iw.String(), value)
 				})
 			}
@@ -89,72 +97,125 @@ func NewStandardIndicatorSet(symbol string, store *MarketDataStore) *StandardInd
 		iw := types.IntervalWindow{Interval: interval, Window: 21}
 
 		// set efault band width to 2.0
-		iwb := types.IntervalWindowBandWidth{IntervalWindow: iw, BandWidth: 2.0}
-		set.boll[iwb] = &indicator.BOLL{IntervalWindow: iw, K: iwb.BandWidth}
-		set.boll[iwb].Bind(store)
+		set.BOLL(iw, 2.0)
 	}
 
 	return set
 }
 
-// BOLL returns the bollinger band indicator of the given interval, the window and bandwidth
-func (set *StandardIndicatorSet) BOLL(iw types.IntervalWindow, bandWidth float64) *indicator.BOLL {
-	iwb := types.IntervalWindowBandWidth{IntervalWindow: iw, BandWidth: bandWidth}
-	inc, ok := set.boll[iwb]
-	if !ok {
-		inc = &indicator.BOLL{IntervalWindow: iw, K: bandWidth}
-		inc.Bind(set.store)
-		set.boll[iwb] = inc
+// Indicator returns the shared indicator of the registered indicator name and the params.
+// The indicator is created and bound to the market data store when it's asked for the first time,
+// and it's warmed up by the loaded klines, see indicator.WarmUpIndicator.
+//
+// The set loads the older klines if the loaded klines are not enough for the indicator. The indicators that are
+// bound to the market data store directly by Bind(store) are warmed up by the loaded klines too, but they don't
+// trigger the historical kline loads.
+func (set *StandardIndicatorSet) Indicator(name string, params indicator.Params) (indicator.Indicator, error) {
+	params, err := indicator.Normalize(name, params)
+	if err != nil {
+		return nil, err
 	}
 
-	return inc
+	key := strings.ToLower(name) + " " + params.String()
+
+	set.mu.Lock()
+	inc, ok := set.indicators[key]
+	set.mu.Unlock()
+	if ok {
+		return inc, nil
+	}
+
+	inc, err = indicator.New(name, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := set.loadHistory(params.Interval, indicator.WarmUpBars(inc)); err != nil {
+		log.WithError(err).Warnf("%s unable to load the historical %s klines for indicator %s %s", set.Symbol, params.Interval, name, params.String())
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	// the same indicator could be created by the other caller while the historical klines are loaded
+	if existing, ok := set.indicators[key]; ok {
+		return existing, nil
+	}
+
+	// the market data store replays the loaded klines to the new indicator when it's bound
+	inc.Bind(set.store)
+	set.indicators[key] = inc
+	return inc, nil
 }
 
-// SMA returns the simple moving average indicator of the given interval and the window size.
-func (set *StandardIndicatorSet) SMA(iw types.IntervalWindow) *indicator.SMA {
-	inc, ok := set.sma[iw]
-	if !ok {
-		inc = &indicator.SMA{IntervalWindow: iw}
-		inc.Bind(set.store)
-		set.sma[iw] = inc
+// loadHistory records the warm-up bars of the interval, and loads the older klines of the interval
+// if the store doesn't have enough klines. It's called without holding mu since it may query the exchange.
+func (set *StandardIndicatorSet) loadHistory(interval types.Interval, bars int) error {
+	set.mu.Lock()
+	if bars > set.warmUpBars[interval] {
+		set.warmUpBars[interval] = bars
+	}
+	loadKLines := set.loadKLines
+	set.mu.Unlock()
+
+	if loadKLines == nil {
+		return nil
+	}
+
+	set.loadMu.Lock()
+	defer set.loadMu.Unlock()
+
+	// only the preloaded intervals are loaded, the klines of the other intervals are not subscribed
+	window := set.store.kLineWindow(interval)
+	if len(window) == 0 || len(window) >= bars {
+		return nil
 	}
 
+	return loadKLines(interval, bars)
+}
+
+// IndicatorFromConfig returns the shared indicator of the indicator config
+func (set *StandardIndicatorSet) IndicatorFromConfig(config indicator.Config) (indicator.Indicator, error) {
+	return set.Indicator(config.Type, config.Params)
+}
+
+// mustIndicator returns the shared indicator of the builtin indicators, which are always registered
+func (set *StandardIndicatorSet) mustIndicator(name string, params indicator.Params) indicator.Indicator {
+	inc, err := set.Indicator(name, params)
+	if err != nil {
+		panic(err)
+	}
 	return inc
 }
 
+// BOLL returns the bollinger band indicator of the given interval, the window and bandwidth
+func (set *StandardIndicatorSet) BOLL(iw types.IntervalWindow, bandWidth float64) *indicator.BOLL {
+	params := indicator.Params{IntervalWindow: iw, Options: map[string]float64{"k": bandWidth}}
+	return set.mustIndicator("boll", params).(*indicator.BOLL)
+}
+
+// SMA returns the simple moving average indicator of the given interval and the window size.
+func (set *StandardIndicatorSet) SMA(iw types.IntervalWindow) *indicator.SMA {
+	return set.mustIndicator("sma", indicator.Params{IntervalWindow: iw}).(*indicator.SMA)
+}
+
 // EWMA returns the exponential weighed moving average indicator of the given interval and the window size.
 func (set *StandardIndicatorSet) EWMA(iw types.IntervalWindow) *indicator.EWMA {
-	inc, ok := set.ewma[iw]
-	if !ok {
-		inc = &indicator.EWMA{IntervalWindow: iw}
-		inc.Bind(set.store)
-		set.ewma[iw] = inc
-	}
-
-	return inc
+	return set.mustIndicator("ewma", indicator.Params{IntervalWindow: iw}).(*indicator.EWMA)
 }
 
 func (set *StandardIndicatorSet) STOCH(iw types.IntervalWindow) *indicator.STOCH {
-	inc, ok := set.stoch[iw]
-	if !ok {
-		inc = &indicator.STOCH{IntervalWindow: iw}
-		inc.Bind(set.store)
-		set.stoch[iw] = inc
-	}
-
-	return inc
+	return set.mustIndicator("stoch", indicator.Params{IntervalWindow: iw}).(*indicator.STOCH)
 }
 
 // VOLATILITY returns the volatility(stddev) indicator of the given interval and the window size.
 func (set *StandardIndicatorSet) VOLATILITY(iw types.IntervalWindow) *indicator.VOLATILITY {
-	inc, ok := set.volatility[iw]
-	if !ok {
-		inc = &indicator.VOLATILITY{IntervalWindow: iw}
-		inc.Bind(set.store)
-		set.volatility[iw] = inc
-	}
+	return set.mustIndicator("volatility", indicator.Params{IntervalWindow: iw}).(*indicator.VOLATILITY)
+}
 
-	return inc
+// RSI returns the relative strength index indicator of the given interval and the window size.
+func (set *StandardIndicatorSet) RSI(iw types.IntervalWindow) *indicator.RSI {
+	return set.mustIndicator("rsi", indicator.Params{IntervalWindow: iw}).(*indicator.RSI)
 }
 
 // ExchangeSession presents the exchange connection Session
@@ -512,12 +573,15 @@ func (session *ExchangeSession) initSymbol(ctx context.Context, environ *Environ
 	}
 
 	for interval := range klineSubscriptions {
+		// load enough klines to warm up the indicators of the interval
+		limit := defaultKLinePreloadLimit
+		if bars := standardIndicatorSet.warmUpBars[interval]; bars > limit {
+			limit = bars
+		}
+
 		// avoid querying the last unclosed kline
 		endTime := environ.startTime
-		kLines, err := session.Exchange.QueryKLines(ctx, symbol, interval, types.KLineQueryOptions{
-			EndTime: &endTime,
-			Limit:   1000, // indicators need at least 100
-		})
+		kLines, err := session.queryKLinesBackward(ctx, symbol, interval, endTime, limit)
 		if err != nil {
 			return err
 		}
@@ -540,12 +604,90 @@ func (session *ExchangeSession) initSymbol(ctx context.Context, environ *Environ
 		}
 	}
 
+	// the indicators created by the strategies load the older klines if the preloaded klines are not enough
+	standardIndicatorSet.loadKLines = func(interval types.Interval, bars int) error {
+		return session.loadHistoricalKLines(ctx, marketDataStore, interval, bars)
+	}
+
 	log.Infof("%s last price: %v", symbol, session.lastPrices[symbol])
 
 	session.initializedSymbols[symbol] = struct{}{}
 	return nil
 }
 
+// queryKLinesBackward queries at most limit klines of the symbol that end before the end time by batches,
+// from the latest batch to the oldest one. The returned klines are sorted from the oldest to the latest.
+// In the backtest mode, the klines are queried from the backtest database by the backtest exchange.
+func (session *ExchangeSession) queryKLinesBackward(ctx context.Context, symbol string, interval types.Interval, endTime time.Time, limit int) ([]types.KLine, error) {
+	if limit > MaxNumOfKLines {
+		limit = MaxNumOfKLines
+	}
+
+	var kLines []types.KLine
+	for len(kLines) < limit {
+		batchLimit := limit - len(kLines)
+		if batchLimit > defaultKLinePreloadLimit {
+			batchLimit = defaultKLinePreloadLimit
+		}
+
+		batchEndTime := endTime
+		batch, err := session.Exchange.QueryKLines(ctx, symbol, interval, types.KLineQueryOptions{
+			EndTime: &batchEndTime,
+			Limit:   batchLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// drop the klines that are already loaded, in case the exchange doesn't filter the end time strictly
+		if len(kLines) > 0 {
+			var oldest = kLines[0].StartTime.Time()
+			var filtered []types.KLine
+			for _, k := range batch {
+				if k.StartTime.Time().Before(oldest) {
+					filtered = append(filtered, k)
+				}
+			}
+			batch = filtered
+		}
+
+		if len(batch) == 0 {
+			break
+		}
+
+		kLines = append(batch, kLines...)
+		endTime = batch[0].StartTime.Time().Add(-time.Millisecond)
+	}
+
+	if len(kLines) > limit {
+		kLines = kLines[len(kLines)-limit:]
+	}
+
+	return kLines, nil
+}
+
+// loadHistoricalKLines loads the klines before the loaded klines of the interval into the market data store,
+// until the store has the given number of klines or there is no more historical kline
+func (session *ExchangeSession) loadHistoricalKLines(ctx context.Context, store *MarketDataStore, interval types.Interval, bars int) error {
+	window := store.kLineWindow(interval)
+	if len(window) == 0 || len(window) >= bars {
+		return nil
+	}
+
+	endTime := window[0].StartTime.Time().Add(-time.Millisecond)
+	kLines, err := session.queryKLinesBackward(ctx, store.Symbol, interval, endTime, bars-len(window))
+	if err != nil {
+		return err
+	}
+
+	if len(kLines) > 0 {
+		log.Infof("%s %s: %d historical klines loaded for the indicator warm-up", store.Symbol, interval, len(kLines))
+		store.PrependKLines(interval, kLines)
+	}
+
+	return nil
+}
+
 func (session *ExchangeSession) StandardIndicatorSet(symbol string) (*StandardIndicatorSet, bool) {
 	set, ok := session.standardIndicatorSets[symbol]
 	return set, ok
@@ -0,0 +1,145 @@
+package bbgo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/indicator"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func TestStandardIndicatorSet_Indicator(t *testing.T) {
+	set := NewStandardIndicatorSet("BTCUSDT", NewMarketDataStore("BTCUSDT"))
+	iw := types.IntervalWindow{Interval: types.Interval1h, Window: 14}
+
+	inc, err := set.Indicator("EWMA", indicator.Params{IntervalWindow: iw})
+	if assert.NoError(t, err) {
+		assert.Same(t, set.EWMA(iw), inc)
+	}
+
+	// the default options are filled, so the typed accessor shares the same instance
+	inc, err = set.IndicatorFromConfig(indicator.Config{Type: "boll", Params: indicator.Params{IntervalWindow: iw}})
+	if assert.NoError(t, err) {
+		assert.Same(t, set.BOLL(iw, 2.0), inc)
+		assert.NotSame(t, set.BOLL(iw, 3.0), inc)
+	}
+
+	_, err = set.Indicator("foo", indicator.Params{IntervalWindow: iw})
+	assert.Error(t, err)
+}
+
+func buildSessionTestKLines(interval types.Interval, startTime time.Time, n int) (kLines []types.KLine) {
+	for i := 0; i < n; i++ {
+		price := fixedpoint.NewFromFloat(100.0 + float64(i%10))
+		kLines = append(kLines, types.KLine{
+			Symbol:    "BTCUSDT",
+			Interval:  interval,
+			StartTime: types.Time(startTime.Add(time.Duration(i) * interval.Duration())),
+			EndTime:   types.Time(startTime.Add(time.Duration(i+1)*interval.Duration() - time.Millisecond)),
+			Open:      price,
+			High:      price,
+			Low:       price,
+			Close:     price,
+			Closed:    true,
+		})
+	}
+	return kLines
+}
+
+func TestStandardIndicatorSet_WarmUp(t *testing.T) {
+	startTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	kLines := buildSessionTestKLines(types.Interval1h, startTime, 130)
+
+	store := NewMarketDataStore("BTCUSDT")
+	set := NewStandardIndicatorSet("BTCUSDT", store)
+	for _, k := range kLines[100:] {
+		store.AddKLine(k)
+	}
+
+	// the preloaded klines are enough for SMA, the indicator is ready once it's created
+	sma := set.SMA(types.IntervalWindow{Interval: types.Interval1h, Window: 20})
+	assert.True(t, sma.IsReady())
+
+	var loadedBars int
+	set.loadKLines = func(interval types.Interval, bars int) error {
+		loadedBars = bars
+
+		// the set is not locked while the historical klines are loaded
+		done := make(chan struct{})
+		go func() {
+			set.SMA(types.IntervalWindow{Interval: types.Interval1h, Window: 20})
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("the indicator set is locked during the historical kline load")
+		}
+
+		store.PrependKLines(interval, kLines[:100])
+		return nil
+	}
+
+	ewma := set.EWMA(types.IntervalWindow{Interval: types.Interval1h, Window: 20})
+	assert.Equal(t, 60, loadedBars)
+	assert.True(t, ewma.IsReady())
+	assert.Equal(t, 130, ewma.Length())
+
+	// the existing indicator is not updated by the historical klines
+	assert.Equal(t, 1, sma.Length())
+
+	window, ok := store.KLinesOfInterval(types.Interval1h)
+	if assert.True(t, ok) {
+		assert.Equal(t, kLines, []types.KLine(*window))
+	}
+}
+
+type klineTestExchange struct {
+	types.Exchange
+
+	kLines []types.KLine
+}
+
+func (e *klineTestExchange) QueryKLines(ctx context.Context, symbol string, interval types.Interval, options types.KLineQueryOptions) ([]types.KLine, error) {
+	var kLines []types.KLine
+	for _, k := range e.kLines {
+		if !k.EndTime.Time().After(*options.EndTime) {
+			kLines = append(kLines, k)
+		}
+	}
+
+	// the exchange returns at most 500 klines per request
+	limit := options.Limit
+	if limit > 500 {
+		limit = 500
+	}
+
+	if len(kLines) > limit {
+		kLines = kLines[len(kLines)-limit:]
+	}
+	return kLines, nil
+}
+
+func TestExchangeSession_queryKLinesBackward(t *testing.T) {
+	startTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	kLines := buildSessionTestKLines(types.Interval1h, startTime, 2500)
+
+	session := &ExchangeSession{Exchange: &klineTestExchange{kLines: kLines}}
+
+	endTime := kLines[1999].EndTime.Time()
+	queried, err := session.queryKLinesBackward(context.Background(), "BTCUSDT", types.Interval1h, endTime, 1200)
+	if assert.NoError(t, err) {
+		assert.Equal(t, kLines[800:2000], queried)
+	}
+
+	// there are only 2000 klines before the end time
+	queried, err = session.queryKLinesBackward(context.Background(), "BTCUSDT", types.Interval1h, endTime, 3000)
+	if assert.NoError(t, err) {
+		assert.Equal(t, kLines[:2000], queried)
+	}
+}
@@ -0,0 +1,580 @@
+package bbgo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// SmartOrderAllocation is the quantity routed to one session
+type SmartOrderAllocation struct {
+	Session  string           `json:"session"`
+	Quantity fixedpoint.Value `json:"quantity"`
+
+	// Price is the worst price level that the allocation takes from the order book
+	Price fixedpoint.Value `json:"price"`
+
+	// Cost is the estimated quote quantity including the taker fee,
+	// it's the cost of a buy order and the proceeds of a sell order
+	Cost fixedpoint.Value `json:"cost"`
+
+	TakerFeeRate fixedpoint.Value `json:"takerFeeRate"`
+}
+
+// SmartOrderChild is the child order submitted to one session
+type SmartOrderChild struct {
+	Allocation SmartOrderAllocation `json:"allocation"`
+	Order      types.Order          `json:"order"`
+	Trades     []types.Trade        `json:"trades,omitempty"`
+}
+
+func (c *SmartOrderChild) executedQuantity() (quantity fixedpoint.Value) {
+	for _, t := range c.Trades {
+		quantity = quantity.Add(t.Quantity)
+	}
+	return quantity
+}
+
+func (c *SmartOrderChild) isDone() bool {
+	switch c.Order.Status {
+	case types.OrderStatusFilled, types.OrderStatusCanceled, types.OrderStatusRejected:
+		// the order update may arrive before the last trade
+		return c.executedQuantity().Compare(c.Order.ExecutedQuantity) >= 0
+	}
+	return false
+}
+
+// SmartOrderExecution is the parent execution of the child orders routed by the SmartOrderRouter
+type SmartOrderExecution struct {
+	Symbol   string           `json:"symbol"`
+	Side     types.SideType   `json:"side"`
+	Quantity fixedpoint.Value `json:"quantity"`
+
+	Children []*SmartOrderChild `json:"children"`
+
+	// submitted is true when all the child orders are submitted
+	submitted bool
+
+	mu sync.Mutex
+}
+
+// ExecutedQuantity returns the total quantity of the fills of the child orders
+func (e *SmartOrderExecution) ExecutedQuantity() (quantity fixedpoint.Value) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, c := range e.Children {
+		quantity = quantity.Add(c.executedQuantity())
+	}
+	return quantity
+}
+
+// QuoteQuantity returns the total quote quantity of the fills of the child orders
+func (e *SmartOrderExecution) QuoteQuantity() (quoteQuantity fixedpoint.Value) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, c := range e.Children {
+		for _, t := range c.Trades {
+			quoteQuantity = quoteQuantity.Add(t.QuoteQuantity)
+		}
+	}
+	return quoteQuantity
+}
+
+// AveragePrice returns the volume weighted average price of the fills
+func (e *SmartOrderExecution) AveragePrice() fixedpoint.Value {
+	quantity := e.ExecutedQuantity()
+	if quantity.IsZero() {
+		return fixedpoint.Zero
+	}
+
+	return e.QuoteQuantity().Div(quantity)
+}
+
+// Fees returns the fees of the fills by the fee currencies
+func (e *SmartOrderExecution) Fees() map[string]fixedpoint.Value {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var fees = make(map[string]fixedpoint.Value)
+	for _, c := range e.Children {
+		for _, t := range c.Trades {
+			fees[t.FeeCurrency] = fees[t.FeeCurrency].Add(t.Fee)
+		}
+	}
+	return fees
+}
+
+// Trades returns the fills of all the child orders
+func (e *SmartOrderExecution) Trades() (trades []types.Trade) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, c := range e.Children {
+		trades = append(trades, c.Trades...)
+	}
+	return trades
+}
+
+// IsDone returns true when all the child orders are closed and their fills are received
+func (e *SmartOrderExecution) IsDone() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.submitted {
+		return false
+	}
+
+	for _, c := range e.Children {
+		if !c.isDone() {
+			return false
+		}
+	}
+	return true
+}
+
+type smartOrderLevel struct {
+	name    string
+	market  types.Market
+	feeRate fixedpoint.Value
+
+	price fixedpoint.Value
+	// effectivePrice is the price including the taker fee
+	effectivePrice fixedpoint.Value
+	volume         fixedpoint.Value
+}
+
+type smartOrderKey struct {
+	session string
+	orderID uint64
+}
+
+// SmartOrderRouter splits an order across all the sessions that list the symbol.
+//
+// The quantity is allocated to the best price levels of the order books of the sessions,
+// ranked by the price including the taker fee of each session, and is capped by the available balance
+// of each session. The child orders are submitted as market orders through the OrderExecutionRouter,
+// and their fills are consolidated into one SmartOrderExecution.
+//
+// The order book of the symbol must be subscribed in the sessions, see ExchangeSession.OrderBook.
+//
+//go:generate callbackgen -type SmartOrderRouter
+type SmartOrderRouter struct {
+	// Depth is the number of the order book price levels used for the routing, 0 means all the levels
+	Depth int
+
+	sessions map[string]*ExchangeSession
+	router   OrderExecutionRouter
+
+	mu sync.Mutex
+
+	// children maps the session names and the order IDs of the child orders to the executions
+	children map[smartOrderKey]*SmartOrderExecution
+
+	// pendingUpdates and pendingTrades are the updates received while the child orders are being submitted,
+	// the updates may arrive before the created order is returned, e.g. in the back-test
+	submitting     int
+	pendingUpdates map[smartOrderKey]types.Order
+	pendingTrades  map[smartOrderKey][]types.Trade
+
+	updateCallbacks []func(execution *SmartOrderExecution)
+	doneCallbacks   []func(execution *SmartOrderExecution)
+}
+
+func NewSmartOrderRouter(sessions map[string]*ExchangeSession, router OrderExecutionRouter) *SmartOrderRouter {
+	return &SmartOrderRouter{
+		sessions:       sessions,
+		router:         router,
+		children:       make(map[smartOrderKey]*SmartOrderExecution),
+		pendingUpdates: make(map[smartOrderKey]types.Order),
+		pendingTrades:  make(map[smartOrderKey][]types.Trade),
+	}
+}
+
+// BindStream binds the order updates and the trade updates of the sessions
+func (r *SmartOrderRouter) BindStream() {
+	for name, session := range r.sessions {
+		name := name
+		session.UserDataStream.OnOrderUpdate(func(order types.Order) {
+			r.handleOrderUpdate(name, order)
+		})
+		session.UserDataStream.OnTradeUpdate(func(trade types.Trade) {
+			r.handleTradeUpdate(name, trade)
+		})
+	}
+}
+
+// Route allocates the quantity to the sessions without submitting the orders
+func (r *SmartOrderRouter) Route(symbol string, side types.SideType, quantity fixedpoint.Value) ([]SmartOrderAllocation, error) {
+	if quantity.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid quantity %v", quantity)
+	}
+
+	if side != types.SideTypeBuy && side != types.SideTypeSell {
+		return nil, fmt.Errorf("invalid side %s", side)
+	}
+
+	// the sessions that the allocation is below the minimal quantity are excluded,
+	// and the quantity is allocated again
+	var excluded = make(map[string]struct{})
+	for {
+		allocations, err := r.allocate(symbol, side, quantity, excluded)
+		if err != nil {
+			return nil, err
+		}
+
+		var dust bool
+		for _, a := range allocations {
+			market, _ := r.sessions[a.Session].Market(symbol)
+			if a.Quantity.Compare(market.MinQuantity) < 0 || a.Quantity.Mul(a.Price).Compare(market.MinNotional) < 0 {
+				excluded[a.Session] = struct{}{}
+				dust = true
+			}
+		}
+
+		if !dust {
+			return allocations, nil
+		}
+	}
+}
+
+func (r *SmartOrderRouter) allocate(symbol string, side types.SideType, quantity fixedpoint.Value, excluded map[string]struct{}) ([]SmartOrderAllocation, error) {
+	var levels []smartOrderLevel
+	var budgets = make(map[string]fixedpoint.Value)
+
+	// iterate the sessions in the name order, so that the levels of the same price are allocated in the same order
+	var sessionNames []string
+	for name := range r.sessions {
+		sessionNames = append(sessionNames, name)
+	}
+	sort.Strings(sessionNames)
+
+	for _, name := range sessionNames {
+		if _, ok := excluded[name]; ok {
+			continue
+		}
+
+		sessionLevels, budget, ok := r.sessionLevels(name, r.sessions[name], symbol, side)
+		if !ok {
+			continue
+		}
+
+		levels = append(levels, sessionLevels...)
+		budgets[name] = budget
+	}
+
+	// buy from the lowest cost, and sell to the highest proceeds
+	sort.SliceStable(levels, func(i, j int) bool {
+		if side == types.SideTypeBuy {
+			return levels[i].effectivePrice.Compare(levels[j].effectivePrice) < 0
+		}
+		return levels[i].effectivePrice.Compare(levels[j].effectivePrice) > 0
+	})
+
+	var allocations = make(map[string]*SmartOrderAllocation)
+	var names []string
+	var remaining = quantity
+	for _, level := range levels {
+		if remaining.Sign() <= 0 {
+			break
+		}
+
+		q := fixedpoint.Min(remaining, level.volume)
+
+		// the budget of a buy is the quote balance, the budget of a sell is the base balance
+		budget := budgets[level.name]
+		if side == types.SideTypeBuy {
+			q = fixedpoint.Min(q, budget.Div(level.effectivePrice))
+		} else {
+			q = fixedpoint.Min(q, budget)
+		}
+
+		if level.market.StepSize.Sign() > 0 {
+			q = level.market.TruncateQuantity(q)
+		}
+
+		if q.Sign() <= 0 {
+			continue
+		}
+
+		a, ok := allocations[level.name]
+		if !ok {
+			a = &SmartOrderAllocation{Session: level.name, TakerFeeRate: level.feeRate}
+			allocations[level.name] = a
+			names = append(names, level.name)
+		}
+
+		cost := q.Mul(level.effectivePrice)
+		a.Quantity = a.Quantity.Add(q)
+		a.Price = level.price
+		a.Cost = a.Cost.Add(cost)
+
+		if side == types.SideTypeBuy {
+			budgets[level.name] = budget.Sub(cost)
+		} else {
+			budgets[level.name] = budget.Sub(q)
+		}
+		remaining = remaining.Sub(q)
+	}
+
+	var result []SmartOrderAllocation
+	var total fixedpoint.Value
+	for _, name := range names {
+		a := *allocations[name]
+		total = total.Add(a.Quantity)
+		result = append(result, a)
+	}
+
+	if len(result) == 0 || total.Compare(quantity) < 0 {
+		return nil, fmt.Errorf("insufficient order book depth or balance to %s %v %s, %v can be routed", side, quantity, symbol, total)
+	}
+
+	return result, nil
+}
+
+// sessionLevels returns the price levels of the opposite side of the order book, and the available balance
+func (r *SmartOrderRouter) sessionLevels(name string, session *ExchangeSession, symbol string, side types.SideType) ([]smartOrderLevel, fixedpoint.Value, bool) {
+	market, ok := session.Market(symbol)
+	if !ok {
+		return nil, fixedpoint.Zero, false
+	}
+
+	book, ok := session.OrderBook(symbol)
+	if !ok {
+		return nil, fixedpoint.Zero, false
+	}
+
+	book.Lock()
+	if valid, err := book.OrderBook.IsValid(); !valid {
+		book.Unlock()
+		log.WithError(err).Warnf("%s %s order book is invalid, skip routing", name, symbol)
+		return nil, fixedpoint.Zero, false
+	}
+
+	// buy takes the asks, and sell takes the bids
+	var pvs types.PriceVolumeSlice
+	if side == types.SideTypeBuy {
+		pvs = book.OrderBook.SideBook(types.SideTypeSell)
+	} else {
+		pvs = book.OrderBook.SideBook(types.SideTypeBuy)
+	}
+
+	if r.Depth > 0 {
+		pvs = pvs.CopyDepth(r.Depth)
+	} else {
+		pvs = pvs.Copy()
+	}
+	book.Unlock()
+
+	feeRate := session.TakerFeeRate
+	account := session.GetAccount()
+	if feeRate.IsZero() && account != nil {
+		feeRate = account.TakerFeeRate
+	}
+
+	var budget fixedpoint.Value
+	if account != nil {
+		currency := market.BaseCurrency
+		if side == types.SideTypeBuy {
+			currency = market.QuoteCurrency
+		}
+
+		if balance, ok := account.Balance(currency); ok {
+			budget = balance.Available
+		}
+	}
+
+	if budget.Sign() <= 0 {
+		return nil, fixedpoint.Zero, false
+	}
+
+	var levels []smartOrderLevel
+	for _, pv := range pvs {
+		effectivePrice := pv.Price.Mul(fixedpoint.One.Add(feeRate))
+		if side == types.SideTypeSell {
+			effectivePrice = pv.Price.Mul(fixedpoint.One.Sub(feeRate))
+		}
+
+		levels = append(levels, smartOrderLevel{
+			name:           name,
+			market:         market,
+			feeRate:        feeRate,
+			price:          pv.Price,
+			effectivePrice: effectivePrice,
+			volume:         pv.Volume,
+		})
+	}
+
+	return levels, budget, true
+}
+
+// Submit routes the quantity and submits the child market orders to the sessions.
+// If some of the child orders fail, the execution of the submitted child orders is returned with the error.
+func (r *SmartOrderRouter) Submit(ctx context.Context, symbol string, side types.SideType, quantity fixedpoint.Value) (*SmartOrderExecution, error) {
+	allocations, err := r.Route(symbol, side, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	execution := &SmartOrderExecution{
+		Symbol:   symbol,
+		Side:     side,
+		Quantity: quantity,
+	}
+
+	r.mu.Lock()
+	r.submitting++
+	r.mu.Unlock()
+
+	var submitErr error
+	for _, a := range allocations {
+		createdOrders, err := r.router.SubmitOrdersTo(ctx, a.Session, types.SubmitOrder{
+			Symbol:   symbol,
+			Side:     side,
+			Type:     types.OrderTypeMarket,
+			Quantity: a.Quantity,
+		})
+		if err == nil && len(createdOrders) == 0 {
+			err = fmt.Errorf("no order is created")
+		}
+
+		if err != nil {
+			log.WithError(err).Errorf("%s %s child order submit error", a.Session, symbol)
+			if submitErr == nil {
+				submitErr = errors.Wrapf(err, "%s child order submit error", a.Session)
+			}
+			continue
+		}
+
+		child := &SmartOrderChild{Allocation: a, Order: createdOrders[0]}
+		key := smartOrderKey{session: a.Session, orderID: child.Order.OrderID}
+
+		r.mu.Lock()
+		if update, ok := r.pendingUpdates[key]; ok {
+			child.Order = update
+		}
+		child.Trades = r.pendingTrades[key]
+		delete(r.pendingUpdates, key)
+		delete(r.pendingTrades, key)
+
+		execution.mu.Lock()
+		execution.Children = append(execution.Children, child)
+		execution.mu.Unlock()
+
+		r.children[key] = execution
+		r.mu.Unlock()
+	}
+
+	execution.mu.Lock()
+	execution.submitted = true
+	execution.mu.Unlock()
+
+	r.mu.Lock()
+	r.submitting--
+	if r.submitting == 0 {
+		r.pendingUpdates = make(map[smartOrderKey]types.Order)
+		r.pendingTrades = make(map[smartOrderKey][]types.Trade)
+	}
+	r.mu.Unlock()
+
+	if len(execution.Children) == 0 {
+		return nil, submitErr
+	}
+
+	r.update(execution)
+	return execution, submitErr
+}
+
+func (r *SmartOrderRouter) handleOrderUpdate(session string, order types.Order) {
+	key := smartOrderKey{session: session, orderID: order.OrderID}
+
+	r.mu.Lock()
+	execution, ok := r.children[key]
+	if !ok {
+		if r.submitting > 0 {
+			r.pendingUpdates[key] = order
+		}
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+
+	execution.mu.Lock()
+	for _, c := range execution.Children {
+		if c.Allocation.Session == session && c.Order.OrderID == order.OrderID {
+			c.Order = order
+		}
+	}
+	execution.mu.Unlock()
+
+	r.update(execution)
+}
+
+func (r *SmartOrderRouter) handleTradeUpdate(session string, trade types.Trade) {
+	key := smartOrderKey{session: session, orderID: trade.OrderID}
+
+	r.mu.Lock()
+	execution, ok := r.children[key]
+	if !ok {
+		if r.submitting > 0 {
+			r.pendingTrades[key] = append(r.pendingTrades[key], trade)
+		}
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+
+	execution.mu.Lock()
+	for _, c := range execution.Children {
+		if c.Allocation.Session != session || c.Order.OrderID != trade.OrderID {
+			continue
+		}
+
+		if !hasTrade(c.Trades, trade.ID) {
+			c.Trades = append(c.Trades, trade)
+		}
+	}
+	execution.mu.Unlock()
+
+	r.update(execution)
+}
+
+func hasTrade(trades []types.Trade, id uint64) bool {
+	for _, t := range trades {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// update emits the execution update, and removes the done execution
+func (r *SmartOrderRouter) update(execution *SmartOrderExecution) {
+	r.EmitUpdate(execution)
+
+	if !execution.IsDone() {
+		return
+	}
+
+	r.mu.Lock()
+	var removed bool
+	for key, e := range r.children {
+		if e == execution {
+			delete(r.children, key)
+			removed = true
+		}
+	}
+	r.mu.Unlock()
+
+	if removed {
+		r.EmitDone(execution)
+	}
+}
@@ -0,0 +1,171 @@
+package bbgo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// testRouterExchange fills the market orders at the given price, and emits the trade before
+// the created order is returned, like the back-test exchange
+type testRouterExchange struct {
+	types.Exchange
+
+	name        types.ExchangeName
+	stream      *types.StandardStream
+	price       fixedpoint.Value
+	lastOrderID uint64
+	submitted   []types.SubmitOrder
+}
+
+func (e *testRouterExchange) SubmitOrders(ctx context.Context, orders ...types.SubmitOrder) (createdOrders types.OrderSlice, err error) {
+	for _, o := range orders {
+		e.lastOrderID++
+		e.submitted = append(e.submitted, o)
+
+		order := types.Order{SubmitOrder: o, Exchange: e.name, OrderID: e.lastOrderID, Status: types.OrderStatusNew}
+		createdOrders = append(createdOrders, order)
+
+		e.stream.EmitTradeUpdate(types.Trade{
+			ID:            e.lastOrderID,
+			OrderID:       order.OrderID,
+			Exchange:      e.name,
+			Symbol:        o.Symbol,
+			Side:          o.Side,
+			Price:         e.price,
+			Quantity:      o.Quantity,
+			QuoteQuantity: e.price.Mul(o.Quantity),
+			Fee:           fixedpoint.NewFromFloat(0.1),
+			FeeCurrency:   "USDT",
+		})
+
+		order.Status = types.OrderStatusFilled
+		order.ExecutedQuantity = o.Quantity
+		e.stream.EmitOrderUpdate(order)
+	}
+
+	return createdOrders, nil
+}
+
+func newTestRouterSession(name string, feeRate float64, asks types.PriceVolumeSlice, balances types.BalanceMap) (*ExchangeSession, *testRouterExchange) {
+	stream := types.NewStandardStream()
+	exchange := &testRouterExchange{name: types.ExchangeName(name), stream: &stream, price: asks[0].Price}
+
+	book := types.NewStreamBook("BTCUSDT")
+	book.Load(types.SliceOrderBook{
+		Symbol: "BTCUSDT",
+		Bids:   types.PriceVolumeSlice{{Price: asks[0].Price.Sub(fixedpoint.One), Volume: fixedpoint.NewFromInt(10)}},
+		Asks:   asks,
+	})
+
+	account := types.NewAccount()
+	account.UpdateBalances(balances)
+
+	session := &ExchangeSession{
+		TakerFeeRate:   fixedpoint.NewFromFloat(feeRate),
+		Exchange:       exchange,
+		UserDataStream: &stream,
+		Account:        account,
+		markets: map[string]types.Market{
+			"BTCUSDT": {Symbol: "BTCUSDT", BaseCurrency: "BTC", QuoteCurrency: "USDT", TickSize: fixedpoint.NewFromFloat(0.01), StepSize: fixedpoint.NewFromFloat(0.01), MinQuantity: fixedpoint.NewFromFloat(0.01)},
+		},
+		orderBooks: map[string]*types.StreamOrderBook{"BTCUSDT": book},
+	}
+	return session, exchange
+}
+
+func newTestSmartOrderRouter(quoteB float64) (*SmartOrderRouter, *testRouterExchange, *testRouterExchange) {
+	sessionA, exchangeA := newTestRouterSession("a", 0.001, types.PriceVolumeSlice{
+		{Price: fixedpoint.NewFromInt(100), Volume: fixedpoint.NewFromInt(1)},
+		{Price: fixedpoint.NewFromInt(101), Volume: fixedpoint.NewFromInt(2)},
+	}, types.BalanceMap{
+		"USDT": {Currency: "USDT", Available: fixedpoint.NewFromInt(1000)},
+	})
+
+	sessionB, exchangeB := newTestRouterSession("b", 0, types.PriceVolumeSlice{
+		{Price: fixedpoint.NewFromFloat(100.05), Volume: fixedpoint.NewFromInt(1)},
+		{Price: fixedpoint.NewFromFloat(100.5), Volume: fixedpoint.NewFromInt(1)},
+	}, types.BalanceMap{
+		"USDT": {Currency: "USDT", Available: fixedpoint.NewFromFloat(quoteB)},
+	})
+
+	sessions := map[string]*ExchangeSession{"a": sessionA, "b": sessionB}
+	router := NewSmartOrderRouter(sessions, &ExchangeOrderExecutionRouter{sessions: sessions})
+	router.BindStream()
+	return router, exchangeA, exchangeB
+}
+
+func TestSmartOrderRouter_Route(t *testing.T) {
+	t.Run("taker fee", func(t *testing.T) {
+		router, _, _ := newTestSmartOrderRouter(1000)
+
+		// b 100.05 < a 100 * 1.001 < b 100.5 < a 101 * 1.001
+		allocations, err := router.Route("BTCUSDT", types.SideTypeBuy, fixedpoint.NewFromFloat(2.5))
+		if assert.NoError(t, err) && assert.Len(t, allocations, 2) {
+			assert.Equal(t, "b", allocations[0].Session)
+			assert.Equal(t, "1.5", allocations[0].Quantity.String())
+			assert.Equal(t, "100.5", allocations[0].Price.String())
+			assert.Equal(t, "a", allocations[1].Session)
+			assert.Equal(t, "1", allocations[1].Quantity.String())
+			assert.Equal(t, "100.1", allocations[1].Cost.String())
+		}
+	})
+
+	t.Run("balance", func(t *testing.T) {
+		router, _, _ := newTestSmartOrderRouter(150)
+
+		// b can only buy 1 @ 100.05 and 0.49 @ 100.5 with 150 USDT
+		allocations, err := router.Route("BTCUSDT", types.SideTypeBuy, fixedpoint.NewFromFloat(2.5))
+		if assert.NoError(t, err) && assert.Len(t, allocations, 2) {
+			assert.Equal(t, "b", allocations[0].Session)
+			assert.Equal(t, "1.49", allocations[0].Quantity.String())
+			assert.Equal(t, "a", allocations[1].Session)
+			assert.Equal(t, "1.01", allocations[1].Quantity.String())
+			assert.Equal(t, "101", allocations[1].Price.String())
+		}
+	})
+
+	t.Run("insufficient depth", func(t *testing.T) {
+		router, _, _ := newTestSmartOrderRouter(1000)
+		_, err := router.Route("BTCUSDT", types.SideTypeBuy, fixedpoint.NewFromInt(10))
+		assert.Error(t, err)
+
+		// no BTC balance to sell
+		_, err = router.Route("BTCUSDT", types.SideTypeSell, fixedpoint.NewFromInt(1))
+		assert.Error(t, err)
+	})
+}
+
+func TestSmartOrderRouter_Submit(t *testing.T) {
+	router, exchangeA, exchangeB := newTestSmartOrderRouter(1000)
+
+	var done []*SmartOrderExecution
+	router.OnDone(func(execution *SmartOrderExecution) {
+		done = append(done, execution)
+	})
+
+	execution, err := router.Submit(context.Background(), "BTCUSDT", types.SideTypeBuy, fixedpoint.NewFromInt(2))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.Len(t, exchangeB.submitted, 1) {
+		assert.Equal(t, types.OrderTypeMarket, exchangeB.submitted[0].Type)
+		assert.Equal(t, "1", exchangeB.submitted[0].Quantity.String())
+	}
+	if assert.Len(t, exchangeA.submitted, 1) {
+		assert.Equal(t, "1", exchangeA.submitted[0].Quantity.String())
+	}
+
+	// the fills arrived before the child orders were returned
+	assert.True(t, execution.IsDone())
+	assert.Len(t, done, 1)
+	assert.Len(t, execution.Trades(), 2)
+	assert.Equal(t, "2", execution.ExecutedQuantity().String())
+	assert.Equal(t, "100.025", execution.AveragePrice().String())
+	assert.Equal(t, "0.2", execution.Fees()["USDT"].String())
+}
@@ -2,6 +2,7 @@ package bbgo
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -20,6 +21,8 @@ type TradeCollector struct {
 	tradeC     chan types.Trade
 	position   *types.Position
 	orderStore *OrderStore
+
+	mu         sync.Mutex
 	doneTrades map[types.TradeKey]struct{}
 
 	recoverCallbacks        []func(trade types.Trade)
@@ -94,21 +97,44 @@ func (c *TradeCollector) Recover(ctx context.Context, ex types.ExchangeTradeHist
 	return nil
 }
 
+// Processed returns true if the trade is already added to the position
+func (c *TradeCollector) Processed(trade types.Trade) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, done := c.doneTrades[trade.Key()]
+	return done
+}
+
+// markDone marks the trade as processed, returns false if the trade is already processed
+func (c *TradeCollector) markDone(key types.TradeKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, done := c.doneTrades[key]; done {
+		return false
+	}
+
+	c.doneTrades[key] = struct{}{}
+	return true
+}
+
 // Process filters the received trades and see if there are orders matching the trades
 // if we have the order in the order store, then the trade will be considered for the position.
 // profit will also be calculated.
 func (c *TradeCollector) Process() bool {
 	positionChanged := false
 	c.tradeStore.Filter(func(trade types.Trade) bool {
-		key := trade.Key()
-
 		// if it's already done, remove the trade from the trade store
-		if _, done := c.doneTrades[key]; done {
+		if c.Processed(trade) {
 			return true
 		}
 
 		if c.orderStore.Exists(trade.OrderID) {
-			c.doneTrades[key] = struct{}{}
+			if !c.markDone(trade.Key()) {
+				return true
+			}
+
 			if profit, netProfit, madeProfit := c.position.AddTrade(trade); madeProfit {
 				c.EmitTrade(trade, profit, netProfit)
 				c.EmitProfit(trade, profit, netProfit)
@@ -133,10 +159,8 @@ func (c *TradeCollector) Process() bool {
 // return false when the given trade is not added
 func (c *TradeCollector) processTrade(trade types.Trade) bool {
 	if c.orderStore.Exists(trade.OrderID) {
-		key := trade.Key()
-
 		// if it's already done, remove the trade from the trade store
-		if _, done := c.doneTrades[key]; done {
+		if !c.markDone(trade.Key()) {
 			return false
 		}
 
@@ -147,7 +171,6 @@ func (c *TradeCollector) processTrade(trade types.Trade) bool {
 			c.EmitTrade(trade, fixedpoint.Zero, fixedpoint.Zero)
 		}
 		c.EmitPositionUpdate(c.position)
-		c.doneTrades[key] = struct{}{}
 		return true
 	}
 	return false
@@ -156,9 +179,8 @@ func (c *TradeCollector) processTrade(trade types.Trade) bool {
 // return true when the given trade is added
 // return false when the given trade is not added
 func (c *TradeCollector) ProcessTrade(trade types.Trade) bool {
-	key := trade.Key()
 	// if it's already done, remove the trade from the trade store
-	if _, done := c.doneTrades[key]; done {
+	if c.Processed(trade) {
 		return false
 	}
 
@@ -68,6 +68,8 @@ type Trader struct {
 
 	riskControls *RiskControls
 
+	reconciliation *ReconciliationService
+
 	crossExchangeStrategies []CrossExchangeStrategy
 	exchangeStrategies      map[string][]SingleExchangeStrategy
 
@@ -303,6 +305,10 @@ func (trader *Trader) Run(ctx context.Context) error {
 		return err
 	}
 
+	if trader.riskControls != nil && trader.riskControls.Reconciliation != nil {
+		trader.setupReconciliation(trader.riskControls.Reconciliation)
+	}
+
 	if err := trader.RunAllSingleExchangeStrategy(ctx); err != nil {
 		return err
 	}
@@ -335,6 +341,14 @@ func (trader *Trader) Run(ctx context.Context) error {
 		}
 	}
 
+	if trader.riskControls != nil && trader.riskControls.Portfolio != nil {
+		trader.runPortfolioRiskEngine(ctx, trader.riskControls.Portfolio)
+	}
+
+	if trader.reconciliation != nil {
+		go trader.reconciliation.Run(ctx)
+	}
+
 	return trader.environment.Connect(ctx)
 }
 
@@ -442,6 +456,7 @@ func (trader *Trader) injectCommonServices(s interface{}) error {
 		trader.environment,
 		persistence,
 		persistenceFacade, // if the strategy use persistence facade separately
+		trader.reconciliation,
 	)
 }
 
@@ -449,3 +464,47 @@ func (trader *Trader) injectCommonServices(s interface{}) error {
 func (trader *Trader) ReportPnL() *PnLReporterManager {
 	return NewPnLReporter(&trader.environment.Notifiability)
 }
+
+// runPortfolioRiskEngine starts the portfolio risk engine that controls all the strategies,
+// it must be called before the user data streams are connected.
+func (trader *Trader) runPortfolioRiskEngine(ctx context.Context, control *PortfolioRiskControl) {
+	engine := NewPortfolioRiskEngine(control, trader.environment.sessions, &trader.environment.Notifiability)
+	for sessionName, strategies := range trader.exchangeStrategies {
+		for _, strategy := range strategies {
+			engine.AddStrategy(sessionName, strategy)
+		}
+	}
+
+	for _, strategy := range trader.crossExchangeStrategies {
+		engine.AddStrategy("", strategy)
+	}
+
+	engine.SeedPositions()
+	engine.BindStream()
+	go engine.Run(ctx)
+}
+
+// setupReconciliation creates the reconciliation service, and adds it to the risk check chain of every session,
+// so that the orders of the drifted positions are rejected. The strategies register their trade collectors
+// to the injected *ReconciliationService.
+func (trader *Trader) setupReconciliation(config *ReconciliationConfig) {
+	trader.reconciliation = NewReconciliationService(config, &trader.environment.Notifiability)
+
+	if trader.riskControls.SessionBasedRiskControl == nil {
+		trader.riskControls.SessionBasedRiskControl = make(map[string]*SessionBasedRiskControl)
+	}
+
+	for sessionName := range trader.environment.sessions {
+		control, ok := trader.riskControls.SessionBasedRiskControl[sessionName]
+		if !ok || control == nil {
+			control = &SessionBasedRiskControl{}
+			trader.riskControls.SessionBasedRiskControl[sessionName] = control
+		}
+
+		if control.OrderExecutor == nil {
+			control.OrderExecutor = &RiskControlOrderExecutor{}
+		}
+
+		control.OrderExecutor.Checks = append(RiskCheckList{trader.reconciliation}, control.OrderExecutor.Checks...)
+	}
+}
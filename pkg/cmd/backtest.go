@@ -22,6 +22,7 @@ import (
 	"github.com/c9s/bbgo/pkg/bbgo"
 	"github.com/c9s/bbgo/pkg/cmd/cmdutil"
 	"github.com/c9s/bbgo/pkg/data/tsv"
+	"github.com/c9s/bbgo/pkg/fixedpoint"
 	"github.com/c9s/bbgo/pkg/service"
 	"github.com/c9s/bbgo/pkg/types"
 	"github.com/c9s/bbgo/pkg/util"
@@ -253,7 +254,11 @@ var BacktestCmd = &cobra.Command{
 			if err != nil {
 				return errors.Wrap(err, "failed to create backtest exchange")
 			}
-			environ.AddExchange(name.String(), backtestExchange)
+			session := environ.AddExchange(name.String(), backtestExchange)
+
+			// the simulated margin and futures accounts are enabled by the backtest account config
+			session.Margin = backtestExchange.GetMarginSettings().IsMargin
+			session.Futures = backtestExchange.GetFuturesSettings().IsFutures
 		}
 
 		if err := environ.Init(ctx); err != nil {
@@ -281,9 +286,32 @@ var BacktestCmd = &cobra.Command{
 
 		var kLineHandlers []func(k types.KLine, exSource *backtest.ExchangeDataSource)
 		var manifests backtest.Manifests
+		var equityCurveDumper *backtest.EquityCurveDumper
 		var runID = userConfig.GetSignature() + "_" + uuid.NewString()
 		var reportDir = outputDirectory
 
+		// equity curves for the performance metrics -- record per 1h kline
+		equityRecorder := backtest.NewEquityRecorder()
+		kLineHandlers = append(kLineHandlers, func(k types.KLine, exSource *backtest.ExchangeDataSource) {
+			if k.Interval != types.Interval1h {
+				return
+			}
+
+			market, ok := exSource.Session.Market(k.Symbol)
+			if !ok {
+				return
+			}
+
+			account, err := exSource.Exchange.QueryAccount(ctx)
+			if err != nil {
+				log.WithError(err).Errorf("query back-test account error")
+				return
+			}
+
+			equityRecorder.RecordSymbol(exSource.Session.Name, market, account.Balances(), futuresPositions(account), k.Close, k.EndTime.Time())
+			equityRecorder.Record(exSource.Session.Name, k.EndTime.Time(), exSource.Exchange.Equity())
+		})
+
 		if generatingReport {
 			if reportFileInSubDir {
 				// reportDir = filepath.Join(reportDir, backtestSessionName)
@@ -335,6 +363,40 @@ var BacktestCmd = &cobra.Command{
 				}
 			})
 
+			// equity, drawdown and buy-and-hold baseline recording -- record per 1m kline
+			equityCurveDataDir := filepath.Join(reportDir, "equity_curves")
+			if err := util.SafeMkdirAll(equityCurveDataDir); err != nil {
+				return err
+			}
+
+			equityCurveDumper = backtest.NewEquityCurveDumper(equityCurveDataDir)
+			defer func() {
+				if err := equityCurveDumper.Close(); err != nil {
+					log.WithError(err).Errorf("equity curve dumper can not close files")
+				}
+			}()
+
+			kLineHandlers = append(kLineHandlers, func(k types.KLine, exSource *backtest.ExchangeDataSource) {
+				if k.Interval != types.Interval1m || !k.Closed {
+					return
+				}
+
+				market, ok := exSource.Session.Market(k.Symbol)
+				if !ok {
+					return
+				}
+
+				account, err := exSource.Exchange.QueryAccount(ctx)
+				if err != nil {
+					log.WithError(err).Errorf("query back-test account error")
+					return
+				}
+
+				if err := equityCurveDumper.Record(exSource.Session.Name, market, account.Balances(), futuresPositions(account), k); err != nil {
+					log.WithError(err).Errorf("can not write equity curve to file")
+				}
+			})
+
 			// equity curve recording -- record per 1h kline
 			equityCurveTsv, err := tsv.NewWriterFile(filepath.Join(reportDir, "equity_curve.tsv"))
 			if err != nil {
@@ -477,7 +539,27 @@ var BacktestCmd = &cobra.Command{
 			summaryReport.Intervals = append(summaryReport.Intervals, interval)
 		}
 
+		metricsCalculator := &backtest.MetricsCalculator{StartTime: startTime, EndTime: endTime}
+		var sessionEquityCurves [][]backtest.EquityPoint
 		for _, session := range environ.Sessions() {
+			backtestExchange, ok := session.Exchange.(*backtest.Exchange)
+			if !ok {
+				return fmt.Errorf("unexpected error, exchange instance is not a backtest exchange")
+			}
+
+			// like the symbol equity curves, the session equity curve starts from the initial balances valued by the start prices
+			startPrices := make(map[string]fixedpoint.Value)
+			for _, symbol := range userConfig.Backtest.Symbols {
+				if price, ok := session.StartPrice(symbol); ok {
+					startPrices[symbol] = price
+				}
+			}
+
+			initBalances := userConfig.Backtest.GetAccount(session.Exchange.Name().String()).Balances.BalanceMap()
+			sessionEquityCurves = append(sessionEquityCurves, append([]backtest.EquityPoint{{
+				Time:   startTime,
+				Equity: backtestExchange.InitialEquity(initBalances, startPrices),
+			}}, equityRecorder.Curve(session.Name)...))
 
 			for symbol, trades := range session.Trades {
 				symbolReport, err := createSymbolReport(userConfig, session, symbol, trades.Trades)
@@ -485,6 +567,16 @@ var BacktestCmd = &cobra.Command{
 					return err
 				}
 
+				equityCurve := append([]backtest.EquityPoint{{
+					Time:   startTime,
+					Equity: inQuoteAsset(symbolReport.InitialBalances, symbolReport.Market, symbolReport.StartPrice),
+				}}, equityRecorder.Curve(backtest.SymbolKey(session.Name, symbol))...)
+
+				symbolMetricsCalculator := &backtest.MetricsCalculator{StartTime: startTime, EndTime: endTime}
+				symbolMetricsCalculator.AddTrades(symbolReport.Market, trades.Trades)
+				symbolReport.Metrics = symbolMetricsCalculator.Calculate(equityCurve)
+				metricsCalculator.AddTrades(symbolReport.Market, trades.Trades)
+
 				summaryReport.Symbols = append(summaryReport.Symbols, symbol)
 				summaryReport.SymbolReports = append(summaryReport.SymbolReports, *symbolReport)
 				summaryReport.TotalProfit = symbolReport.PnL.Profit
@@ -500,7 +592,14 @@ var BacktestCmd = &cobra.Command{
 			}
 		}
 
+		summaryReport.Metrics = metricsCalculator.Calculate(backtest.MergeEquityCurves(sessionEquityCurves...))
+
 		if generatingReport {
+			summaryReport.EquityCurves, err = rewriteEquityCurvePaths(equityCurveDumper.Entries(), reportDir)
+			if err != nil {
+				return err
+			}
+
 			summaryReportFile := filepath.Join(reportDir, "summary.json")
 
 			// output summary report filepath to stdout, so that our optimizer can read from it
@@ -513,9 +612,10 @@ var BacktestCmd = &cobra.Command{
 			// append report index
 			if reportFileInSubDir {
 				if err := backtest.AddReportIndexRun(outputDirectory, backtest.Run{
-					ID:     runID,
-					Config: userConfig,
-					Time:   time.Now(),
+					ID:           runID,
+					Config:       userConfig,
+					Time:         time.Now(),
+					EquityCurves: summaryReport.EquityCurves,
 				}); err != nil {
 					return err
 				}
@@ -567,15 +667,21 @@ func createSymbolReport(userConfig *bbgo.Config, session *bbgo.ExchangeSession,
 	accountConfig := userConfig.Backtest.GetAccount(session.Exchange.Name().String())
 	initBalances := accountConfig.Balances.BalanceMap()
 	finalBalances := session.GetAccount().Balances()
+	finalAccount, err := backtestExchange.QueryAccount(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
 	symbolReport := backtest.SessionSymbolReport{
-		Exchange:        session.Exchange.Name(),
-		Symbol:          symbol,
-		Market:          market,
-		LastPrice:       lastPrice,
-		StartPrice:      startPrice,
-		PnL:             report,
-		InitialBalances: initBalances,
-		FinalBalances:   finalBalances,
+		Exchange:         session.Exchange.Name(),
+		Symbol:           symbol,
+		Market:           market,
+		LastPrice:        lastPrice,
+		StartPrice:       startPrice,
+		PnL:              report,
+		InitialBalances:  initBalances,
+		FinalBalances:    finalBalances,
+		FuturesPositions: futuresPositions(finalAccount),
 		// Manifests:       manifests,
 	}
 
@@ -683,6 +789,29 @@ func sync(ctx context.Context, userConfig *bbgo.Config, backtestService *service
 					}
 				}
 			}
+
+			// ticks are only needed in the back-test time range
+			if userConfig.Backtest.SyncMarketTrades {
+				endTime := time.Now()
+				if userConfig.Backtest.EndTime != nil {
+					endTime = userConfig.Backtest.EndTime.Time()
+				}
+
+				if err := backtestService.SyncMarketTrades(ctx, sourceExchange, symbol, userConfig.Backtest.StartTime.Time(), endTime); err != nil {
+					return err
+				}
+			}
+
+			if userConfig.Backtest.SyncFundingRates {
+				endTime := time.Now()
+				if userConfig.Backtest.EndTime != nil {
+					endTime = userConfig.Backtest.EndTime.Time()
+				}
+
+				if err := backtestService.SyncFundingRates(ctx, sourceExchange, symbol, userConfig.Backtest.StartTime.Time(), endTime); err != nil {
+					return err
+				}
+			}
 		}
 	}
 	return nil
@@ -699,3 +828,17 @@ func rewriteManifestPaths(manifests backtest.Manifests, basePath string) (backte
 	}
 	return filterManifests, nil
 }
+
+func rewriteEquityCurvePaths(entries []backtest.EquityCurveEntry, basePath string) ([]backtest.EquityCurveEntry, error) {
+	var rewritten []backtest.EquityCurveEntry
+	for _, entry := range entries {
+		p, err := filepath.Rel(basePath, entry.Filename)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Filename = p
+		rewritten = append(rewritten, entry)
+	}
+	return rewritten, nil
+}
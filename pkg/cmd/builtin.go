@@ -7,6 +7,7 @@ import (
 	_ "github.com/c9s/bbgo/pkg/strategy/bollmaker"
 	_ "github.com/c9s/bbgo/pkg/strategy/emastop"
 	_ "github.com/c9s/bbgo/pkg/strategy/etf"
+	_ "github.com/c9s/bbgo/pkg/strategy/exprtrader"
 	_ "github.com/c9s/bbgo/pkg/strategy/ewoDgtrd"
 	_ "github.com/c9s/bbgo/pkg/strategy/factorzoo"
 	_ "github.com/c9s/bbgo/pkg/strategy/flashcrash"
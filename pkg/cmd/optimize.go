@@ -6,17 +6,27 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
 	"github.com/c9s/bbgo/pkg/optimizer"
+	"github.com/c9s/bbgo/pkg/types"
 )
 
 func init() {
 	optimizeCmd.Flags().String("optimizer-config", "optimizer.yaml", "config file")
 	optimizeCmd.Flags().String("output", "output", "backtest report output directory")
 	optimizeCmd.Flags().Bool("json", false, "print optimizer metrics in json format")
+	optimizeCmd.Flags().String("algorithm", "", "search algorithm: grid, random, lhs or tpe (overrides the optimizer config)")
+	optimizeCmd.Flags().Int("max-trials", 0, "trial budget of the random, lhs and tpe algorithms (overrides the optimizer config)")
+	optimizeCmd.Flags().String("objective", "", "the metric to maximize, e.g. totalProfit, sharpeRatio (overrides the optimizer config)")
+	optimizeCmd.Flags().Int("top", 0, "print the top N trials only, 0 means all trials")
+	optimizeCmd.Flags().Int("workers", 0, "number of the back-test processes that run concurrently (overrides the optimizer config)")
+	optimizeCmd.Flags().Duration("timeout", 0, "timeout of one trial, e.g. 30m (overrides the optimizer config)")
+	optimizeCmd.Flags().String("cache-dir", "", "directory of the cached trial results (overrides the optimizer config)")
+	optimizeCmd.Flags().Bool("walk-forward", false, "optimize on the rolling in-sample windows and evaluate on the out-of-sample windows")
 	RootCmd.AddCommand(optimizeCmd)
 }
 
@@ -48,6 +58,46 @@ var optimizeCmd = &cobra.Command{
 			return err
 		}
 
+		algorithm, err := cmd.Flags().GetString("algorithm")
+		if err != nil {
+			return err
+		}
+
+		maxTrials, err := cmd.Flags().GetInt("max-trials")
+		if err != nil {
+			return err
+		}
+
+		objective, err := cmd.Flags().GetString("objective")
+		if err != nil {
+			return err
+		}
+
+		top, err := cmd.Flags().GetInt("top")
+		if err != nil {
+			return err
+		}
+
+		workers, err := cmd.Flags().GetInt("workers")
+		if err != nil {
+			return err
+		}
+
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return err
+		}
+
+		cacheDir, err := cmd.Flags().GetString("cache-dir")
+		if err != nil {
+			return err
+		}
+
+		walkForward, err := cmd.Flags().GetBool("walk-forward")
+		if err != nil {
+			return err
+		}
+
 		yamlBody, err := ioutil.ReadFile(configFile)
 		if err != nil {
 			return err
@@ -64,6 +114,30 @@ var optimizeCmd = &cobra.Command{
 			return err
 		}
 
+		if maxTrials > 0 {
+			optConfig.MaxTrials = maxTrials
+		}
+
+		if objective != "" {
+			optConfig.Objective = objective
+		}
+
+		if optConfig.Executor == nil {
+			optConfig.Executor = &optimizer.ExecutorConfig{}
+		}
+
+		if workers > 0 {
+			optConfig.Executor.MaxNumOfProcesses = workers
+		}
+
+		if timeout > 0 {
+			optConfig.Executor.Timeout = types.Duration(timeout)
+		}
+
+		if cacheDir != "" {
+			optConfig.Executor.CacheDir = cacheDir
+		}
+
 		// the config json template used for patch
 		configJson, err := json.MarshalIndent(obj, "", "  ")
 		if err != nil {
@@ -79,15 +153,50 @@ var optimizeCmd = &cobra.Command{
 			return err
 		}
 
-		executor := &optimizer.LocalProcessExecutor{
+		var executor optimizer.Executor = &optimizer.LocalProcessExecutor{
 			Bin:       os.Args[0],
 			WorkDir:   ".",
 			ConfigDir: configDir,
 			OutputDir: outputDirectory,
+			Timeout:   optConfig.Executor.Timeout.Duration(),
 		}
 
-		optz := &optimizer.GridOptimizer{
-			Config: optConfig,
+		if optConfig.Executor.CacheDir != "" {
+			executor, err = optimizer.NewCachedExecutor(executor, optConfig.Executor.CacheDir)
+			if err != nil {
+				return err
+			}
+		}
+
+		optz, err := optimizer.New(algorithm, optConfig)
+		if err != nil {
+			return err
+		}
+
+		if walkForward {
+			wfo := &optimizer.WalkForwardOptimizer{
+				Config:    optConfig,
+				Optimizer: optz,
+			}
+
+			report, err := wfo.Run(executor, configJson)
+			if err != nil {
+				return err
+			}
+
+			if printJsonFormat {
+				out, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return err
+				}
+
+				// print walk-forward report JSON to stdout
+				fmt.Println(string(out))
+				return nil
+			}
+
+			printWalkForwardReport(report)
+			return nil
 		}
 
 		metrics, err := optz.Run(executor, configJson)
@@ -95,6 +204,10 @@ var optimizeCmd = &cobra.Command{
 			return err
 		}
 
+		if top > 0 && len(metrics) > top {
+			metrics = metrics[:top]
+		}
+
 		if printJsonFormat {
 			out, err := json.MarshalIndent(metrics, "", "  ")
 			if err != nil {
@@ -108,11 +221,34 @@ var optimizeCmd = &cobra.Command{
 				fmt.Printf("%v\n", metrics[0].Labels)
 			}
 
-			for _, m := range metrics {
-				fmt.Printf("%v => %v\n", m.Params, m.Value)
+			for i, m := range metrics {
+				fmt.Printf("#%d %v => %v\n", i+1, m.Params, m.Value)
 			}
 		}
 
 		return nil
 	},
 }
+
+func printWalkForwardReport(report *optimizer.WalkForwardReport) {
+	if len(report.Folds) > 0 {
+		fmt.Printf("%v\n", report.Folds[0].Labels)
+	}
+
+	for i, fold := range report.Folds {
+		fmt.Printf("fold #%d in-sample %s ~ %s, out-of-sample %s ~ %s\n", i+1,
+			fold.InSample.StartTime.Format(time.RFC3339), fold.InSample.EndTime.Format(time.RFC3339),
+			fold.OutOfSample.StartTime.Format(time.RFC3339), fold.OutOfSample.EndTime.Format(time.RFC3339))
+		fmt.Printf("  %v => in-sample %v, out-of-sample %v\n", fold.Params, fold.InSampleValue, fold.OutOfSampleValue)
+	}
+
+	if len(report.StableParams) == 0 {
+		fmt.Println("no stable parameters across the folds")
+		return
+	}
+
+	fmt.Println("stable parameters:")
+	for _, s := range report.StableParams {
+		fmt.Printf("  %v => %d folds, average in-sample %v\n", s.Params, s.NumOfFolds, s.AverageInSampleValue)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
+	"github.com/c9s/bbgo/pkg/backtest"
 	"github.com/c9s/bbgo/pkg/bbgo"
 	"github.com/c9s/bbgo/pkg/cmd/cmdutil"
 	"github.com/c9s/bbgo/pkg/types"
@@ -44,6 +45,11 @@ var orderbookCmd = &cobra.Command{
 			return err
 		}
 
+		recordDirectory, err := cmd.Flags().GetString("record")
+		if err != nil {
+			return err
+		}
+
 		environ := bbgo.NewEnvironment()
 		if err := environ.ConfigureExchangeSessions(userConfig); err != nil {
 			return err
@@ -59,6 +65,17 @@ var orderbookCmd = &cobra.Command{
 		s := session.Exchange.NewStream()
 		s.SetPublicOnly()
 		s.Subscribe(types.BookChannel, symbol, types.SubscribeOptions{})
+
+		// record the depth for the backtest depth matching engine
+		if len(recordDirectory) > 0 {
+			dumper := backtest.NewDepthDumper(recordDirectory, session.Exchange.Name())
+			dumper.BindStream(s)
+			defer func() {
+				if err := dumper.Close(); err != nil {
+					log.WithError(err).Errorf("depth dumper can not close files")
+				}
+			}()
+		}
 		s.OnBookSnapshot(func(book types.SliceOrderBook) {
 			if dumpDepthUpdate {
 				log.Infof("orderbook snapshot: %s", book.String())
@@ -163,6 +180,7 @@ func init() {
 	orderbookCmd.Flags().String("session", "", "session name")
 	orderbookCmd.Flags().String("symbol", "", "the trading pair. e.g, BTCUSDT, LTCUSDT...")
 	orderbookCmd.Flags().Bool("dump-update", false, "dump the depth update")
+	orderbookCmd.Flags().String("record", "", "record the depth snapshots and updates into the given directory for the backtest depth matching engine")
 
 	orderUpdateCmd.Flags().String("session", "", "session name")
 	RootCmd.AddCommand(orderbookCmd)
@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/c9s/bbgo/pkg/bbgo"
+	"github.com/c9s/bbgo/pkg/service"
+)
+
+func init() {
+	persistenceShowCmd.Flags().Int64("version", 0, "the version of the store, the latest version is shown by default")
+	persistenceShowCmd.Flags().Bool("history", false, "list the versions of the store")
+	persistenceResetCmd.Flags().Bool("prefix", false, "reset all the stores that have the given store id prefix")
+
+	PersistenceCmd.AddCommand(persistenceListCmd)
+	PersistenceCmd.AddCommand(persistenceShowCmd)
+	PersistenceCmd.AddCommand(persistenceDiffCmd)
+	PersistenceCmd.AddCommand(persistenceResetCmd)
+	RootCmd.AddCommand(PersistenceCmd)
+}
+
+// PersistenceCmd manages the strategy states stored by the database persistence,
+// the strategy state fields are stored with the store id "state:{instanceID}:{field}"
+var PersistenceCmd = &cobra.Command{
+	Use:          "persistence",
+	Short:        "manage the stored strategy states of the database persistence",
+	SilenceUsage: true,
+}
+
+// go run ./cmd/bbgo persistence list state:grid
+var persistenceListCmd = &cobra.Command{
+	Use:          "list [STORE_ID_PREFIX]",
+	Short:        "list the stores and their latest versions",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		ps, err := newDatabasePersistenceService(ctx)
+		if err != nil {
+			return err
+		}
+
+		var prefix string
+		if len(args) > 0 {
+			prefix = args[0]
+		}
+
+		records, err := ps.QueryLatest(ctx, prefix)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "STORE ID\tVERSION\tUPDATED AT")
+		for _, record := range records {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", record.StoreID, record.Version, record.CreatedAt.String())
+		}
+		return w.Flush()
+	},
+}
+
+// go run ./cmd/bbgo persistence show state:grid-BTCUSDT:position --version 3
+var persistenceShowCmd = &cobra.Command{
+	Use:          "show STORE_ID [--version VERSION] [--history]",
+	Short:        "show the stored value of a store",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		version, err := cmd.Flags().GetInt64("version")
+		if err != nil {
+			return err
+		}
+
+		history, err := cmd.Flags().GetBool("history")
+		if err != nil {
+			return err
+		}
+
+		ps, err := newDatabasePersistenceService(ctx)
+		if err != nil {
+			return err
+		}
+
+		if history {
+			records, err := ps.QueryHistory(ctx, args[0], 0)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tCREATED AT\tSIZE")
+			for _, record := range records {
+				fmt.Fprintf(w, "%d\t%s\t%d\n", record.Version, record.CreatedAt.String(), len(record.Data))
+			}
+			return w.Flush()
+		}
+
+		record, err := queryPersistenceRecord(ctx, ps, args[0], version)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(record.Data), "", "  "); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s version %d (%s)\n", record.StoreID, record.Version, record.CreatedAt.String())
+		fmt.Println(buf.String())
+		return nil
+	},
+}
+
+// go run ./cmd/bbgo persistence diff state:grid-BTCUSDT:position 2 3
+var persistenceDiffCmd = &cobra.Command{
+	Use:          "diff STORE_ID [FROM_VERSION [TO_VERSION]]",
+	Short:        "show the changed fields between two versions of a store, the previous version and the latest version are compared by default",
+	Args:         cobra.RangeArgs(1, 3),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		ps, err := newDatabasePersistenceService(ctx)
+		if err != nil {
+			return err
+		}
+
+		storeID := args[0]
+
+		var fromVersion, toVersion int64
+		if len(args) > 1 {
+			if fromVersion, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+				return errors.Wrapf(err, "invalid from version %q", args[1])
+			}
+		}
+
+		if len(args) > 2 {
+			if toVersion, err = strconv.ParseInt(args[2], 10, 64); err != nil {
+				return errors.Wrapf(err, "invalid to version %q", args[2])
+			}
+		}
+
+		to, err := queryPersistenceRecord(ctx, ps, storeID, toVersion)
+		if err != nil {
+			return err
+		}
+
+		if fromVersion == 0 {
+			fromVersion = to.Version - 1
+		}
+
+		from, err := queryPersistenceRecord(ctx, ps, storeID, fromVersion)
+		if err != nil {
+			return err
+		}
+
+		changes, err := diffPersistenceData(from.Data, to.Data)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s version %d -> %d\n", storeID, from.Version, to.Version)
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		return nil
+	},
+}
+
+// go run ./cmd/bbgo persistence reset state:grid-BTCUSDT: --prefix
+var persistenceResetCmd = &cobra.Command{
+	Use:          "reset STORE_ID [--prefix]",
+	Short:        "delete the store and its history",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		prefix, err := cmd.Flags().GetBool("prefix")
+		if err != nil {
+			return err
+		}
+
+		ps, err := newDatabasePersistenceService(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !prefix {
+			if _, err := queryPersistenceRecord(ctx, ps, args[0], 0); err != nil {
+				return err
+			}
+
+			if err := ps.NewStore(args[0]).Reset(); err != nil {
+				return err
+			}
+
+			fmt.Printf("%s is reset\n", args[0])
+			return nil
+		}
+
+		n, err := ps.Reset(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%d rows of the stores with prefix %s are deleted\n", n, args[0])
+		return nil
+	},
+}
+
+func newDatabasePersistenceService(ctx context.Context) (*service.DatabasePersistenceService, error) {
+	environ := bbgo.NewEnvironment()
+	if err := environ.ConfigureDatabase(ctx); err != nil {
+		return nil, err
+	}
+
+	conf := &service.DatabasePersistenceConfig{}
+	if userConfig != nil && userConfig.Persistence != nil && userConfig.Persistence.Database != nil {
+		conf = userConfig.Persistence.Database
+	}
+
+	if err := environ.ConfigurePersistence(&bbgo.PersistenceConfig{Database: conf}); err != nil {
+		return nil, err
+	}
+
+	return environ.PersistenceServiceFacade.Database, nil
+}
+
+func queryPersistenceRecord(ctx context.Context, ps *service.DatabasePersistenceService, storeID string, version int64) (*service.PersistenceRecord, error) {
+	record, err := ps.QueryVersion(ctx, storeID, version)
+	if err != nil {
+		if version > 0 {
+			return nil, errors.Wrapf(err, "version %d of store %s is not found", version, storeID)
+		}
+
+		return nil, errors.Wrapf(err, "store %s is not found", storeID)
+	}
+
+	return record, nil
+}
+
+// diffPersistenceData compares the json values by the field paths, and returns the changes in the unified diff style
+func diffPersistenceData(from, to string) ([]string, error) {
+	var fromValue, toValue interface{}
+	if err := json.Unmarshal([]byte(from), &fromValue); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(to), &toValue); err != nil {
+		return nil, err
+	}
+
+	var fromFields = make(map[string]string)
+	var toFields = make(map[string]string)
+	flattenJSON("", fromValue, fromFields)
+	flattenJSON("", toValue, toFields)
+
+	var paths []string
+	for path := range fromFields {
+		paths = append(paths, path)
+	}
+
+	for path := range toFields {
+		if _, ok := fromFields[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	var changes []string
+	for _, path := range paths {
+		fromField, inFrom := fromFields[path]
+		toField, inTo := toFields[path]
+		if inFrom && inTo && fromField == toField {
+			continue
+		}
+
+		if inFrom {
+			changes = append(changes, fmt.Sprintf("- %s: %s", path, fromField))
+		}
+
+		if inTo {
+			changes = append(changes, fmt.Sprintf("+ %s: %s", path, toField))
+		}
+	}
+
+	return changes, nil
+}
+
+func flattenJSON(path string, value interface{}, fields map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if path == "" {
+				flattenJSON(key, field, fields)
+			} else {
+				flattenJSON(path+"."+key, field, fields)
+			}
+		}
+
+	case []interface{}:
+		for i, field := range v {
+			flattenJSON(fmt.Sprintf("%s[%d]", path, i), field, fields)
+		}
+
+	default:
+		data, _ := json.Marshal(v)
+		if path == "" {
+			path = "."
+		}
+		fields[path] = string(data)
+	}
+}
@@ -31,6 +31,15 @@ func inQuoteAsset(balances types.BalanceMap, market types.Market, price fixedpoi
 	return base.Total().Mul(price).Add(quote.Total())
 }
 
+// futuresPositions returns the positions of the futures account, nil is returned if it's not a futures account
+func futuresPositions(account *types.Account) types.FuturesPositionMap {
+	if account.FuturesInfo == nil {
+		return nil
+	}
+
+	return account.FuturesInfo.Positions
+}
+
 func inBaseAsset(balances types.BalanceMap, market types.Market, price fixedpoint.Value) fixedpoint.Value {
 	quote := balances[market.QuoteCurrency]
 	base := balances[market.BaseCurrency]
@@ -1193,6 +1193,96 @@ func (e *Exchange) submitSpotOrder(ctx context.Context, order types.SubmitOrder)
 	return createdOrder, err
 }
 
+// SubmitOCOOrder submits the take-profit order as a LIMIT_MAKER order and the stop-loss order as a STOP_LOSS
+// (STOP_MARKET) or STOP_LOSS_LIMIT (STOP_LIMIT) order in one spot OCO order list.
+// The margin and the futures accounts are not supported.
+func (e *Exchange) SubmitOCOOrder(ctx context.Context, takeProfit, stopLoss types.SubmitOrder) (createdOrders types.OrderSlice, err error) {
+	if e.IsMargin || e.IsFutures {
+		return nil, errors.Wrap(types.ErrOCOOrderNotSupported, "binance oco order is only supported by the spot account")
+	}
+
+	if stopLoss.Type != types.OrderTypeStopMarket && stopLoss.Type != types.OrderTypeStopLimit {
+		return nil, errors.Wrapf(types.ErrOCOOrderNotSupported, "binance oco order does not support the %s stop-loss order", stopLoss.Type)
+	}
+
+	if takeProfit.Symbol != stopLoss.Symbol || takeProfit.Side != stopLoss.Side || takeProfit.Quantity.Compare(stopLoss.Quantity) != 0 {
+		return nil, fmt.Errorf("the orders of the binance oco order must have the same symbol, side and quantity")
+	}
+
+	if err := orderLimiter.Wait(ctx); err != nil {
+		log.WithError(err).Errorf("order rate limiter wait error")
+	}
+
+	market := takeProfit.Market
+	formatPrice := func(price fixedpoint.Value) string {
+		if market.Symbol != "" {
+			return market.FormatPrice(price)
+		}
+		return price.FormatString(8)
+	}
+
+	quantity := takeProfit.Quantity.FormatString(8)
+	if market.Symbol != "" {
+		quantity = market.FormatQuantity(takeProfit.Quantity)
+	}
+
+	req := e.client.NewCreateOCOService().
+		Symbol(takeProfit.Symbol).
+		Side(binance.SideType(takeProfit.Side)).
+		Quantity(quantity).
+		Price(formatPrice(takeProfit.Price)).
+		StopPrice(formatPrice(stopLoss.StopPrice))
+
+	if stopLoss.Type == types.OrderTypeStopLimit {
+		req.StopLimitPrice(formatPrice(stopLoss.Price)).
+			StopLimitTimeInForce(binance.TimeInForceTypeGTC)
+	}
+
+	if clientOrderID := newSpotClientOrderID(takeProfit.ClientOrderID); len(clientOrderID) > 0 {
+		req.LimitClientOrderID(clientOrderID)
+	}
+
+	if clientOrderID := newSpotClientOrderID(stopLoss.ClientOrderID); len(clientOrderID) > 0 {
+		req.StopClientOrderID(clientOrderID)
+	}
+
+	req.NewOrderRespType(binance.NewOrderRespTypeRESULT)
+
+	response, err := req.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("spot oco order creation response: %+v", response)
+
+	for _, report := range response.OrderReports {
+		createdOrder, err := toGlobalOrder(&binance.Order{
+			Symbol:                   report.Symbol,
+			OrderID:                  report.OrderID,
+			ClientOrderID:            report.ClientOrderID,
+			Price:                    report.Price,
+			OrigQuantity:             report.OrigQuantity,
+			ExecutedQuantity:         report.ExecutedQuantity,
+			CummulativeQuoteQuantity: report.CummulativeQuoteQuantity,
+			Status:                   report.Status,
+			TimeInForce:              report.TimeInForce,
+			Type:                     report.Type,
+			Side:                     report.Side,
+			StopPrice:                report.StopPrice,
+			IcebergQuantity:          report.IcebergQuantity,
+			UpdateTime:               report.TransactionTime,
+			Time:                     report.TransactionTime,
+		}, false)
+		if err != nil {
+			return createdOrders, err
+		}
+
+		createdOrders = append(createdOrders, *createdOrder)
+	}
+
+	return createdOrders, nil
+}
+
 func (e *Exchange) SubmitOrders(ctx context.Context, orders ...types.SubmitOrder) (createdOrders types.OrderSlice, err error) {
 	for _, order := range orders {
 		if err := orderLimiter.Wait(ctx); err != nil {
@@ -1426,6 +1516,70 @@ func (e *Exchange) QueryTrades(ctx context.Context, symbol string, options *type
 	}
 }
 
+// QueryMarketTrades queries the public aggregate trades of the market, the trades are sorted by the trade ID
+func (e *Exchange) QueryMarketTrades(ctx context.Context, symbol string, options *types.TradeQueryOptions) (trades []types.Trade, err error) {
+	req := e.client.NewAggTradesService().Symbol(symbol)
+
+	if options.Limit > 0 {
+		req.Limit(int(options.Limit))
+	} else {
+		req.Limit(1000)
+	}
+
+	// BINANCE uses inclusive last trade ID, and fromId can not be sent with startTime or endTime
+	if options.LastTradeID > 0 {
+		req.FromID(int64(options.LastTradeID))
+	} else if options.StartTime != nil {
+		// the time range of the aggregate trades must be less than 1 hour
+		endTime := options.StartTime.Add(time.Hour - time.Millisecond)
+		if options.EndTime != nil && options.EndTime.Before(endTime) {
+			endTime = *options.EndTime
+		}
+
+		req.StartTime(options.StartTime.UnixMilli())
+		req.EndTime(endTime.UnixMilli())
+	}
+
+	remoteTrades, err := req.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range remoteTrades {
+		price, err := fixedpoint.NewFromString(t.Price)
+		if err != nil {
+			return nil, err
+		}
+
+		quantity, err := fixedpoint.NewFromString(t.Quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		// the buyer is the maker means the taker sold
+		side := types.SideTypeBuy
+		if t.IsBuyerMaker {
+			side = types.SideTypeSell
+		}
+
+		trades = append(trades, types.Trade{
+			ID:            uint64(t.AggTradeID),
+			Exchange:      types.ExchangeBinance,
+			Symbol:        symbol,
+			Price:         price,
+			Quantity:      quantity,
+			QuoteQuantity: price.Mul(quantity),
+			Side:          side,
+			IsBuyer:       !t.IsBuyerMaker,
+			IsMaker:       t.IsBuyerMaker,
+			Time:          types.Time(time.UnixMilli(t.Timestamp)),
+			Fee:           fixedpoint.Zero,
+		})
+	}
+
+	return trades, nil
+}
+
 // QueryDepth query the order book depth of a symbol
 func (e *Exchange) QueryDepth(ctx context.Context, symbol string) (snapshot types.SliceOrderBook, finalUpdateID int64, err error) {
 	var response *binance.DepthResponse
@@ -1539,12 +1693,46 @@ func (e *Exchange) QueryFundingRateHistory(ctx context.Context, symbol string) (
 	}
 
 	return &types.FundingRate{
+		Exchange:    types.ExchangeBinance,
+		Symbol:      symbol,
 		FundingRate: fundingRate,
 		FundingTime: time.Unix(0, rate.FundingTime*int64(time.Millisecond)),
 		Time:        time.Unix(0, rate.Time*int64(time.Millisecond)),
 	}, nil
 }
 
+// QueryFundingRates queries the funding rate history of the perpetual futures in the time range, the rates are sorted by the funding time
+func (e *Exchange) QueryFundingRates(ctx context.Context, symbol string, startTime, endTime time.Time) ([]types.FundingRate, error) {
+	futuresClient := binance.NewFuturesClient(e.key, e.secret)
+	rates, err := futuresClient.NewFundingRateService().
+		Symbol(symbol).
+		StartTime(startTime.UnixMilli()).
+		EndTime(endTime.UnixMilli()).
+		Limit(1000).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var fundingRates []types.FundingRate
+	for _, rate := range rates {
+		fundingRate, err := fixedpoint.NewFromString(rate.FundingRate)
+		if err != nil {
+			return nil, err
+		}
+
+		fundingRates = append(fundingRates, types.FundingRate{
+			Exchange:    types.ExchangeBinance,
+			Symbol:      rate.Symbol,
+			FundingRate: fundingRate,
+			FundingTime: time.Unix(0, rate.FundingTime*int64(time.Millisecond)),
+			Time:        time.Unix(0, rate.Time*int64(time.Millisecond)),
+		})
+	}
+
+	return fundingRates, nil
+}
+
 func (e *Exchange) QueryPositionRisk(ctx context.Context, symbol string) (*types.PositionRisk, error) {
 	futuresClient := binance.NewFuturesClient(e.key, e.secret)
 
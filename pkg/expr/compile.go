@@ -0,0 +1,439 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/c9s/bbgo/pkg/bbgo"
+	"github.com/c9s/bbgo/pkg/indicator"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// Env is the environment that the expressions are compiled with.
+// The kline fields and the indicators are of the symbol and the interval of the environment.
+type Env struct {
+	Interval   types.Interval
+	Store      *bbgo.MarketDataStore
+	Indicators *bbgo.StandardIndicatorSet
+}
+
+// NewEnv creates the environment of the symbol and the interval from the session
+func NewEnv(session *bbgo.ExchangeSession, symbol string, interval types.Interval) (*Env, error) {
+	store, ok := session.MarketDataStore(symbol)
+	if !ok {
+		return nil, fmt.Errorf("market data store of %s not found", symbol)
+	}
+
+	indicators, ok := session.StandardIndicatorSet(symbol)
+	if !ok {
+		return nil, fmt.Errorf("standard indicator set of %s not found", symbol)
+	}
+
+	return &Env{Interval: interval, Store: store, Indicators: indicators}, nil
+}
+
+func (env *Env) intervalWindow(window int) types.IntervalWindow {
+	return types.IntervalWindow{Interval: env.Interval, Window: window}
+}
+
+type kind int
+
+const (
+	kindNumber kind = iota
+	kindBool
+)
+
+func (k kind) String() string {
+	if k == kindBool {
+		return "bool"
+	}
+	return "number"
+}
+
+type argKind int
+
+const (
+	// argSeries is a number expression
+	argSeries argKind = iota
+
+	// argBool is a bool expression
+	argBool
+
+	// argWindow is a positive integer constant
+	argWindow
+
+	// argNumber is a number constant
+	argNumber
+
+	// argClose is the close price source of the standard indicators
+	argClose
+)
+
+// function is the builtin function of the expressions.
+// The arguments of build are types.Series, types.BoolSeries, int or float64 by the argument kinds,
+// the close price source argument is omitted.
+type function struct {
+	signatures [][]argKind
+	result     kind
+	build      func(env *Env, args []interface{}) (interface{}, error)
+}
+
+var klineFields = map[string]func(window *types.KLineWindow) types.Series{
+	"open":   (*types.KLineWindow).Open,
+	"high":   (*types.KLineWindow).High,
+	"low":    (*types.KLineWindow).Low,
+	"close":  (*types.KLineWindow).Close,
+	"volume": (*types.KLineWindow).Volume,
+}
+
+// standardIndicator returns the function of the indicator that takes an optional close price source and the window
+func standardIndicator(build func(env *Env, window int) types.Series) function {
+	return function{
+		signatures: [][]argKind{{argWindow}, {argClose, argWindow}},
+		result:     kindNumber,
+		build: func(env *Env, args []interface{}) (interface{}, error) {
+			return build(env, args[0].(int)), nil
+		},
+	}
+}
+
+func seriesFunction(fn func(a types.Series) types.Series) function {
+	return function{
+		signatures: [][]argKind{{argSeries}},
+		result:     kindNumber,
+		build: func(env *Env, args []interface{}) (interface{}, error) {
+			return fn(args[0].(types.Series)), nil
+		},
+	}
+}
+
+func rollingFunction(fn func(a types.Series, window int) float64) function {
+	return function{
+		signatures: [][]argKind{{argSeries, argWindow}},
+		result:     kindNumber,
+		build: func(env *Env, args []interface{}) (interface{}, error) {
+			return &rollingSeries{a: args[0].(types.Series), window: args[1].(int), fn: fn}, nil
+		},
+	}
+}
+
+func crossFunction(fn func(a, b types.Series) types.BoolSeries) function {
+	return function{
+		signatures: [][]argKind{{argSeries, argSeries}},
+		result:     kindBool,
+		build: func(env *Env, args []interface{}) (interface{}, error) {
+			return fn(args[0].(types.Series), args[1].(types.Series)), nil
+		},
+	}
+}
+
+var functions = map[string]function{
+	"sma": standardIndicator(func(env *Env, window int) types.Series {
+		return env.Indicators.SMA(env.intervalWindow(window))
+	}),
+	"ewma": standardIndicator(func(env *Env, window int) types.Series {
+		return env.Indicators.EWMA(env.intervalWindow(window))
+	}),
+	"rsi": standardIndicator(func(env *Env, window int) types.Series {
+		return env.Indicators.RSI(env.intervalWindow(window))
+	}),
+	"stoch_k": standardIndicator(func(env *Env, window int) types.Series {
+		return env.Indicators.STOCH(env.intervalWindow(window)).GetK()
+	}),
+	"stoch_d": standardIndicator(func(env *Env, window int) types.Series {
+		return env.Indicators.STOCH(env.intervalWindow(window)).GetD()
+	}),
+	"boll_up": {
+		signatures: [][]argKind{{argWindow}, {argWindow, argNumber}},
+		result:     kindNumber,
+		build: func(env *Env, args []interface{}) (interface{}, error) {
+			return env.Indicators.BOLL(env.intervalWindow(args[0].(int)), bollBandWidth(args)).GetUpBand(), nil
+		},
+	},
+	"boll_mid": {
+		signatures: [][]argKind{{argWindow}, {argWindow, argNumber}},
+		result:     kindNumber,
+		build: func(env *Env, args []interface{}) (interface{}, error) {
+			return env.Indicators.BOLL(env.intervalWindow(args[0].(int)), bollBandWidth(args)).GetSMA(), nil
+		},
+	},
+	"boll_down": {
+		signatures: [][]argKind{{argWindow}, {argWindow, argNumber}},
+		result:     kindNumber,
+		build: func(env *Env, args []interface{}) (interface{}, error) {
+			return env.Indicators.BOLL(env.intervalWindow(args[0].(int)), bollBandWidth(args)).GetDownBand(), nil
+		},
+	},
+	"abs": seriesFunction(types.Abs),
+	"change": {
+		signatures: [][]argKind{{argSeries}, {argSeries, argWindow}},
+		result:     kindNumber,
+		build: func(env *Env, args []interface{}) (interface{}, error) {
+			if len(args) > 1 {
+				return types.Change(args[0].(types.Series), args[1].(int)), nil
+			}
+			return types.Change(args[0].(types.Series)), nil
+		},
+	},
+	"highest":    rollingFunction(highest),
+	"lowest":     rollingFunction(lowest),
+	"mean":       rollingFunction(mean),
+	"stdev":      rollingFunction(stdev),
+	"crossover":  crossFunction(types.CrossOver),
+	"crossunder": crossFunction(types.CrossUnder),
+}
+
+// lookupFunction returns the builtin function of the name, or the function of the registered indicator
+// that is a types.Series and takes the window, for example, hull(9)
+func lookupFunction(name string) (function, bool) {
+	if fn, ok := functions[name]; ok {
+		return fn, true
+	}
+
+	if !indicator.IsRegistered(name) {
+		return function{}, false
+	}
+
+	probe, err := indicator.New(name, indicator.Params{})
+	if err != nil {
+		return function{}, false
+	}
+
+	if _, ok := probe.(types.Series); !ok {
+		return function{}, false
+	}
+
+	return function{
+		signatures: [][]argKind{{argWindow}},
+		result:     kindNumber,
+		build: func(env *Env, args []interface{}) (interface{}, error) {
+			inc, err := env.Indicators.Indicator(name, indicator.Params{IntervalWindow: env.intervalWindow(args[0].(int))})
+			if err != nil {
+				return nil, err
+			}
+			return inc.(types.Series), nil
+		},
+	}, true
+}
+
+func bollBandWidth(args []interface{}) float64 {
+	if len(args) > 1 {
+		return args[1].(float64)
+	}
+	return 2.0
+}
+
+// check checks the syntax tree and returns the kind of the expression
+func check(n node) (kind, error) {
+	switch n := n.(type) {
+
+	case *numberNode:
+		return kindNumber, nil
+
+	case *boolNode:
+		return kindBool, nil
+
+	case *identNode:
+		if _, ok := klineFields[n.name]; !ok {
+			return 0, fmt.Errorf("undefined variable %s", n.name)
+		}
+		return kindNumber, nil
+
+	case *indexNode:
+		if err := expectKind(n.operand, kindNumber); err != nil {
+			return 0, err
+		}
+		return kindNumber, nil
+
+	case *unaryNode:
+		if n.op == "not" {
+			return kindBool, expectKind(n.operand, kindBool)
+		}
+		return kindNumber, expectKind(n.operand, kindNumber)
+
+	case *binaryNode:
+		switch n.op {
+		case "and", "or":
+			if err := expectKind(n.left, kindBool); err != nil {
+				return 0, err
+			}
+			return kindBool, expectKind(n.right, kindBool)
+
+		case "<", "<=", ">", ">=", "==", "!=":
+			if err := expectKind(n.left, kindNumber); err != nil {
+				return 0, err
+			}
+			return kindBool, expectKind(n.right, kindNumber)
+
+		default:
+			if err := expectKind(n.left, kindNumber); err != nil {
+				return 0, err
+			}
+			return kindNumber, expectKind(n.right, kindNumber)
+		}
+
+	case *callNode:
+		fn, ok := lookupFunction(n.name)
+		if !ok {
+			return 0, fmt.Errorf("undefined function %s", n.name)
+		}
+
+		if _, err := matchSignature(n, fn); err != nil {
+			return 0, err
+		}
+
+		return fn.result, nil
+	}
+
+	return 0, fmt.Errorf("unexpected node %s", n)
+}
+
+func expectKind(n node, k kind) error {
+	actual, err := check(n)
+	if err != nil {
+		return err
+	}
+
+	if actual != k {
+		return fmt.Errorf("%s is a %s expression, expecting a %s expression", n, actual, k)
+	}
+
+	return nil
+}
+
+// matchSignature returns the signature that matches the number of the arguments, and checks the arguments
+func matchSignature(n *callNode, fn function) ([]argKind, error) {
+	for _, signature := range fn.signatures {
+		if len(signature) != len(n.args) {
+			continue
+		}
+
+		for i, ak := range signature {
+			if err := checkArg(n, n.args[i], ak); err != nil {
+				return nil, err
+			}
+		}
+
+		return signature, nil
+	}
+
+	return nil, fmt.Errorf("invalid number of arguments of %s, %d given", n.name, len(n.args))
+}
+
+func checkArg(call *callNode, arg node, ak argKind) error {
+	switch ak {
+
+	case argSeries:
+		return expectKind(arg, kindNumber)
+
+	case argBool:
+		return expectKind(arg, kindBool)
+
+	case argWindow:
+		if n, ok := arg.(*numberNode); ok && n.value >= 1 && n.value == math.Trunc(n.value) {
+			return nil
+		}
+		return fmt.Errorf("the window of %s must be a positive integer, got %s", call.name, arg)
+
+	case argNumber:
+		if _, ok := arg.(*numberNode); ok {
+			return nil
+		}
+		return fmt.Errorf("the argument of %s must be a number, got %s", call.name, arg)
+
+	case argClose:
+		if n, ok := arg.(*identNode); ok && n.name == "close" {
+			return nil
+		}
+		return fmt.Errorf("the source of %s must be close, got %s", call.name, arg)
+	}
+
+	return nil
+}
+
+// compile builds the series of the checked syntax tree, it returns the error of the indicators that can not be created
+func compile(env *Env, n node) (interface{}, error) {
+	switch n := n.(type) {
+
+	case *numberNode:
+		return types.NumberSeries(n.value), nil
+
+	case *boolNode:
+		return constBoolSeries(n.value), nil
+
+	case *identNode:
+		return &klineSeries{env: env, field: klineFields[n.name]}, nil
+
+	case *indexNode:
+		operand, err := compile(env, n.operand)
+		if err != nil {
+			return nil, err
+		}
+		return &shiftSeries{a: operand.(types.Series), offset: n.offset}, nil
+
+	case *unaryNode:
+		operand, err := compile(env, n.operand)
+		if err != nil {
+			return nil, err
+		}
+
+		if n.op == "not" {
+			return &notSeries{a: operand.(types.BoolSeries)}, nil
+		}
+		return types.Mul(operand, -1.0), nil
+
+	case *binaryNode:
+		left, err := compile(env, n.left)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := compile(env, n.right)
+		if err != nil {
+			return nil, err
+		}
+
+		switch n.op {
+		case "and", "or":
+			return &logicSeries{a: left.(types.BoolSeries), b: right.(types.BoolSeries), op: n.op}, nil
+		case "<", "<=", ">", ">=", "==", "!=":
+			return &compareSeries{a: left.(types.Series), b: right.(types.Series), op: n.op}, nil
+		case "+":
+			return types.Add(left, right), nil
+		case "-":
+			return types.Minus(left, right), nil
+		case "*":
+			return types.Mul(left, right), nil
+		case "/":
+			return types.Div(left, right), nil
+		}
+
+	case *callNode:
+		fn, _ := lookupFunction(n.name)
+		signature, _ := matchSignature(n, fn)
+
+		var args []interface{}
+		for i, ak := range signature {
+			switch ak {
+			case argSeries, argBool:
+				arg, err := compile(env, n.args[i])
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+			case argWindow:
+				args = append(args, int(n.args[i].(*numberNode).value))
+			case argNumber:
+				args = append(args, n.args[i].(*numberNode).value)
+			}
+		}
+
+		series, err := fn.build(env, args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", n.name, err)
+		}
+		return series, nil
+	}
+
+	panic(fmt.Errorf("unexpected node %s", n))
+}
@@ -0,0 +1,113 @@
+// Package expr implements a small expression language of the indicator signals, for example:
+//
+//	crossover(ewma(close, 9), ewma(close, 21)) and rsi(14) < 70
+//
+// The expressions are parsed and checked when the config is loaded, and are compiled to
+// types.Series or types.BoolSeries graphs of the StandardIndicatorSet indicators and the kline fields.
+//
+// The expression supports the number literals, true and false, the kline fields (open, high, low, close, volume),
+// the previous bars (close[1]), the arithmetic operators (+ - * /), the comparison operators (< <= > >= == !=),
+// the logical operators (and, or, not, && and || and !), and the functions:
+//
+//	sma([close,] window), ewma([close,] window), rsi([close,] window)
+//	stoch_k(window), stoch_d(window)
+//	boll_up(window[, bandWidth]), boll_mid(window[, bandWidth]), boll_down(window[, bandWidth])
+//	abs(x), change(x[, offset]), highest(x, window), lowest(x, window), mean(x, window), stdev(x, window)
+//	crossover(a, b), crossunder(a, b)
+//
+// The other registered indicators that are number series can be called with the window, for example, hull(9) or dema(21).
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// Expr is a parsed expression, it's unmarshalled from a string
+type Expr struct {
+	source string
+	root   node
+	kind   kind
+}
+
+// Parse parses and checks the expression
+func Parse(source string) (*Expr, error) {
+	root, err := parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", source, err)
+	}
+
+	k, err := check(root)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", source, err)
+	}
+
+	return &Expr{source: source, root: root, kind: k}, nil
+}
+
+// MustParse is like Parse but panics if the expression can not be parsed
+func MustParse(source string) *Expr {
+	e, err := Parse(source)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+// IsBool returns true if the expression is a condition
+func (e *Expr) IsBool() bool {
+	return e.kind == kindBool
+}
+
+// Series compiles the number expression
+func (e *Expr) Series(env *Env) (types.Series, error) {
+	if e.kind != kindNumber {
+		return nil, fmt.Errorf("expression %q is a %s expression, expecting a number expression", e.source, e.kind)
+	}
+
+	series, err := compile(env, e.root)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q compile error: %w", e.source, err)
+	}
+
+	return series.(types.Series), nil
+}
+
+// BoolSeries compiles the condition expression
+func (e *Expr) BoolSeries(env *Env) (types.BoolSeries, error) {
+	if e.kind != kindBool {
+		return nil, fmt.Errorf("expression %q is a %s expression, expecting a bool expression", e.source, e.kind)
+	}
+
+	series, err := compile(env, e.root)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q compile error: %w", e.source, err)
+	}
+
+	return series.(types.BoolSeries), nil
+}
+
+func (e *Expr) UnmarshalJSON(data []byte) error {
+	var source string
+	if err := json.Unmarshal(data, &source); err != nil {
+		return err
+	}
+
+	parsed, err := Parse(source)
+	if err != nil {
+		return err
+	}
+
+	*e = *parsed
+	return nil
+}
+
+func (e Expr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.source)
+}
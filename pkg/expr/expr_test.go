@@ -0,0 +1,216 @@
+package expr
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/bbgo"
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		source string
+		want   string
+		isBool bool
+	}{
+		{source: "1 + 2 * close[1]", want: "(1 + (2 * close[1]))"},
+		{source: "-close + -2", want: "((- close) + -2)"},
+		{source: "(high - low) / 2", want: "((high - low) / 2)"},
+		{source: "crossover(ewma(close,9), ewma(close,21)) and rsi(14) < 70", want: "(crossover(ewma(close, 9), ewma(close, 21)) and (rsi(14) < 70))", isBool: true},
+		{source: "not close > open || false && true", want: "((not (close > open)) or (false and true))", isBool: true},
+		{source: "!(close <= boll_down(21, 2.5))", want: "(not (close <= boll_down(21, 2.5)))", isBool: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			e, err := Parse(tt.source)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, e.root.String())
+				assert.Equal(t, tt.isBool, e.IsBool())
+			}
+		})
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	var tests = []string{
+		"",
+		"close >",
+		"(close",
+		"close $ 1",
+		"rsi(14) < 70 70",
+		"foo",
+		"foo(1)",
+		"hull(close, 9)",
+		"sma(0)",
+		"sma(close, 1.5)",
+		"ewma(high, 9)",
+		"rsi(14, 1, 2)",
+		"close and true",
+		"close > open + (1 < 2)",
+		"close[-1]",
+		"boll_up(21, close)",
+	}
+
+	for _, source := range tests {
+		_, err := Parse(source)
+		assert.Error(t, err, source)
+	}
+}
+
+func TestExpr_JSON(t *testing.T) {
+	var config struct {
+		Entry *Expr `json:"entry"`
+	}
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"entry":"close > open"}`), &config))
+	assert.Equal(t, "close > open", config.Entry.String())
+
+	out, err := json.Marshal(config)
+	assert.NoError(t, err)
+	config.Entry = nil
+	assert.NoError(t, json.Unmarshal(out, &config))
+	assert.Equal(t, "close > open", config.Entry.String())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"entry":"close >"}`), &config))
+}
+
+func newTestEnv() (*Env, *bbgo.MarketDataStore) {
+	store := bbgo.NewMarketDataStore("BTCUSDT")
+	env := &Env{
+		Interval:   types.Interval1m,
+		Store:      store,
+		Indicators: bbgo.NewStandardIndicatorSet("BTCUSDT", store),
+	}
+	return env, store
+}
+
+func addTestKLines(store *bbgo.MarketDataStore, closes ...float64) {
+	var startTime = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	if window, ok := store.KLinesOfInterval(types.Interval1m); ok && window.Len() > 0 {
+		startTime = window.Last().EndTime.Time()
+	}
+
+	for i, c := range closes {
+		store.AddKLine(types.KLine{
+			Symbol:    "BTCUSDT",
+			Interval:  types.Interval1m,
+			StartTime: types.Time(startTime.Add(time.Duration(i) * time.Minute)),
+			EndTime:   types.Time(startTime.Add(time.Duration(i+1) * time.Minute)),
+			Open:      fixedpoint.NewFromFloat(c - 1),
+			High:      fixedpoint.NewFromFloat(c + 1),
+			Low:       fixedpoint.NewFromFloat(c - 2),
+			Close:     fixedpoint.NewFromFloat(c),
+			Closed:    true,
+		})
+	}
+}
+
+func TestExpr_Series(t *testing.T) {
+	env, store := newTestEnv()
+
+	var tests = []struct {
+		source string
+		want   float64
+	}{
+		{source: "close", want: 12},
+		{source: "close[1]", want: 7},
+		{source: "(high - low) / 2", want: 1.5},
+		{source: "-close + 2 * open", want: 10},
+		{source: "change(close)", want: 5},
+		{source: "change(close, 2)", want: 4},
+		{source: "highest(close, 3)", want: 12},
+		{source: "lowest(close[1], 3)", want: 7},
+		{source: "mean(close, 2)", want: 9.5},
+		{source: "stdev(close, 2)", want: 2.5},
+		{source: "abs(close[1] - close)", want: 5},
+		{source: "sma(3)", want: 9},
+		{source: "sma(close, 3)[1]", want: 8},
+		{source: "dema(1)", want: 12},
+	}
+
+	// the indicators are bound when the expressions are compiled
+	var compiled = make([]types.Series, len(tests))
+	for i, tt := range tests {
+		series, err := MustParse(tt.source).Series(env)
+		if !assert.NoError(t, err) {
+			return
+		}
+		compiled[i] = series
+	}
+
+	addTestKLines(store, 10, 9, 8, 7, 12)
+
+	for i, tt := range tests {
+		assert.InDelta(t, tt.want, compiled[i].Last(), 1e-9, tt.source)
+	}
+
+	_, err := MustParse("close > open").Series(env)
+	assert.Error(t, err)
+}
+
+func TestExpr_BoolSeries(t *testing.T) {
+	env, store := newTestEnv()
+
+	var tests = []struct {
+		source string
+		want   bool
+	}{
+		{source: "close > close[1]", want: true},
+		{source: "close <= open", want: false},
+		{source: "crossover(close, sma(3))", want: true},
+		{source: "crossunder(close, sma(3))", want: false},
+		{source: "crossover(close, sma(3)) and close > 100", want: false},
+		{source: "crossover(close, sma(3)) and not close > 100", want: true},
+		{source: "close > 100 or close[1] == 7", want: true},
+	}
+
+	var compiled = make([]types.BoolSeries, len(tests))
+	for i, tt := range tests {
+		series, err := MustParse(tt.source).BoolSeries(env)
+		if !assert.NoError(t, err) {
+			return
+		}
+		compiled[i] = series
+	}
+
+	addTestKLines(store, 10, 9, 8, 7, 12)
+
+	for i, tt := range tests {
+		assert.Equal(t, tt.want, compiled[i].Last(), tt.source)
+	}
+
+	// the compiled series follows the new klines
+	cross := compiled[2]
+	addTestKLines(store, 13)
+	assert.False(t, cross.Last())
+	assert.True(t, cross.Index(1))
+
+	_, err := MustParse("close").BoolSeries(env)
+	assert.Error(t, err)
+}
+
+func TestExpr_BuildError(t *testing.T) {
+	functions["failing"] = function{
+		signatures: [][]argKind{{argWindow}},
+		result:     kindNumber,
+		build: func(env *Env, args []interface{}) (interface{}, error) {
+			return nil, errors.New("indicator not available")
+		},
+	}
+	defer delete(functions, "failing")
+
+	env, _ := newTestEnv()
+
+	_, err := MustParse("close - failing(3)").Series(env)
+	assert.EqualError(t, err, `expression "close - failing(3)" compile error: failing: indicator not available`)
+
+	_, err = MustParse("close > failing(3)").BoolSeries(env)
+	assert.Error(t, err)
+}
@@ -0,0 +1,460 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenLeftBracket
+	tokenRightBracket
+	tokenComma
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func (t token) String() string {
+	if t.typ == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.val)
+}
+
+// operators are sorted by the length, so that the longer operators are matched first
+var operators = []string{"&&", "||", "<=", ">=", "==", "!=", "+", "-", "*", "/", "<", ">", "!"}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	var runes = []rune(source)
+
+	for pos := 0; pos < len(runes); {
+		r := runes[pos]
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+
+		case unicode.IsDigit(r) || (r == '.' && pos+1 < len(runes) && unicode.IsDigit(runes[pos+1])):
+			start := pos
+			for pos < len(runes) && (unicode.IsDigit(runes[pos]) || runes[pos] == '.') {
+				pos++
+			}
+			tokens = append(tokens, token{typ: tokenNumber, val: string(runes[start:pos]), pos: start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := pos
+			for pos < len(runes) && (unicode.IsLetter(runes[pos]) || unicode.IsDigit(runes[pos]) || runes[pos] == '_') {
+				pos++
+			}
+			tokens = append(tokens, token{typ: tokenIdent, val: string(runes[start:pos]), pos: start})
+
+		case r == '(':
+			tokens = append(tokens, token{typ: tokenLeftParen, val: "(", pos: pos})
+			pos++
+
+		case r == ')':
+			tokens = append(tokens, token{typ: tokenRightParen, val: ")", pos: pos})
+			pos++
+
+		case r == '[':
+			tokens = append(tokens, token{typ: tokenLeftBracket, val: "[", pos: pos})
+			pos++
+
+		case r == ']':
+			tokens = append(tokens, token{typ: tokenRightBracket, val: "]", pos: pos})
+			pos++
+
+		case r == ',':
+			tokens = append(tokens, token{typ: tokenComma, val: ",", pos: pos})
+			pos++
+
+		default:
+			var matched bool
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[pos:]), op) {
+					tokens = append(tokens, token{typ: tokenOperator, val: op, pos: pos})
+					pos += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, pos)
+			}
+		}
+	}
+
+	tokens = append(tokens, token{typ: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// node is the syntax tree node of the expression
+type node interface {
+	String() string
+}
+
+type numberNode struct {
+	value float64
+	text  string
+}
+
+func (n *numberNode) String() string { return n.text }
+
+type boolNode struct {
+	value bool
+}
+
+func (n *boolNode) String() string { return strconv.FormatBool(n.value) }
+
+type identNode struct {
+	name string
+}
+
+func (n *identNode) String() string { return n.name }
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n *callNode) String() string {
+	var args []string
+	for _, a := range n.args {
+		args = append(args, a.String())
+	}
+	return n.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n *unaryNode) String() string { return "(" + n.op + " " + n.operand.String() + ")" }
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) String() string {
+	return "(" + n.left.String() + " " + n.op + " " + n.right.String() + ")"
+}
+
+// indexNode is the value of the previous bars, for example, close[1] is the close price of the previous kline
+type indexNode struct {
+	operand node
+	offset  int
+}
+
+func (n *indexNode) String() string { return n.operand.String() + "[" + strconv.Itoa(n.offset) + "]" }
+
+// parser is a recursive descent parser of the grammar:
+//
+//	or      = and { ("or" | "||") and }
+//	and     = not { ("and" | "&&") not }
+//	not     = ("not" | "!") not | compare
+//	compare = sum [ ("<" | "<=" | ">" | ">=" | "==" | "!=") sum ]
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/") unary }
+//	unary   = "-" unary | postfix
+//	postfix = primary { "[" integer "]" }
+//	primary = number | "true" | "false" | ident | ident "(" [ or { "," or } ] ")" | "(" or ")"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func parse(source string) (node, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.typ != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) unexpected(t token) error {
+	return fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+func (p *parser) expect(typ tokenType, val string) error {
+	if t := p.next(); t.typ != typ {
+		return fmt.Errorf("expecting %q, got %s at position %d", val, t, t.pos)
+	}
+	return nil
+}
+
+// acceptOperator consumes the next token if it's one of the given operators or keywords
+func (p *parser) acceptOperator(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.typ != tokenOperator && t.typ != tokenIdent {
+		return "", false
+	}
+
+	for _, op := range ops {
+		if t.val == op {
+			p.next()
+			return op, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.acceptOperator("or", "||"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: "or", left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.acceptOperator("and", "&&"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: "and", left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, ok := p.acceptOperator("not", "!"); ok {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return &unaryNode{op: "not", operand: operand}, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.acceptOperator("<", "<=", ">", ">=", "==", "!=")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	return &binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseSum() (node, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.acceptOperator("+", "-")
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseProduct() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.acceptOperator("*", "/")
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.acceptOperator("-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		// fold the negative number literals
+		if n, ok := operand.(*numberNode); ok {
+			return &numberNode{value: -n.value, text: "-" + n.text}, nil
+		}
+
+		return &unaryNode{op: "-", operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().typ == tokenLeftBracket {
+		p.next()
+
+		t := p.next()
+		offset, err := strconv.Atoi(t.val)
+		if t.typ != tokenNumber || err != nil || offset < 0 {
+			return nil, fmt.Errorf("the offset must be a non-negative integer, got %s at position %d", t, t.pos)
+		}
+
+		if err := p.expect(tokenRightBracket, "]"); err != nil {
+			return nil, err
+		}
+
+		n = &indexNode{operand: n, offset: offset}
+	}
+
+	return n, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.typ {
+
+	case tokenNumber:
+		value, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at position %d", t, t.pos)
+		}
+		return &numberNode{value: value, text: t.val}, nil
+
+	case tokenLeftParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(tokenRightParen, ")"); err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	case tokenIdent:
+		switch t.val {
+		case "true", "false":
+			return &boolNode{value: t.val == "true"}, nil
+		case "and", "or", "not":
+			return nil, p.unexpected(t)
+		}
+
+		if p.peek().typ != tokenLeftParen {
+			return &identNode{name: t.val}, nil
+		}
+
+		p.next()
+
+		call := &callNode{name: t.val}
+		if p.peek().typ == tokenRightParen {
+			p.next()
+			return call, nil
+		}
+
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+
+			if p.peek().typ == tokenComma {
+				p.next()
+				continue
+			}
+
+			if err := p.expect(tokenRightParen, ")"); err != nil {
+				return nil, err
+			}
+			return call, nil
+		}
+	}
+
+	return nil, p.unexpected(t)
+}
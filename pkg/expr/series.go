@@ -0,0 +1,224 @@
+package expr
+
+import (
+	"math"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// klineSeries is the kline field series of the environment interval,
+// the kline window is looked up on every access since it's created by the first kline of the interval
+type klineSeries struct {
+	env   *Env
+	field func(window *types.KLineWindow) types.Series
+}
+
+func (s *klineSeries) series() types.Series {
+	window, ok := s.env.Store.KLinesOfInterval(s.env.Interval)
+	if !ok {
+		return nil
+	}
+	return s.field(window)
+}
+
+func (s *klineSeries) Last() float64 {
+	return s.Index(0)
+}
+
+func (s *klineSeries) Index(i int) float64 {
+	series := s.series()
+	if series == nil {
+		return 0
+	}
+	return series.Index(i)
+}
+
+func (s *klineSeries) Length() int {
+	series := s.series()
+	if series == nil {
+		return 0
+	}
+	return series.Length()
+}
+
+var _ types.Series = &klineSeries{}
+
+// shiftSeries is the series of the previous bars, shiftSeries.Index(i) == a.Index(i + offset)
+type shiftSeries struct {
+	a      types.Series
+	offset int
+}
+
+func (s *shiftSeries) Last() float64 {
+	return s.Index(0)
+}
+
+func (s *shiftSeries) Index(i int) float64 {
+	return s.a.Index(i + s.offset)
+}
+
+func (s *shiftSeries) Length() int {
+	if length := s.a.Length() - s.offset; length > 0 {
+		return length
+	}
+	return 0
+}
+
+var _ types.Series = &shiftSeries{}
+
+// rollingSeries applies fn to the window of the series ending at each bar
+type rollingSeries struct {
+	a      types.Series
+	window int
+	fn     func(a types.Series, window int) float64
+}
+
+func (s *rollingSeries) Last() float64 {
+	return s.Index(0)
+}
+
+func (s *rollingSeries) Index(i int) float64 {
+	if i >= s.Length() {
+		return 0
+	}
+	return s.fn(&shiftSeries{a: s.a, offset: i}, s.window)
+}
+
+func (s *rollingSeries) Length() int {
+	if length := s.a.Length() - s.window + 1; length > 0 {
+		return length
+	}
+	return 0
+}
+
+var _ types.Series = &rollingSeries{}
+
+func highest(a types.Series, window int) float64 {
+	return types.Highest(a, window)
+}
+
+func lowest(a types.Series, window int) float64 {
+	return types.Lowest(a, window)
+}
+
+func mean(a types.Series, window int) float64 {
+	var sum float64
+	for i := 0; i < window; i++ {
+		sum += a.Index(i)
+	}
+	return sum / float64(window)
+}
+
+func stdev(a types.Series, window int) float64 {
+	avg := mean(a, window)
+	var sum float64
+	for i := 0; i < window; i++ {
+		diff := a.Index(i) - avg
+		sum += diff * diff
+	}
+	return math.Sqrt(sum / float64(window))
+}
+
+type compareSeries struct {
+	a, b types.Series
+	op   string
+}
+
+func (s *compareSeries) Last() bool {
+	return s.Index(0)
+}
+
+func (s *compareSeries) Index(i int) bool {
+	if i >= s.Length() {
+		return false
+	}
+
+	a, b := s.a.Index(i), s.b.Index(i)
+	switch s.op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	}
+	return false
+}
+
+func (s *compareSeries) Length() int {
+	return minLength(s.a.Length(), s.b.Length())
+}
+
+var _ types.BoolSeries = &compareSeries{}
+
+type logicSeries struct {
+	a, b types.BoolSeries
+	op   string
+}
+
+func (s *logicSeries) Last() bool {
+	return s.Index(0)
+}
+
+func (s *logicSeries) Index(i int) bool {
+	if s.op == "and" {
+		return s.a.Index(i) && s.b.Index(i)
+	}
+	return s.a.Index(i) || s.b.Index(i)
+}
+
+func (s *logicSeries) Length() int {
+	return minLength(s.a.Length(), s.b.Length())
+}
+
+var _ types.BoolSeries = &logicSeries{}
+
+type notSeries struct {
+	a types.BoolSeries
+}
+
+func (s *notSeries) Last() bool {
+	return s.Index(0)
+}
+
+func (s *notSeries) Index(i int) bool {
+	if i >= s.a.Length() {
+		return false
+	}
+	return !s.a.Index(i)
+}
+
+func (s *notSeries) Length() int {
+	return s.a.Length()
+}
+
+var _ types.BoolSeries = &notSeries{}
+
+type constBoolSeries bool
+
+func (s constBoolSeries) Last() bool {
+	return bool(s)
+}
+
+func (s constBoolSeries) Index(_ int) bool {
+	return bool(s)
+}
+
+func (s constBoolSeries) Length() int {
+	return math.MaxInt32
+}
+
+var _ types.BoolSeries = constBoolSeries(false)
+
+func minLength(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
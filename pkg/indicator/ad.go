@@ -15,7 +15,7 @@ Accumulation/Distribution Indicator (A/D)
 //go:generate callbackgen -type AD
 type AD struct {
 	types.IntervalWindow
-	Values   types.Float64Slice
+	Values   types.Float64RingBuffer
 	PrePrice float64
 
 	EndTime         time.Time
@@ -35,22 +35,15 @@ func (inc *AD) Update(high, low, cloze, volume float64) {
 }
 
 func (inc *AD) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0.0
-	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *AD) Index(i int) float64 {
-	length := len(inc.Values)
-	if length == 0 || length-i-1 < 0 {
-		return 0
-	}
-	return inc.Values[length-i-1]
+	return inc.Values.Index(i)
 }
 
 func (inc *AD) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 var _ types.Series = &AD{}
@@ -77,3 +70,11 @@ func (inc *AD) handleKLineWindowUpdate(interval types.Interval, window types.KLi
 func (inc *AD) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *AD) WarmUpBars() int {
+	return 1
+}
+
+func (inc *AD) IsReady() bool {
+	return inc.Values.Length() > 0
+}
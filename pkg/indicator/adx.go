@@ -0,0 +1,151 @@
+package indicator
+
+import (
+	"math"
+	"time"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+/*
+adx implements the average directional index (ADX) with the plus and minus directional indicators (+DI, -DI).
+The true range, the directional movements and the directional index are smoothed by the Wilder's moving average (RMA)
+of the window.
+
+Average Directional Index (ADX)
+- https://www.investopedia.com/terms/a/adx.asp
+*/
+//go:generate callbackgen -type ADX
+type ADX struct {
+	types.IntervalWindow
+
+	Values  types.Float64RingBuffer
+	PlusDI  types.Float64RingBuffer
+	MinusDI types.Float64RingBuffer
+
+	trueRange     *RMA
+	plusDM        *RMA
+	minusDM       *RMA
+	dx            *RMA
+	previousHigh  float64
+	previousLow   float64
+	previousClose float64
+
+	EndTime         time.Time
+	UpdateCallbacks []func(adx, plusDI, minusDI float64)
+}
+
+func (inc *ADX) Update(high, low, cloze float64) {
+	if inc.trueRange == nil {
+		inc.trueRange = &RMA{IntervalWindow: inc.IntervalWindow}
+		inc.plusDM = &RMA{IntervalWindow: inc.IntervalWindow}
+		inc.minusDM = &RMA{IntervalWindow: inc.IntervalWindow}
+		inc.dx = &RMA{IntervalWindow: inc.IntervalWindow}
+		inc.previousHigh, inc.previousLow, inc.previousClose = high, low, cloze
+		return
+	}
+
+	trueRange := math.Max(high-low, math.Max(math.Abs(high-inc.previousClose), math.Abs(low-inc.previousClose)))
+
+	upMove := high - inc.previousHigh
+	downMove := inc.previousLow - low
+	plusDM, minusDM := 0.0, 0.0
+	if upMove > downMove && upMove > 0 {
+		plusDM = upMove
+	}
+	if downMove > upMove && downMove > 0 {
+		minusDM = downMove
+	}
+
+	inc.previousHigh, inc.previousLow, inc.previousClose = high, low, cloze
+
+	inc.trueRange.Update(trueRange)
+	inc.plusDM.Update(plusDM)
+	inc.minusDM.Update(minusDM)
+
+	if inc.trueRange.Sources.Length() < inc.Window {
+		return
+	}
+
+	var plusDI, minusDI float64
+	if atr := inc.trueRange.Last(); atr != 0 {
+		plusDI = 100.0 * inc.plusDM.Last() / atr
+		minusDI = 100.0 * inc.minusDM.Last() / atr
+	}
+
+	inc.PlusDI.Push(plusDI)
+	inc.MinusDI.Push(minusDI)
+
+	var dx float64
+	if sum := plusDI + minusDI; sum != 0 {
+		dx = 100.0 * math.Abs(plusDI-minusDI) / sum
+	}
+
+	inc.dx.Update(dx)
+	if inc.dx.Sources.Length() < inc.Window {
+		return
+	}
+
+	inc.Values.Push(inc.dx.Last())
+}
+
+func (inc *ADX) Last() float64 {
+	return inc.Values.Last()
+}
+
+func (inc *ADX) Index(i int) float64 {
+	return inc.Values.Index(i)
+}
+
+func (inc *ADX) Length() int {
+	return inc.Values.Length()
+}
+
+var _ types.Series = &ADX{}
+
+func (inc *ADX) GetPlusDI() types.Series {
+	return &inc.PlusDI
+}
+
+func (inc *ADX) GetMinusDI() types.Series {
+	return &inc.MinusDI
+}
+
+func (inc *ADX) calculateAndUpdate(kLines []types.KLine) {
+	if len(kLines) == 0 {
+		return
+	}
+
+	for _, k := range kLines {
+		if inc.EndTime != zeroTime && !k.EndTime.After(inc.EndTime) {
+			continue
+		}
+		inc.Update(k.High.Float64(), k.Low.Float64(), k.Close.Float64())
+	}
+
+	if inc.Values.Length() > 0 {
+		inc.EmitUpdate(inc.Last(), inc.PlusDI.Last(), inc.MinusDI.Last())
+	}
+	inc.EndTime = kLines[len(kLines)-1].EndTime.Time()
+}
+
+func (inc *ADX) handleKLineWindowUpdate(interval types.Interval, window types.KLineWindow) {
+	if inc.Interval != interval {
+		return
+	}
+
+	inc.calculateAndUpdate(window)
+}
+
+func (inc *ADX) Bind(updater KLineWindowUpdater) {
+	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
+}
+
+// WarmUpBars returns the bars of the directional indexes plus the bars of the smoothed DX
+func (inc *ADX) WarmUpBars() int {
+	return 2 * inc.Window
+}
+
+func (inc *ADX) IsReady() bool {
+	return inc.Values.Length() > 0
+}
@@ -0,0 +1,37 @@
+package indicator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+/*
+python, following the TradingView DMI:
+
+up, down = high - prev_high, prev_low - low
+plus_dm = up if up > down and up > 0 else 0
+minus_dm = down if down > up and down > 0 else 0
+atr = rma(true_range, 14)
+plus_di, minus_di = 100 * rma(plus_dm, 14) / atr, 100 * rma(minus_dm, 14) / atr
+adx = rma(100 * abs(plus_di - minus_di) / (plus_di + minus_di), 14)
+*/
+
+func TestADX(t *testing.T) {
+	var Delta = 1e-6
+	kLines := buildTrendTestKLines()
+
+	adx := &ADX{IntervalWindow: types.IntervalWindow{Window: 14}}
+	adx.calculateAndUpdate(kLines)
+
+	assert.Equal(t, 53, adx.Length())
+	assert.InDelta(t, 52.97553039114628, adx.Last(), Delta)
+	assert.InDelta(t, 53.77496218337257, adx.Index(1), Delta)
+	assert.InDelta(t, 54.635888728847036, adx.Index(2), Delta)
+	assert.InDelta(t, 9.865544722724854, adx.GetPlusDI().Last(), Delta)
+	assert.InDelta(t, 10.138715068155758, adx.GetPlusDI().Index(1), Delta)
+	assert.InDelta(t, 24.49894829259485, adx.GetMinusDI().Last(), Delta)
+	assert.InDelta(t, 25.177307811088173, adx.GetMinusDI().Index(1), Delta)
+}
@@ -0,0 +1,112 @@
+package indicator
+
+import (
+	"time"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+/*
+aroon implements the Aroon indicator, the up line and the down line measure the bars since
+the highest high and the lowest low of the last window + 1 bars:
+
+	up = 100 * (window - bars since the highest high) / window
+	down = 100 * (window - bars since the lowest low) / window
+	oscillator = up - down
+
+If there are multiple highest highs or lowest lows, the most recent one is used.
+
+Aroon Indicator
+- https://www.investopedia.com/terms/a/aroon.asp
+*/
+//go:generate callbackgen -type Aroon
+type Aroon struct {
+	types.IntervalWindow
+
+	Up         types.Float64RingBuffer
+	Down       types.Float64RingBuffer
+	Oscillator types.Float64RingBuffer
+
+	HighValues types.Float64RingBuffer
+	LowValues  types.Float64RingBuffer
+
+	EndTime         time.Time
+	UpdateCallbacks []func(up, down float64)
+}
+
+func (inc *Aroon) Update(high, low float64) {
+	inc.HighValues.Push(high)
+	inc.LowValues.Push(low)
+
+	if inc.HighValues.Length() < inc.Window+1 {
+		return
+	}
+
+	var highestIndex, lowestIndex int
+	for i := 1; i <= inc.Window; i++ {
+		if inc.HighValues.Index(i) > inc.HighValues.Index(highestIndex) {
+			highestIndex = i
+		}
+		if inc.LowValues.Index(i) < inc.LowValues.Index(lowestIndex) {
+			lowestIndex = i
+		}
+	}
+
+	window := float64(inc.Window)
+	up := 100.0 * (window - float64(highestIndex)) / window
+	down := 100.0 * (window - float64(lowestIndex)) / window
+
+	inc.Up.Push(up)
+	inc.Down.Push(down)
+	inc.Oscillator.Push(up - down)
+}
+
+func (inc *Aroon) GetUp() types.Series {
+	return &inc.Up
+}
+
+func (inc *Aroon) GetDown() types.Series {
+	return &inc.Down
+}
+
+func (inc *Aroon) GetOscillator() types.Series {
+	return &inc.Oscillator
+}
+
+func (inc *Aroon) calculateAndUpdate(kLines []types.KLine) {
+	if len(kLines) == 0 {
+		return
+	}
+
+	for _, k := range kLines {
+		if inc.EndTime != zeroTime && !k.EndTime.After(inc.EndTime) {
+			continue
+		}
+		inc.Update(k.High.Float64(), k.Low.Float64())
+	}
+
+	if inc.Up.Length() > 0 {
+		inc.EmitUpdate(inc.Up.Last(), inc.Down.Last())
+	}
+	inc.EndTime = kLines[len(kLines)-1].EndTime.Time()
+}
+
+func (inc *Aroon) handleKLineWindowUpdate(interval types.Interval, window types.KLineWindow) {
+	if inc.Interval != interval {
+		return
+	}
+
+	inc.calculateAndUpdate(window)
+}
+
+func (inc *Aroon) Bind(updater KLineWindowUpdater) {
+	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
+}
+
+func (inc *Aroon) WarmUpBars() int {
+	return inc.Window + 1
+}
+
+func (inc *Aroon) IsReady() bool {
+	return inc.Up.Length() > 0
+}
@@ -0,0 +1,32 @@
+package indicator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+/*
+python:
+
+highs, lows = high[i-14:i+1], low[i-14:i+1]
+bars_since_high = 14 - max(k for k, v in enumerate(highs) if v == max(highs))
+bars_since_low = 14 - max(k for k, v in enumerate(lows) if v == min(lows))
+up, down = 100 * (14 - bars_since_high) / 14, 100 * (14 - bars_since_low) / 14
+*/
+
+func TestAroon(t *testing.T) {
+	var Delta = 1e-9
+	kLines := buildTrendTestKLines()
+
+	aroon := &Aroon{IntervalWindow: types.IntervalWindow{Window: 14}}
+	aroon.calculateAndUpdate(kLines)
+
+	assert.Equal(t, len(kLines)-14, aroon.GetUp().Length())
+	assert.InDelta(t, 0.0, aroon.GetUp().Last(), Delta)
+	assert.InDelta(t, 78.57142857142857, aroon.GetDown().Last(), Delta)
+	assert.InDelta(t, 85.71428571428571, aroon.GetDown().Index(1), Delta)
+	assert.InDelta(t, -78.57142857142857, aroon.GetOscillator().Last(), Delta)
+}
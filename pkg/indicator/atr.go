@@ -10,7 +10,7 @@ import (
 //go:generate callbackgen -type ATR
 type ATR struct {
 	types.IntervalWindow
-	PercentageVolatility types.Float64Slice
+	PercentageVolatility types.Float64RingBuffer
 
 	PreviousClose float64
 	RMA           *RMA
@@ -95,3 +95,12 @@ func (inc *ATR) handleKLineWindowUpdate(interval types.Interval, window types.KL
 func (inc *ATR) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+// WarmUpBars returns the window of the true ranges, plus the first kline for the previous close
+func (inc *ATR) WarmUpBars() int {
+	return inc.Window + 1
+}
+
+func (inc *ATR) IsReady() bool {
+	return inc.RMA != nil && inc.RMA.Sources.Length() >= inc.Window
+}
@@ -29,10 +29,10 @@ type BOLL struct {
 	// times of Std, generally it's 2
 	K float64
 
-	SMA      types.Float64Slice
-	StdDev   types.Float64Slice
-	UpBand   types.Float64Slice
-	DownBand types.Float64Slice
+	SMA      types.Float64RingBuffer
+	StdDev   types.Float64RingBuffer
+	UpBand   types.Float64RingBuffer
+	DownBand types.Float64RingBuffer
 
 	EndTime time.Time
 
@@ -58,34 +58,19 @@ func (inc *BOLL) GetStdDev() types.Series {
 }
 
 func (inc *BOLL) LastUpBand() float64 {
-	if len(inc.UpBand) == 0 {
-		return 0.0
-	}
-
-	return inc.UpBand[len(inc.UpBand)-1]
+	return inc.UpBand.Last()
 }
 
 func (inc *BOLL) LastDownBand() float64 {
-	if len(inc.DownBand) == 0 {
-		return 0.0
-	}
-
-	return inc.DownBand[len(inc.DownBand)-1]
+	return inc.DownBand.Last()
 }
 
 func (inc *BOLL) LastStdDev() float64 {
-	if len(inc.StdDev) == 0 {
-		return 0.0
-	}
-
-	return inc.StdDev[len(inc.StdDev)-1]
+	return inc.StdDev.Last()
 }
 
 func (inc *BOLL) LastSMA() float64 {
-	if len(inc.SMA) > 0 {
-		return inc.SMA[len(inc.SMA)-1]
-	}
-	return 0.0
+	return inc.SMA.Last()
 }
 
 func (inc *BOLL) calculateAndUpdate(kLines []types.KLine) {
@@ -148,3 +133,11 @@ func (inc *BOLL) handleKLineWindowUpdate(interval types.Interval, window types.K
 func (inc *BOLL) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *BOLL) WarmUpBars() int {
+	return inc.Window
+}
+
+func (inc *BOLL) IsReady() bool {
+	return inc.SMA.Length() > 0
+}
@@ -13,35 +13,29 @@ import (
 //go:generate callbackgen -type CCI
 type CCI struct {
 	types.IntervalWindow
-	Input        types.Float64Slice
-	TypicalPrice types.Float64Slice
-	MA           types.Float64Slice
-	Values       types.Float64Slice
+	Input        types.Float64RingBuffer
+	TypicalPrice types.Float64RingBuffer
+	MA           types.Float64RingBuffer
+	Values       types.Float64RingBuffer
 
 	UpdateCallbacks []func(value float64)
 }
 
 func (inc *CCI) Update(value float64) {
-	if len(inc.TypicalPrice) == 0 {
+	if inc.TypicalPrice.Length() == 0 {
 		inc.TypicalPrice.Push(value)
 		inc.Input.Push(value)
 		return
-	} else if len(inc.TypicalPrice) > MaxNumOfEWMA {
-		inc.TypicalPrice = inc.TypicalPrice[MaxNumOfEWMATruncateSize-1:]
-		inc.Input = inc.Input[MaxNumOfEWMATruncateSize-1:]
 	}
 
 	inc.Input.Push(value)
 	tp := inc.TypicalPrice.Last() - inc.Input.Index(inc.Window) + value
 	inc.TypicalPrice.Push(tp)
-	if len(inc.Input) < inc.Window {
+	if inc.Input.Length() < inc.Window {
 		return
 	}
 	ma := tp / float64(inc.Window)
 	inc.MA.Push(ma)
-	if len(inc.MA) > MaxNumOfEWMA {
-		inc.MA = inc.MA[MaxNumOfEWMATruncateSize-1:]
-	}
 	md := 0.
 	for i := 0; i < inc.Window; i++ {
 		diff := inc.Input.Index(i) - ma
@@ -52,27 +46,18 @@ func (inc *CCI) Update(value float64) {
 	cci := (value - ma) / (0.015 * md)
 
 	inc.Values.Push(cci)
-	if len(inc.Values) > MaxNumOfEWMA {
-		inc.Values = inc.Values[MaxNumOfEWMATruncateSize-1:]
-	}
 }
 
 func (inc *CCI) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0
-	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *CCI) Index(i int) float64 {
-	if i >= len(inc.Values) {
-		return 0
-	}
-	return inc.Values[len(inc.Values)-1-i]
+	return inc.Values.Index(i)
 }
 
 func (inc *CCI) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 var _ types.Series = &CCI{}
@@ -103,3 +88,11 @@ func (inc *CCI) handleKLineWindowUpdate(interval types.Interval, window types.KL
 func (inc *CCI) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *CCI) WarmUpBars() int {
+	return inc.Window
+}
+
+func (inc *CCI) IsReady() bool {
+	return inc.Values.Length() > 0
+}
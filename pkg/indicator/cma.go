@@ -9,7 +9,7 @@ import (
 //go:generate callbackgen -type CA
 type CA struct {
 	Interval        types.Interval
-	Values          types.Float64Slice
+	Values          types.Float64RingBuffer
 	length          float64
 	UpdateCallbacks []func(value float64)
 }
@@ -18,27 +18,18 @@ func (inc *CA) Update(x float64) {
 	newVal := (inc.Values.Last()*inc.length + x) / (inc.length + 1.)
 	inc.length += 1
 	inc.Values.Push(newVal)
-	if len(inc.Values) > MaxNumOfEWMA {
-		inc.Values = inc.Values[MaxNumOfEWMATruncateSize-1:]
-	}
 }
 
 func (inc *CA) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0
-	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *CA) Index(i int) float64 {
-	if i >= len(inc.Values) {
-		return 0
-	}
-	return inc.Values[len(inc.Values)-1-i]
+	return inc.Values.Index(i)
 }
 
 func (inc *CA) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 var _ types.Series = &CA{}
@@ -61,3 +52,11 @@ func (inc *CA) handleKLineWindowUpdate(interval types.Interval, window types.KLi
 func (inc *CA) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *CA) WarmUpBars() int {
+	return 1
+}
+
+func (inc *CA) IsReady() bool {
+	return inc.Values.Length() > 0
+}
@@ -10,7 +10,7 @@ import (
 //go:generate callbackgen -type DEMA
 type DEMA struct {
 	types.IntervalWindow
-	Values types.Float64Slice
+	Values types.Float64RingBuffer
 	a1     *EWMA
 	a2     *EWMA
 
@@ -18,7 +18,7 @@ type DEMA struct {
 }
 
 func (inc *DEMA) Update(value float64) {
-	if len(inc.Values) == 0 {
+	if inc.Values.Length() == 0 {
 		inc.a1 = &EWMA{IntervalWindow: types.IntervalWindow{inc.Interval, inc.Window}}
 		inc.a2 = &EWMA{IntervalWindow: types.IntervalWindow{inc.Interval, inc.Window}}
 	}
@@ -26,9 +26,6 @@ func (inc *DEMA) Update(value float64) {
 	inc.a1.Update(value)
 	inc.a2.Update(inc.a1.Last())
 	inc.Values.Push(2*inc.a1.Last() - inc.a2.Last())
-	if len(inc.Values) > MaxNumOfEWMA {
-		inc.Values = inc.Values[MaxNumOfEWMATruncateSize-1:]
-	}
 }
 
 func (inc *DEMA) Last() float64 {
@@ -36,14 +33,11 @@ func (inc *DEMA) Last() float64 {
 }
 
 func (inc *DEMA) Index(i int) float64 {
-	if len(inc.Values)-i-1 >= 0 {
-		return inc.Values[len(inc.Values)-1-i]
-	}
-	return 0
+	return inc.Values.Index(i)
 }
 
 func (inc *DEMA) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 var _ types.Series = &DEMA{}
@@ -71,3 +65,11 @@ func (inc *DEMA) handleKLineWindowUpdate(interval types.Interval, window types.K
 func (inc *DEMA) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *DEMA) WarmUpBars() int {
+	return recursiveWarmUpFactor * inc.Window
+}
+
+func (inc *DEMA) IsReady() bool {
+	return hasEnoughValues(&inc.Values, inc.WarmUpBars())
+}
@@ -0,0 +1,95 @@
+package indicator
+
+import (
+	"time"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+/*
+donchian implements the Donchian channels indicator, the up band is the highest high of the window,
+the down band is the lowest low of the window, and the middle band is the average of them.
+
+Donchian Channels
+- https://www.investopedia.com/terms/d/donchianchannels.asp
+*/
+//go:generate callbackgen -type Donchian
+type Donchian struct {
+	types.IntervalWindow
+
+	UpBand   types.Float64RingBuffer
+	MidBand  types.Float64RingBuffer
+	DownBand types.Float64RingBuffer
+
+	HighValues types.Float64RingBuffer
+	LowValues  types.Float64RingBuffer
+
+	EndTime         time.Time
+	UpdateCallbacks []func(upBand, midBand, downBand float64)
+}
+
+func (inc *Donchian) Update(high, low float64) {
+	inc.HighValues.Push(high)
+	inc.LowValues.Push(low)
+
+	if inc.HighValues.Length() < inc.Window {
+		return
+	}
+
+	upBand := inc.HighValues.Tail(inc.Window).Max()
+	downBand := inc.LowValues.Tail(inc.Window).Min()
+
+	inc.UpBand.Push(upBand)
+	inc.DownBand.Push(downBand)
+	inc.MidBand.Push((upBand + downBand) / 2.0)
+}
+
+func (inc *Donchian) GetUpBand() types.Series {
+	return &inc.UpBand
+}
+
+func (inc *Donchian) GetMidBand() types.Series {
+	return &inc.MidBand
+}
+
+func (inc *Donchian) GetDownBand() types.Series {
+	return &inc.DownBand
+}
+
+func (inc *Donchian) calculateAndUpdate(kLines []types.KLine) {
+	if len(kLines) == 0 {
+		return
+	}
+
+	for _, k := range kLines {
+		if inc.EndTime != zeroTime && !k.EndTime.After(inc.EndTime) {
+			continue
+		}
+		inc.Update(k.High.Float64(), k.Low.Float64())
+	}
+
+	if inc.UpBand.Length() > 0 {
+		inc.EmitUpdate(inc.UpBand.Last(), inc.MidBand.Last(), inc.DownBand.Last())
+	}
+	inc.EndTime = kLines[len(kLines)-1].EndTime.Time()
+}
+
+func (inc *Donchian) handleKLineWindowUpdate(interval types.Interval, window types.KLineWindow) {
+	if inc.Interval != interval {
+		return
+	}
+
+	inc.calculateAndUpdate(window)
+}
+
+func (inc *Donchian) Bind(updater KLineWindowUpdater) {
+	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
+}
+
+func (inc *Donchian) WarmUpBars() int {
+	return inc.Window
+}
+
+func (inc *Donchian) IsReady() bool {
+	return inc.UpBand.Length() > 0
+}
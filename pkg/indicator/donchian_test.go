@@ -0,0 +1,32 @@
+package indicator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+/*
+python:
+
+up, down = max(high[-20:]), min(low[-20:])
+mid = (up + down) / 2
+*/
+
+func TestDonchian(t *testing.T) {
+	var Delta = 1e-9
+	kLines := buildTrendTestKLines()
+
+	donchian := &Donchian{IntervalWindow: types.IntervalWindow{Window: 20}}
+	donchian.calculateAndUpdate(kLines[:19])
+	assert.Equal(t, 0, donchian.UpBand.Length())
+
+	donchian.calculateAndUpdate(kLines)
+	assert.Equal(t, len(kLines)-19, donchian.GetUpBand().Length())
+	assert.InDelta(t, 8138.0, donchian.GetUpBand().Last(), Delta)
+	assert.InDelta(t, 8147.0, donchian.GetUpBand().Index(1), Delta)
+	assert.InDelta(t, 8089.0, donchian.GetDownBand().Last(), Delta)
+	assert.InDelta(t, 8113.5, donchian.GetMidBand().Last(), Delta)
+}
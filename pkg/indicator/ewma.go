@@ -9,14 +9,10 @@ import (
 	"github.com/c9s/bbgo/pkg/types"
 )
 
-// These numbers should be aligned with bbgo MaxNumOfKLines and MaxNumOfKLinesTruncate
-const MaxNumOfEWMA = 5_000
-const MaxNumOfEWMATruncateSize = 100
-
 //go:generate callbackgen -type EWMA
 type EWMA struct {
 	types.IntervalWindow
-	Values       types.Float64Slice
+	Values       types.Float64RingBuffer
 	LastOpenTime time.Time
 
 	UpdateCallbacks []func(value float64)
@@ -25,11 +21,9 @@ type EWMA struct {
 func (inc *EWMA) Update(value float64) {
 	var multiplier = 2.0 / float64(1+inc.Window)
 
-	if len(inc.Values) == 0 {
+	if inc.Values.Length() == 0 {
 		inc.Values.Push(value)
 		return
-	} else if len(inc.Values) > MaxNumOfEWMA {
-		inc.Values = inc.Values[MaxNumOfEWMATruncateSize-1:]
 	}
 
 	ema := (1-multiplier)*inc.Last() + multiplier*value
@@ -37,23 +31,15 @@ func (inc *EWMA) Update(value float64) {
 }
 
 func (inc *EWMA) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0
-	}
-
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *EWMA) Index(i int) float64 {
-	if i >= len(inc.Values) {
-		return 0
-	}
-
-	return inc.Values[len(inc.Values)-1-i]
+	return inc.Values.Index(i)
 }
 
 func (inc *EWMA) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 func (inc *EWMA) calculateAndUpdate(allKLines []types.KLine) {
@@ -68,18 +54,15 @@ func (inc *EWMA) calculateAndUpdate(allKLines []types.KLine) {
 
 	// init the values fromNthK the kline data
 	var fromNthK = 1
-	if len(inc.Values) == 0 {
+	if inc.Values.Length() == 0 {
 		// for the first value, we should use the close price
-		inc.Values = []float64{priceF(allKLines[0])}
+		inc.Values.Push(priceF(allKLines[0]))
+		inc.LastOpenTime = allKLines[0].StartTime.Time()
 	} else {
-		if len(inc.Values) >= MaxNumOfEWMA {
-			inc.Values = inc.Values[MaxNumOfEWMATruncateSize-1:]
-		}
-
-		fromNthK = len(inc.Values)
+		fromNthK = dataLen
 
-		// update ewma with the existing values
-		for i := dataLen - 1; i > 0; i-- {
+		// update ewma with the klines after the last updated kline
+		for i := dataLen - 1; i >= 0; i-- {
 			var k = allKLines[i]
 			if k.StartTime.After(inc.LastOpenTime) {
 				fromNthK = i
@@ -91,18 +74,13 @@ func (inc *EWMA) calculateAndUpdate(allKLines []types.KLine) {
 
 	for i := fromNthK; i < dataLen; i++ {
 		var k = allKLines[i]
-		var ewma = priceF(k)*multiplier + (1-multiplier)*inc.Values[i-1]
+		var ewma = priceF(k)*multiplier + (1-multiplier)*inc.Values.Last()
 		inc.Values.Push(ewma)
 		inc.LastOpenTime = k.StartTime.Time()
 		inc.EmitUpdate(ewma)
 	}
 
-	if len(inc.Values) != dataLen {
-		// check error
-		log.Warnf("%s EMA (%d) value length (%d) != kline window length (%d)", inc.Interval, inc.Window, len(inc.Values), dataLen)
-	}
-
-	v1 := math.Floor(inc.Values[len(inc.Values)-1]*100.0) / 100.0
+	v1 := math.Floor(inc.Values.Last()*100.0) / 100.0
 	v2 := math.Floor(CalculateKLinesEMA(allKLines, priceF, inc.Window)*100.0) / 100.0
 	if v1 != v2 {
 		log.Warnf("ACCUMULATED %s EMA (%d) %f != EMA %f", inc.Interval, inc.Window, v1, v2)
@@ -137,3 +115,12 @@ func (inc *EWMA) Bind(updater KLineWindowUpdater) {
 }
 
 var _ types.Series = &EWMA{}
+
+// WarmUpBars returns the bars that EWMA needs to converge, see recursiveWarmUpFactor
+func (inc *EWMA) WarmUpBars() int {
+	return recursiveWarmUpFactor * inc.Window
+}
+
+func (inc *EWMA) IsReady() bool {
+	return hasEnoughValues(&inc.Values, inc.WarmUpBars())
+}
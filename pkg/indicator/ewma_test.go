@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"math"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 
 	"github.com/c9s/bbgo/pkg/fixedpoint"
 	"github.com/c9s/bbgo/pkg/types"
@@ -1077,3 +1080,43 @@ func Test_calculateEWMA(t *testing.T) {
 		})
 	}
 }
+
+func TestEWMA_calculateAndUpdate(t *testing.T) {
+	var input []fixedpoint.Value
+	if err := json.Unmarshal(ethusdt5m, &input); err != nil {
+		panic(err)
+	}
+
+	var startTime = time.Date(2020, 12, 5, 0, 0, 0, 0, time.UTC)
+	var klines []types.KLine
+	for i, p := range input[:100] {
+		klines = append(klines, types.KLine{
+			StartTime: types.Time(startTime.Add(time.Duration(i) * 5 * time.Minute)),
+			Close:     p,
+		})
+	}
+
+	ewma := &EWMA{IntervalWindow: types.IntervalWindow{Interval: types.Interval5m, Window: 9}}
+	ewma.calculateAndUpdate(klines[:90])
+	assert.Equal(t, 90, ewma.Length())
+
+	// only the new klines are calculated
+	for i := 91; i <= len(klines); i++ {
+		ewma.calculateAndUpdate(klines[:i])
+	}
+
+	assert.Equal(t, 100, ewma.Length())
+	assert.InDelta(t, CalculateKLinesEMA(klines, KLineClosePriceMapper, 9), ewma.Last(), 1e-9)
+}
+
+func TestEWMA_Bounded(t *testing.T) {
+	ewma := &EWMA{IntervalWindow: types.IntervalWindow{Interval: types.Interval5m, Window: 9}}
+	for i := 0; i < types.DefaultFloat64RingBufferCapacity*2; i++ {
+		ewma.Update(1.0)
+	}
+
+	assert.Equal(t, types.DefaultFloat64RingBufferCapacity, ewma.Length())
+	assert.Equal(t, 1.0, ewma.Last())
+	assert.Equal(t, 1.0, ewma.Index(ewma.Length()-1))
+	assert.Equal(t, 0.0, ewma.Index(ewma.Length()))
+}
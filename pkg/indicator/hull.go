@@ -64,6 +64,7 @@ func (inc *HULL) calculateAndUpdate(allKLines []types.KLine) {
 		}
 		if doable {
 			inc.Update(k.Close.Float64())
+			inc.ma1.LastOpenTime = k.StartTime.Time()
 			inc.EmitUpdate(inc.Last())
 		}
 	}
@@ -80,3 +81,11 @@ func (inc *HULL) handleKLineWindowUpdate(interval types.Interval, window types.K
 func (inc *HULL) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *HULL) WarmUpBars() int {
+	return recursiveWarmUpFactor * inc.Window
+}
+
+func (inc *HULL) IsReady() bool {
+	return inc.result != nil && hasEnoughValues(&inc.result.Values, inc.WarmUpBars())
+}
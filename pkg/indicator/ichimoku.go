@@ -0,0 +1,130 @@
+package indicator
+
+import (
+	"time"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const defaultIchimokuBasePeriod = 26
+const defaultIchimokuSpanBPeriod = 52
+
+/*
+ichimoku implements the Ichimoku Kinko Hyo (Ichimoku Cloud) indicator.
+The window is the period of the conversion line, generally it's 9.
+
+The leading spans are stored at the bar that they are calculated, they are plotted BasePeriod bars ahead,
+so the cloud of the current bar is SpanA.Index(BasePeriod) and SpanB.Index(BasePeriod).
+The lagging span is the close price plotted BasePeriod bars behind.
+
+Ichimoku Cloud
+- https://www.investopedia.com/terms/i/ichimoku-cloud.asp
+*/
+//go:generate callbackgen -type ICHIMOKU
+type ICHIMOKU struct {
+	types.IntervalWindow
+
+	// BasePeriod is the period of the base line (kijun-sen), 26 by default
+	BasePeriod int
+
+	// SpanBPeriod is the period of the leading span B (senkou span B), 52 by default
+	SpanBPeriod int
+
+	ConversionLine types.Float64RingBuffer
+	BaseLine       types.Float64RingBuffer
+	SpanA          types.Float64RingBuffer
+	SpanB          types.Float64RingBuffer
+	LaggingSpan    types.Float64RingBuffer
+
+	HighValues types.Float64RingBuffer
+	LowValues  types.Float64RingBuffer
+
+	EndTime         time.Time
+	UpdateCallbacks []func(conversion, base, spanA, spanB float64)
+}
+
+func (inc *ICHIMOKU) Update(high, low, cloze float64) {
+	if inc.BasePeriod == 0 {
+		inc.BasePeriod = defaultIchimokuBasePeriod
+	}
+	if inc.SpanBPeriod == 0 {
+		inc.SpanBPeriod = defaultIchimokuSpanBPeriod
+	}
+
+	inc.HighValues.Push(high)
+	inc.LowValues.Push(low)
+
+	conversion := inc.midPrice(inc.Window)
+	base := inc.midPrice(inc.BasePeriod)
+
+	inc.ConversionLine.Push(conversion)
+	inc.BaseLine.Push(base)
+	inc.SpanA.Push((conversion + base) / 2.0)
+	inc.SpanB.Push(inc.midPrice(inc.SpanBPeriod))
+	inc.LaggingSpan.Push(cloze)
+}
+
+// midPrice returns the average of the highest high and the lowest low of the period
+func (inc *ICHIMOKU) midPrice(period int) float64 {
+	return (inc.HighValues.Tail(period).Max() + inc.LowValues.Tail(period).Min()) / 2.0
+}
+
+func (inc *ICHIMOKU) GetConversionLine() types.Series {
+	return &inc.ConversionLine
+}
+
+func (inc *ICHIMOKU) GetBaseLine() types.Series {
+	return &inc.BaseLine
+}
+
+func (inc *ICHIMOKU) GetSpanA() types.Series {
+	return &inc.SpanA
+}
+
+func (inc *ICHIMOKU) GetSpanB() types.Series {
+	return &inc.SpanB
+}
+
+func (inc *ICHIMOKU) GetLaggingSpan() types.Series {
+	return &inc.LaggingSpan
+}
+
+func (inc *ICHIMOKU) calculateAndUpdate(kLines []types.KLine) {
+	if len(kLines) == 0 {
+		return
+	}
+
+	for _, k := range kLines {
+		if inc.EndTime != zeroTime && !k.EndTime.After(inc.EndTime) {
+			continue
+		}
+		inc.Update(k.High.Float64(), k.Low.Float64(), k.Close.Float64())
+	}
+
+	inc.EmitUpdate(inc.ConversionLine.Last(), inc.BaseLine.Last(), inc.SpanA.Last(), inc.SpanB.Last())
+	inc.EndTime = kLines[len(kLines)-1].EndTime.Time()
+}
+
+func (inc *ICHIMOKU) handleKLineWindowUpdate(interval types.Interval, window types.KLineWindow) {
+	if inc.Interval != interval {
+		return
+	}
+
+	inc.calculateAndUpdate(window)
+}
+
+func (inc *ICHIMOKU) Bind(updater KLineWindowUpdater) {
+	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
+}
+
+// WarmUpBars returns the period of the leading span B, which is the longest period of the lines
+func (inc *ICHIMOKU) WarmUpBars() int {
+	if inc.SpanBPeriod == 0 {
+		return defaultIchimokuSpanBPeriod
+	}
+	return inc.SpanBPeriod
+}
+
+func (inc *ICHIMOKU) IsReady() bool {
+	return hasEnoughValues(&inc.HighValues, inc.WarmUpBars())
+}
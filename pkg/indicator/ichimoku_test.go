@@ -0,0 +1,36 @@
+package indicator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+/*
+python:
+
+def mid(i, p):
+    lo = max(0, i - p + 1)
+    return (max(high[lo:i+1]) + min(low[lo:i+1])) / 2
+
+conversion, base, span_b = mid(i, 9), mid(i, 26), mid(i, 52)
+span_a = (conversion + base) / 2
+*/
+
+func TestICHIMOKU(t *testing.T) {
+	var Delta = 1e-9
+	kLines := buildTrendTestKLines()
+
+	ichimoku := &ICHIMOKU{IntervalWindow: types.IntervalWindow{Window: 9}}
+	ichimoku.calculateAndUpdate(kLines)
+
+	assert.Equal(t, len(kLines), ichimoku.ConversionLine.Length())
+	assert.InDelta(t, 8102.0, ichimoku.GetConversionLine().Last(), Delta)
+	assert.InDelta(t, 8102.0, ichimoku.GetConversionLine().Index(1), Delta)
+	assert.InDelta(t, 8120.5, ichimoku.GetBaseLine().Last(), Delta)
+	assert.InDelta(t, 8111.25, ichimoku.GetSpanA().Last(), Delta)
+	assert.InDelta(t, 8170.5, ichimoku.GetSpanB().Last(), Delta)
+	assert.InDelta(t, kLines[len(kLines)-1].Close.Float64(), ichimoku.GetLaggingSpan().Last(), Delta)
+}
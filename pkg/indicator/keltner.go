@@ -0,0 +1,124 @@
+package indicator
+
+import (
+	"time"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const defaultKeltnerATRWindow = 10
+const defaultKeltnerMultiplier = 2.0
+
+/*
+keltner implements the Keltner channels indicator, the middle band is the EMA of the close price of the window,
+and the bands are the middle band plus and minus the multiple of the ATR.
+
+Keltner Channel
+- https://www.investopedia.com/terms/k/keltnerchannel.asp
+*/
+//go:generate callbackgen -type Keltner
+type Keltner struct {
+	types.IntervalWindow
+
+	// ATRWindow is the window of the ATR, 10 by default
+	ATRWindow int
+
+	// K is the multiple of the ATR, 2 by default
+	K float64
+
+	MidBand  types.Float64RingBuffer
+	UpBand   types.Float64RingBuffer
+	DownBand types.Float64RingBuffer
+
+	ewma *EWMA
+	atr  *ATR
+
+	EndTime         time.Time
+	UpdateCallbacks []func(midBand, upBand, downBand float64)
+}
+
+func (inc *Keltner) Update(high, low, cloze float64) {
+	if inc.ewma == nil {
+		if inc.ATRWindow == 0 {
+			inc.ATRWindow = defaultKeltnerATRWindow
+		}
+		if inc.K == 0 {
+			inc.K = defaultKeltnerMultiplier
+		}
+		inc.ewma = &EWMA{IntervalWindow: inc.IntervalWindow}
+		inc.atr = &ATR{IntervalWindow: types.IntervalWindow{Interval: inc.Interval, Window: inc.ATRWindow}}
+	}
+
+	inc.ewma.Update(cloze)
+	inc.atr.Update(high, low, cloze)
+
+	// the ATR is not ready until the window of the true ranges is filled
+	if inc.atr.RMA == nil || inc.atr.RMA.Sources.Length() < inc.ATRWindow {
+		return
+	}
+
+	mid := inc.ewma.Last()
+	band := inc.K * inc.atr.Last()
+	inc.MidBand.Push(mid)
+	inc.UpBand.Push(mid + band)
+	inc.DownBand.Push(mid - band)
+}
+
+func (inc *Keltner) GetMidBand() types.Series {
+	return &inc.MidBand
+}
+
+func (inc *Keltner) GetUpBand() types.Series {
+	return &inc.UpBand
+}
+
+func (inc *Keltner) GetDownBand() types.Series {
+	return &inc.DownBand
+}
+
+func (inc *Keltner) calculateAndUpdate(kLines []types.KLine) {
+	if len(kLines) == 0 {
+		return
+	}
+
+	for _, k := range kLines {
+		if inc.EndTime != zeroTime && !k.EndTime.After(inc.EndTime) {
+			continue
+		}
+		inc.Update(k.High.Float64(), k.Low.Float64(), k.Close.Float64())
+	}
+
+	if inc.MidBand.Length() > 0 {
+		inc.EmitUpdate(inc.MidBand.Last(), inc.UpBand.Last(), inc.DownBand.Last())
+	}
+	inc.EndTime = kLines[len(kLines)-1].EndTime.Time()
+}
+
+func (inc *Keltner) handleKLineWindowUpdate(interval types.Interval, window types.KLineWindow) {
+	if inc.Interval != interval {
+		return
+	}
+
+	inc.calculateAndUpdate(window)
+}
+
+func (inc *Keltner) Bind(updater KLineWindowUpdater) {
+	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
+}
+
+// WarmUpBars returns the bars that both the EWMA middle band and the ATR need
+func (inc *Keltner) WarmUpBars() int {
+	atrWindow := inc.ATRWindow
+	if atrWindow == 0 {
+		atrWindow = defaultKeltnerATRWindow
+	}
+
+	if bars := recursiveWarmUpFactor * inc.Window; bars > atrWindow+1 {
+		return bars
+	}
+	return atrWindow + 1
+}
+
+func (inc *Keltner) IsReady() bool {
+	return inc.ewma != nil && inc.ewma.IsReady() && inc.MidBand.Length() > 0
+}
@@ -0,0 +1,32 @@
+package indicator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+/*
+python:
+
+mid = ema(close, 20) # seeded by the first close
+atr = rma(true_range, 10) # seeded by the SMA of the first 10 true ranges
+up, down = mid + 2 * atr, mid - 2 * atr
+*/
+
+func TestKeltner(t *testing.T) {
+	var Delta = 1e-6
+	kLines := buildTrendTestKLines()
+
+	keltner := &Keltner{IntervalWindow: types.IntervalWindow{Window: 20}}
+	keltner.calculateAndUpdate(kLines)
+
+	// the first ATR is calculated at the 11th kline
+	assert.Equal(t, len(kLines)-10, keltner.GetMidBand().Length())
+	assert.InDelta(t, 8114.605925574752, keltner.GetMidBand().Last(), Delta)
+	assert.InDelta(t, 8116.038128266831, keltner.GetMidBand().Index(1), Delta)
+	assert.InDelta(t, 8140.490164476199, keltner.GetUpBand().Last(), Delta)
+	assert.InDelta(t, 8088.721686673305, keltner.GetDownBand().Last(), Delta)
+}
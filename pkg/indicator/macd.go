@@ -18,11 +18,11 @@ type MACD struct {
 	types.IntervalWindow     // 9
 	ShortPeriod          int // 12
 	LongPeriod           int // 26
-	Values               types.Float64Slice
+	Values               types.Float64RingBuffer
 	FastEWMA             EWMA
 	SlowEWMA             EWMA
 	SignalLine           EWMA
-	Histogram            types.Float64Slice
+	Histogram            types.Float64RingBuffer
 
 	EndTime time.Time
 
@@ -30,7 +30,7 @@ type MACD struct {
 }
 
 func (inc *MACD) Update(x float64) {
-	if len(inc.Values) == 0 {
+	if inc.Values.Length() == 0 {
 		inc.FastEWMA = EWMA{IntervalWindow: types.IntervalWindow{Window: inc.ShortPeriod}}
 		inc.SlowEWMA = EWMA{IntervalWindow: types.IntervalWindow{Window: inc.LongPeriod}}
 		inc.SignalLine = EWMA{IntervalWindow: types.IntervalWindow{Window: inc.Window}}
@@ -55,7 +55,7 @@ func (inc *MACD) calculateMACD(kLines []types.KLine, priceF KLinePriceMapper) fl
 	for _, kline := range kLines {
 		inc.Update(kline.Close.Float64())
 	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *MACD) calculateAndUpdate(kLines []types.KLine) {
@@ -70,7 +70,7 @@ func (inc *MACD) calculateAndUpdate(kLines []types.KLine) {
 		inc.Update(k.Close.Float64())
 	}
 
-	inc.EmitUpdate(inc.Values[len(inc.Values)-1])
+	inc.EmitUpdate(inc.Values.Last())
 	inc.EndTime = kLines[len(kLines)-1].EndTime.Time()
 }
 
@@ -91,22 +91,15 @@ type MACDValues struct {
 }
 
 func (inc *MACDValues) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0.0
-	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *MACDValues) Index(i int) float64 {
-	length := len(inc.Values)
-	if length == 0 || length-1-i < 0 {
-		return 0.0
-	}
-	return inc.Values[length-1+i]
+	return inc.Values.Index(i)
 }
 
 func (inc *MACDValues) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 func (inc *MACD) MACD() types.Series {
@@ -116,3 +109,12 @@ func (inc *MACD) MACD() types.Series {
 func (inc *MACD) Singals() types.Series {
 	return &inc.SignalLine
 }
+
+// WarmUpBars returns the bars that the slow EWMA and then the signal line need to converge
+func (inc *MACD) WarmUpBars() int {
+	return recursiveWarmUpFactor * (inc.LongPeriod + inc.Window)
+}
+
+func (inc *MACD) IsReady() bool {
+	return hasEnoughValues(&inc.Values, inc.WarmUpBars())
+}
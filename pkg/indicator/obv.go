@@ -15,7 +15,7 @@ On-Balance Volume (OBV) Definition
 //go:generate callbackgen -type OBV
 type OBV struct {
 	types.IntervalWindow
-	Values   types.Float64Slice
+	Values   types.Float64RingBuffer
 	PrePrice float64
 
 	EndTime         time.Time
@@ -23,7 +23,7 @@ type OBV struct {
 }
 
 func (inc *OBV) Update(price, volume float64) {
-	if len(inc.Values) == 0 {
+	if inc.Values.Length() == 0 {
 		inc.PrePrice = price
 		inc.Values.Push(volume)
 		return
@@ -37,10 +37,7 @@ func (inc *OBV) Update(price, volume float64) {
 }
 
 func (inc *OBV) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0.0
-	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *OBV) calculateAndUpdate(kLines []types.KLine) {
@@ -65,3 +62,11 @@ func (inc *OBV) handleKLineWindowUpdate(interval types.Interval, window types.KL
 func (inc *OBV) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *OBV) WarmUpBars() int {
+	return 1
+}
+
+func (inc *OBV) IsReady() bool {
+	return inc.Values.Length() > 0
+}
@@ -52,8 +52,8 @@ func Test_calculateOBV(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			obv := OBV{IntervalWindow: types.IntervalWindow{Window: tt.window}}
 			obv.calculateAndUpdate(tt.kLines)
-			assert.Equal(t, len(obv.Values), len(tt.want))
-			for i, v := range obv.Values {
+			assert.Equal(t, obv.Values.Length(), len(tt.want))
+			for i, v := range obv.Values.Slice() {
 				assert.InDelta(t, v, tt.want[i], Delta)
 			}
 		})
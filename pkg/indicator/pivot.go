@@ -15,8 +15,8 @@ type Pivot struct {
 	types.IntervalWindow
 
 	// Values
-	Lows  types.Float64Slice // higher low
-	Highs types.Float64Slice // lower high
+	Lows  types.Float64RingBuffer // higher low
+	Highs types.Float64RingBuffer // lower high
 
 	EndTime time.Time
 
@@ -24,17 +24,11 @@ type Pivot struct {
 }
 
 func (inc *Pivot) LastLow() float64 {
-	if len(inc.Lows) == 0 {
-		return 0.0
-	}
-	return inc.Lows[len(inc.Lows)-1]
+	return inc.Lows.Last()
 }
 
 func (inc *Pivot) LastHigh() float64 {
-	if len(inc.Highs) == 0 {
-		return 0.0
-	}
-	return inc.Highs[len(inc.Highs)-1]
+	return inc.Highs.Last()
 }
 
 func (inc *Pivot) calculateAndUpdate(klines []types.KLine) {
@@ -59,13 +53,6 @@ func (inc *Pivot) calculateAndUpdate(klines []types.KLine) {
 	inc.Lows.Push(l)
 	inc.Highs.Push(h)
 
-	if len(inc.Lows) > MaxNumOfVOL {
-		inc.Lows = inc.Lows[MaxNumOfVOLTruncateSize-1:]
-	}
-	if len(inc.Highs) > MaxNumOfVOL {
-		inc.Highs = inc.Highs[MaxNumOfVOLTruncateSize-1:]
-	}
-
 	inc.EndTime = klines[end].GetEndTime().Time()
 
 	inc.EmitUpdate(l, h)
@@ -115,3 +102,11 @@ func KLineLowPriceMapper(k types.KLine) float64 {
 func KLineHighPriceMapper(k types.KLine) float64 {
 	return k.High.Float64()
 }
+
+func (inc *Pivot) WarmUpBars() int {
+	return inc.Window
+}
+
+func (inc *Pivot) IsReady() bool {
+	return inc.Lows.Length() > 0
+}
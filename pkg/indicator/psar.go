@@ -0,0 +1,195 @@
+package indicator
+
+import (
+	"math"
+	"time"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const defaultPSARAccelerationStart = 0.02
+const defaultPSARAccelerationIncrement = 0.02
+const defaultPSARAccelerationMax = 0.2
+
+/*
+psar implements the parabolic stop and reverse (Parabolic SAR) indicator, the window is not used.
+The calculation follows the TradingView ta.sar function.
+
+Parabolic SAR
+- https://www.investopedia.com/terms/p/parabolicindicator.asp
+*/
+//go:generate callbackgen -type PSAR
+type PSAR struct {
+	types.IntervalWindow
+
+	// AccelerationStart is the initial acceleration factor, 0.02 by default
+	AccelerationStart float64
+
+	// AccelerationIncrement is the increment of the acceleration factor on each new extreme point, 0.02 by default
+	AccelerationIncrement float64
+
+	// AccelerationMax is the max acceleration factor, 0.2 by default
+	AccelerationMax float64
+
+	Values types.Float64RingBuffer
+
+	// Direction is 1 when the SAR is below the price (up trend), and -1 when the SAR is above the price
+	Direction types.Float64RingBuffer
+
+	HighValues  types.Float64RingBuffer
+	LowValues   types.Float64RingBuffer
+	CloseValues types.Float64RingBuffer
+
+	sar          float64
+	extremePoint float64
+	acceleration float64
+	isBelow      bool
+
+	EndTime         time.Time
+	UpdateCallbacks []func(value float64)
+}
+
+func (inc *PSAR) Update(high, low, cloze float64) {
+	if inc.AccelerationStart == 0 {
+		inc.AccelerationStart = defaultPSARAccelerationStart
+	}
+	if inc.AccelerationIncrement == 0 {
+		inc.AccelerationIncrement = defaultPSARAccelerationIncrement
+	}
+	if inc.AccelerationMax == 0 {
+		inc.AccelerationMax = defaultPSARAccelerationMax
+	}
+
+	inc.HighValues.Push(high)
+	inc.LowValues.Push(low)
+	inc.CloseValues.Push(cloze)
+
+	length := inc.CloseValues.Length()
+	if length < 2 {
+		return
+	}
+
+	isFirstTrendBar := false
+	if inc.Values.Length() == 0 {
+		if cloze > inc.CloseValues.Index(1) {
+			inc.isBelow = true
+			inc.extremePoint = high
+			inc.sar = inc.LowValues.Index(1)
+		} else {
+			inc.isBelow = false
+			inc.extremePoint = low
+			inc.sar = inc.HighValues.Index(1)
+		}
+		isFirstTrendBar = true
+		inc.acceleration = inc.AccelerationStart
+	}
+
+	inc.sar += inc.acceleration * (inc.extremePoint - inc.sar)
+
+	// reverse the trend when the price crosses the SAR
+	if inc.isBelow {
+		if inc.sar > low {
+			isFirstTrendBar = true
+			inc.isBelow = false
+			inc.sar = math.Max(high, inc.extremePoint)
+			inc.extremePoint = low
+			inc.acceleration = inc.AccelerationStart
+		}
+	} else {
+		if inc.sar < high {
+			isFirstTrendBar = true
+			inc.isBelow = true
+			inc.sar = math.Min(low, inc.extremePoint)
+			inc.extremePoint = high
+			inc.acceleration = inc.AccelerationStart
+		}
+	}
+
+	if !isFirstTrendBar {
+		if inc.isBelow {
+			if high > inc.extremePoint {
+				inc.extremePoint = high
+				inc.acceleration = math.Min(inc.acceleration+inc.AccelerationIncrement, inc.AccelerationMax)
+			}
+		} else {
+			if low < inc.extremePoint {
+				inc.extremePoint = low
+				inc.acceleration = math.Min(inc.acceleration+inc.AccelerationIncrement, inc.AccelerationMax)
+			}
+		}
+	}
+
+	// the SAR can not go beyond the range of the previous two bars
+	if inc.isBelow {
+		inc.sar = math.Min(inc.sar, inc.LowValues.Index(1))
+		if length > 2 {
+			inc.sar = math.Min(inc.sar, inc.LowValues.Index(2))
+		}
+		inc.Direction.Push(1)
+	} else {
+		inc.sar = math.Max(inc.sar, inc.HighValues.Index(1))
+		if length > 2 {
+			inc.sar = math.Max(inc.sar, inc.HighValues.Index(2))
+		}
+		inc.Direction.Push(-1)
+	}
+
+	inc.Values.Push(inc.sar)
+}
+
+func (inc *PSAR) Last() float64 {
+	return inc.Values.Last()
+}
+
+func (inc *PSAR) Index(i int) float64 {
+	return inc.Values.Index(i)
+}
+
+func (inc *PSAR) Length() int {
+	return inc.Values.Length()
+}
+
+var _ types.Series = &PSAR{}
+
+// GetDirection returns the series of the trend directions, 1 is the up trend and -1 is the down trend
+func (inc *PSAR) GetDirection() types.Series {
+	return &inc.Direction
+}
+
+func (inc *PSAR) calculateAndUpdate(kLines []types.KLine) {
+	if len(kLines) == 0 {
+		return
+	}
+
+	for _, k := range kLines {
+		if inc.EndTime != zeroTime && !k.EndTime.After(inc.EndTime) {
+			continue
+		}
+		inc.Update(k.High.Float64(), k.Low.Float64(), k.Close.Float64())
+	}
+
+	if inc.Values.Length() > 0 {
+		inc.EmitUpdate(inc.Last())
+	}
+	inc.EndTime = kLines[len(kLines)-1].EndTime.Time()
+}
+
+func (inc *PSAR) handleKLineWindowUpdate(interval types.Interval, window types.KLineWindow) {
+	if inc.Interval != interval {
+		return
+	}
+
+	inc.calculateAndUpdate(window)
+}
+
+func (inc *PSAR) Bind(updater KLineWindowUpdater) {
+	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
+}
+
+func (inc *PSAR) WarmUpBars() int {
+	return 2
+}
+
+func (inc *PSAR) IsReady() bool {
+	return inc.Values.Length() > 0
+}
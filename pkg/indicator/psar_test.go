@@ -0,0 +1,32 @@
+package indicator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+/*
+python, the same steps as the TradingView pine_sar function with start = 0.02, inc = 0.02 and max = 0.2
+*/
+
+func TestPSAR(t *testing.T) {
+	var Delta = 1e-6
+	kLines := buildTrendTestKLines()
+
+	psar := &PSAR{IntervalWindow: types.IntervalWindow{Window: 1}}
+	psar.calculateAndUpdate(kLines)
+
+	assert.Equal(t, len(kLines)-1, psar.Length())
+	assert.InDelta(t, 8113.470992, psar.Last(), Delta)
+	assert.InDelta(t, 8113.9704, psar.Index(1), Delta)
+	assert.InDelta(t, 8114.48, psar.Index(2), Delta)
+	assert.InDelta(t, 8115.0, psar.Index(3), Delta)
+	assert.InDelta(t, 8098.665893, psar.Index(4), Delta)
+
+	// the trend is reversed at the 4th last kline
+	assert.Equal(t, -1.0, psar.GetDirection().Index(3))
+	assert.Equal(t, 1.0, psar.GetDirection().Index(4))
+}
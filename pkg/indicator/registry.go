@@ -0,0 +1,266 @@
+package indicator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// Indicator is the indicator that is calculated from the kline windows of the KLineWindowUpdater
+type Indicator interface {
+	Bind(updater KLineWindowUpdater)
+}
+
+// Params are the parameters of the indicator constructors
+type Params struct {
+	types.IntervalWindow
+
+	// Options are the extra parameters by the names, for example, "k" of BOLL
+	Options map[string]float64
+}
+
+// Option returns the option value of the name, or the default value if the option is not set
+func (p Params) Option(name string, defaultValue float64) float64 {
+	if v, ok := p.Options[name]; ok {
+		return v
+	}
+	return defaultValue
+}
+
+// String returns the canonical string of the params, the options are sorted by the names
+func (p Params) String() string {
+	var names []string
+	for name := range p.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(p.IntervalWindow.String())
+	for _, name := range names {
+		b.WriteString(" " + name + "=" + strconv.FormatFloat(p.Options[name], 'f', -1, 64))
+	}
+	return b.String()
+}
+
+// Constructor creates the indicator of the params, the indicator is not bound yet
+type Constructor func(params Params) Indicator
+
+type registryEntry struct {
+	constructor Constructor
+	defaults    map[string]float64
+}
+
+var registryMutex sync.RWMutex
+var registry = make(map[string]registryEntry)
+
+// Register registers the indicator constructor of the name, the name is case-insensitive.
+// The default options are filled into the params before the constructor is called.
+func Register(name string, constructor Constructor, defaults map[string]float64) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	key := strings.ToLower(name)
+	if _, ok := registry[key]; ok {
+		panic(fmt.Errorf("indicator %s is already registered", name))
+	}
+
+	registry[key] = registryEntry{constructor: constructor, defaults: defaults}
+}
+
+// Normalize fills the default options of the registered indicator into the params,
+// so that the params of the same indicator have the same string
+func Normalize(name string, params Params) (Params, error) {
+	registryMutex.RLock()
+	entry, ok := registry[strings.ToLower(name)]
+	registryMutex.RUnlock()
+
+	if !ok {
+		return params, fmt.Errorf("indicator %s is not registered", name)
+	}
+
+	var options = make(map[string]float64, len(entry.defaults)+len(params.Options))
+	for k, v := range entry.defaults {
+		options[k] = v
+	}
+	for k, v := range params.Options {
+		options[k] = v
+	}
+
+	params.Options = options
+	return params, nil
+}
+
+// New creates the registered indicator of the name with the params
+func New(name string, params Params) (Indicator, error) {
+	params, err := Normalize(name, params)
+	if err != nil {
+		return nil, err
+	}
+
+	registryMutex.RLock()
+	entry := registry[strings.ToLower(name)]
+	registryMutex.RUnlock()
+
+	return entry.constructor(params), nil
+}
+
+// IsRegistered returns true if the indicator of the name is registered
+func IsRegistered(name string) bool {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	_, ok := registry[strings.ToLower(name)]
+	return ok
+}
+
+// Names returns the sorted names of the registered indicators
+func Names() (names []string) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Config is the config of a registered indicator, the fields other than type, interval and window are the options:
+//
+//	type: boll
+//	interval: 1h
+//	window: 21
+//	k: 2.0
+type Config struct {
+	Type string `json:"type"`
+	Params
+}
+
+func (c *Config) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var config Config
+	for name, value := range fields {
+		var err error
+		switch name {
+		case "type":
+			err = json.Unmarshal(value, &config.Type)
+		case "interval":
+			err = json.Unmarshal(value, &config.Interval)
+		case "window":
+			err = json.Unmarshal(value, &config.Window)
+		default:
+			var v float64
+			err = json.Unmarshal(value, &v)
+			if err == nil {
+				if config.Options == nil {
+					config.Options = make(map[string]float64)
+				}
+				config.Options[name] = v
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid indicator config field %s: %w", name, err)
+		}
+	}
+
+	if !IsRegistered(config.Type) {
+		return fmt.Errorf("indicator %q is not registered", config.Type)
+	}
+
+	if config.Window <= 0 {
+		return fmt.Errorf("invalid window %d of indicator %s", config.Window, config.Type)
+	}
+
+	*c = config
+	return nil
+}
+
+func (c Config) MarshalJSON() ([]byte, error) {
+	var fields = map[string]interface{}{
+		"type":     c.Type,
+		"interval": c.Interval,
+		"window":   c.Window,
+	}
+
+	for name, v := range c.Options {
+		fields[name] = v
+	}
+
+	return json.Marshal(fields)
+}
+
+func init() {
+	Register("ad", func(p Params) Indicator { return &AD{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("adx", func(p Params) Indicator { return &ADX{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("aroon", func(p Params) Indicator { return &Aroon{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("atr", func(p Params) Indicator { return &ATR{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("boll", func(p Params) Indicator {
+		return &BOLL{IntervalWindow: p.IntervalWindow, K: p.Options["k"]}
+	}, map[string]float64{"k": 2.0})
+	Register("ca", func(p Params) Indicator { return &CA{Interval: p.Interval} }, nil)
+	Register("cci", func(p Params) Indicator { return &CCI{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("dema", func(p Params) Indicator { return &DEMA{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("donchian", func(p Params) Indicator { return &Donchian{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("ewma", func(p Params) Indicator { return &EWMA{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("hull", func(p Params) Indicator { return &HULL{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("ichimoku", func(p Params) Indicator {
+		return &ICHIMOKU{
+			IntervalWindow: p.IntervalWindow,
+			BasePeriod:     int(p.Options["base"]),
+			SpanBPeriod:    int(p.Options["spanB"]),
+		}
+	}, map[string]float64{"base": defaultIchimokuBasePeriod, "spanB": defaultIchimokuSpanBPeriod})
+	Register("keltner", func(p Params) Indicator {
+		return &Keltner{IntervalWindow: p.IntervalWindow, ATRWindow: int(p.Options["atrWindow"]), K: p.Options["k"]}
+	}, map[string]float64{"atrWindow": defaultKeltnerATRWindow, "k": defaultKeltnerMultiplier})
+	Register("macd", func(p Params) Indicator {
+		return &MACD{
+			IntervalWindow: p.IntervalWindow,
+			ShortPeriod:    int(p.Options["short"]),
+			LongPeriod:     int(p.Options["long"]),
+		}
+	}, map[string]float64{"short": 12, "long": 26})
+	Register("obv", func(p Params) Indicator { return &OBV{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("pivot", func(p Params) Indicator { return &Pivot{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("psar", func(p Params) Indicator {
+		return &PSAR{
+			IntervalWindow:        p.IntervalWindow,
+			AccelerationStart:     p.Options["start"],
+			AccelerationIncrement: p.Options["increment"],
+			AccelerationMax:       p.Options["max"],
+		}
+	}, map[string]float64{
+		"start":     defaultPSARAccelerationStart,
+		"increment": defaultPSARAccelerationIncrement,
+		"max":       defaultPSARAccelerationMax,
+	})
+	Register("rma", func(p Params) Indicator { return &RMA{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("rsi", func(p Params) Indicator { return &RSI{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("sma", func(p Params) Indicator { return &SMA{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("stoch", func(p Params) Indicator { return &STOCH{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("supertrend", func(p Params) Indicator {
+		return &SuperTrend{IntervalWindow: p.IntervalWindow, ATRMultiplier: p.Options["multiplier"]}
+	}, map[string]float64{"multiplier": defaultSuperTrendMultiplier})
+	Register("tema", func(p Params) Indicator { return &TEMA{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("till", func(p Params) Indicator {
+		return &TILL{IntervalWindow: p.IntervalWindow, VolumeFactor: p.Options["volumeFactor"]}
+	}, map[string]float64{"volumeFactor": defaultVolumeFactor})
+	Register("tma", func(p Params) Indicator { return &TMA{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("vidya", func(p Params) Indicator { return &VIDYA{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("volatility", func(p Params) Indicator { return &VOLATILITY{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("vwap", func(p Params) Indicator { return &VWAP{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("vwma", func(p Params) Indicator { return &VWMA{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("wwma", func(p Params) Indicator { return &WWMA{IntervalWindow: p.IntervalWindow} }, nil)
+	Register("zlema", func(p Params) Indicator { return &ZLEMA{IntervalWindow: p.IntervalWindow} }, nil)
+}
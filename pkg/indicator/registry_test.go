@@ -0,0 +1,77 @@
+package indicator
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func TestNew(t *testing.T) {
+	iw := types.IntervalWindow{Interval: types.Interval1h, Window: 21}
+
+	inc, err := New("BOLL", Params{IntervalWindow: iw})
+	if assert.NoError(t, err) {
+		boll, ok := inc.(*BOLL)
+		if assert.True(t, ok) {
+			assert.Equal(t, iw, boll.IntervalWindow)
+			assert.Equal(t, 2.0, boll.K)
+		}
+	}
+
+	inc, err = New("boll", Params{IntervalWindow: iw, Options: map[string]float64{"k": 2.5}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2.5, inc.(*BOLL).K)
+	}
+
+	_, err = New("foo", Params{IntervalWindow: iw})
+	assert.Error(t, err)
+
+	for _, name := range Names() {
+		inc, err := New(name, Params{IntervalWindow: iw})
+		assert.NoError(t, err, name)
+		assert.NotNil(t, inc, name)
+	}
+}
+
+func TestRegister_Duplicated(t *testing.T) {
+	assert.Panics(t, func() {
+		Register("SMA", func(p Params) Indicator { return &SMA{IntervalWindow: p.IntervalWindow} }, nil)
+	})
+}
+
+func TestNormalize(t *testing.T) {
+	iw := types.IntervalWindow{Interval: types.Interval1h, Window: 26}
+
+	a, err := Normalize("macd", Params{IntervalWindow: iw})
+	assert.NoError(t, err)
+
+	b, err := Normalize("macd", Params{IntervalWindow: iw, Options: map[string]float64{"short": 12}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1h (26) long=26 short=12", a.String())
+	assert.Equal(t, a.String(), b.String())
+}
+
+func TestConfig_JSON(t *testing.T) {
+	var config Config
+	err := json.Unmarshal([]byte(`{"type":"boll","interval":"1h","window":21,"k":2.5}`), &config)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "boll", config.Type)
+		assert.Equal(t, types.IntervalWindow{Interval: types.Interval1h, Window: 21}, config.IntervalWindow)
+		assert.Equal(t, 2.5, config.Option("k", 2.0))
+	}
+
+	out, err := json.Marshal(config)
+	if assert.NoError(t, err) {
+		var config2 Config
+		assert.NoError(t, json.Unmarshal(out, &config2))
+		assert.Equal(t, config, config2)
+	}
+
+	assert.Error(t, json.Unmarshal([]byte(`{"type":"foo","interval":"1h","window":21}`), &config))
+	assert.Error(t, json.Unmarshal([]byte(`{"type":"sma","interval":"1h"}`), &config))
+	assert.Error(t, json.Unmarshal([]byte(`{"type":"boll","interval":"1h","window":21,"k":"a"}`), &config))
+}
@@ -10,8 +10,8 @@ import (
 //go:generate callbackgen -type RMA
 type RMA struct {
 	types.IntervalWindow
-	Values  types.Float64Slice
-	Sources types.Float64Slice
+	Values  types.Float64RingBuffer
+	Sources types.Float64RingBuffer
 
 	EndTime         time.Time
 	UpdateCallbacks []func(value float64)
@@ -20,13 +20,13 @@ type RMA struct {
 func (inc *RMA) Update(x float64) {
 	inc.Sources.Push(x)
 
-	if len(inc.Sources) < inc.Window {
+	if inc.Sources.Length() < inc.Window {
 		inc.Values.Push(0)
 		return
 	}
 
-	if len(inc.Sources) == inc.Window {
-		inc.Values.Push(inc.Sources.Mean())
+	if inc.Sources.Length() == inc.Window {
+		inc.Values.Push(inc.Sources.Tail(inc.Window).Mean())
 		return
 	}
 
@@ -40,15 +40,11 @@ func (inc *RMA) Last() float64 {
 }
 
 func (inc *RMA) Index(i int) float64 {
-	length := len(inc.Values)
-	if length == 0 || length-i-1 < 0 {
-		return 0
-	}
-	return inc.Values[length-i-1]
+	return inc.Values.Index(i)
 }
 
 func (inc *RMA) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 var _ types.Series = &RMA{}
@@ -75,3 +71,11 @@ func (inc *RMA) handleKLineWindowUpdate(interval types.Interval, window types.KL
 func (inc *RMA) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *RMA) WarmUpBars() int {
+	return inc.Window
+}
+
+func (inc *RMA) IsReady() bool {
+	return inc.Sources.Length() >= inc.Window
+}
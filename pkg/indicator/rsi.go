@@ -15,8 +15,8 @@ https://www.investopedia.com/terms/r/rsi.asp
 //go:generate callbackgen -type RSI
 type RSI struct {
 	types.IntervalWindow
-	Values          types.Float64Slice
-	Prices          types.Float64Slice
+	Values          types.Float64RingBuffer
+	Prices          types.Float64RingBuffer
 	PreviousAvgLoss float64
 	PreviousAvgGain float64
 
@@ -27,19 +27,19 @@ type RSI struct {
 func (inc *RSI) Update(price float64) {
 	inc.Prices.Push(price)
 
-	if len(inc.Prices) < inc.Window+1 {
+	if inc.Prices.Length() < inc.Window+1 {
 		return
 	}
 
 	var avgGain float64
 	var avgLoss float64
-	if len(inc.Prices) == inc.Window+1 {
-		priceDifferences := inc.Prices.Diff()
+	if inc.Prices.Length() == inc.Window+1 {
+		priceDifferences := inc.Prices.Tail(inc.Window + 1).Diff()
 
 		avgGain = priceDifferences.PositiveValuesOrZero().Abs().Sum() / float64(inc.Window)
 		avgLoss = priceDifferences.NegativeValuesOrZero().Abs().Sum() / float64(inc.Window)
 	} else {
-		difference := price - inc.Prices[len(inc.Prices)-2]
+		difference := price - inc.Prices.Index(1)
 		currentGain := math.Max(difference, 0)
 		currentLoss := -math.Min(difference, 0)
 
@@ -56,22 +56,15 @@ func (inc *RSI) Update(price float64) {
 }
 
 func (inc *RSI) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0.0
-	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *RSI) Index(i int) float64 {
-	length := len(inc.Values)
-	if length <= 0 || length-i-1 < 0 {
-		return 0.0
-	}
-	return inc.Values[length-i-1]
+	return inc.Values.Index(i)
 }
 
 func (inc *RSI) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 var _ types.Series = &RSI{}
@@ -99,3 +92,11 @@ func (inc *RSI) handleKLineWindowUpdate(interval types.Interval, window types.KL
 func (inc *RSI) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *RSI) WarmUpBars() int {
+	return inc.Window + 1
+}
+
+func (inc *RSI) IsReady() bool {
+	return inc.Values.Length() > 0
+}
@@ -60,8 +60,8 @@ func Test_calculateRSI(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			rsi := RSI{IntervalWindow: types.IntervalWindow{Window: tt.window}}
 			rsi.calculateAndUpdate(tt.kLines)
-			assert.Equal(t, len(rsi.Values), len(tt.want))
-			for i, v := range rsi.Values {
+			assert.Equal(t, rsi.Values.Length(), len(tt.want))
+			for i, v := range rsi.Values.Slice() {
 				assert.InDelta(t, v, tt.want[i], Delta)
 			}
 		})
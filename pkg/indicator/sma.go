@@ -9,50 +9,38 @@ import (
 	"github.com/c9s/bbgo/pkg/types"
 )
 
-const MaxNumOfSMA = 5_000
-const MaxNumOfSMATruncateSize = 100
-
 var zeroTime time.Time
 
 //go:generate callbackgen -type SMA
 type SMA struct {
 	types.IntervalWindow
-	Values  types.Float64Slice
+	Values  types.Float64RingBuffer
 	EndTime time.Time
 
 	UpdateCallbacks []func(value float64)
 }
 
 func (inc *SMA) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0.0
-	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *SMA) Index(i int) float64 {
-	length := len(inc.Values)
-	if length == 0 || length-i-1 < 0 {
-		return 0.0
-	}
-
-	return inc.Values[length-i-1]
+	return inc.Values.Index(i)
 }
 
 func (inc *SMA) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 var _ types.Series = &SMA{}
 
 func (inc *SMA) Update(value float64) {
-	length := len(inc.Values)
-	if length == 0 {
-		inc.Values = append(inc.Values, value)
+	if inc.Values.Length() == 0 {
+		inc.Values.Push(value)
 		return
 	}
-	newVal := (inc.Values[length-1]*float64(inc.Window-1) + value) / float64(inc.Window)
-	inc.Values = append(inc.Values, newVal)
+	newVal := (inc.Values.Last()*float64(inc.Window-1) + value) / float64(inc.Window)
+	inc.Values.Push(newVal)
 }
 
 func (inc *SMA) calculateAndUpdate(kLines []types.KLine) {
@@ -76,10 +64,6 @@ func (inc *SMA) calculateAndUpdate(kLines []types.KLine) {
 	}
 	inc.Values.Push(sma)
 
-	if len(inc.Values) > MaxNumOfSMA {
-		inc.Values = inc.Values[MaxNumOfSMATruncateSize-1:]
-	}
-
 	inc.EndTime = kLines[index].EndTime.Time()
 
 	inc.EmitUpdate(sma)
@@ -111,3 +95,11 @@ func calculateSMA(kLines []types.KLine, window int, priceF KLinePriceMapper) (fl
 	avg := sum / float64(window)
 	return avg, nil
 }
+
+func (inc *SMA) WarmUpBars() int {
+	return inc.Window
+}
+
+func (inc *SMA) IsReady() bool {
+	return inc.Values.Length() > 0
+}
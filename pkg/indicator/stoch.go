@@ -17,11 +17,11 @@ Stochastic Oscillator
 //go:generate callbackgen -type STOCH
 type STOCH struct {
 	types.IntervalWindow
-	K types.Float64Slice
-	D types.Float64Slice
+	K types.Float64RingBuffer
+	D types.Float64RingBuffer
 
-	HighValues types.Float64Slice
-	LowValues  types.Float64Slice
+	HighValues types.Float64RingBuffer
+	LowValues  types.Float64RingBuffer
 
 	EndTime         time.Time
 	UpdateCallbacks []func(k float64, d float64)
@@ -46,17 +46,11 @@ func (inc *STOCH) Update(high, low, cloze float64) {
 }
 
 func (inc *STOCH) LastK() float64 {
-	if len(inc.K) == 0 {
-		return 0.0
-	}
-	return inc.K[len(inc.K)-1]
+	return inc.K.Last()
 }
 
 func (inc *STOCH) LastD() float64 {
-	if len(inc.K) == 0 {
-		return 0.0
-	}
-	return inc.D[len(inc.D)-1]
+	return inc.D.Last()
 }
 
 func (inc *STOCH) calculateAndUpdate(kLines []types.KLine) {
@@ -94,3 +88,12 @@ func (inc *STOCH) GetD() types.Series {
 func (inc *STOCH) GetK() types.Series {
 	return &inc.K
 }
+
+// WarmUpBars returns the bars of the window and the D period of the smoothed K
+func (inc *STOCH) WarmUpBars() int {
+	return inc.Window + DPeriod - 1
+}
+
+func (inc *STOCH) IsReady() bool {
+	return hasEnoughValues(&inc.K, inc.WarmUpBars())
+}
@@ -0,0 +1,167 @@
+package indicator
+
+import (
+	"time"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const defaultSuperTrendMultiplier = 3.0
+
+/*
+supertrend implements the SuperTrend indicator, the bands are the median price plus and minus
+the multiple of the ATR of the window. The super trend is the lower band in the up trend,
+and the upper band in the down trend.
+
+SuperTrend
+- https://www.tradingview.com/support/solutions/43000634738-supertrend/
+*/
+//go:generate callbackgen -type SuperTrend
+type SuperTrend struct {
+	types.IntervalWindow
+
+	// ATRMultiplier is the multiple of the ATR of the bands, 3 by default
+	ATRMultiplier float64
+
+	Values    types.Float64RingBuffer
+	UpBand    types.Float64RingBuffer
+	DownBand  types.Float64RingBuffer
+	Direction types.Float64RingBuffer
+
+	atr           *ATR
+	previousClose float64
+
+	EndTime         time.Time
+	UpdateCallbacks []func(value float64, direction int)
+}
+
+func (inc *SuperTrend) Update(high, low, cloze float64) {
+	if inc.atr == nil {
+		if inc.ATRMultiplier == 0 {
+			inc.ATRMultiplier = defaultSuperTrendMultiplier
+		}
+		inc.atr = &ATR{IntervalWindow: types.IntervalWindow{Interval: inc.Interval, Window: inc.Window}}
+	}
+
+	inc.atr.Update(high, low, cloze)
+	defer func() { inc.previousClose = cloze }()
+
+	// the ATR is not ready until the window of the true ranges is filled
+	if inc.atr.RMA == nil || inc.atr.RMA.Sources.Length() < inc.Window {
+		return
+	}
+
+	median := (high + low) / 2.0
+	band := inc.ATRMultiplier * inc.atr.Last()
+	upBand := median + band
+	downBand := median - band
+
+	if inc.Values.Length() == 0 {
+		inc.UpBand.Push(upBand)
+		inc.DownBand.Push(downBand)
+		inc.Direction.Push(-1)
+		inc.Values.Push(upBand)
+		return
+	}
+
+	previousUpBand := inc.UpBand.Last()
+	previousDownBand := inc.DownBand.Last()
+	previousValue := inc.Values.Last()
+
+	// the bands only move toward the price unless the previous close broke them
+	if upBand > previousUpBand && inc.previousClose <= previousUpBand {
+		upBand = previousUpBand
+	}
+	if downBand < previousDownBand && inc.previousClose >= previousDownBand {
+		downBand = previousDownBand
+	}
+
+	var direction = 1.0
+	if previousValue == previousUpBand {
+		if cloze <= upBand {
+			direction = -1
+		}
+	} else if cloze < downBand {
+		direction = -1
+	}
+
+	inc.UpBand.Push(upBand)
+	inc.DownBand.Push(downBand)
+	inc.Direction.Push(direction)
+
+	if direction > 0 {
+		inc.Values.Push(downBand)
+	} else {
+		inc.Values.Push(upBand)
+	}
+}
+
+func (inc *SuperTrend) Last() float64 {
+	return inc.Values.Last()
+}
+
+func (inc *SuperTrend) Index(i int) float64 {
+	return inc.Values.Index(i)
+}
+
+func (inc *SuperTrend) Length() int {
+	return inc.Values.Length()
+}
+
+var _ types.Series = &SuperTrend{}
+
+// LastDirection returns 1 in the up trend, -1 in the down trend, and 0 if it's not calculated yet
+func (inc *SuperTrend) LastDirection() int {
+	return int(inc.Direction.Last())
+}
+
+func (inc *SuperTrend) GetUpBand() types.Series {
+	return &inc.UpBand
+}
+
+func (inc *SuperTrend) GetDownBand() types.Series {
+	return &inc.DownBand
+}
+
+// GetDirection returns the series of the trend directions, 1 is the up trend and -1 is the down trend
+func (inc *SuperTrend) GetDirection() types.Series {
+	return &inc.Direction
+}
+
+func (inc *SuperTrend) calculateAndUpdate(kLines []types.KLine) {
+	if len(kLines) == 0 {
+		return
+	}
+
+	for _, k := range kLines {
+		if inc.EndTime != zeroTime && !k.EndTime.After(inc.EndTime) {
+			continue
+		}
+		inc.Update(k.High.Float64(), k.Low.Float64(), k.Close.Float64())
+	}
+
+	if inc.Values.Length() > 0 {
+		inc.EmitUpdate(inc.Last(), inc.LastDirection())
+	}
+	inc.EndTime = kLines[len(kLines)-1].EndTime.Time()
+}
+
+func (inc *SuperTrend) handleKLineWindowUpdate(interval types.Interval, window types.KLineWindow) {
+	if inc.Interval != interval {
+		return
+	}
+
+	inc.calculateAndUpdate(window)
+}
+
+func (inc *SuperTrend) Bind(updater KLineWindowUpdater) {
+	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
+}
+
+func (inc *SuperTrend) WarmUpBars() int {
+	return inc.Window + 1
+}
+
+func (inc *SuperTrend) IsReady() bool {
+	return inc.Values.Length() > 0
+}
@@ -0,0 +1,75 @@
+package indicator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+/*
+python, following the TradingView supertrend:
+
+atr = rma(true_range, window)
+basic_up, basic_down = hl2 + multiplier * atr, hl2 - multiplier * atr
+up = basic_up if basic_up < prev_up or prev_close > prev_up else prev_up
+down = basic_down if basic_down > prev_down or prev_close < prev_down else prev_down
+if prev_supertrend == prev_up:
+    direction = 1 if close > up else -1
+else:
+    direction = -1 if close < down else 1
+supertrend = down if direction == 1 else up
+*/
+
+func TestSuperTrend(t *testing.T) {
+	var Delta = 1e-6
+
+	t.Run("down trend", func(t *testing.T) {
+		kLines := buildTrendTestKLines()
+
+		supertrend := &SuperTrend{IntervalWindow: types.IntervalWindow{Window: 10}}
+		supertrend.calculateAndUpdate(kLines)
+
+		assert.Equal(t, 70, supertrend.Length())
+		assert.InDelta(t, 8137.915257224903, supertrend.Last(), Delta)
+		assert.InDelta(t, 8137.915257224903, supertrend.Index(1), Delta)
+		assert.Equal(t, -1, supertrend.LastDirection())
+		assert.Equal(t, supertrend.GetUpBand().Last(), supertrend.Last())
+	})
+
+	t.Run("reversal", func(t *testing.T) {
+		var kLines []types.KLine
+		var startTime = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 35; i++ {
+			c := 100.0 - float64(i)
+			if i >= 20 {
+				c = 81.0 + 3*float64(i-19)
+			}
+			kLines = append(kLines, types.KLine{
+				StartTime: types.Time(startTime.Add(time.Duration(i) * time.Minute)),
+				EndTime:   types.Time(startTime.Add(time.Duration(i+1) * time.Minute)),
+				High:      fixedpoint.NewFromFloat(c + 1),
+				Low:       fixedpoint.NewFromFloat(c - 1),
+				Close:     fixedpoint.NewFromFloat(c),
+			})
+		}
+
+		supertrend := &SuperTrend{IntervalWindow: types.IntervalWindow{Window: 5}, ATRMultiplier: 2}
+		supertrend.calculateAndUpdate(kLines[:21])
+		assert.Equal(t, -1, supertrend.LastDirection())
+
+		supertrend.calculateAndUpdate(kLines[:22])
+		assert.Equal(t, 1, supertrend.LastDirection())
+		assert.Equal(t, -1.0, supertrend.GetDirection().Index(1))
+
+		supertrend.calculateAndUpdate(kLines)
+		assert.Equal(t, 30, supertrend.Length())
+		assert.InDelta(t, 118.14073748835533, supertrend.Last(), Delta)
+		assert.InDelta(t, 115.17592186044416, supertrend.Index(1), Delta)
+		assert.InDelta(t, 112.2199023255552, supertrend.Index(2), Delta)
+		assert.Equal(t, supertrend.GetDownBand().Last(), supertrend.Last())
+	})
+}
@@ -10,7 +10,7 @@ import (
 //go:generate callbackgen -type TEMA
 type TEMA struct {
 	types.IntervalWindow
-	Values types.Float64Slice
+	Values types.Float64RingBuffer
 	A1     *EWMA
 	A2     *EWMA
 	A3     *EWMA
@@ -19,7 +19,7 @@ type TEMA struct {
 }
 
 func (inc *TEMA) Update(value float64) {
-	if len(inc.Values) == 0 {
+	if inc.Values.Length() == 0 {
 		inc.A1 = &EWMA{IntervalWindow: types.IntervalWindow{inc.Interval, inc.Window}}
 		inc.A2 = &EWMA{IntervalWindow: types.IntervalWindow{inc.Interval, inc.Window}}
 		inc.A3 = &EWMA{IntervalWindow: types.IntervalWindow{inc.Interval, inc.Window}}
@@ -34,21 +34,15 @@ func (inc *TEMA) Update(value float64) {
 }
 
 func (inc *TEMA) Last() float64 {
-	if len(inc.Values) > 0 {
-		return inc.Values[len(inc.Values)-1]
-	}
-	return 0.0
+	return inc.Values.Last()
 }
 
 func (inc *TEMA) Index(i int) float64 {
-	if i >= len(inc.Values) {
-		return 0
-	}
-	return inc.Values[len(inc.Values)-i-1]
+	return inc.Values.Index(i)
 }
 
 func (inc *TEMA) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 var _ types.Series = &TEMA{}
@@ -76,3 +70,11 @@ func (inc *TEMA) handleKLineWindowUpdate(interval types.Interval, window types.K
 func (inc *TEMA) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *TEMA) WarmUpBars() int {
+	return recursiveWarmUpFactor * inc.Window
+}
+
+func (inc *TEMA) IsReady() bool {
+	return hasEnoughValues(&inc.Values, inc.WarmUpBars())
+}
@@ -94,6 +94,7 @@ func (inc *TILL) calculateAndUpdate(allKLines []types.KLine) {
 		}
 		if doable {
 			inc.Update(k.Close.Float64())
+			inc.e1.LastOpenTime = k.StartTime.Time()
 			inc.EmitUpdate(inc.Last())
 		}
 	}
@@ -110,3 +111,11 @@ func (inc *TILL) handleKLineWindowUpdate(interval types.Interval, window types.K
 func (inc *TILL) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *TILL) WarmUpBars() int {
+	return recursiveWarmUpFactor * inc.Window
+}
+
+func (inc *TILL) IsReady() bool {
+	return inc.e6 != nil && hasEnoughValues(&inc.e6.Values, inc.WarmUpBars())
+}
@@ -71,3 +71,11 @@ func (inc *TMA) handleKLineWindowUpdate(interval types.Interval, window types.KL
 func (inc *TMA) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *TMA) WarmUpBars() int {
+	return inc.Window
+}
+
+func (inc *TMA) IsReady() bool {
+	return inc.s2 != nil && hasEnoughValues(&inc.s2.Values, inc.WarmUpBars())
+}
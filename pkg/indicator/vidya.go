@@ -11,8 +11,8 @@ import (
 //go:generate callbackgen -type VIDYA
 type VIDYA struct {
 	types.IntervalWindow
-	Values types.Float64Slice
-	input  types.Float64Slice
+	Values types.Float64RingBuffer
+	input  types.Float64RingBuffer
 
 	UpdateCallbacks []func(value float64)
 }
@@ -24,13 +24,10 @@ func (inc *VIDYA) Update(value float64) {
 		return
 	}
 	inc.input.Push(value)
-	if len(inc.input) > MaxNumOfEWMA {
-		inc.input = inc.input[MaxNumOfEWMATruncateSize-1:]
-	}
 	/*upsum := 0.
 	downsum := 0.
 	for i := 0; i < inc.Window; i++ {
-		if len(inc.input) <= i+1 {
+		if inc.input.Length() <= i+1 {
 			break
 		}
 		diff := inc.input.Index(i) - inc.input.Index(i+1)
@@ -49,9 +46,6 @@ func (inc *VIDYA) Update(value float64) {
 	CMO := math.Abs(types.Sum(change, inc.Window) / types.Sum(types.Abs(change), inc.Window))
 	alpha := 2. / float64(inc.Window+1)
 	inc.Values.Push(value*alpha*CMO + inc.Values.Last()*(1.-alpha*CMO))
-	if inc.Values.Length() > MaxNumOfEWMA {
-		inc.Values = inc.Values[MaxNumOfEWMATruncateSize-1:]
-	}
 }
 
 func (inc *VIDYA) Last() float64 {
@@ -91,3 +85,11 @@ func (inc *VIDYA) handleKLineWindowUpdate(interval types.Interval, window types.
 func (inc *VIDYA) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *VIDYA) WarmUpBars() int {
+	return recursiveWarmUpFactor * inc.Window
+}
+
+func (inc *VIDYA) IsReady() bool {
+	return hasEnoughValues(&inc.Values, inc.WarmUpBars())
+}
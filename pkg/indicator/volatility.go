@@ -10,25 +10,19 @@ import (
 	"github.com/c9s/bbgo/pkg/types"
 )
 
-const MaxNumOfVOL = 5_000
-const MaxNumOfVOLTruncateSize = 100
-
 //var zeroTime time.Time
 
 //go:generate callbackgen -type VOLATILITY
 type VOLATILITY struct {
 	types.IntervalWindow
-	Values  types.Float64Slice
+	Values  types.Float64RingBuffer
 	EndTime time.Time
 
 	UpdateCallbacks []func(value float64)
 }
 
 func (inc *VOLATILITY) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0.0
-	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *VOLATILITY) calculateAndUpdate(klines []types.KLine) {
@@ -52,10 +46,6 @@ func (inc *VOLATILITY) calculateAndUpdate(klines []types.KLine) {
 	}
 	inc.Values.Push(volatility)
 
-	if len(inc.Values) > MaxNumOfVOL {
-		inc.Values = inc.Values[MaxNumOfVOLTruncateSize-1:]
-	}
-
 	inc.EndTime = klines[end].GetEndTime().Time()
 
 	inc.EmitUpdate(volatility)
@@ -95,3 +85,11 @@ func calculateVOLATILITY(klines []types.KLine, window int, priceF KLinePriceMapp
 	sd := math.Sqrt(sv / float64(len(klines)))
 	return sd, nil
 }
+
+func (inc *VOLATILITY) WarmUpBars() int {
+	return inc.Window
+}
+
+func (inc *VOLATILITY) IsReady() bool {
+	return inc.Values.Length() > 0
+}
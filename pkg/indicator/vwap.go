@@ -18,9 +18,9 @@ Volume-Weighted Average Price (VWAP) Explained
 //go:generate callbackgen -type VWAP
 type VWAP struct {
 	types.IntervalWindow
-	Values      types.Float64Slice
-	Prices      types.Float64Slice
-	Volumes     types.Float64Slice
+	Values      types.Float64RingBuffer
+	Prices      types.Float64RingBuffer
+	Volumes     types.Float64RingBuffer
 	WeightedSum float64
 	VolumeSum   float64
 
@@ -29,13 +29,18 @@ type VWAP struct {
 }
 
 func (inc *VWAP) Update(price, volume float64) {
+	// keep one more price than the window for the eviction, the default capacity could be smaller than the window
+	if inc.Window != 0 && inc.Prices.Length() == 0 {
+		inc.Prices = *types.NewFloat64RingBuffer(inc.Window + 1)
+		inc.Volumes = *types.NewFloat64RingBuffer(inc.Window + 1)
+	}
+
 	inc.Prices.Push(price)
 	inc.Volumes.Push(volume)
 
-	if inc.Window != 0 && len(inc.Prices) > inc.Window {
-		popIndex := len(inc.Prices) - inc.Window - 1
-		inc.WeightedSum -= inc.Prices[popIndex] * inc.Volumes[popIndex]
-		inc.VolumeSum -= inc.Volumes[popIndex]
+	if inc.Window != 0 && inc.Prices.Length() > inc.Window {
+		inc.WeightedSum -= inc.Prices.Index(inc.Window) * inc.Volumes.Index(inc.Window)
+		inc.VolumeSum -= inc.Volumes.Index(inc.Window)
 	}
 
 	inc.WeightedSum += price * volume
@@ -46,23 +51,15 @@ func (inc *VWAP) Update(price, volume float64) {
 }
 
 func (inc *VWAP) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0.0
-	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *VWAP) Index(i int) float64 {
-	length := len(inc.Values)
-	if length == 0 || length-i-1 < 0 {
-		return 0
-	}
-
-	return inc.Values[length-i-1]
+	return inc.Values.Index(i)
 }
 
 func (inc *VWAP) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 var _ types.Series = &VWAP{}
@@ -100,3 +97,15 @@ func CalculateVWAP(klines []types.KLine, priceF KLinePriceMapper, window int) fl
 	}
 	return vwap.Last()
 }
+
+// WarmUpBars returns the window, or 1 for the cumulative VWAP without the window
+func (inc *VWAP) WarmUpBars() int {
+	if inc.Window == 0 {
+		return 1
+	}
+	return inc.Window
+}
+
+func (inc *VWAP) IsReady() bool {
+	return hasEnoughValues(&inc.Values, inc.WarmUpBars())
+}
@@ -72,3 +72,20 @@ func Test_calculateVWAP(t *testing.T) {
 		})
 	}
 }
+
+func TestVWAP_LargeWindow(t *testing.T) {
+	// the window is larger than the default capacity of the ring buffers
+	window := types.DefaultFloat64RingBufferCapacity + 10
+	vwap := &VWAP{IntervalWindow: types.IntervalWindow{Interval: types.Interval1m, Window: window}}
+	for i := 0; i < window; i++ {
+		vwap.Update(1.0, 1.0)
+	}
+	for i := 0; i < window; i++ {
+		vwap.Update(2.0, 1.0)
+	}
+
+	// the prices of the first window are all evicted
+	if math.Abs(vwap.Last()-2.0) > 1e-9 {
+		t.Errorf("VWAP.Last() = %v, want 2", vwap.Last())
+	}
+}
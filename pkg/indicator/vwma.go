@@ -21,29 +21,22 @@ Volume Weighted Moving Average
 //go:generate callbackgen -type VWMA
 type VWMA struct {
 	types.IntervalWindow
-	Values  types.Float64Slice
+	Values  types.Float64RingBuffer
 	EndTime time.Time
 
 	UpdateCallbacks []func(value float64)
 }
 
 func (inc *VWMA) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0.0
-	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *VWMA) Index(i int) float64 {
-	length := len(inc.Values)
-	if length == 0 || length-i-1 < 0 {
-		return 0
-	}
-	return inc.Values[length-i-1]
+	return inc.Values.Index(i)
 }
 
 func (inc *VWMA) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 var _ types.Series = &VWMA{}
@@ -84,10 +77,6 @@ func (inc *VWMA) calculateAndUpdate(kLines []types.KLine) {
 	vwma := pv / v
 	inc.Values.Push(vwma)
 
-	if len(inc.Values) > MaxNumOfSMA {
-		inc.Values = inc.Values[MaxNumOfSMATruncateSize-1:]
-	}
-
 	inc.EndTime = kLines[index].EndTime.Time()
 
 	inc.EmitUpdate(vwma)
@@ -104,3 +93,11 @@ func (inc *VWMA) handleKLineWindowUpdate(interval types.Interval, window types.K
 func (inc *VWMA) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+func (inc *VWMA) WarmUpBars() int {
+	return inc.Window
+}
+
+func (inc *VWMA) IsReady() bool {
+	return inc.Values.Length() > 0
+}
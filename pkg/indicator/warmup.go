@@ -0,0 +1,73 @@
+package indicator
+
+import "github.com/c9s/bbgo/pkg/types"
+
+// recursiveWarmUpFactor is the multiple of the window that the recursive moving averages (EWMA and the indicators
+// built on it) need to converge. After 3 windows, the weight of the initial value of EWMA is less than 0.3%.
+const recursiveWarmUpFactor = 3
+
+// WarmUpIndicator is the indicator that declares how many klines it needs before its values are valid,
+// so that the historical klines can be loaded to fill the indicator before the strategy starts.
+type WarmUpIndicator interface {
+	Indicator
+
+	// WarmUpBars returns the number of the klines that the indicator needs to be ready
+	WarmUpBars() int
+
+	// IsReady returns true if the indicator has calculated enough klines
+	IsReady() bool
+}
+
+// WarmUpBars returns the number of the klines that the indicator needs, or 0 if the indicator doesn't declare it
+func WarmUpBars(inc Indicator) int {
+	if w, ok := inc.(WarmUpIndicator); ok {
+		return w.WarmUpBars()
+	}
+	return 0
+}
+
+// IsReady returns true if the indicator is ready, the indicator that doesn't declare the warm-up is always ready
+func IsReady(inc Indicator) bool {
+	if w, ok := inc.(WarmUpIndicator); ok {
+		return w.IsReady()
+	}
+	return true
+}
+
+// hasEnoughValues returns true if the values have the given number of values,
+// or the buffer is full since the values beyond the capacity are dropped
+func hasEnoughValues(values *types.Float64RingBuffer, bars int) bool {
+	return values.Length() >= bars || values.Length() == values.Cap()
+}
+
+var _ WarmUpIndicator = &AD{}
+var _ WarmUpIndicator = &ADX{}
+var _ WarmUpIndicator = &Aroon{}
+var _ WarmUpIndicator = &ATR{}
+var _ WarmUpIndicator = &BOLL{}
+var _ WarmUpIndicator = &CA{}
+var _ WarmUpIndicator = &CCI{}
+var _ WarmUpIndicator = &DEMA{}
+var _ WarmUpIndicator = &Donchian{}
+var _ WarmUpIndicator = &EWMA{}
+var _ WarmUpIndicator = &HULL{}
+var _ WarmUpIndicator = &ICHIMOKU{}
+var _ WarmUpIndicator = &Keltner{}
+var _ WarmUpIndicator = &MACD{}
+var _ WarmUpIndicator = &OBV{}
+var _ WarmUpIndicator = &Pivot{}
+var _ WarmUpIndicator = &PSAR{}
+var _ WarmUpIndicator = &RMA{}
+var _ WarmUpIndicator = &RSI{}
+var _ WarmUpIndicator = &SMA{}
+var _ WarmUpIndicator = &STOCH{}
+var _ WarmUpIndicator = &SuperTrend{}
+var _ WarmUpIndicator = &TEMA{}
+var _ WarmUpIndicator = &TILL{}
+var _ WarmUpIndicator = &TMA{}
+var _ WarmUpIndicator = &VIDYA{}
+var _ WarmUpIndicator = &VOLATILITY{}
+var _ WarmUpIndicator = &VWAP{}
+var _ WarmUpIndicator = &VWMA{}
+var _ WarmUpIndicator = &WWMA{}
+var _ WarmUpIndicator = &ZLEMA{}
@@ -0,0 +1,88 @@
+package indicator
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+type testKLineWindowUpdater struct {
+	callbacks []func(interval types.Interval, window types.KLineWindow)
+}
+
+func (u *testKLineWindowUpdater) OnKLineWindowUpdate(cb func(interval types.Interval, window types.KLineWindow)) {
+	u.callbacks = append(u.callbacks, cb)
+}
+
+func (u *testKLineWindowUpdater) emit(interval types.Interval, window types.KLineWindow) {
+	for _, cb := range u.callbacks {
+		cb(interval, window)
+	}
+}
+
+func buildWarmUpTestKLines(interval types.Interval, n int) (kLines []types.KLine) {
+	var startTime = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		price := 100.0 + 10.0*math.Sin(float64(i)/10.0)
+		kLines = append(kLines, types.KLine{
+			Interval:  interval,
+			StartTime: types.Time(startTime.Add(time.Duration(i) * interval.Duration())),
+			EndTime:   types.Time(startTime.Add(time.Duration(i+1) * interval.Duration())),
+			Open:      fixedpoint.NewFromFloat(price - 0.5),
+			High:      fixedpoint.NewFromFloat(price + 1.0),
+			Low:       fixedpoint.NewFromFloat(price - 1.0),
+			Close:     fixedpoint.NewFromFloat(price),
+			Volume:    fixedpoint.NewFromFloat(1.0 + float64(i%5)),
+		})
+	}
+	return kLines
+}
+
+func TestWarmUpIndicator(t *testing.T) {
+	iw := types.IntervalWindow{Interval: types.Interval1m, Window: 14}
+	kLines := buildWarmUpTestKLines(iw.Interval, 300)
+
+	for _, name := range Names() {
+		inc, err := New(name, Params{IntervalWindow: iw})
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+
+		w, ok := inc.(WarmUpIndicator)
+		if !assert.True(t, ok, "%s should implement WarmUpIndicator", name) {
+			continue
+		}
+
+		bars := w.WarmUpBars()
+		if !assert.True(t, bars > 0 && bars <= len(kLines), "%s warm-up bars %d", name, bars) {
+			continue
+		}
+
+		updater := &testKLineWindowUpdater{}
+		inc.Bind(updater)
+
+		var window types.KLineWindow
+		for i, k := range kLines[:bars] {
+			assert.False(t, w.IsReady(), "%s should not be ready after %d klines", name, i)
+			window.Add(k)
+			updater.emit(iw.Interval, window)
+		}
+
+		assert.True(t, w.IsReady(), "%s should be ready after %d klines", name, bars)
+	}
+}
+
+func TestWarmUpBars(t *testing.T) {
+	iw := types.IntervalWindow{Interval: types.Interval1h, Window: 20}
+	assert.Equal(t, 20, WarmUpBars(&SMA{IntervalWindow: iw}))
+	assert.Equal(t, 60, WarmUpBars(&EWMA{IntervalWindow: iw}))
+	assert.Equal(t, 21, WarmUpBars(&RSI{IntervalWindow: iw}))
+	assert.Equal(t, 52, WarmUpBars(&ICHIMOKU{IntervalWindow: iw}))
+	assert.Equal(t, 0, WarmUpBars(&Line{}))
+	assert.True(t, IsReady(&Line{}))
+}
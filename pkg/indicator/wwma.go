@@ -9,24 +9,19 @@ import (
 // Refer URL: http://fxcorporate.com/help/MS/NOTFIFO/i_WMA.html
 // TODO: Cannot see any difference between RMA and this
 
-const MaxNumOfWWMA = 5_000
-const MaxNumOfWWMATruncateSize = 100
-
 //go:generate callbackgen -type WWMA
 type WWMA struct {
 	types.IntervalWindow
-	Values       types.Float64Slice
+	Values       types.Float64RingBuffer
 	LastOpenTime time.Time
 
 	UpdateCallbacks []func(value float64)
 }
 
 func (inc *WWMA) Update(value float64) {
-	if len(inc.Values) == 0 {
+	if inc.Values.Length() == 0 {
 		inc.Values.Push(value)
 		return
-	} else if len(inc.Values) > MaxNumOfWWMA {
-		inc.Values = inc.Values[MaxNumOfWWMATruncateSize-1:]
 	}
 
 	last := inc.Last()
@@ -35,23 +30,15 @@ func (inc *WWMA) Update(value float64) {
 }
 
 func (inc *WWMA) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0
-	}
-
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *WWMA) Index(i int) float64 {
-	if i >= len(inc.Values) {
-		return 0
-	}
-
-	return inc.Values[len(inc.Values)-1-i]
+	return inc.Values.Index(i)
 }
 
 func (inc *WWMA) Length() int {
-	return len(inc.Values)
+	return inc.Values.Length()
 }
 
 func (inc *WWMA) calculateAndUpdate(allKLines []types.KLine) {
@@ -86,3 +73,13 @@ func (inc *WWMA) Bind(updater KLineWindowUpdater) {
 }
 
 var _ types.Series = &WWMA{}
+
+// WarmUpBars returns the double bars of EWMA, since the smoothing factor of WWMA is 1/window,
+// about the half of the EWMA one
+func (inc *WWMA) WarmUpBars() int {
+	return 2 * recursiveWarmUpFactor * inc.Window
+}
+
+func (inc *WWMA) IsReady() bool {
+	return hasEnoughValues(&inc.Values, inc.WarmUpBars())
+}
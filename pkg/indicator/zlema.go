@@ -11,7 +11,7 @@ import (
 type ZLEMA struct {
 	types.IntervalWindow
 
-	data  types.Float64Slice
+	data  types.Float64RingBuffer
 	zlema *EWMA
 	lag   int
 
@@ -45,13 +45,10 @@ func (inc *ZLEMA) Update(value float64) {
 		inc.lag = int((float64(inc.Window)-1.)/2. + 0.5)
 	}
 	inc.data.Push(value)
-	if len(inc.data) > MaxNumOfEWMA {
-		inc.data = inc.data[MaxNumOfEWMATruncateSize-1:]
-	}
 	if inc.lag >= inc.data.Length() {
 		return
 	}
-	emaData := 2.*value - inc.data[len(inc.data)-1-inc.lag]
+	emaData := 2.*value - inc.data.Index(inc.lag)
 	inc.zlema.Update(emaData)
 }
 
@@ -80,3 +77,13 @@ func (inc *ZLEMA) handleKLineWindowUpdate(interval types.Interval, window types.
 func (inc *ZLEMA) Bind(updater KLineWindowUpdater) {
 	updater.OnKLineWindowUpdate(inc.handleKLineWindowUpdate)
 }
+
+// WarmUpBars returns the bars of the EWMA plus the lag of the de-lagged data
+func (inc *ZLEMA) WarmUpBars() int {
+	lag := int((float64(inc.Window)-1.)/2. + 0.5)
+	return recursiveWarmUpFactor*inc.Window + lag
+}
+
+func (inc *ZLEMA) IsReady() bool {
+	return hasEnoughValues(&inc.data, inc.WarmUpBars())
+}
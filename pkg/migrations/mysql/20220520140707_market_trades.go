@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/c9s/rockhopper"
+)
+
+func init() {
+	AddMigration(upMarketTrades, downMarketTrades)
+
+}
+
+func upMarketTrades(ctx context.Context, tx rockhopper.SQLExecutor) (err error) {
+	// This code is executed when the migration is applied.
+
+	_, err = tx.ExecContext(ctx, "CREATE TABLE `market_trades`\n(\n    `gid`            BIGINT UNSIGNED         NOT NULL AUTO_INCREMENT,\n    `exchange`       VARCHAR(24)             NOT NULL,\n    `symbol`         VARCHAR(20)             NOT NULL,\n    -- id is the public trade id of the exchange\n    `id`             BIGINT UNSIGNED         NOT NULL,\n    `price`          DECIMAL(20, 8) UNSIGNED NOT NULL,\n    `quantity`       DECIMAL(20, 8) UNSIGNED NOT NULL,\n    `quote_quantity` DECIMAL(20, 8) UNSIGNED NOT NULL,\n    -- side is the side of the taker\n    `side`           VARCHAR(4)              NOT NULL DEFAULT '',\n    `is_buyer`       BOOLEAN                 NOT NULL DEFAULT FALSE,\n    `is_maker`       BOOLEAN                 NOT NULL DEFAULT FALSE,\n    `traded_at`      DATETIME(3)             NOT NULL,\n    PRIMARY KEY (`gid`),\n    UNIQUE KEY `id` (`exchange`, `symbol`, `id`)\n);")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "CREATE INDEX `market_trades_traded_at_symbol` ON `market_trades` (`traded_at`, `symbol`, `exchange`);")
+	if err != nil {
+		return err
+	}
+
+	return err
+}
+
+func downMarketTrades(ctx context.Context, tx rockhopper.SQLExecutor) (err error) {
+	// This code is executed when the migration is rolled back.
+
+	_, err = tx.ExecContext(ctx, "DROP INDEX `market_trades_traded_at_symbol` ON `market_trades`;")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DROP TABLE `market_trades`;")
+	if err != nil {
+		return err
+	}
+
+	return err
+}
@@ -0,0 +1,34 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/c9s/rockhopper"
+)
+
+func init() {
+	AddMigration(upFundingRates, downFundingRates)
+
+}
+
+func upFundingRates(ctx context.Context, tx rockhopper.SQLExecutor) (err error) {
+	// This code is executed when the migration is applied.
+
+	_, err = tx.ExecContext(ctx, "CREATE TABLE `funding_rates`\n(\n    `gid`          BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,\n    `exchange`     VARCHAR(24)     NOT NULL,\n    `symbol`       VARCHAR(20)     NOT NULL,\n    `funding_rate` DECIMAL(16, 8)  NOT NULL,\n    `funding_time` DATETIME(3)     NOT NULL,\n    PRIMARY KEY (`gid`),\n    UNIQUE KEY `funding_time` (`exchange`, `symbol`, `funding_time`)\n);")
+	if err != nil {
+		return err
+	}
+
+	return err
+}
+
+func downFundingRates(ctx context.Context, tx rockhopper.SQLExecutor) (err error) {
+	// This code is executed when the migration is rolled back.
+
+	_, err = tx.ExecContext(ctx, "DROP TABLE `funding_rates`;")
+	if err != nil {
+		return err
+	}
+
+	return err
+}
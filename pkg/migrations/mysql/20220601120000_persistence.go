@@ -0,0 +1,34 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/c9s/rockhopper"
+)
+
+func init() {
+	AddMigration(upPersistence, downPersistence)
+
+}
+
+func upPersistence(ctx context.Context, tx rockhopper.SQLExecutor) (err error) {
+	// This code is executed when the migration is applied.
+
+	_, err = tx.ExecContext(ctx, "CREATE TABLE `persistence`\n(\n    `gid`        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,\n    `store_id`   VARCHAR(191)    NOT NULL,\n    `version`    BIGINT UNSIGNED NOT NULL,\n    `data`       LONGTEXT        NOT NULL,\n    `created_at` DATETIME(3)     NOT NULL,\n    PRIMARY KEY (`gid`),\n    UNIQUE KEY `store_id_version` (`store_id`, `version`)\n);")
+	if err != nil {
+		return err
+	}
+
+	return err
+}
+
+func downPersistence(ctx context.Context, tx rockhopper.SQLExecutor) (err error) {
+	// This code is executed when the migration is rolled back.
+
+	_, err = tx.ExecContext(ctx, "DROP TABLE `persistence`;")
+	if err != nil {
+		return err
+	}
+
+	return err
+}
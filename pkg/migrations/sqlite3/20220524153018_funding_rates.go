@@ -0,0 +1,44 @@
+package sqlite3
+
+import (
+	"context"
+
+	"github.com/c9s/rockhopper"
+)
+
+func init() {
+	AddMigration(upFundingRates, downFundingRates)
+
+}
+
+func upFundingRates(ctx context.Context, tx rockhopper.SQLExecutor) (err error) {
+	// This code is executed when the migration is applied.
+
+	_, err = tx.ExecContext(ctx, "CREATE TABLE `funding_rates`\n(\n    `gid`          INTEGER PRIMARY KEY AUTOINCREMENT,\n    `exchange`     VARCHAR(24)    NOT NULL,\n    `symbol`       VARCHAR(20)    NOT NULL,\n    `funding_rate` DECIMAL(16, 8) NOT NULL,\n    `funding_time` DATETIME(3)    NOT NULL\n);")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "CREATE UNIQUE INDEX `funding_rates_exchange_symbol_funding_time` ON `funding_rates` (`exchange`, `symbol`, `funding_time`);")
+	if err != nil {
+		return err
+	}
+
+	return err
+}
+
+func downFundingRates(ctx context.Context, tx rockhopper.SQLExecutor) (err error) {
+	// This code is executed when the migration is rolled back.
+
+	_, err = tx.ExecContext(ctx, "DROP INDEX IF EXISTS `funding_rates_exchange_symbol_funding_time`;")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DROP TABLE IF EXISTS `funding_rates`;")
+	if err != nil {
+		return err
+	}
+
+	return err
+}
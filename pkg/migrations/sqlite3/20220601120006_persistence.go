@@ -0,0 +1,44 @@
+package sqlite3
+
+import (
+	"context"
+
+	"github.com/c9s/rockhopper"
+)
+
+func init() {
+	AddMigration(upPersistence, downPersistence)
+
+}
+
+func upPersistence(ctx context.Context, tx rockhopper.SQLExecutor) (err error) {
+	// This code is executed when the migration is applied.
+
+	_, err = tx.ExecContext(ctx, "CREATE TABLE `persistence`\n(\n    `gid`        INTEGER PRIMARY KEY AUTOINCREMENT,\n    `store_id`   VARCHAR(191) NOT NULL,\n    `version`    BIGINT       NOT NULL,\n    `data`       TEXT         NOT NULL,\n    `created_at` DATETIME(3)  NOT NULL\n);")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "CREATE UNIQUE INDEX `persistence_store_id_version` ON `persistence` (`store_id`, `version`);")
+	if err != nil {
+		return err
+	}
+
+	return err
+}
+
+func downPersistence(ctx context.Context, tx rockhopper.SQLExecutor) (err error) {
+	// This code is executed when the migration is rolled back.
+
+	_, err = tx.ExecContext(ctx, "DROP INDEX IF EXISTS `persistence_store_id_version`;")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DROP TABLE IF EXISTS `persistence`;")
+	if err != nil {
+		return err
+	}
+
+	return err
+}
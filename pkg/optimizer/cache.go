@@ -0,0 +1,64 @@
+package optimizer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/c9s/bbgo/pkg/backtest"
+	"github.com/c9s/bbgo/pkg/util"
+)
+
+// CachedExecutor caches the summary reports of the executor on the disk, the reports are keyed by the hash of the
+// rendered config json, so that the trials that have been run are not executed again.
+type CachedExecutor struct {
+	Executor Executor
+	Dir      string
+}
+
+func NewCachedExecutor(executor Executor, dir string) (*CachedExecutor, error) {
+	if err := util.SafeMkdirAll(dir); err != nil {
+		return nil, err
+	}
+
+	return &CachedExecutor{
+		Executor: executor,
+		Dir:      dir,
+	}, nil
+}
+
+func (e *CachedExecutor) cacheFile(configJson []byte) string {
+	sum := sha256.Sum256(configJson)
+	return filepath.Join(e.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (e *CachedExecutor) Execute(configJson []byte) (*backtest.SummaryReport, error) {
+	cacheFile := e.cacheFile(configJson)
+	if _, err := os.Stat(cacheFile); err == nil {
+		summaryReport, err := backtest.ReadSummaryReport(cacheFile)
+		if err == nil {
+			log.Debugf("trial result is loaded from cache: %s", cacheFile)
+			return summaryReport, nil
+		}
+
+		log.WithError(err).Warnf("can not read the cached trial result %s, executing the trial again", cacheFile)
+	}
+
+	summaryReport, err := e.Executor.Execute(configJson)
+	if err != nil {
+		return nil, err
+	}
+
+	// write to a temporary file first, so that a crash never leaves a broken cache file
+	tmpFile := cacheFile + ".tmp"
+	if err := util.WriteJsonFile(tmpFile, summaryReport); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmpFile, cacheFile); err != nil {
+		return nil, err
+	}
+
+	return summaryReport, nil
+}
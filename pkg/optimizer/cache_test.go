@@ -0,0 +1,122 @@
+package optimizer
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/backtest"
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+)
+
+// countingExecutor returns the length of the config json as the total profit
+type countingExecutor struct {
+	mu                 sync.Mutex
+	numOfExecutions    int
+	running            int
+	maxRunning         int
+	delay              time.Duration
+	failedConfigLength int
+}
+
+func (e *countingExecutor) Execute(configJson []byte) (*backtest.SummaryReport, error) {
+	e.mu.Lock()
+	e.numOfExecutions++
+	e.running++
+	if e.running > e.maxRunning {
+		e.maxRunning = e.running
+	}
+	e.mu.Unlock()
+
+	time.Sleep(e.delay)
+
+	e.mu.Lock()
+	e.running--
+	e.mu.Unlock()
+
+	if len(configJson) == e.failedConfigLength {
+		return nil, fmt.Errorf("back-test failed")
+	}
+
+	return &backtest.SummaryReport{TotalProfit: fixedpoint.NewFromInt(int64(len(configJson)))}, nil
+}
+
+func TestCachedExecutor(t *testing.T) {
+	executor := &countingExecutor{}
+	cached, err := NewCachedExecutor(executor, t.TempDir())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		report, err := cached.Execute([]byte(`{"a": 1}`))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "8", report.TotalProfit.String())
+		}
+	}
+	assert.Equal(t, 1, executor.numOfExecutions)
+
+	_, err = cached.Execute([]byte(`{"a": 2}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, executor.numOfExecutions)
+
+	// the failed trial is not cached
+	executor.failedConfigLength = len(`{"a": 3}`)
+	_, err = cached.Execute([]byte(`{"a": 3}`))
+	assert.Error(t, err)
+	executor.failedConfigLength = 0
+	_, err = cached.Execute([]byte(`{"a": 3}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, executor.numOfExecutions)
+}
+
+func TestExecuteAll(t *testing.T) {
+	executor := &countingExecutor{delay: 20 * time.Millisecond, failedConfigLength: 3}
+
+	var configs [][]byte
+	for i := 1; i <= 8; i++ {
+		configs = append(configs, make([]byte, i))
+	}
+
+	results := executeAll(executor, 4, configs)
+	if assert.Len(t, results, 8) {
+		for i, result := range results {
+			if i == 2 {
+				assert.Error(t, result.err)
+				continue
+			}
+
+			if assert.NoError(t, result.err) {
+				assert.Equal(t, int64(i+1), result.summaryReport.TotalProfit.Int64())
+			}
+		}
+	}
+
+	assert.Equal(t, 8, executor.numOfExecutions)
+	assert.LessOrEqual(t, executor.maxRunning, 4)
+	assert.Greater(t, executor.maxRunning, 1)
+}
+
+func TestGridOptimizer_Workers(t *testing.T) {
+	executor := &countingExecutor{delay: 10 * time.Millisecond}
+	optz := &GridOptimizer{
+		Config: &Config{
+			Executor: &ExecutorConfig{MaxNumOfProcesses: 3},
+			Matrix: []SelectorConfig{
+				{Type: "iterate", Label: "interval", Path: "/interval", Values: []string{"1m", "5m", "15m"}},
+				{Type: "range", Label: "amount", Path: "/amount", Min: fixedpoint.NewFromInt(10), Max: fixedpoint.NewFromInt(1000), Step: fixedpoint.NewFromInt(990)},
+			},
+		},
+	}
+
+	metrics, err := optz.Run(executor, []byte(`{"interval": "1m", "amount": 1}`))
+	if assert.NoError(t, err) && assert.Len(t, metrics, 6) {
+		// the longest config json goes first
+		assert.Equal(t, []interface{}{"15m", fixedpoint.NewFromInt(1000)}, metrics[0].Params)
+	}
+
+	assert.LessOrEqual(t, executor.maxRunning, 3)
+}
@@ -1,11 +1,13 @@
 package optimizer
 
 import (
+	"fmt"
 	"io/ioutil"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
 )
 
 type SelectorConfig struct {
@@ -18,10 +20,61 @@ type SelectorConfig struct {
 	Step   fixedpoint.Value `json:"step,omitempty" yaml:"step,omitempty"`
 }
 
+// ExecutorConfig is the config of the back-test executor
+type ExecutorConfig struct {
+	// MaxNumOfProcesses is the number of the back-test processes that run concurrently, the default is 1
+	MaxNumOfProcesses int `yaml:"maxNumOfProcesses,omitempty"`
+
+	// Timeout is the timeout of one trial, like "30m", zero means no timeout
+	Timeout types.Duration `yaml:"timeout,omitempty"`
+
+	// CacheDir is the directory of the trial results, the trials that have been run are skipped
+	CacheDir string `yaml:"cacheDir,omitempty"`
+}
+
 type Config struct {
+	// Objective is the name of the metric value function to maximize, see MetricValueFuncs.
+	// The default objective is totalProfit.
+	Objective string `yaml:"objective,omitempty"`
+
+	// Algorithm is the search algorithm of the optimizer: grid, random, lhs or tpe. The default algorithm is grid.
+	Algorithm string `yaml:"algorithm,omitempty"`
+
+	// MaxTrials is the trial budget of the random, lhs and tpe algorithms
+	MaxTrials int `yaml:"maxTrials,omitempty"`
+
+	// Seed is the random seed of the sampling algorithms, zero means a time-based seed
+	Seed int64 `yaml:"seed,omitempty"`
+
+	Executor *ExecutorConfig `yaml:"executor,omitempty"`
+
+	// WalkForward is the config of the walk-forward mode
+	WalkForward *WalkForwardConfig `yaml:"walkForward,omitempty"`
+
 	Matrix []SelectorConfig `yaml:"matrix"`
 }
 
+func (c *Config) numOfWorkers() int {
+	if c.Executor != nil && c.Executor.MaxNumOfProcesses > 0 {
+		return c.Executor.MaxNumOfProcesses
+	}
+	return 1
+}
+
+// MetricValueFunc returns the metric value function of the objective
+func (c *Config) MetricValueFunc() (MetricValueFunc, error) {
+	if c.Objective == "" {
+		return TotalProfitMetricValueFunc, nil
+	}
+
+	f, ok := MetricValueFuncs[c.Objective]
+	if !ok {
+		return nil, fmt.Errorf("unknown optimizer objective: %s", c.Objective)
+	}
+
+	return f, nil
+}
+
 func LoadConfig(yamlConfigFileName string) (*Config, error) {
 	configYaml, err := ioutil.ReadFile(yamlConfigFileName)
 	if err != nil {
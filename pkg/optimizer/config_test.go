@@ -0,0 +1,22 @@
+package optimizer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfig_ExecutorTimeout(t *testing.T) {
+	var config Config
+	err := yaml.Unmarshal([]byte(`
+executor:
+  maxNumOfProcesses: 4
+  timeout: 30m
+`), &config)
+	if assert.NoError(t, err) && assert.NotNil(t, config.Executor) {
+		assert.Equal(t, 4, config.Executor.MaxNumOfProcesses)
+		assert.Equal(t, 30*time.Minute, config.Executor.Timeout.Duration())
+	}
+}
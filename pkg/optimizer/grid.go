@@ -2,10 +2,6 @@ package optimizer
 
 import (
 	"encoding/json"
-	"fmt"
-	"sort"
-
-	"github.com/evanphx/json-patch/v5"
 
 	"github.com/c9s/bbgo/pkg/backtest"
 	"github.com/c9s/bbgo/pkg/fixedpoint"
@@ -17,10 +13,61 @@ var TotalProfitMetricValueFunc = func(summaryReport *backtest.SummaryReport) fix
 	return summaryReport.TotalProfit
 }
 
+// performanceMetricValueFunc returns the metric value function that reads the summary performance metrics,
+// the reports without the metrics are valued as zero
+func performanceMetricValueFunc(f func(metrics *backtest.PerformanceMetrics) fixedpoint.Value) MetricValueFunc {
+	return func(summaryReport *backtest.SummaryReport) fixedpoint.Value {
+		if summaryReport.Metrics == nil {
+			return fixedpoint.Zero
+		}
+		return f(summaryReport.Metrics)
+	}
+}
+
+var SharpeRatioMetricValueFunc = performanceMetricValueFunc(func(metrics *backtest.PerformanceMetrics) fixedpoint.Value {
+	return metrics.SharpeRatio
+})
+
+var SortinoRatioMetricValueFunc = performanceMetricValueFunc(func(metrics *backtest.PerformanceMetrics) fixedpoint.Value {
+	return metrics.SortinoRatio
+})
+
+var CalmarRatioMetricValueFunc = performanceMetricValueFunc(func(metrics *backtest.PerformanceMetrics) fixedpoint.Value {
+	return metrics.CalmarRatio
+})
+
+// MaxDrawdownMetricValueFunc negates the max drawdown percent, so that the smaller drawdown ranks higher
+var MaxDrawdownMetricValueFunc = performanceMetricValueFunc(func(metrics *backtest.PerformanceMetrics) fixedpoint.Value {
+	return metrics.MaxDrawdownPercent.Neg()
+})
+
+var WinRateMetricValueFunc = performanceMetricValueFunc(func(metrics *backtest.PerformanceMetrics) fixedpoint.Value {
+	return metrics.WinRate
+})
+
+var ProfitFactorMetricValueFunc = performanceMetricValueFunc(func(metrics *backtest.PerformanceMetrics) fixedpoint.Value {
+	return metrics.ProfitFactor
+})
+
+// MetricValueFuncs are the metric value functions that can be selected by the objective of the optimizer config,
+// the higher metric value is the better.
+var MetricValueFuncs = map[string]MetricValueFunc{
+	"totalProfit":  TotalProfitMetricValueFunc,
+	"sharpeRatio":  SharpeRatioMetricValueFunc,
+	"sortinoRatio": SortinoRatioMetricValueFunc,
+	"calmarRatio":  CalmarRatioMetricValueFunc,
+	"maxDrawdown":  MaxDrawdownMetricValueFunc,
+	"winRate":      WinRateMetricValueFunc,
+	"profitFactor": ProfitFactorMetricValueFunc,
+}
+
 type Metric struct {
 	Labels []string         `json:"labels,omitempty"`
 	Params []interface{}    `json:"params,omitempty"`
 	Value  fixedpoint.Value `json:"value,omitempty"`
+
+	// Metrics are the performance metrics of the trial back-test
+	Metrics *backtest.PerformanceMetrics `json:"metrics,omitempty"`
 }
 
 type GridOptimizer struct {
@@ -37,6 +84,7 @@ func (o *GridOptimizer) buildOps() []OpFunc {
 	o.ParamLabels = make([]string, len(o.Config.Matrix))
 
 	for i, selector := range o.Config.Matrix {
+		var selector = selector
 		var path = selector.Path
 		var ii = i // copy variable because we need to use them in the closure
 
@@ -62,15 +110,7 @@ func (o *GridOptimizer) buildOps() []OpFunc {
 
 			f := func(configJson []byte, next func(configJson []byte) error) error {
 				for _, val := range values {
-					jsonOp := []byte(reformatJson(fmt.Sprintf(`[{"op": "replace", "path": "%s", "value": %v }]`, path, val)))
-					patch, err := jsonpatch.DecodePatch(jsonOp)
-					if err != nil {
-						return err
-					}
-
-					log.Debugf("json op: %s", jsonOp)
-
-					patchedJson, err := patch.ApplyIndent(configJson, "  ")
+					patchedJson, err := patchConfig(configJson, selector, val)
 					if err != nil {
 						return err
 					}
@@ -92,15 +132,7 @@ func (o *GridOptimizer) buildOps() []OpFunc {
 				for _, val := range values {
 					log.Debugf("%d %s: %v of %v", ii, path, val, values)
 
-					jsonOp := []byte(reformatJson(fmt.Sprintf(`[{"op": "replace", "path": "%s", "value": "%s"}]`, path, val)))
-					patch, err := jsonpatch.DecodePatch(jsonOp)
-					if err != nil {
-						return err
-					}
-
-					log.Debugf("json op: %s", jsonOp)
-
-					patchedJson, err := patch.ApplyIndent(configJson, "  ")
+					patchedJson, err := patchConfig(configJson, selector, val)
 					if err != nil {
 						return err
 					}
@@ -123,25 +155,19 @@ func (o *GridOptimizer) buildOps() []OpFunc {
 func (o *GridOptimizer) Run(executor Executor, configJson []byte) ([]Metric, error) {
 	o.CurrentParams = make([]interface{}, len(o.Config.Matrix))
 
-	var metrics []Metric
+	metricValueFunc, err := o.Config.MetricValueFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	// collect the configs of all the combinations, and then execute them with the worker pool
+	var configs [][]byte
+	var params [][]interface{}
 
 	var ops = o.buildOps()
 	var app = func(configJson []byte, next func(configJson []byte) error) error {
-		summaryReport, err := executor.Execute(configJson)
-		if err != nil {
-			return err
-		}
-
-		// TODO: Add more metric value function
-		metricValue := TotalProfitMetricValueFunc(summaryReport)
-
-		metrics = append(metrics, Metric{
-			Params: o.CurrentParams,
-			Labels: o.ParamLabels,
-			Value:  metricValue,
-		})
-
-		log.Infof("current params: %+v => %+v", o.CurrentParams, metricValue)
+		configs = append(configs, configJson)
+		params = append(params, append([]interface{}(nil), o.CurrentParams...))
 		return nil
 	}
 
@@ -159,14 +185,37 @@ func (o *GridOptimizer) Run(executor Executor, configJson []byte) ([]Metric, err
 		}
 	}
 
-	err := wrapper(configJson)
+	if err := wrapper(configJson); err != nil {
+		return nil, err
+	}
+
+	var metrics []Metric
+	var lastErr error
+	for i, result := range executeAll(executor, o.Config.numOfWorkers(), configs) {
+		if result.err != nil {
+			log.WithError(result.err).Errorf("params: %+v back-test error", params[i])
+			lastErr = result.err
+			continue
+		}
+
+		metricValue := metricValueFunc(result.summaryReport)
+		metrics = append(metrics, Metric{
+			Params:  params[i],
+			Labels:  o.ParamLabels,
+			Value:   metricValue,
+			Metrics: result.summaryReport.Metrics,
+		})
+
+		log.Infof("current params: %+v => %+v", params[i], metricValue)
+	}
+
+	// all the trials failed
+	if len(metrics) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
 
-	sort.Slice(metrics, func(i, j int) bool {
-		a := metrics[i].Value
-		b := metrics[j].Value
-		return a.Compare(b) > 0
-	})
-	return metrics, err
+	sortMetrics(metrics)
+	return metrics, nil
 }
 
 func reformatJson(text string) string {
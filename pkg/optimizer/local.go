@@ -1,10 +1,13 @@
 package optimizer
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
@@ -18,11 +21,15 @@ type Executor interface {
 	Execute(configJson []byte) (*backtest.SummaryReport, error)
 }
 
+// LocalProcessExecutor runs the back-test in a bbgo subprocess, it's safe to execute the configs concurrently
 type LocalProcessExecutor struct {
 	Bin       string
 	WorkDir   string
 	ConfigDir string
 	OutputDir string
+
+	// Timeout kills the back-test process that runs longer than the timeout, zero means no timeout
+	Timeout time.Duration
 }
 
 func (e *LocalProcessExecutor) Execute(configJson []byte) (*backtest.SummaryReport, error) {
@@ -41,13 +48,28 @@ func (e *LocalProcessExecutor) Execute(configJson []byte) (*backtest.SummaryRepo
 		return nil, err
 	}
 
+	defer func() {
+		_ = tf.Close()
+		_ = os.Remove(tf.Name())
+	}()
+
 	if _, err = tf.Write(yamlConfig); err != nil {
 		return nil, err
 	}
 
-	c := exec.Command(e.Bin, "backtest", "--config", tf.Name(), "--output", e.OutputDir, "--subdir")
+	ctx := context.Background()
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+
+	c := exec.CommandContext(ctx, e.Bin, "backtest", "--config", tf.Name(), "--output", e.OutputDir, "--subdir")
 	output, err := c.Output()
 	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("back-test timeout after %s: %w", e.Timeout, err)
+		}
 		return nil, err
 	}
 
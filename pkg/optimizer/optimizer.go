@@ -0,0 +1,201 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	AlgorithmGrid   = "grid"
+	AlgorithmRandom = "random"
+	AlgorithmLHS    = "lhs"
+	AlgorithmTPE    = "tpe"
+)
+
+const defaultMaxTrials = 50
+
+// Optimizer runs the back-tests with the parameters of the config matrix and returns the metrics ranked by the
+// metric value, the best metric goes first.
+type Optimizer interface {
+	Run(executor Executor, configJson []byte) ([]Metric, error)
+}
+
+// New creates the optimizer of the algorithm, the algorithm of the config is used if the given algorithm is empty
+func New(algorithm string, config *Config) (Optimizer, error) {
+	if algorithm == "" {
+		algorithm = config.Algorithm
+	}
+
+	switch algorithm {
+	case "", AlgorithmGrid:
+		return &GridOptimizer{Config: config}, nil
+
+	case AlgorithmRandom:
+		return &RandomOptimizer{Config: config}, nil
+
+	case AlgorithmLHS:
+		return &RandomOptimizer{Config: config, LatinHypercube: true}, nil
+
+	case AlgorithmTPE:
+		return &TPEOptimizer{Config: config}, nil
+	}
+
+	return nil, fmt.Errorf("unknown optimizer algorithm: %s", algorithm)
+}
+
+// trial is an evaluated point of the search space, units are the positions in the unit interval of the dimensions
+type trial struct {
+	units  []float64
+	params []interface{}
+	value  float64
+}
+
+// proposeFunc proposes the units of the next trial from the evaluated trials,
+// it's called once per trial within the trial budget
+type proposeFunc func(trials []trial) []float64
+
+// searchRunner runs the trials proposed by the search algorithm within the trial budget
+type searchRunner struct {
+	config *Config
+	dims   []dimension
+	rand   *rand.Rand
+}
+
+func newSearchRunner(config *Config) (*searchRunner, error) {
+	dims, err := newDimensions(config)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &searchRunner{
+		config: config,
+		dims:   dims,
+		rand:   rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+func (r *searchRunner) maxTrials() int {
+	if r.config.MaxTrials > 0 {
+		return r.config.MaxTrials
+	}
+	return defaultMaxTrials
+}
+
+func (r *searchRunner) labels() []string {
+	var labels []string
+	for _, d := range r.dims {
+		labels = append(labels, d.label)
+	}
+	return labels
+}
+
+func (r *searchRunner) run(executor Executor, configJson []byte, propose proposeFunc) ([]Metric, error) {
+	metricValueFunc, err := r.config.MetricValueFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	var trials []trial
+	var metrics []Metric
+	var lastErr error
+	var evaluated = map[string]float64{}
+	var labels = r.labels()
+	var numOfWorkers = r.config.numOfWorkers()
+
+	// the trials are proposed in batches of the worker pool size, the trials of a batch are proposed
+	// by the same evaluated trials
+	var numOfProposals = 0
+	for numOfProposals < r.maxTrials() {
+		var batch, duplicates []trial
+		var configs [][]byte
+		var pending = map[string]struct{}{}
+
+		for len(batch) < numOfWorkers && numOfProposals < r.maxTrials() {
+			units := propose(trials)
+			numOfProposals++
+
+			params := make([]interface{}, len(r.dims))
+			patchedJson := configJson
+			for j, d := range r.dims {
+				params[j] = d.value(units[j])
+				patchedJson, err = patchConfig(patchedJson, d.selector, params[j])
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// the discrete search space may propose the evaluated parameters again
+			key := paramsKey(params)
+			if value, ok := evaluated[key]; ok {
+				log.Debugf("trial #%d params: %+v was evaluated", len(trials), params)
+				trials = append(trials, trial{units: units, params: params, value: value})
+				continue
+			}
+
+			if _, ok := pending[key]; ok {
+				duplicates = append(duplicates, trial{units: units, params: params})
+				continue
+			}
+
+			pending[key] = struct{}{}
+			batch = append(batch, trial{units: units, params: params})
+			configs = append(configs, patchedJson)
+		}
+
+		for i, result := range executeAll(executor, numOfWorkers, configs) {
+			t := batch[i]
+			if result.err != nil {
+				log.WithError(result.err).Errorf("trial #%d params: %+v back-test error", len(trials), t.params)
+				lastErr = result.err
+
+				// the failed trial is treated as the worst trial
+				t.value = math.Inf(-1)
+				trials = append(trials, t)
+				continue
+			}
+
+			metricValue := metricValueFunc(result.summaryReport)
+			t.value = metricValue.Float64()
+			evaluated[paramsKey(t.params)] = t.value
+			trials = append(trials, t)
+			metrics = append(metrics, Metric{
+				Labels:  labels,
+				Params:  t.params,
+				Value:   metricValue,
+				Metrics: result.summaryReport.Metrics,
+			})
+
+			log.Infof("trial #%d params: %+v => %+v", len(trials)-1, t.params, metricValue)
+		}
+
+		for _, t := range duplicates {
+			t.value = math.Inf(-1)
+			if value, ok := evaluated[paramsKey(t.params)]; ok {
+				t.value = value
+			}
+			trials = append(trials, t)
+		}
+	}
+
+	// all the trials failed
+	if len(metrics) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	sortMetrics(metrics)
+	return metrics, nil
+}
+
+func sortMetrics(metrics []Metric) {
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Value.Compare(metrics[j].Value) > 0
+	})
+}
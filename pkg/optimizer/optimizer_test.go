@@ -0,0 +1,105 @@
+package optimizer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/backtest"
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+)
+
+// quadraticExecutor values the config by -(x - 3)^2 - (y - 7)^2, the best params are x = 3, y = 7
+type quadraticExecutor struct {
+	numOfExecutions int
+}
+
+func (e *quadraticExecutor) Execute(configJson []byte) (*backtest.SummaryReport, error) {
+	e.numOfExecutions++
+
+	var config struct {
+		X float64 `json:"x"`
+		Y float64 `json:"y"`
+	}
+	if err := json.Unmarshal(configJson, &config); err != nil {
+		return nil, err
+	}
+
+	value := -(config.X-3)*(config.X-3) - (config.Y-7)*(config.Y-7)
+	return &backtest.SummaryReport{TotalProfit: fixedpoint.NewFromFloat(value)}, nil
+}
+
+func newQuadraticConfig(algorithm string) *Config {
+	return &Config{
+		Algorithm: algorithm,
+		MaxTrials: 60,
+		Seed:      1,
+		Matrix: []SelectorConfig{
+			{Type: "range", Label: "x", Path: "/x", Min: fixedpoint.Zero, Max: fixedpoint.NewFromInt(10), Step: fixedpoint.One},
+			{Type: "range", Label: "y", Path: "/y", Min: fixedpoint.Zero, Max: fixedpoint.NewFromInt(10), Step: fixedpoint.One},
+		},
+	}
+}
+
+func TestOptimizers(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRandom, AlgorithmLHS, AlgorithmTPE} {
+		t.Run(algorithm, func(t *testing.T) {
+			optz, err := New("", newQuadraticConfig(algorithm))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			executor := &quadraticExecutor{}
+			metrics, err := optz.Run(executor, []byte(`{"x": 0, "y": 0}`))
+			if !assert.NoError(t, err) || !assert.NotEmpty(t, metrics) {
+				return
+			}
+
+			// the duplicated params are not executed again
+			assert.Equal(t, len(metrics), executor.numOfExecutions)
+			assert.LessOrEqual(t, executor.numOfExecutions, 60)
+			assert.Equal(t, []string{"x", "y"}, metrics[0].Labels)
+
+			for i := 1; i < len(metrics); i++ {
+				assert.True(t, metrics[i-1].Value.Compare(metrics[i].Value) >= 0)
+			}
+
+			// the best trial should be close to the optimum
+			assert.GreaterOrEqual(t, metrics[0].Value.Float64(), -5.0)
+		})
+	}
+}
+
+func TestLatinHypercube(t *testing.T) {
+	runner, err := newSearchRunner(newQuadraticConfig(AlgorithmLHS))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	samples := latinHypercube(runner, 10)
+	for dim := 0; dim < 2; dim++ {
+		strata := map[int]bool{}
+		for _, sample := range samples {
+			strata[int(sample[dim]*10)] = true
+		}
+		assert.Len(t, strata, 10)
+	}
+}
+
+func TestDimension_Value(t *testing.T) {
+	d := dimension{selector: SelectorConfig{Type: "range", Min: fixedpoint.NewFromFloat(0.1), Max: fixedpoint.NewFromFloat(0.2), Step: fixedpoint.NewFromFloat(0.05)}}
+	assert.Equal(t, 3, d.numOfChoices())
+	assert.Equal(t, "0.1", d.value(0).(fixedpoint.Value).String())
+	assert.Equal(t, "0.15", d.value(0.5).(fixedpoint.Value).String())
+	assert.Equal(t, "0.2", d.value(1).(fixedpoint.Value).String())
+
+	d = dimension{selector: SelectorConfig{Type: "iterate", Values: []string{"1m", "5m"}}}
+	assert.Equal(t, "1m", d.value(0.2))
+	assert.Equal(t, "5m", d.value(0.7))
+}
+
+func TestNew_UnknownAlgorithm(t *testing.T) {
+	_, err := New("annealing", &Config{})
+	assert.Error(t, err)
+}
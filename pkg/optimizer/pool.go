@@ -0,0 +1,43 @@
+package optimizer
+
+import (
+	"sync"
+
+	"github.com/c9s/bbgo/pkg/backtest"
+)
+
+type executeResult struct {
+	summaryReport *backtest.SummaryReport
+	err           error
+}
+
+// executeAll executes the configs with the worker pool of the given size,
+// the results are in the same order as the configs.
+func executeAll(executor Executor, numOfWorkers int, configs [][]byte) []executeResult {
+	if numOfWorkers < 1 {
+		numOfWorkers = 1
+	}
+
+	results := make([]executeResult, len(configs))
+	indexC := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < numOfWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexC {
+				summaryReport, err := executor.Execute(configs[idx])
+				results[idx] = executeResult{summaryReport: summaryReport, err: err}
+			}
+		}()
+	}
+
+	for idx := range configs {
+		indexC <- idx
+	}
+	close(indexC)
+
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,53 @@
+package optimizer
+
+// RandomOptimizer samples the parameters of the config matrix randomly within the trial budget.
+//
+// With LatinHypercube, the range of every parameter is divided into MaxTrials strata of equal probability,
+// and every stratum is sampled exactly once, so that the samples spread over the whole search space.
+type RandomOptimizer struct {
+	Config *Config
+
+	LatinHypercube bool
+}
+
+func (o *RandomOptimizer) Run(executor Executor, configJson []byte) ([]Metric, error) {
+	runner, err := newSearchRunner(o.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	if !o.LatinHypercube {
+		return runner.run(executor, configJson, func(trials []trial) []float64 {
+			units := make([]float64, len(runner.dims))
+			for i := range units {
+				units[i] = runner.rand.Float64()
+			}
+			return units
+		})
+	}
+
+	samples := latinHypercube(runner, runner.maxTrials())
+	next := 0
+	return runner.run(executor, configJson, func(trials []trial) []float64 {
+		units := samples[next]
+		next++
+		return units
+	})
+}
+
+// latinHypercube generates n samples, every dimension of the samples is a random permutation of the n strata
+func latinHypercube(runner *searchRunner, n int) [][]float64 {
+	samples := make([][]float64, n)
+	for i := range samples {
+		samples[i] = make([]float64, len(runner.dims))
+	}
+
+	for j := range runner.dims {
+		perm := runner.rand.Perm(n)
+		for i := 0; i < n; i++ {
+			samples[i][j] = (float64(perm[i]) + runner.rand.Float64()) / float64(n)
+		}
+	}
+
+	return samples
+}
@@ -0,0 +1,107 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/evanphx/json-patch/v5"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+)
+
+// patchConfig replaces the value of the selector path in the config json
+func patchConfig(configJson []byte, selector SelectorConfig, value interface{}) ([]byte, error) {
+	var jsonOp []byte
+	switch selector.Type {
+	case "range":
+		jsonOp = []byte(reformatJson(fmt.Sprintf(`[{"op": "replace", "path": "%s", "value": %v }]`, selector.Path, value)))
+	default:
+		jsonOp = []byte(reformatJson(fmt.Sprintf(`[{"op": "replace", "path": "%s", "value": "%s"}]`, selector.Path, value)))
+	}
+
+	patch, err := jsonpatch.DecodePatch(jsonOp)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("json op: %s", jsonOp)
+
+	return patch.ApplyIndent(configJson, "  ")
+}
+
+// dimension maps a number in the unit interval [0, 1) to the parameter value of the selector.
+//
+// The range selector with a step is discretized by the step, and the range selector without a step is sampled
+// continuously. The iterate selector is mapped to its values.
+type dimension struct {
+	selector SelectorConfig
+	label    string
+}
+
+func newDimensions(config *Config) ([]dimension, error) {
+	var dims []dimension
+	for _, selector := range config.Matrix {
+		label := selector.Label
+		if label == "" {
+			label = selector.Path
+		}
+
+		switch selector.Type {
+		case "range":
+			if selector.Max.Compare(selector.Min) < 0 {
+				return nil, fmt.Errorf("%s: max %v is less than min %v", label, selector.Max, selector.Min)
+			}
+
+		case "iterate":
+			if len(selector.Values) == 0 {
+				return nil, fmt.Errorf("%s: iterate selector requires values", label)
+			}
+
+		default:
+			return nil, fmt.Errorf("%s: unsupported selector type %q", label, selector.Type)
+		}
+
+		dims = append(dims, dimension{selector: selector, label: label})
+	}
+
+	return dims, nil
+}
+
+// numOfChoices returns the number of the discrete values, zero means the dimension is continuous
+func (d dimension) numOfChoices() int {
+	switch d.selector.Type {
+	case "range":
+		if d.selector.Step.Sign() <= 0 {
+			return 0
+		}
+		return int(d.selector.Max.Sub(d.selector.Min).Div(d.selector.Step).Float64()+1e-9) + 1
+
+	default:
+		return len(d.selector.Values)
+	}
+}
+
+func (d dimension) value(u float64) interface{} {
+	u = math.Min(math.Max(u, 0), math.Nextafter(1, 0))
+
+	if d.selector.Type == "iterate" {
+		return d.selector.Values[int(u*float64(len(d.selector.Values)))]
+	}
+
+	n := d.numOfChoices()
+	if n == 0 {
+		return d.selector.Min.Add(d.selector.Max.Sub(d.selector.Min).Mul(fixedpoint.NewFromFloat(u)))
+	}
+
+	return d.selector.Min.Add(d.selector.Step.Mul(fixedpoint.NewFromInt(int64(u * float64(n)))))
+}
+
+// paramsKey returns the key of the parameter values for finding the duplicated trials
+func paramsKey(params []interface{}) string {
+	var parts []string
+	for _, p := range params {
+		parts = append(parts, fmt.Sprintf("%v", p))
+	}
+	return strings.Join(parts, "|")
+}
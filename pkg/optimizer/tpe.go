@@ -0,0 +1,144 @@
+package optimizer
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	defaultTPEGamma         = 0.25
+	defaultTPENumCandidates = 24
+	defaultTPEStartupTrials = 10
+)
+
+// TPEOptimizer is a Bayesian optimizer that uses the tree-structured Parzen estimator (TPE).
+//
+// After the random startup trials, the evaluated trials are split into the good trials (the best Gamma fraction)
+// and the bad trials. For every parameter, the candidates are sampled from the density of the good trials l(x),
+// and the candidate that maximizes l(x) / g(x) is chosen, where g(x) is the density of the bad trials.
+type TPEOptimizer struct {
+	Config *Config
+
+	// NumStartupTrials is the number of the random trials before the estimator is used
+	NumStartupTrials int
+
+	// Gamma is the fraction of the trials that are treated as the good trials
+	Gamma float64
+
+	// NumCandidates is the number of the candidates sampled for every parameter
+	NumCandidates int
+}
+
+func (o *TPEOptimizer) Run(executor Executor, configJson []byte) ([]Metric, error) {
+	runner, err := newSearchRunner(o.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	numStartupTrials := o.NumStartupTrials
+	if numStartupTrials <= 0 {
+		numStartupTrials = defaultTPEStartupTrials
+	}
+
+	gamma := o.Gamma
+	if gamma <= 0 || gamma >= 1 {
+		gamma = defaultTPEGamma
+	}
+
+	numCandidates := o.NumCandidates
+	if numCandidates <= 0 {
+		numCandidates = defaultTPENumCandidates
+	}
+
+	return runner.run(executor, configJson, func(trials []trial) []float64 {
+		units := make([]float64, len(runner.dims))
+		if len(trials) < numStartupTrials {
+			for i := range units {
+				units[i] = runner.rand.Float64()
+			}
+			return units
+		}
+
+		good, bad := splitTrials(trials, gamma)
+		for i := range units {
+			l := newParzenEstimator(trialUnits(good, i))
+			g := newParzenEstimator(trialUnits(bad, i))
+
+			best, bestScore := 0.0, math.Inf(-1)
+			for c := 0; c < numCandidates; c++ {
+				x := l.sample(runner.rand)
+				score := math.Log(l.pdf(x)) - math.Log(g.pdf(x))
+				if score > bestScore {
+					best, bestScore = x, score
+				}
+			}
+
+			units[i] = best
+		}
+
+		return units
+	})
+}
+
+// splitTrials splits the trials into the best gamma fraction and the rest
+func splitTrials(trials []trial, gamma float64) (good, bad []trial) {
+	sorted := append([]trial(nil), trials...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].value > sorted[j].value
+	})
+
+	n := int(math.Ceil(gamma * float64(len(sorted))))
+	if n < 1 {
+		n = 1
+	}
+
+	return sorted[:n], sorted[n:]
+}
+
+func trialUnits(trials []trial, dim int) []float64 {
+	units := make([]float64, len(trials))
+	for i, t := range trials {
+		units[i] = t.units[dim]
+	}
+	return units
+}
+
+// parzenEstimator is the mixture of the gaussian kernels at the observed points and the uniform prior on [0, 1)
+type parzenEstimator struct {
+	mus   []float64
+	sigma float64
+}
+
+func newParzenEstimator(points []float64) *parzenEstimator {
+	// the bandwidth shrinks as the points increase
+	sigma := 1.0 / math.Pow(float64(len(points)+1), 0.5)
+	sigma = math.Min(math.Max(sigma, 0.02), 0.5)
+	return &parzenEstimator{mus: points, sigma: sigma}
+}
+
+func (p *parzenEstimator) pdf(x float64) float64 {
+	density := 1.0 // the uniform prior
+	for _, mu := range p.mus {
+		z := (x - mu) / p.sigma
+		density += math.Exp(-0.5*z*z) / (p.sigma * math.Sqrt(2*math.Pi))
+	}
+	return density / float64(len(p.mus)+1)
+}
+
+func (p *parzenEstimator) sample(r *rand.Rand) float64 {
+	idx := r.Intn(len(p.mus) + 1)
+	if idx == len(p.mus) {
+		return r.Float64()
+	}
+
+	// resample until the point falls in the unit interval
+	for i := 0; i < 100; i++ {
+		x := p.mus[idx] + r.NormFloat64()*p.sigma
+		if x >= 0 && x < 1 {
+			return x
+		}
+	}
+
+	return p.mus[idx]
+}
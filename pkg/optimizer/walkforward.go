@@ -0,0 +1,339 @@
+package optimizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/evanphx/json-patch/v5"
+
+	"github.com/c9s/bbgo/pkg/backtest"
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const day = 24 * time.Hour
+
+// WalkForwardConfig splits the back-test time range into the rolling in-sample and out-of-sample windows
+type WalkForwardConfig struct {
+	// InSampleDays is the length of the in-sample window that the parameters are optimized on
+	InSampleDays int `yaml:"inSampleDays"`
+
+	// OutOfSampleDays is the length of the out-of-sample window that follows the in-sample window
+	OutOfSampleDays int `yaml:"outOfSampleDays"`
+
+	// StepDays is the distance between the folds, the default is OutOfSampleDays
+	StepDays int `yaml:"stepDays,omitempty"`
+
+	// TopN is the number of the best in-sample trials of every fold that are compared for the stable parameters,
+	// the default is 5
+	TopN int `yaml:"topN,omitempty"`
+
+	// StableParamBuckets is the number of the buckets that the continuous range parameters (the range without a step)
+	// are divided into when the stable parameters are compared, the default is 10
+	StableParamBuckets int `yaml:"stableParamBuckets,omitempty"`
+}
+
+// Window is a back-test time range
+type Window struct {
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+}
+
+// WalkForwardFold is the result of one fold, the best parameters of the in-sample window are evaluated
+// on the out-of-sample window
+type WalkForwardFold struct {
+	InSample    Window `json:"inSample"`
+	OutOfSample Window `json:"outOfSample"`
+
+	Labels []string      `json:"labels"`
+	Params []interface{} `json:"params"`
+
+	InSampleValue    fixedpoint.Value `json:"inSampleValue"`
+	OutOfSampleValue fixedpoint.Value `json:"outOfSampleValue"`
+
+	InSampleMetrics    *backtest.PerformanceMetrics `json:"inSampleMetrics,omitempty"`
+	OutOfSampleMetrics *backtest.PerformanceMetrics `json:"outOfSampleMetrics,omitempty"`
+}
+
+// StableParams is the parameter set that is ranked in the top in-sample trials of multiple folds, the continuous
+// parameters are compared by their buckets and the parameters of the best trial in the buckets are reported
+type StableParams struct {
+	Labels               []string         `json:"labels"`
+	Params               []interface{}    `json:"params"`
+	NumOfFolds           int              `json:"numOfFolds"`
+	AverageInSampleValue fixedpoint.Value `json:"averageInSampleValue"`
+}
+
+type WalkForwardReport struct {
+	Folds        []WalkForwardFold `json:"folds"`
+	StableParams []StableParams    `json:"stableParams,omitempty"`
+}
+
+// WalkForwardOptimizer runs the optimizer on every in-sample window, and evaluates the best parameters
+// on the following out-of-sample window.
+type WalkForwardOptimizer struct {
+	Config    *Config
+	Optimizer Optimizer
+}
+
+// SplitWalkForwardWindows splits the time range into the rolling in-sample and out-of-sample windows
+func SplitWalkForwardWindows(startTime, endTime time.Time, config *WalkForwardConfig) (inSamples, outOfSamples []Window, err error) {
+	if config.InSampleDays <= 0 || config.OutOfSampleDays <= 0 {
+		return nil, nil, fmt.Errorf("walk-forward inSampleDays and outOfSampleDays must be positive")
+	}
+
+	step := config.StepDays
+	if step <= 0 {
+		step = config.OutOfSampleDays
+	}
+
+	inSample := time.Duration(config.InSampleDays) * day
+	outOfSample := time.Duration(config.OutOfSampleDays) * day
+	for t := startTime; !t.Add(inSample + outOfSample).After(endTime); t = t.Add(time.Duration(step) * day) {
+		inSamples = append(inSamples, Window{StartTime: t, EndTime: t.Add(inSample)})
+		outOfSamples = append(outOfSamples, Window{StartTime: t.Add(inSample), EndTime: t.Add(inSample + outOfSample)})
+	}
+
+	if len(inSamples) == 0 {
+		return nil, nil, fmt.Errorf("the back-test time range %s ~ %s is shorter than one walk-forward fold", startTime, endTime)
+	}
+
+	return inSamples, outOfSamples, nil
+}
+
+// patchBacktestWindow replaces the back-test start time and end time of the config json
+func patchBacktestWindow(configJson []byte, window Window) ([]byte, error) {
+	jsonOp := []byte(reformatJson(fmt.Sprintf(`[{"op": "add", "path": "/backtest/startTime", "value": "%s"}, {"op": "add", "path": "/backtest/endTime", "value": "%s"}]`,
+		window.StartTime.Format(time.RFC3339),
+		window.EndTime.Format(time.RFC3339))))
+
+	patch, err := jsonpatch.DecodePatch(jsonOp)
+	if err != nil {
+		return nil, err
+	}
+
+	return patch.ApplyIndent(configJson, "  ")
+}
+
+// backtestTimeRange reads the back-test time range from the config json
+func backtestTimeRange(configJson []byte) (startTime, endTime time.Time, err error) {
+	var config struct {
+		Backtest *struct {
+			StartTime types.LooseFormatTime  `json:"startTime"`
+			EndTime   *types.LooseFormatTime `json:"endTime"`
+		} `json:"backtest"`
+	}
+
+	if err := json.Unmarshal(configJson, &config); err != nil {
+		return startTime, endTime, err
+	}
+
+	if config.Backtest == nil {
+		return startTime, endTime, fmt.Errorf("backtest config is not defined")
+	}
+
+	startTime = config.Backtest.StartTime.Time()
+	endTime = time.Now()
+	if config.Backtest.EndTime != nil {
+		endTime = config.Backtest.EndTime.Time()
+	}
+
+	return startTime, endTime, nil
+}
+
+func (o *WalkForwardOptimizer) Run(executor Executor, configJson []byte) (*WalkForwardReport, error) {
+	if o.Config.WalkForward == nil {
+		return nil, fmt.Errorf("walkForward config is not defined")
+	}
+
+	metricValueFunc, err := o.Config.MetricValueFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	startTime, endTime, err := backtestTimeRange(configJson)
+	if err != nil {
+		return nil, err
+	}
+
+	inSamples, outOfSamples, err := SplitWalkForwardWindows(startTime, endTime, o.Config.WalkForward)
+	if err != nil {
+		return nil, err
+	}
+
+	topN := o.Config.WalkForward.TopN
+	if topN <= 0 {
+		topN = 5
+	}
+
+	report := &WalkForwardReport{}
+	var topMetrics [][]Metric
+	for i := range inSamples {
+		log.Infof("walk-forward fold #%d: in-sample %s ~ %s, out-of-sample %s ~ %s", i,
+			inSamples[i].StartTime, inSamples[i].EndTime,
+			outOfSamples[i].StartTime, outOfSamples[i].EndTime)
+
+		inSampleJson, err := patchBacktestWindow(configJson, inSamples[i])
+		if err != nil {
+			return nil, err
+		}
+
+		metrics, err := o.Optimizer.Run(executor, inSampleJson)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(metrics) == 0 {
+			return nil, fmt.Errorf("walk-forward fold #%d: no in-sample trial was completed", i)
+		}
+
+		best := metrics[0]
+		if len(metrics) > topN {
+			topMetrics = append(topMetrics, metrics[:topN])
+		} else {
+			topMetrics = append(topMetrics, metrics)
+		}
+
+		fold := WalkForwardFold{
+			InSample:        inSamples[i],
+			OutOfSample:     outOfSamples[i],
+			Labels:          best.Labels,
+			Params:          best.Params,
+			InSampleValue:   best.Value,
+			InSampleMetrics: best.Metrics,
+		}
+
+		fold.OutOfSampleValue, fold.OutOfSampleMetrics, err = o.evaluate(executor, metricValueFunc, configJson, outOfSamples[i], best.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Infof("walk-forward fold #%d params: %+v => in-sample %v, out-of-sample %v", i, fold.Params, fold.InSampleValue, fold.OutOfSampleValue)
+		report.Folds = append(report.Folds, fold)
+	}
+
+	report.StableParams = findStableParams(topMetrics, o.Config.Matrix, o.Config.WalkForward.StableParamBuckets)
+	return report, nil
+}
+
+// evaluate runs the back-test of the parameters on the window
+func (o *WalkForwardOptimizer) evaluate(executor Executor, metricValueFunc MetricValueFunc, configJson []byte, window Window, params []interface{}) (fixedpoint.Value, *backtest.PerformanceMetrics, error) {
+	windowJson, err := o.renderParams(configJson, window, params)
+	if err != nil {
+		return fixedpoint.Zero, nil, err
+	}
+
+	summaryReport, err := executor.Execute(windowJson)
+	if err != nil {
+		return fixedpoint.Zero, nil, err
+	}
+
+	return metricValueFunc(summaryReport), summaryReport.Metrics, nil
+}
+
+// renderParams patches the config json with the back-test window and the parameters of the config matrix
+func (o *WalkForwardOptimizer) renderParams(configJson []byte, window Window, params []interface{}) ([]byte, error) {
+	patchedJson, err := patchBacktestWindow(configJson, window)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, selector := range o.Config.Matrix {
+		patchedJson, err = patchConfig(patchedJson, selector, params[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return patchedJson, nil
+}
+
+// stableParamsKey returns the key of the parameters for comparing the parameters of the folds, the continuous range
+// parameters are replaced by their bucket since the sampled values rarely repeat
+func stableParamsKey(params []interface{}, matrix []SelectorConfig, numOfBuckets int) string {
+	parts := make([]interface{}, len(params))
+	for i, p := range params {
+		parts[i] = p
+		if i >= len(matrix) {
+			continue
+		}
+
+		selector := matrix[i]
+		value, ok := p.(fixedpoint.Value)
+		if !ok || selector.Type != "range" || selector.Step.Sign() > 0 || selector.Max.Compare(selector.Min) <= 0 {
+			continue
+		}
+
+		bucket := int(value.Sub(selector.Min).Div(selector.Max.Sub(selector.Min)).Float64() * float64(numOfBuckets))
+		if bucket >= numOfBuckets {
+			bucket = numOfBuckets - 1
+		}
+
+		parts[i] = fmt.Sprintf("bucket#%d", bucket)
+	}
+
+	return paramsKey(parts)
+}
+
+// findStableParams returns the parameter sets that are in the top metrics of at least half of the folds
+// (and at least two folds), ranked by the number of the folds and the average in-sample value
+func findStableParams(topMetrics [][]Metric, matrix []SelectorConfig, numOfBuckets int) []StableParams {
+	if numOfBuckets <= 0 {
+		numOfBuckets = 10
+	}
+
+	threshold := int(math.Max(2, math.Ceil(float64(len(topMetrics))/2)))
+
+	var keys []string
+	var sets = map[string]*StableParams{}
+	var sums = map[string]fixedpoint.Value{}
+	var bestValues = map[string]fixedpoint.Value{}
+	for _, metrics := range topMetrics {
+		// the metrics are ranked, the best metric of the bucket in the fold goes first
+		var seen = map[string]struct{}{}
+		for _, m := range metrics {
+			key := stableParamsKey(m.Params, matrix, numOfBuckets)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			s, ok := sets[key]
+			if !ok {
+				s = &StableParams{Labels: m.Labels, Params: m.Params}
+				sets[key] = s
+				bestValues[key] = m.Value
+				keys = append(keys, key)
+			} else if m.Value.Compare(bestValues[key]) > 0 {
+				s.Params = m.Params
+				bestValues[key] = m.Value
+			}
+
+			s.NumOfFolds++
+			sums[key] = sums[key].Add(m.Value)
+		}
+	}
+
+	var stable []StableParams
+	for _, key := range keys {
+		s := sets[key]
+		if s.NumOfFolds < threshold {
+			continue
+		}
+
+		s.AverageInSampleValue = sums[key].Div(fixedpoint.NewFromInt(int64(s.NumOfFolds)))
+		stable = append(stable, *s)
+	}
+
+	sort.SliceStable(stable, func(i, j int) bool {
+		if stable[i].NumOfFolds != stable[j].NumOfFolds {
+			return stable[i].NumOfFolds > stable[j].NumOfFolds
+		}
+		return stable[i].AverageInSampleValue.Compare(stable[j].AverageInSampleValue) > 0
+	})
+
+	return stable
+}
@@ -0,0 +1,140 @@
+package optimizer
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/backtest"
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// windowExecutor values the param x by the distance to the target of the back-test start time
+type windowExecutor struct {
+	mu      sync.Mutex
+	targets map[time.Time]float64
+	windows []time.Time
+}
+
+func (e *windowExecutor) Execute(configJson []byte) (*backtest.SummaryReport, error) {
+	var config struct {
+		X        float64 `json:"x"`
+		Backtest struct {
+			StartTime types.LooseFormatTime `json:"startTime"`
+		} `json:"backtest"`
+	}
+
+	if err := json.Unmarshal(configJson, &config); err != nil {
+		return nil, err
+	}
+
+	startTime := config.Backtest.StartTime.Time().UTC()
+
+	e.mu.Lock()
+	e.windows = append(e.windows, startTime)
+	e.mu.Unlock()
+
+	value := -(config.X - e.targets[startTime]) * (config.X - e.targets[startTime])
+	return &backtest.SummaryReport{TotalProfit: fixedpoint.NewFromFloat(value)}, nil
+}
+
+func TestSplitWalkForwardWindows(t *testing.T) {
+	startTime := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	endTime := startTime.Add(100 * day)
+
+	inSamples, outOfSamples, err := SplitWalkForwardWindows(startTime, endTime, &WalkForwardConfig{InSampleDays: 30, OutOfSampleDays: 20})
+	if assert.NoError(t, err) && assert.Len(t, inSamples, 3) {
+		assert.Equal(t, startTime, inSamples[0].StartTime)
+		assert.Equal(t, startTime.Add(30*day), outOfSamples[0].StartTime)
+		assert.Equal(t, startTime.Add(20*day), inSamples[1].StartTime)
+		assert.Equal(t, startTime.Add(90*day), outOfSamples[2].EndTime)
+	}
+
+	_, _, err = SplitWalkForwardWindows(startTime, endTime, &WalkForwardConfig{InSampleDays: 90, OutOfSampleDays: 20})
+	assert.Error(t, err)
+}
+
+func TestWalkForwardOptimizer(t *testing.T) {
+	startTime := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// the best x of the windows: fold 1 in-sample 2, out-of-sample 4; fold 2 in-sample 4, out-of-sample 5
+	executor := &windowExecutor{
+		targets: map[time.Time]float64{
+			startTime:               2,
+			startTime.Add(10 * day): 4,
+			startTime.Add(20 * day): 5,
+		},
+	}
+
+	config := &Config{
+		WalkForward: &WalkForwardConfig{InSampleDays: 10, OutOfSampleDays: 10, TopN: 3},
+		Matrix: []SelectorConfig{
+			{Type: "range", Label: "x", Path: "/x", Min: fixedpoint.Zero, Max: fixedpoint.NewFromInt(10), Step: fixedpoint.One},
+		},
+	}
+
+	wfo := &WalkForwardOptimizer{
+		Config:    config,
+		Optimizer: &GridOptimizer{Config: config},
+	}
+
+	report, err := wfo.Run(executor, []byte(`{"x": 0, "backtest": {"startTime": "2022-01-01", "endTime": "2022-01-31"}}`))
+	if !assert.NoError(t, err) || !assert.Len(t, report.Folds, 2) {
+		return
+	}
+
+	// 11 in-sample trials of every fold, the best in-sample trial is not executed again
+	assert.Len(t, executor.windows, 2*11+2)
+
+	fold := report.Folds[0]
+	assert.Equal(t, []interface{}{fixedpoint.NewFromInt(2)}, fold.Params)
+	assert.Equal(t, "0", fold.InSampleValue.String())
+	assert.Equal(t, "-4", fold.OutOfSampleValue.String())
+
+	fold = report.Folds[1]
+	assert.Equal(t, []interface{}{fixedpoint.NewFromInt(4)}, fold.Params)
+	assert.Equal(t, "-1", fold.OutOfSampleValue.String())
+
+	// x = 3 is in the top 3 of both folds
+	if assert.Len(t, report.StableParams, 1) {
+		assert.Equal(t, 2, report.StableParams[0].NumOfFolds)
+		assert.Equal(t, []interface{}{fixedpoint.NewFromInt(3)}, report.StableParams[0].Params)
+		assert.Equal(t, "-1", report.StableParams[0].AverageInSampleValue.String())
+	}
+}
+
+func TestFindStableParams_ContinuousParams(t *testing.T) {
+	matrix := []SelectorConfig{
+		{Type: "range", Label: "x", Path: "/x", Min: fixedpoint.Zero, Max: fixedpoint.NewFromInt(10)},
+		{Type: "iterate", Label: "side", Path: "/side", Values: []string{"buy", "sell"}},
+	}
+
+	newMetric := func(x float64, side string, value float64) Metric {
+		return Metric{
+			Labels: []string{"x", "side"},
+			Params: []interface{}{fixedpoint.NewFromFloat(x), side},
+			Value:  fixedpoint.NewFromFloat(value),
+		}
+	}
+
+	topMetrics := [][]Metric{
+		{newMetric(2.01, "buy", 3), newMetric(2.05, "buy", 2), newMetric(7.5, "buy", 1)},
+		{newMetric(2.93, "buy", 5), newMetric(2.5, "sell", 4)},
+		{newMetric(8.1, "buy", 1)},
+	}
+
+	// x = 2.01, 2.05 and 2.93 are in the same bucket [2, 3), the bucket is counted once per fold
+	stable := findStableParams(topMetrics, matrix, 10)
+	if assert.Len(t, stable, 1) {
+		assert.Equal(t, 2, stable[0].NumOfFolds)
+		assert.Equal(t, []interface{}{fixedpoint.NewFromFloat(2.93), "buy"}, stable[0].Params)
+		assert.Equal(t, "4", stable[0].AverageInSampleValue.String())
+	}
+
+	// without the selectors, the values are compared exactly
+	assert.Len(t, findStableParams(topMetrics, nil, 10), 0)
+}
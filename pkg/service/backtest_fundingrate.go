@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// fundingRateRecord is the row of the funding_rates table,
+// the funding time is scanned by types.Time since the sqlite driver returns the datetime as a string
+type fundingRateRecord struct {
+	Exchange    types.ExchangeName `db:"exchange"`
+	Symbol      string             `db:"symbol"`
+	Rate        fixedpoint.Value   `db:"funding_rate"`
+	FundingTime types.Time         `db:"funding_time"`
+}
+
+func newFundingRateRecord(rate types.FundingRate) fundingRateRecord {
+	return fundingRateRecord{
+		Exchange:    rate.Exchange,
+		Symbol:      rate.Symbol,
+		Rate:        rate.FundingRate,
+		FundingTime: types.Time(rate.FundingTime),
+	}
+}
+
+func (r fundingRateRecord) FundingRate() types.FundingRate {
+	return types.FundingRate{
+		Exchange:    r.Exchange,
+		Symbol:      r.Symbol,
+		FundingRate: r.Rate,
+		FundingTime: r.FundingTime.Time(),
+	}
+}
+
+// SyncFundingRates syncs the funding rates of the perpetual futures symbol into the funding_rates table
+func (s *BacktestService) SyncFundingRates(ctx context.Context, exchange types.Exchange, symbol string, startTime, endTime time.Time) error {
+	service, ok := exchange.(types.ExchangeFundingRateService)
+	if !ok {
+		return fmt.Errorf("exchange %s does not support querying funding rates", exchange.Name())
+	}
+
+	lastRate, err := s.QueryLastFundingRate(exchange.Name(), symbol)
+	if err != nil {
+		return err
+	}
+
+	if lastRate != nil && lastRate.FundingTime.After(startTime) {
+		log.Infof("found last funding rate at %s, syncing from it", lastRate.FundingTime)
+		startTime = lastRate.FundingTime.Add(time.Millisecond)
+	}
+
+	count := 0
+	for startTime.Before(endTime) {
+		rates, err := service.QueryFundingRates(ctx, symbol, startTime, endTime)
+		if err != nil {
+			return err
+		}
+
+		if len(rates) == 0 {
+			break
+		}
+
+		if err := s.BatchInsertFundingRates(rates); err != nil {
+			return err
+		}
+
+		count += len(rates)
+		startTime = rates[len(rates)-1].FundingTime.Add(time.Millisecond)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	log.Infof("synced %s funding rates from exchange %s, count: %d", symbol, exchange.Name(), count)
+	return nil
+}
+
+// QueryLastFundingRate queries the last funding rate of the symbol from the database
+func (s *BacktestService) QueryLastFundingRate(ex types.ExchangeName, symbol string) (*types.FundingRate, error) {
+	sql := "SELECT `exchange`, `symbol`, `funding_rate`, `funding_time` " +
+		"FROM `funding_rates` WHERE `exchange` = :exchange AND `symbol` = :symbol ORDER BY `funding_time` DESC LIMIT 1"
+
+	rows, err := s.DB.NamedQuery(sql, map[string]interface{}{
+		"exchange": ex.String(),
+		"symbol":   symbol,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "query funding rate error")
+	}
+
+	defer rows.Close()
+
+	if rows.Next() {
+		var record fundingRateRecord
+		if err := rows.StructScan(&record); err != nil {
+			return nil, err
+		}
+
+		rate := record.FundingRate()
+		return &rate, nil
+	}
+
+	return nil, rows.Err()
+}
+
+func (s *BacktestService) BatchInsertFundingRates(rates []types.FundingRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	var records = make([]fundingRateRecord, 0, len(rates))
+	for _, rate := range rates {
+		records = append(records, newFundingRateRecord(rate))
+	}
+
+	sql := "INSERT INTO `funding_rates` (`exchange`, `symbol`, `funding_rate`, `funding_time`)" +
+		" VALUES (:exchange, :symbol, :funding_rate, :funding_time)"
+
+	_, err := s.DB.NamedExec(sql, records)
+	return err
+}
+
+// QueryFundingRates queries the funding rates of the symbol in the time range, sorted by the funding time
+func (s *BacktestService) QueryFundingRates(ex types.ExchangeName, symbol string, since, until time.Time) ([]types.FundingRate, error) {
+	sql := "SELECT `exchange`, `symbol`, `funding_rate`, `funding_time` " +
+		"FROM `funding_rates` WHERE `exchange` = :exchange AND `symbol` = :symbol AND `funding_time` BETWEEN :since AND :until ORDER BY `funding_time` ASC"
+
+	rows, err := s.DB.NamedQuery(sql, map[string]interface{}{
+		"exchange": ex.String(),
+		"symbol":   symbol,
+		"since":    since,
+		"until":    until,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "query funding rates error")
+	}
+
+	defer rows.Close()
+
+	var rates []types.FundingRate
+	for rows.Next() {
+		var record fundingRateRecord
+		if err := rows.StructScan(&record); err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, record.FundingRate())
+	}
+
+	return rates, rows.Err()
+}
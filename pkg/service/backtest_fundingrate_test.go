@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func TestBacktestService_FundingRates(t *testing.T) {
+	db, err := prepareDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	xdb := sqlx.NewDb(db.DB, "sqlite3")
+	service := &BacktestService{DB: xdb}
+
+	now := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	var rates []types.FundingRate
+	for i := 0; i < 3; i++ {
+		rates = append(rates, types.FundingRate{
+			Exchange:    types.ExchangeBinance,
+			Symbol:      "BTCUSDT",
+			FundingRate: fixedpoint.NewFromInt(int64(i + 1)).Div(fixedpoint.NewFromInt(10000)),
+			FundingTime: now.Add(time.Duration(8*i) * time.Hour),
+		})
+	}
+
+	err = service.BatchInsertFundingRates(rates)
+	assert.NoError(t, err)
+
+	lastRate, err := service.QueryLastFundingRate(types.ExchangeBinance, "BTCUSDT")
+	assert.NoError(t, err)
+	if assert.NotNil(t, lastRate) {
+		assert.InDelta(t, 0.0003, lastRate.FundingRate.Float64(), 1e-6)
+	}
+
+	loaded, err := service.QueryFundingRates(types.ExchangeBinance, "BTCUSDT", now.Add(time.Hour), now.Add(24*time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, loaded, 2) {
+		assert.InDelta(t, 0.0002, loaded[0].FundingRate.Float64(), 1e-6)
+		assert.True(t, loaded[0].FundingTime.Equal(now.Add(8*time.Hour)))
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+// SyncMarketTrades syncs the public trades (ticks) of the symbol into the market_trades table.
+// The trades before the first stored trade are backfilled if the start time is earlier than the stored trades,
+// and then the trades after the last stored trade are synced.
+func (s *BacktestService) SyncMarketTrades(ctx context.Context, exchange types.Exchange, symbol string, startTime, endTime time.Time) error {
+	service, ok := exchange.(types.ExchangeMarketTradeService)
+	if !ok {
+		return fmt.Errorf("exchange %s does not support querying market trades", exchange.Name())
+	}
+
+	firstTrade, err := s.QueryFirstMarketTrade(exchange.Name(), symbol)
+	if err != nil {
+		return err
+	}
+
+	lastTrade, err := s.QueryLastMarketTrade(exchange.Name(), symbol)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	if firstTrade != nil && firstTrade.Time.Time().After(startTime) {
+		log.Infof("found first market trade %d at %s, backfilling from %s", firstTrade.ID, firstTrade.Time, startTime)
+
+		n, err := s.syncMarketTrades(ctx, service, symbol, &types.TradeQueryOptions{StartTime: &startTime, Limit: 1000}, endTime, firstTrade.ID)
+		count += n
+		if err != nil {
+			return err
+		}
+	}
+
+	options := &types.TradeQueryOptions{StartTime: &startTime, Limit: 1000}
+	if lastTrade != nil && lastTrade.Time.Time().After(startTime) {
+		log.Infof("found last market trade %d at %s, syncing from it", lastTrade.ID, lastTrade.Time)
+		options.LastTradeID = lastTrade.ID + 1
+	}
+
+	n, err := s.syncMarketTrades(ctx, service, symbol, options, endTime, 0)
+	count += n
+	if err != nil {
+		return err
+	}
+
+	log.Infof("synced %s market trades from exchange %s, count: %d", symbol, exchange.Name(), count)
+	return nil
+}
+
+// syncMarketTrades queries the market trades from the query options and inserts them until the end time,
+// or until the trade of the stop id if the stop id is not zero, it returns the number of the inserted trades
+func (s *BacktestService) syncMarketTrades(ctx context.Context, service types.ExchangeMarketTradeService, symbol string, options *types.TradeQueryOptions, endTime time.Time, stopID uint64) (int, error) {
+	count := 0
+	for {
+		trades, err := service.QueryMarketTrades(ctx, symbol, options)
+		if err != nil {
+			return count, err
+		}
+
+		if len(trades) == 0 {
+			// no trades in this time range, move to the next time range
+			if options.LastTradeID == 0 && options.StartTime.Add(time.Hour).Before(endTime) {
+				nextStartTime := options.StartTime.Add(time.Hour)
+				options.StartTime = &nextStartTime
+				continue
+			}
+			break
+		}
+
+		var inRange []types.Trade
+		for _, trade := range trades {
+			if trade.Time.Time().After(endTime) || (stopID > 0 && trade.ID >= stopID) {
+				break
+			}
+			inRange = append(inRange, trade)
+		}
+
+		if err := s.BatchInsertMarketTrades(inRange); err != nil {
+			return count, err
+		}
+
+		count += len(inRange)
+		if len(inRange) < len(trades) {
+			break
+		}
+
+		options.LastTradeID = trades[len(trades)-1].ID + 1
+
+		select {
+		case <-ctx.Done():
+			return count, ctx.Err()
+		default:
+		}
+	}
+
+	return count, nil
+}
+
+// QueryFirstMarketTrade queries the first market trade of the symbol from the database
+func (s *BacktestService) QueryFirstMarketTrade(ex types.ExchangeName, symbol string) (*types.Trade, error) {
+	return s.queryMarketTrade(ex, symbol, "ASC")
+}
+
+// QueryLastMarketTrade queries the last market trade of the symbol from the database
+func (s *BacktestService) QueryLastMarketTrade(ex types.ExchangeName, symbol string) (*types.Trade, error) {
+	return s.queryMarketTrade(ex, symbol, "DESC")
+}
+
+func (s *BacktestService) queryMarketTrade(ex types.ExchangeName, symbol string, orderBy string) (*types.Trade, error) {
+	sql := "SELECT `exchange`, `symbol`, `id`, `price`, `quantity`, `quote_quantity`, `side`, `is_buyer`, `is_maker`, `traded_at` " +
+		"FROM `market_trades` WHERE `exchange` = :exchange AND `symbol` = :symbol ORDER BY `id` " + orderBy + " LIMIT 1"
+
+	rows, err := s.DB.NamedQuery(sql, map[string]interface{}{
+		"exchange": ex.String(),
+		"symbol":   symbol,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "query market trade error")
+	}
+
+	defer rows.Close()
+
+	if rows.Next() {
+		var trade types.Trade
+		err = rows.StructScan(&trade)
+		return &trade, err
+	}
+
+	return nil, rows.Err()
+}
+
+func (s *BacktestService) BatchInsertMarketTrades(trades []types.Trade) error {
+	if len(trades) == 0 {
+		return nil
+	}
+
+	sql := "INSERT INTO `market_trades` (`exchange`, `symbol`, `id`, `price`, `quantity`, `quote_quantity`, `side`, `is_buyer`, `is_maker`, `traded_at`)" +
+		" VALUES (:exchange, :symbol, :id, :price, :quantity, :quote_quantity, :side, :is_buyer, :is_maker, :traded_at)"
+
+	_, err := s.DB.NamedExec(sql, trades)
+	return err
+}
+
+// QueryMarketTradesCh queries the market trades of the symbols in the time range, sorted by the trade time
+func (s *BacktestService) QueryMarketTradesCh(since, until time.Time, exchange types.ExchangeName, symbols []string) (chan types.Trade, chan error) {
+	ch := make(chan types.Trade, 1000)
+	errC := make(chan error, 1)
+
+	sql := "SELECT `exchange`, `symbol`, `id`, `price`, `quantity`, `quote_quantity`, `side`, `is_buyer`, `is_maker`, `traded_at` " +
+		"FROM `market_trades` WHERE `traded_at` BETWEEN :since AND :until AND `symbol` IN (:symbols) AND `exchange` = :exchange ORDER BY `traded_at` ASC, `id` ASC"
+
+	sql, args, err := sqlx.Named(sql, map[string]interface{}{
+		"since":    since,
+		"until":    until,
+		"symbols":  symbols,
+		"exchange": exchange.String(),
+	})
+	if err == nil {
+		sql, args, err = sqlx.In(sql, args...)
+	}
+
+	if err != nil {
+		close(ch)
+		errC <- err
+		close(errC)
+		return ch, errC
+	}
+
+	rows, err := s.DB.Queryx(s.DB.Rebind(sql), args...)
+	if err != nil {
+		close(ch)
+		errC <- err
+		close(errC)
+		return ch, errC
+	}
+
+	go func() {
+		defer close(errC)
+		defer close(ch)
+		defer rows.Close()
+
+		for rows.Next() {
+			var trade types.Trade
+			if err := rows.StructScan(&trade); err != nil {
+				errC <- err
+				return
+			}
+
+			ch <- trade
+		}
+
+		if err := rows.Err(); err != nil {
+			errC <- err
+		}
+	}()
+
+	return ch, errC
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+func TestBacktestService_MarketTrades(t *testing.T) {
+	db, err := prepareDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	xdb := sqlx.NewDb(db.DB, "sqlite3")
+	service := &BacktestService{DB: xdb}
+
+	now := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	var trades []types.Trade
+	for i := 0; i < 5; i++ {
+		trades = append(trades, types.Trade{
+			ID:            uint64(100 + i),
+			Exchange:      types.ExchangeBinance,
+			Symbol:        "BTCUSDT",
+			Price:         fixedpoint.NewFromInt(30000 + int64(i)),
+			Quantity:      fixedpoint.NewFromFloat(0.1),
+			QuoteQuantity: fixedpoint.NewFromFloat(3000.0),
+			Side:          types.SideTypeBuy,
+			IsBuyer:       true,
+			Time:          types.Time(now.Add(time.Duration(i) * time.Second)),
+		})
+	}
+
+	err = service.BatchInsertMarketTrades(trades)
+	assert.NoError(t, err)
+
+	lastTrade, err := service.QueryLastMarketTrade(types.ExchangeBinance, "BTCUSDT")
+	assert.NoError(t, err)
+	if assert.NotNil(t, lastTrade) {
+		assert.Equal(t, uint64(104), lastTrade.ID)
+	}
+
+	tradeC, errC := service.QueryMarketTradesCh(now.Add(time.Second), now.Add(3*time.Second), types.ExchangeBinance, []string{"BTCUSDT"})
+	var loaded []types.Trade
+	for trade := range tradeC {
+		loaded = append(loaded, trade)
+	}
+	assert.NoError(t, <-errC)
+	if assert.Len(t, loaded, 3) {
+		assert.Equal(t, uint64(101), loaded[0].ID)
+		assert.Equal(t, "30001", loaded[0].Price.String())
+		assert.Equal(t, types.SideTypeBuy, loaded[0].Side)
+	}
+}
+
+type marketTradeTestExchange struct {
+	types.Exchange
+
+	trades []types.Trade
+}
+
+func (e *marketTradeTestExchange) Name() types.ExchangeName {
+	return types.ExchangeBinance
+}
+
+func (e *marketTradeTestExchange) QueryMarketTrades(ctx context.Context, symbol string, options *types.TradeQueryOptions) (trades []types.Trade, err error) {
+	for _, trade := range e.trades {
+		if options.LastTradeID > 0 && trade.ID < options.LastTradeID {
+			continue
+		}
+
+		if options.LastTradeID == 0 && trade.Time.Time().Before(*options.StartTime) {
+			continue
+		}
+
+		// the exchange returns at most 20 trades per request
+		if len(trades) == 20 {
+			break
+		}
+
+		trades = append(trades, trade)
+	}
+	return trades, nil
+}
+
+func TestBacktestService_SyncMarketTrades_Backfill(t *testing.T) {
+	db, err := prepareDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	xdb := sqlx.NewDb(db.DB, "sqlite3")
+	service := &BacktestService{DB: xdb}
+
+	startTime := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	exchange := &marketTradeTestExchange{}
+	for i := 0; i < 100; i++ {
+		exchange.trades = append(exchange.trades, types.Trade{
+			ID:            uint64(1000 + i),
+			Exchange:      types.ExchangeBinance,
+			Symbol:        "BTCUSDT",
+			Price:         fixedpoint.NewFromInt(30000),
+			Quantity:      fixedpoint.NewFromFloat(0.1),
+			QuoteQuantity: fixedpoint.NewFromFloat(3000.0),
+			Side:          types.SideTypeBuy,
+			IsBuyer:       true,
+			Time:          types.Time(startTime.Add(time.Duration(i) * time.Minute)),
+		})
+	}
+
+	endTime := startTime.Add(99 * time.Minute)
+
+	// the first run syncs the later half
+	err = service.SyncMarketTrades(context.Background(), exchange, "BTCUSDT", startTime.Add(50*time.Minute), endTime)
+	assert.NoError(t, err)
+
+	firstTrade, err := service.QueryFirstMarketTrade(types.ExchangeBinance, "BTCUSDT")
+	assert.NoError(t, err)
+	if assert.NotNil(t, firstTrade) {
+		assert.Equal(t, uint64(1050), firstTrade.ID)
+	}
+
+	// the second run asks for an earlier start time, the gap before the first stored trade is backfilled
+	err = service.SyncMarketTrades(context.Background(), exchange, "BTCUSDT", startTime, endTime)
+	assert.NoError(t, err)
+
+	tradeC, errC := service.QueryMarketTradesCh(startTime, endTime, types.ExchangeBinance, []string{"BTCUSDT"})
+	var loaded []types.Trade
+	for trade := range tradeC {
+		loaded = append(loaded, trade)
+	}
+	assert.NoError(t, <-errC)
+	if assert.Len(t, loaded, 100) {
+		for i, trade := range loaded {
+			assert.Equal(t, uint64(1000+i), trade.ID)
+		}
+	}
+}
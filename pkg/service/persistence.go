@@ -1,5 +1,7 @@
 package service
 
+import "context"
+
 type PersistenceService interface {
 	NewStore(id string, subIDs ...string) Store
 }
@@ -10,6 +12,15 @@ type Store interface {
 	Reset() error
 }
 
+// TransactionalPersistenceService is the persistence service that can save multiple stores atomically
+type TransactionalPersistenceService interface {
+	PersistenceService
+
+	// Transaction calls fn with the persistence service of the transaction,
+	// the saves of the stores are committed only when fn returns nil.
+	Transaction(ctx context.Context, fn func(ps PersistenceService) error) error
+}
+
 type RedisPersistenceConfig struct {
 	Host     string `yaml:"host" json:"host" env:"REDIS_HOST"`
 	Port     string `yaml:"port" json:"port" env:"REDIS_PORT"`
@@ -20,3 +31,13 @@ type RedisPersistenceConfig struct {
 type JsonPersistenceConfig struct {
 	Directory string `yaml:"directory" json:"directory"`
 }
+
+type DatabasePersistenceConfig struct {
+	// Driver and DSN are the database of the persistence, the database configured
+	// by the environment variables (DB_DRIVER and DB_DSN) is used if they are empty.
+	Driver string `yaml:"driver,omitempty" json:"driver,omitempty"`
+	DSN    string `yaml:"dsn,omitempty" json:"dsn,omitempty"`
+
+	// MaxVersions is the max number of the versions kept for each store, the default is 100
+	MaxVersions int `yaml:"maxVersions,omitempty" json:"maxVersions,omitempty"`
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const defaultPersistenceMaxVersions = 100
+
+// PersistenceRecord is a version of the persistence store
+type PersistenceRecord struct {
+	GID       int64      `json:"gid" db:"gid"`
+	StoreID   string     `json:"storeID" db:"store_id"`
+	Version   int64      `json:"version" db:"version"`
+	Data      string     `json:"data" db:"data"`
+	CreatedAt types.Time `json:"createdAt" db:"created_at"`
+}
+
+// DatabasePersistenceService stores the values as the versioned rows of the persistence table.
+// A save inserts a new version when the value is changed, so that the prior values are kept as the history.
+type DatabasePersistenceService struct {
+	DB *sqlx.DB
+
+	// MaxVersions is the max number of the versions kept for each store, the older versions are deleted
+	MaxVersions int
+}
+
+func NewDatabasePersistenceService(db *sqlx.DB, maxVersions int) *DatabasePersistenceService {
+	if maxVersions <= 0 {
+		maxVersions = defaultPersistenceMaxVersions
+	}
+
+	return &DatabasePersistenceService{
+		DB:          db,
+		MaxVersions: maxVersions,
+	}
+}
+
+func (s *DatabasePersistenceService) NewStore(id string, subIDs ...string) Store {
+	return &DatabaseStore{
+		ID:          joinStoreID(id, subIDs...),
+		db:          s.DB,
+		maxVersions: s.MaxVersions,
+	}
+}
+
+// Transaction calls fn with the persistence service of a database transaction,
+// the stores created by the given persistence service are saved atomically.
+func (s *DatabasePersistenceService) Transaction(ctx context.Context, fn func(ps PersistenceService) error) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&txPersistenceService{tx: tx, maxVersions: s.MaxVersions}); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Wrapf(err, "rollback error: %v", rollbackErr)
+		}
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// QueryLatest returns the latest versions of the stores that have the store ID prefix
+func (s *DatabasePersistenceService) QueryLatest(ctx context.Context, prefix string) ([]PersistenceRecord, error) {
+	var records []PersistenceRecord
+	err := s.DB.SelectContext(ctx, &records, "SELECT p.* FROM `persistence` p "+
+		"INNER JOIN (SELECT `store_id`, MAX(`version`) AS `version` FROM `persistence` WHERE `store_id` LIKE ? ESCAPE '!' GROUP BY `store_id`) l "+
+		"ON p.`store_id` = l.`store_id` AND p.`version` = l.`version` ORDER BY p.`store_id`", likePrefix(prefix))
+	return records, err
+}
+
+// QueryHistory returns the versions of the store from the latest one
+func (s *DatabasePersistenceService) QueryHistory(ctx context.Context, storeID string, limit int) ([]PersistenceRecord, error) {
+	if limit <= 0 {
+		limit = s.MaxVersions
+	}
+
+	var records []PersistenceRecord
+	err := s.DB.SelectContext(ctx, &records, "SELECT * FROM `persistence` WHERE `store_id` = ? ORDER BY `version` DESC LIMIT ?", storeID, limit)
+	return records, err
+}
+
+// QueryVersion returns the given version of the store, the latest version is returned if version is zero
+func (s *DatabasePersistenceService) QueryVersion(ctx context.Context, storeID string, version int64) (*PersistenceRecord, error) {
+	return queryPersistenceVersion(ctx, s.DB, storeID, version)
+}
+
+// Reset deletes the stores that have the store ID prefix with their history, and returns the number of the deleted rows
+func (s *DatabasePersistenceService) Reset(ctx context.Context, prefix string) (int64, error) {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM `persistence` WHERE `store_id` LIKE ? ESCAPE '!'", likePrefix(prefix))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+type txPersistenceService struct {
+	tx          *sqlx.Tx
+	maxVersions int
+}
+
+func (s *txPersistenceService) NewStore(id string, subIDs ...string) Store {
+	return &DatabaseStore{
+		ID:          joinStoreID(id, subIDs...),
+		tx:          s.tx,
+		maxVersions: s.maxVersions,
+	}
+}
+
+type DatabaseStore struct {
+	ID string
+
+	db          *sqlx.DB
+	tx          *sqlx.Tx
+	maxVersions int
+}
+
+func (store *DatabaseStore) Load(val interface{}) error {
+	var ext sqlx.QueryerContext = store.db
+	if store.tx != nil {
+		ext = store.tx
+	}
+
+	record, err := queryPersistenceVersion(context.Background(), ext, store.ID, 0)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPersistenceNotExists
+		}
+
+		return err
+	}
+
+	return json.Unmarshal([]byte(record.Data), val)
+}
+
+func (store *DatabaseStore) Save(val interface{}) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	return store.withTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+		var version int64
+		latest, err := queryPersistenceVersion(ctx, tx, store.ID, 0)
+		switch err {
+		case nil:
+			// the value is not changed, no need to create a new version
+			if latest.Data == string(data) {
+				return nil
+			}
+			version = latest.Version
+
+		case sql.ErrNoRows:
+
+		default:
+			return err
+		}
+
+		version++
+		if _, err := tx.ExecContext(ctx, "INSERT INTO `persistence` (`store_id`, `version`, `data`, `created_at`) VALUES (?, ?, ?, ?)",
+			store.ID, version, string(data), types.Time(time.Now())); err != nil {
+			return err
+		}
+
+		if store.maxVersions > 0 && version > int64(store.maxVersions) {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM `persistence` WHERE `store_id` = ? AND `version` <= ?", store.ID, version-int64(store.maxVersions)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Reset deletes the store with its history
+func (store *DatabaseStore) Reset() error {
+	return store.withTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM `persistence` WHERE `store_id` = ?", store.ID)
+		return err
+	})
+}
+
+// withTx calls fn with the transaction of the store, or a new transaction if the store is not in a transaction
+func (store *DatabaseStore) withTx(ctx context.Context, fn func(ctx context.Context, tx *sqlx.Tx) error) error {
+	if store.tx != nil {
+		return fn(ctx, store.tx)
+	}
+
+	tx, err := store.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(ctx, tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Wrapf(err, "rollback error: %v", rollbackErr)
+		}
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func queryPersistenceVersion(ctx context.Context, ext sqlx.QueryerContext, storeID string, version int64) (*PersistenceRecord, error) {
+	var record PersistenceRecord
+	var err error
+	if version > 0 {
+		err = sqlx.GetContext(ctx, ext, &record, "SELECT * FROM `persistence` WHERE `store_id` = ? AND `version` = ?", storeID, version)
+	} else {
+		err = sqlx.GetContext(ctx, ext, &record, "SELECT * FROM `persistence` WHERE `store_id` = ? ORDER BY `version` DESC LIMIT 1", storeID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// joinStoreID joins the store id with the sub ids, the same as the redis key
+func joinStoreID(id string, subIDs ...string) string {
+	if len(subIDs) > 0 {
+		id += ":" + strings.Join(subIDs, ":")
+	}
+
+	return id
+}
+
+// likePrefix escapes the prefix for the LIKE pattern with the escape character "!"
+func likePrefix(prefix string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix) + "%"
+}
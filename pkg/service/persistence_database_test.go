@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabasePersistenceService(t *testing.T) {
+	db, err := prepareDB(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err := db.Close()
+		assert.NoError(t, err)
+	}()
+
+	xdb := sqlx.NewDb(db.DB, "sqlite3")
+	service := NewDatabasePersistenceService(xdb, 3)
+	ctx := context.Background()
+
+	store := service.NewStore("state", "grid_BTCUSDT", "position")
+
+	var i int64
+	assert.Equal(t, ErrPersistenceNotExists, store.Load(&i))
+
+	for _, v := range []int64{1, 2, 2, 3, 4} {
+		assert.NoError(t, store.Save(v))
+	}
+
+	assert.NoError(t, store.Load(&i))
+	assert.Equal(t, int64(4), i)
+
+	// the unchanged value is not saved, and only the latest 3 versions are kept
+	history, err := service.QueryHistory(ctx, "state:grid_BTCUSDT:position", 0)
+	if assert.NoError(t, err) && assert.Len(t, history, 3) {
+		assert.Equal(t, int64(4), history[0].Version)
+		assert.Equal(t, "4", history[0].Data)
+		assert.Equal(t, "2", history[2].Data)
+	}
+
+	record, err := service.QueryVersion(ctx, "state:grid_BTCUSDT:position", 3)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "3", record.Data)
+	}
+
+	t.Run("transaction", func(t *testing.T) {
+		err := service.Transaction(ctx, func(ps PersistenceService) error {
+			assert.NoError(t, ps.NewStore("state", "grid_BTCUSDT", "position").Save(int64(5)))
+			assert.NoError(t, ps.NewStore("state", "grid_BTCUSDT", "profit").Save("10"))
+			return errors.New("rollback")
+		})
+		assert.Error(t, err)
+
+		assert.NoError(t, store.Load(&i))
+		assert.Equal(t, int64(4), i)
+
+		err = service.Transaction(ctx, func(ps PersistenceService) error {
+			if err := ps.NewStore("state", "grid_BTCUSDT", "position").Save(int64(5)); err != nil {
+				return err
+			}
+			return ps.NewStore("state", "grid_BTCUSDT", "profit").Save("10")
+		})
+		assert.NoError(t, err)
+
+		records, err := service.QueryLatest(ctx, "state:grid_BTCUSDT:")
+		if assert.NoError(t, err) && assert.Len(t, records, 2) {
+			assert.Equal(t, "state:grid_BTCUSDT:position", records[0].StoreID)
+			assert.Equal(t, "5", records[0].Data)
+			assert.Equal(t, "state:grid_BTCUSDT:profit", records[1].StoreID)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		assert.NoError(t, service.NewStore("state", "gridXBTCUSDT", "position").Save(int64(1)))
+
+		// the underscore of the prefix is not a wildcard
+		n, err := service.Reset(ctx, "state:grid_BTCUSDT:")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), n)
+
+		assert.Equal(t, ErrPersistenceNotExists, store.Load(&i))
+
+		records, err := service.QueryLatest(ctx, "")
+		if assert.NoError(t, err) && assert.Len(t, records, 1) {
+			assert.Equal(t, "state:gridXBTCUSDT:position", records[0].StoreID)
+		}
+
+		assert.NoError(t, service.NewStore("state", "gridXBTCUSDT", "position").Reset())
+		records, err = service.QueryLatest(ctx, "")
+		assert.NoError(t, err)
+		assert.Empty(t, records)
+	})
+}
@@ -1,14 +1,20 @@
 package service
 
 type PersistenceServiceFacade struct {
-	Redis  *RedisPersistenceService
-	Json   *JsonPersistenceService
-	Memory *MemoryService
+	Database *DatabasePersistenceService
+	Redis    *RedisPersistenceService
+	Json     *JsonPersistenceService
+	Memory   *MemoryService
 }
 
 // Get returns the preferred persistence service by fallbacks
-// Redis will be preferred at the first position.
+// Database will be preferred at the first position since it saves the values atomically,
+// and then Redis.
 func (facade *PersistenceServiceFacade) Get() PersistenceService {
+	if facade.Database != nil {
+		return facade.Database
+	}
+
 	if facade.Redis != nil {
 		return facade.Redis
 	}
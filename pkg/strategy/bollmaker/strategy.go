@@ -56,6 +56,7 @@ type Strategy struct {
 
 	Environment          *bbgo.Environment
 	StandardIndicatorSet *bbgo.StandardIndicatorSet
+	Reconciliation       *bbgo.ReconciliationService `json:"-"`
 	Market               types.Market
 
 	// Symbol is the market symbol you want to trade
@@ -604,6 +605,9 @@ func (s *Strategy) Run(ctx context.Context, orderExecutor bbgo.OrderExecutor, se
 	s.orderStore.BindStream(session.UserDataStream)
 
 	s.tradeCollector = bbgo.NewTradeCollector(s.Symbol, s.Position, s.orderStore)
+	if s.Reconciliation != nil {
+		s.Reconciliation.AddTradeCollector(session, s.tradeCollector)
+	}
 
 	s.tradeCollector.OnTrade(func(trade types.Trade, profit, netProfit fixedpoint.Value) {
 		// StrategyController
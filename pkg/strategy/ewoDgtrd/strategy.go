@@ -120,7 +120,7 @@ func (inc *CCISTOCH) Update(cloze float64) {
 
 func (inc *CCISTOCH) BuySignal() bool {
 	hasGrey := false
-	for i := 0; i < len(inc.ma.Values); i++ {
+	for i := 0; i < inc.ma.Length(); i++ {
 		v := inc.ma.Index(i)
 		if v > 80 {
 			return false
@@ -138,7 +138,7 @@ func (inc *CCISTOCH) BuySignal() bool {
 
 func (inc *CCISTOCH) SellSignal() bool {
 	hasGrey := false
-	for i := 0; i < len(inc.ma.Values); i++ {
+	for i := 0; i < inc.ma.Length(); i++ {
 		v := inc.ma.Index(i)
 		if v < 20 {
 			return false
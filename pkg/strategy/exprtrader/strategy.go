@@ -0,0 +1,254 @@
+package exprtrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/c9s/bbgo/pkg/bbgo"
+	"github.com/c9s/bbgo/pkg/expr"
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+)
+
+const ID = "exprtrader"
+
+var log = logrus.WithField("strategy", ID)
+
+func init() {
+	bbgo.RegisterStrategy(ID, &Strategy{})
+}
+
+// Strategy opens a position when the entry condition is true and closes it when the exit condition is true.
+// The conditions are the expressions of the expr package, they are evaluated on every closed kline of Interval.
+type Strategy struct {
+	*bbgo.Notifiability
+	*bbgo.Persistence
+
+	Environment *bbgo.Environment
+	Market      types.Market
+
+	Symbol   string           `json:"symbol"`
+	Interval types.Interval   `json:"interval"`
+	Quantity fixedpoint.Value `json:"quantity"`
+
+	// Side is the side of the entry order, buy opens a long position and sell opens a short position.
+	// The default side is buy.
+	Side types.SideType `json:"side,omitempty"`
+
+	// Entry is the condition to open the position, for example:
+	//   crossover(ewma(close, 9), ewma(close, 21)) and rsi(14) < 70
+	Entry *expr.Expr `json:"entry"`
+
+	// Exit is the condition to close the position
+	Exit *expr.Expr `json:"exit"`
+
+	// persistence fields
+	Position    *types.Position    `json:"position,omitempty" persistence:"position"`
+	ProfitStats *types.ProfitStats `json:"profitStats,omitempty" persistence:"profit_stats"`
+
+	session        *bbgo.ExchangeSession
+	orderExecutor  bbgo.OrderExecutor
+	orderStore     *bbgo.OrderStore
+	tradeCollector *bbgo.TradeCollector
+
+	entry types.BoolSeries
+	exit  types.BoolSeries
+
+	// StrategyController
+	bbgo.StrategyController
+}
+
+func (s *Strategy) ID() string {
+	return ID
+}
+
+func (s *Strategy) InstanceID() string {
+	return fmt.Sprintf("%s:%s:%s", ID, s.Symbol, s.Interval)
+}
+
+func (s *Strategy) Validate() error {
+	if len(s.Symbol) == 0 {
+		return errors.New("symbol is required")
+	}
+
+	if len(s.Interval) == 0 {
+		return errors.New("interval is required")
+	}
+
+	if s.Quantity.Sign() <= 0 {
+		return errors.New("quantity must be greater than zero")
+	}
+
+	if s.Side != "" && s.Side != types.SideTypeBuy && s.Side != types.SideTypeSell {
+		return fmt.Errorf("invalid side %s", s.Side)
+	}
+
+	if s.Entry == nil || s.Exit == nil {
+		return errors.New("entry and exit conditions are required")
+	}
+
+	if !s.Entry.IsBool() {
+		return fmt.Errorf("entry %q is not a condition", s.Entry)
+	}
+
+	if !s.Exit.IsBool() {
+		return fmt.Errorf("exit %q is not a condition", s.Exit)
+	}
+
+	return nil
+}
+
+func (s *Strategy) Subscribe(session *bbgo.ExchangeSession) {
+	session.Subscribe(types.KLineChannel, s.Symbol, types.SubscribeOptions{Interval: s.Interval})
+}
+
+func (s *Strategy) CurrentPosition() *types.Position {
+	return s.Position
+}
+
+func (s *Strategy) ClosePosition(ctx context.Context, percentage fixedpoint.Value) error {
+	base := s.Position.GetBase()
+	if base.IsZero() {
+		return fmt.Errorf("no opened %s position", s.Position.Symbol)
+	}
+
+	quantity := base.Mul(percentage).Abs()
+	side := types.SideTypeBuy
+	if base.Sign() > 0 {
+		side = types.SideTypeSell
+	}
+
+	if quantity.Compare(s.Market.MinQuantity) < 0 {
+		return fmt.Errorf("order quantity %v is too small, less than %v", quantity, s.Market.MinQuantity)
+	}
+
+	return s.submitOrder(ctx, side, quantity)
+}
+
+func (s *Strategy) submitOrder(ctx context.Context, side types.SideType, quantity fixedpoint.Value) error {
+	createdOrders, err := s.orderExecutor.SubmitOrders(ctx, types.SubmitOrder{
+		Symbol:   s.Symbol,
+		Side:     side,
+		Type:     types.OrderTypeMarket,
+		Quantity: quantity,
+		Market:   s.Market,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.orderStore.Add(createdOrders...)
+	s.tradeCollector.Process()
+	return nil
+}
+
+func (s *Strategy) Run(ctx context.Context, orderExecutor bbgo.OrderExecutor, session *bbgo.ExchangeSession) error {
+	// StrategyController
+	s.Status = types.StrategyStatusRunning
+
+	s.OnSuspend(func() {
+		s.Status = types.StrategyStatusStopped
+		_ = s.Persistence.Sync(s)
+	})
+
+	s.OnEmergencyStop(func() {
+		_ = s.ClosePosition(ctx, fixedpoint.One)
+	})
+
+	if s.Side == "" {
+		s.Side = types.SideTypeBuy
+	}
+
+	s.session = session
+	s.orderExecutor = orderExecutor
+
+	env, err := expr.NewEnv(session, s.Symbol, s.Interval)
+	if err != nil {
+		return err
+	}
+
+	if s.entry, err = s.Entry.BoolSeries(env); err != nil {
+		return err
+	}
+
+	if s.exit, err = s.Exit.BoolSeries(env); err != nil {
+		return err
+	}
+
+	if s.Position == nil {
+		s.Position = types.NewPositionFromMarket(s.Market)
+	}
+
+	if s.ProfitStats == nil {
+		s.ProfitStats = types.NewProfitStats(s.Market)
+	}
+
+	instanceID := s.InstanceID()
+
+	// Always update the position fields
+	s.Position.Strategy = ID
+	s.Position.StrategyInstanceID = instanceID
+
+	s.orderStore = bbgo.NewOrderStore(s.Symbol)
+	s.orderStore.BindStream(session.UserDataStream)
+
+	s.tradeCollector = bbgo.NewTradeCollector(s.Symbol, s.Position, s.orderStore)
+	s.tradeCollector.OnTrade(func(trade types.Trade, profit, netProfit fixedpoint.Value) {
+		s.Notifiability.Notify(trade)
+		s.ProfitStats.AddTrade(trade)
+
+		if profit.IsZero() {
+			s.Environment.RecordPosition(s.Position, trade, nil)
+		} else {
+			log.Infof("%s generated profit: %v", s.Symbol, profit)
+			p := s.Position.NewProfit(trade, profit, netProfit)
+			p.Strategy = ID
+			p.StrategyInstanceID = instanceID
+			s.Notify(&p)
+
+			s.ProfitStats.AddProfit(p)
+			s.Notify(&s.ProfitStats)
+
+			s.Environment.RecordPosition(s.Position, trade, &p)
+		}
+	})
+
+	s.tradeCollector.OnPositionUpdate(func(position *types.Position) {
+		log.Infof("position changed: %s", s.Position)
+		s.Notify(s.Position)
+	})
+	s.tradeCollector.BindStream(session.UserDataStream)
+
+	session.MarketDataStream.OnKLineClosed(func(kline types.KLine) {
+		// StrategyController
+		if s.Status != types.StrategyStatusRunning {
+			return
+		}
+
+		if kline.Symbol != s.Symbol || kline.Interval != s.Interval {
+			return
+		}
+
+		if s.Market.IsDustQuantity(s.Position.GetBase().Abs(), kline.Close) {
+			if s.entry.Last() {
+				log.Infof("entry condition %q is true, submitting %s order", s.Entry, s.Side)
+				if err := s.submitOrder(ctx, s.Side, s.Quantity); err != nil {
+					log.WithError(err).Errorf("can not submit the entry order")
+				}
+			}
+			return
+		}
+
+		if s.exit.Last() {
+			log.Infof("exit condition %q is true, closing position", s.Exit)
+			if err := s.ClosePosition(ctx, fixedpoint.One); err != nil {
+				log.WithError(err).Errorf("can not close the position")
+			}
+		}
+	})
+
+	return nil
+}
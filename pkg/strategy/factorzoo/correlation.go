@@ -16,17 +16,14 @@ type KLineValueMapper func(k types.KLine) float64
 //go:generate callbackgen -type Correlation
 type Correlation struct {
 	types.IntervalWindow
-	Values  types.Float64Slice
+	Values  types.Float64RingBuffer
 	EndTime time.Time
 
 	UpdateCallbacks []func(value float64)
 }
 
 func (inc *Correlation) Last() float64 {
-	if len(inc.Values) == 0 {
-		return 0.0
-	}
-	return inc.Values[len(inc.Values)-1]
+	return inc.Values.Last()
 }
 
 func (inc *Correlation) calculateAndUpdate(klines []types.KLine) {
@@ -50,10 +47,6 @@ func (inc *Correlation) calculateAndUpdate(klines []types.KLine) {
 	}
 	inc.Values.Push(correlation)
 
-	if len(inc.Values) > indicator.MaxNumOfVOL {
-		inc.Values = inc.Values[indicator.MaxNumOfVOLTruncateSize-1:]
-	}
-
 	inc.EndTime = klines[end].GetEndTime().Time()
 
 	inc.EmitUpdate(correlation)
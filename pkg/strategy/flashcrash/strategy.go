@@ -96,13 +96,12 @@ func (s *Strategy) updateBidOrders(orderExecutor bbgo.OrderExecutor, session *bb
 		startPrice = startPrice.Mul(s.Percentage)
 	}
 
+	// the accepted orders are created even if some orders are rejected by the risk controls
 	orders, err := orderExecutor.SubmitOrders(context.Background(), submitOrders...)
+	s.activeOrders.Add(orders...)
 	if err != nil {
 		log.WithError(err).Error("submit bid order error")
-		return
 	}
-
-	s.activeOrders.Add(orders...)
 }
 
 func (s *Strategy) Subscribe(session *bbgo.ExchangeSession) {
@@ -53,6 +53,9 @@ type Strategy struct {
 
 	*bbgo.Persistence
 
+	// Reconciliation is injected when the position reconciliation is enabled in the risk controls
+	Reconciliation *bbgo.ReconciliationService `json:"-" yaml:"-"`
+
 	// OrderExecutor is an interface for submitting order.
 	// This field will be injected automatically since it's a single exchange strategy.
 	bbgo.OrderExecutor `json:"-" yaml:"-"`
@@ -604,6 +607,9 @@ func (s *Strategy) Run(ctx context.Context, orderExecutor bbgo.OrderExecutor, se
 	s.activeOrders.BindStream(session.UserDataStream)
 
 	s.tradeCollector = bbgo.NewTradeCollector(s.Symbol, s.state.Position, s.orderStore)
+	if s.Reconciliation != nil {
+		s.Reconciliation.AddTradeCollector(session, s.tradeCollector)
+	}
 
 	s.tradeCollector.OnTrade(func(trade types.Trade, profit, netProfit fixedpoint.Value) {
 		s.Notifiability.Notify(trade)
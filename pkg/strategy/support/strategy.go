@@ -299,15 +299,12 @@ func (s *Strategy) submitOrders(ctx context.Context, orderExecutor bbgo.OrderExe
 		s.Notifiability.Notify(o)
 	}
 
+	// the accepted orders are created even if some orders are rejected by the risk controls
 	createdOrders, err := orderExecutor.SubmitOrders(ctx, orderForms...)
-	if err != nil {
-		return nil, err
-	}
-
 	s.orderStore.Add(createdOrders...)
 	s.activeOrders.Add(createdOrders...)
 	s.tradeCollector.Emit()
-	return createdOrders, nil
+	return createdOrders, err
 }
 
 // Cancel order
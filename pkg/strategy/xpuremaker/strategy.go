@@ -137,10 +137,9 @@ func (s *Strategy) updateOrders(orderExecutor bbgo.OrderExecutor, session *bbgo.
 	createdOrders, err := orderExecutor.SubmitOrders(context.Background(), orders...)
 	if err != nil {
 		log.WithError(err).Errorf("order submit error")
-		return
 	}
 
-	// add created orders to the list
+	// add created orders to the list, the accepted orders are created even if some orders are rejected by the risk controls
 	for i, o := range createdOrders {
 		s.activeOrders[o.ClientOrderID] = createdOrders[i]
 	}
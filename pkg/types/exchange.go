@@ -111,6 +111,16 @@ type ExchangeMarketDataService interface {
 	QueryKLines(ctx context.Context, symbol string, interval Interval, options KLineQueryOptions) ([]KLine, error)
 }
 
+// ExchangeMarketTradeService provides the public trade history of the market, it's used for syncing the tick data for back-testing
+type ExchangeMarketTradeService interface {
+	QueryMarketTrades(ctx context.Context, symbol string, options *TradeQueryOptions) ([]Trade, error)
+}
+
+// ExchangeFundingRateService provides the funding rate history of the perpetual futures, it's used for syncing the funding rates for back-testing
+type ExchangeFundingRateService interface {
+	QueryFundingRates(ctx context.Context, symbol string, startTime, endTime time.Time) ([]FundingRate, error)
+}
+
 type CustomIntervalProvider interface {
 	SupportedInterval() map[Interval]int
 	IsSupportedInterval(interval Interval) bool
@@ -0,0 +1,17 @@
+package types
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrOCOOrderNotSupported is returned by SubmitOCOOrder when the OCO order is not supported by the account or the
+// order types, the caller can fall back to the emulated OCO.
+var ErrOCOOrderNotSupported = errors.New("oco order is not supported")
+
+// ExchangeOCOOrderService is implemented by the exchanges that support the native OCO (one-cancels-the-other) orders.
+// The exchange cancels the other order when one of the orders is filled.
+type ExchangeOCOOrderService interface {
+	// SubmitOCOOrder submits the take-profit limit order and the stop-loss stop order as an OCO order
+	SubmitOCOOrder(ctx context.Context, takeProfit, stopLoss SubmitOrder) (createdOrders OrderSlice, err error)
+}
@@ -0,0 +1,100 @@
+package types
+
+// DefaultFloat64RingBufferCapacity is the capacity of the zero value Float64RingBuffer,
+// it should be aligned with bbgo MaxNumOfKLines
+const DefaultFloat64RingBufferCapacity = 5_000
+
+// Float64RingBuffer is a fixed-capacity series of float64 values, the oldest value is overwritten
+// when a new value is pushed to the full buffer.
+// The zero value is an empty buffer of DefaultFloat64RingBufferCapacity.
+type Float64RingBuffer struct {
+	capacity int
+
+	// values grows up to the capacity, and then it's used circularly
+	values []float64
+
+	// head is the position of the oldest value when the buffer is full
+	head int
+}
+
+func NewFloat64RingBuffer(capacity int) *Float64RingBuffer {
+	if capacity <= 0 {
+		panic("ring buffer capacity must be greater than 0")
+	}
+
+	return &Float64RingBuffer{capacity: capacity}
+}
+
+// Cap returns the max number of the values that the buffer keeps
+func (r *Float64RingBuffer) Cap() int {
+	if r.capacity == 0 {
+		return DefaultFloat64RingBufferCapacity
+	}
+	return r.capacity
+}
+
+func (r *Float64RingBuffer) Push(v float64) {
+	if len(r.values) < r.Cap() {
+		r.values = append(r.values, v)
+		return
+	}
+
+	r.values[r.head] = v
+	r.head = (r.head + 1) % len(r.values)
+}
+
+// Update replaces the latest value, or pushes the value if the buffer is empty
+func (r *Float64RingBuffer) Update(v float64) {
+	if len(r.values) == 0 {
+		r.Push(v)
+		return
+	}
+
+	r.values[r.pos(0)] = v
+}
+
+// Reset removes all the values
+func (r *Float64RingBuffer) Reset() {
+	r.values = r.values[:0]
+	r.head = 0
+}
+
+func (r *Float64RingBuffer) pos(i int) int {
+	return (r.head + len(r.values) - 1 - i) % len(r.values)
+}
+
+func (r *Float64RingBuffer) Last() float64 {
+	return r.Index(0)
+}
+
+// Index returns the value of the i-th latest value, Index(0) is the latest value
+func (r *Float64RingBuffer) Index(i int) float64 {
+	if i < 0 || i >= len(r.values) {
+		return 0.0
+	}
+	return r.values[r.pos(i)]
+}
+
+func (r *Float64RingBuffer) Length() int {
+	return len(r.values)
+}
+
+// Tail returns the copy of the latest size values, from the oldest to the latest
+func (r *Float64RingBuffer) Tail(size int) Float64Slice {
+	if size > len(r.values) {
+		size = len(r.values)
+	}
+
+	var win = make(Float64Slice, size)
+	for i := 0; i < size; i++ {
+		win[size-1-i] = r.Index(i)
+	}
+	return win
+}
+
+// Slice returns the copy of all the values, from the oldest to the latest
+func (r *Float64RingBuffer) Slice() Float64Slice {
+	return r.Tail(len(r.values))
+}
+
+var _ Series = &Float64RingBuffer{}
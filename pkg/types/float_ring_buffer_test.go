@@ -0,0 +1,53 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFloat64RingBuffer(t *testing.T) {
+	r := NewFloat64RingBuffer(3)
+	assert.Equal(t, 0, r.Length())
+	assert.Equal(t, 0.0, r.Last())
+
+	r.Push(1)
+	r.Push(2)
+	assert.Equal(t, 2, r.Length())
+	assert.Equal(t, 2.0, r.Last())
+	assert.Equal(t, 1.0, r.Index(1))
+	assert.Equal(t, 0.0, r.Index(2))
+
+	r.Push(3)
+	r.Push(4)
+	r.Push(5)
+	assert.Equal(t, 3, r.Length())
+	assert.Equal(t, 5.0, r.Index(0))
+	assert.Equal(t, 4.0, r.Index(1))
+	assert.Equal(t, 3.0, r.Index(2))
+	assert.Equal(t, 0.0, r.Index(3))
+	assert.Equal(t, 0.0, r.Index(-1))
+	assert.Equal(t, Float64Slice{4, 5}, r.Tail(2))
+	assert.Equal(t, Float64Slice{3, 4, 5}, r.Slice())
+
+	r.Update(6)
+	assert.Equal(t, Float64Slice{3, 4, 6}, r.Tail(10))
+
+	r.Reset()
+	assert.Equal(t, 0, r.Length())
+	r.Update(7)
+	assert.Equal(t, 7.0, r.Last())
+}
+
+func TestFloat64RingBuffer_ZeroValue(t *testing.T) {
+	var r Float64RingBuffer
+	assert.Equal(t, DefaultFloat64RingBufferCapacity, r.Cap())
+
+	for i := 0; i < DefaultFloat64RingBufferCapacity+10; i++ {
+		r.Push(float64(i))
+	}
+
+	assert.Equal(t, DefaultFloat64RingBufferCapacity, r.Length())
+	assert.Equal(t, float64(DefaultFloat64RingBufferCapacity+9), r.Last())
+	assert.Equal(t, 10.0, r.Index(r.Length()-1))
+}
@@ -7,6 +7,8 @@ import (
 )
 
 type FundingRate struct {
+	Exchange    ExchangeName
+	Symbol      string
 	FundingRate fixedpoint.Value
 	FundingTime time.Time
 	Time        time.Time
@@ -13,6 +13,10 @@ func (i Interval) Minutes() int {
 }
 
 func (i Interval) Duration() time.Duration {
+	if seconds, ok := SecondIntervals[i]; ok {
+		return time.Duration(seconds) * time.Second
+	}
+
 	return time.Duration(i.Minutes()) * time.Minute
 }
 
@@ -66,6 +70,18 @@ var SupportedIntervals = map[Interval]int{
 	Interval3d:  60 * 24 * 3,
 }
 
+var Interval1s = Interval("1s")
+var Interval15s = Interval("15s")
+var Interval30s = Interval("30s")
+
+// SecondIntervals are the intervals shorter than 1m, exchanges don't provide klines of these intervals,
+// they are built from the market trades in the back-test.
+var SecondIntervals = map[Interval]int{
+	Interval1s:  1,
+	Interval15s: 15,
+	Interval30s: 30,
+}
+
 // IntervalWindow is used by the indicators
 type IntervalWindow struct {
 	// The interval of kline
@@ -24,6 +24,21 @@ func (d *Duration) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	return d.parse(o)
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(a interface{}) error) error {
+	var o interface{}
+
+	if err := unmarshal(&o); err != nil {
+		return err
+	}
+
+	return d.parse(o)
+}
+
+// parse parses the duration string like "1m30s", the numbers are in seconds
+func (d *Duration) parse(o interface{}) error {
 	switch t := o.(type) {
 	case string:
 		dd, err := time.ParseDuration(t)
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 
 	"github.com/c9s/bbgo/pkg/fixedpoint"
 )
@@ -78,6 +79,19 @@ func TestDurationParse(t *testing.T) {
 	}
 }
 
+func TestDurationParse_YAML(t *testing.T) {
+	var a struct {
+		Interval Duration `yaml:"interval"`
+		Timeout  Duration `yaml:"timeout"`
+	}
+
+	err := yaml.Unmarshal([]byte("interval: 2m3s\ntimeout: 5\n"), &a)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Duration(2*time.Minute+3*time.Second), a.Interval)
+		assert.Equal(t, Duration(5*time.Second), a.Timeout)
+	}
+}
+
 func Test_formatPrice(t *testing.T) {
 	type args struct {
 		price    fixedpoint.Value
@@ -17,3 +17,13 @@ func NewValidLimiter(r rate.Limit, b int) (*rate.Limiter, error) {
 	}
 	return rate.NewLimiter(r, b), nil
 }
+
+// NewTokenBucket creates a token bucket limiter that refills numOfTokens tokens every interval,
+// the bucket can hold at most burst tokens.
+func NewTokenBucket(numOfTokens float64, interval time.Duration, burst int) (*rate.Limiter, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("Bad rate limit config. Invalid interval %s", interval)
+	}
+
+	return NewValidLimiter(rate.Limit(numOfTokens/interval.Seconds()), burst)
+}
@@ -41,3 +41,22 @@ func TestShouldDelay(t *testing.T) {
 		assert.True(t, ShouldDelay(limiter, minInterval) > 0)
 	}
 }
+
+func TestNewTokenBucket(t *testing.T) {
+	limiter, err := NewTokenBucket(10, time.Second, 5)
+	if assert.NoError(t, err) {
+		assert.Equal(t, rate.Limit(10), limiter.Limit())
+		assert.Equal(t, 5, limiter.Burst())
+	}
+
+	limiter, err = NewTokenBucket(1, time.Minute, 1)
+	if assert.NoError(t, err) {
+		assert.InDelta(t, 1.0/60, float64(limiter.Limit()), 1e-9)
+	}
+
+	_, err = NewTokenBucket(1, 0, 1)
+	assert.Error(t, err)
+
+	_, err = NewTokenBucket(0, time.Second, 1)
+	assert.Error(t, err)
+}